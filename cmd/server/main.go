@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"dothefortune_server/internal/config"
 	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/repository"
 	"dothefortune_server/internal/router"
 	"dothefortune_server/internal/utils"
 )
@@ -30,11 +37,38 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	r := router.SetupRouter(cfg)
+	if err := repository.NewFortuneRepository().BackfillChartFeatures(); err != nil {
+		log.Printf("Failed to backfill chart features: %v", err)
+	}
+
+	r, background := router.SetupRouter(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	background.Start(ctx)
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
 	}
-}
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
 
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+
+	// 진행 중인 요청과 작업을 마무리할 시간을 준다. 작업이 끝나기 전에 프로세스가 죽어도 잠금이 풀린 뒤 다른 워커가 다시 실행한다
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shut down: %v", err)
+	}
+	if err := background.Stop(shutdownCtx); err != nil {
+		log.Printf("Background jobs did not stop in time: %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +20,80 @@ type Config struct {
 	DBSSLMode       string
 	JWTSecret       string
 	GeminiAPIKey    string
+
+	// LLM 백엔드 (gemini, openai, template)와 생성 파라미터
+	LLMProvider    string
+	LLMModel       string
+	LLMBaseURL     string
+	LLMAPIKey      string
+	LLMTemperature float64
+	LLMMaxTokens   int
+	AIFortuneMode  string // structured(한 번에 JSON) 또는 per_category
+
+	// 궁합 카테고리별 분석 작성 방식: template(고정 문구) 또는 ai(두 사주에 맞춰 AI가 작성)
+	AICompatibilityMode string
+
+	// 생성 문장이 안전/품질 검사를 통과하지 못했을 때 다시 생성하는 횟수
+	AIFilterRetries int
+
+	// LLM 호출 보호 (호출 제한 시간, 429/5xx 재시도 횟수, 동시 호출 수, 회로 차단 기준)
+	LLMTimeoutSeconds         int
+	LLMStreamTimeoutSeconds   int
+	LLMMaxRetries             int
+	LLMMaxConcurrency         int
+	LLMBreakerThreshold       int
+	LLMBreakerCooldownSeconds int
+
+	// 오늘의 운세 날짜를 나누는 시간대와 하루 재생성 허용 횟수
+	FortuneTimezone             string
+	DailyFortuneRegenerateLimit int
+
+	// AI 상담: 하루 질문 수, 대화 기록을 프롬프트에 넣는 최대 토큰 수 (넘으면 앞부분을 요약한다)
+	ChatDailyMessageLimit int
+	ChatContextTokens     int
+
+	// 프롬프트 템플릿 디렉터리와 기본 로케일
+	PromptsDir   string
+	PromptLocale string
+
+	// 관리자 API를 쓸 수 있는 계정 이메일 (쉼표로 구분)
+	AdminEmails []string
+
+	// 배우자 이미지 생성 백엔드 (openai, fake)와 호출 제한 시간
+	ImageProvider       string
+	ImageModel          string
+	ImageBaseURL        string
+	ImageAPIKey         string
+	ImageTimeoutSeconds int
+
+	// 생성한 파일 저장소. local이면 StorageDir에 저장하고 StoragePublicURL 경로로 서빙한다
+	StorageBackend   string
+	StorageDir       string
+	StoragePublicURL string
+
+	// 백그라운드 작업: 이 프로세스의 워커 수 (0이면 워커를 띄우지 않는다), 최대 시도 횟수, 폴링 간격, 작업 제한 시간, 첫 재시도 대기 시간
+	WorkerConcurrency      int
+	JobMaxAttempts         int
+	JobPollIntervalSeconds int
+	JobTimeoutSeconds      int
+	JobBackoffSeconds      int
+
+	// 오늘의 운세 예약 생성: 최근 며칠 안에 운세를 본 사용자 대상 (0이면 끈다), 자정 후 시작 시각(분)과 시작 마감 시각(시), 배치 크기, 동시 생성 수
+	PregenerateActiveDays  int
+	PregenerateStartMinute int
+	PregenerateEndHour     int
+	PregenerateBatchSize   int
+	PregenerateConcurrency int
+
+	// AI 호출 한도: 성공한 백엔드 호출 수 기준, 사용자별·전체의 하루(운세 시간대 자정 초기화)와 한 달 한도 (0이면 제한 없음)
+	AIQuotaUserDaily     int
+	AIQuotaUserMonthly   int
+	AIQuotaGlobalDaily   int
+	AIQuotaGlobalMonthly int
+
+	// 로그인 세션: access 토큰 유효 시간(분), refresh 토큰을 쓰지 않아도 세션이 유지되는 기간(일)
+	AccessTokenMinutes int
+	RefreshTokenDays   int
 }
 
 func Load() *Config {
@@ -36,6 +112,64 @@ func Load() *Config {
 		DBSSLMode:       getEnv("DB_SSLMODE", "disable"),
 		JWTSecret:       getEnv("JWT_SECRET", "default_secret_key_change_in_production"),
 		GeminiAPIKey:    getEnv("GEMINI_API_KEY", ""),
+		LLMProvider:     getEnv("LLM_PROVIDER", "gemini"),
+		LLMModel:        getEnv("LLM_MODEL", ""),
+		LLMBaseURL:      getEnv("LLM_BASE_URL", ""),
+		LLMAPIKey:       getEnv("LLM_API_KEY", ""),
+		LLMTemperature:  getEnvFloat("LLM_TEMPERATURE", 0.8),
+		LLMMaxTokens:    getEnvInt("LLM_MAX_TOKENS", 512),
+		AIFortuneMode:   getEnv("AI_FORTUNE_MODE", "structured"),
+
+		AICompatibilityMode: getEnv("AI_COMPATIBILITY_MODE", "template"),
+		AIFilterRetries:     getEnvInt("AI_FILTER_RETRIES", 1),
+
+		LLMTimeoutSeconds:         getEnvInt("LLM_TIMEOUT_SECONDS", 20),
+		LLMStreamTimeoutSeconds:   getEnvInt("LLM_STREAM_TIMEOUT_SECONDS", 60),
+		LLMMaxRetries:             getEnvInt("LLM_MAX_RETRIES", 2),
+		LLMMaxConcurrency:         getEnvInt("LLM_MAX_CONCURRENCY", 8),
+		LLMBreakerThreshold:       getEnvInt("LLM_BREAKER_THRESHOLD", 5),
+		LLMBreakerCooldownSeconds: getEnvInt("LLM_BREAKER_COOLDOWN_SECONDS", 30),
+
+		FortuneTimezone:             getEnv("FORTUNE_TIMEZONE", "Asia/Seoul"),
+		DailyFortuneRegenerateLimit: getEnvInt("DAILY_FORTUNE_REGENERATE_LIMIT", 3),
+
+		ChatDailyMessageLimit: getEnvInt("CHAT_DAILY_MESSAGE_LIMIT", 30),
+		ChatContextTokens:     getEnvInt("CHAT_CONTEXT_TOKENS", 3000),
+
+		PromptsDir:   getEnv("PROMPTS_DIR", "prompts"),
+		PromptLocale: getEnv("PROMPT_LOCALE", "ko"),
+
+		AdminEmails: getEnvList("ADMIN_EMAILS"),
+
+		ImageProvider:       getEnv("IMAGE_PROVIDER", "fake"),
+		ImageModel:          getEnv("IMAGE_MODEL", ""),
+		ImageBaseURL:        getEnv("IMAGE_BASE_URL", ""),
+		ImageAPIKey:         getEnv("IMAGE_API_KEY", ""),
+		ImageTimeoutSeconds: getEnvInt("IMAGE_TIMEOUT_SECONDS", 120),
+
+		StorageBackend:   getEnv("STORAGE_BACKEND", "local"),
+		StorageDir:       getEnv("STORAGE_DIR", "storage"),
+		StoragePublicURL: getEnv("STORAGE_PUBLIC_URL", "/media"),
+
+		WorkerConcurrency:      getEnvInt("WORKER_CONCURRENCY", 4),
+		JobMaxAttempts:         getEnvInt("JOB_MAX_ATTEMPTS", 5),
+		JobPollIntervalSeconds: getEnvInt("JOB_POLL_INTERVAL_SECONDS", 1),
+		JobTimeoutSeconds:      getEnvInt("JOB_TIMEOUT_SECONDS", 300),
+		JobBackoffSeconds:      getEnvInt("JOB_BACKOFF_SECONDS", 10),
+
+		PregenerateActiveDays:  getEnvInt("PREGENERATE_ACTIVE_DAYS", 7),
+		PregenerateStartMinute: getEnvInt("PREGENERATE_START_MINUTE", 5),
+		PregenerateEndHour:     getEnvInt("PREGENERATE_END_HOUR", 6),
+		PregenerateBatchSize:   getEnvInt("PREGENERATE_BATCH_SIZE", 100),
+		PregenerateConcurrency: getEnvInt("PREGENERATE_CONCURRENCY", 2),
+
+		AIQuotaUserDaily:     getEnvInt("AI_QUOTA_USER_DAILY", 100),
+		AIQuotaUserMonthly:   getEnvInt("AI_QUOTA_USER_MONTHLY", 1500),
+		AIQuotaGlobalDaily:   getEnvInt("AI_QUOTA_GLOBAL_DAILY", 0),
+		AIQuotaGlobalMonthly: getEnvInt("AI_QUOTA_GLOBAL_MONTHLY", 0),
+
+		AccessTokenMinutes: getEnvInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:   getEnvInt("REFRESH_TOKEN_DAYS", 30),
 	}
 }
 
@@ -46,3 +180,27 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
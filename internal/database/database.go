@@ -44,6 +44,17 @@ func migrate() error {
 		&models.FortuneInfo{},
 		&models.FortuneRecord{},
 		&models.Compatibility{},
+		&models.PartnerContact{},
+		&models.MatchPreference{},
+		&models.DailyFortune{},
+		&models.Conversation{},
+		&models.ChatMessage{},
+		&models.SpouseImageJob{},
+		&models.Job{},
+		&models.PregenerationRun{},
+		&models.AIUsage{},
+		&models.Session{},
+		&models.MatchScoreLookup{},
 	)
 }
 
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/service"
+)
+
+type AdminHandler struct {
+	promptStore     service.PromptStore
+	jobAdminService service.JobAdminService
+	pregenerator    service.DailyPregenerator
+	aiUsageService  service.AIUsageService
+}
+
+func NewAdminHandler(promptStore service.PromptStore, jobAdminService service.JobAdminService, pregenerator service.DailyPregenerator, aiUsageService service.AIUsageService) *AdminHandler {
+	return &AdminHandler{
+		promptStore:     promptStore,
+		jobAdminService: jobAdminService,
+		pregenerator:    pregenerator,
+		aiUsageService:  aiUsageService,
+	}
+}
+
+type PromptCatalogResponse struct {
+	Prompts []service.PromptInfo `json:"prompts" description:"용도/로케일별 프롬프트 템플릿 버전 목록"`
+}
+
+// GetPrompts godoc
+// @Summary      프롬프트 템플릿 목록 조회
+// @Description  현재 불러온 프롬프트 템플릿을 용도와 로케일별로 조회합니다. active는 지금 생성에 쓰이는 버전입니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  PromptCatalogResponse  "템플릿 목록 조회 성공"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Router       /admin/prompts [get]
+func (h *AdminHandler) GetPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, PromptCatalogResponse{Prompts: h.promptStore.Catalog()})
+}
+
+// ReloadPrompts godoc
+// @Summary      프롬프트 템플릿 다시 불러오기
+// @Description  서버를 재시작하지 않고 프롬프트 템플릿 디렉터리와 manifest.json을 다시 읽습니다. 템플릿 하나라도 읽거나 해석하지 못하면 기존 템플릿을 그대로 유지하고 오류를 반환합니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  PromptCatalogResponse  "다시 불러온 템플릿 목록"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      422  {object}  ErrorResponse  "템플릿 오류 (기존 템플릿 유지)"
+// @Router       /admin/prompts/reload [post]
+func (h *AdminHandler) ReloadPrompts(c *gin.Context) {
+	if err := h.promptStore.Reload(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, PromptCatalogResponse{Prompts: h.promptStore.Catalog()})
+}
+
+// GetJobs godoc
+// @Summary      백그라운드 작업 목록 조회
+// @Description  작업 큐의 작업을 최신순으로 조회합니다. 상태(pending, running, completed, dead)와 타입으로 거를 수 있고, 상태별 전체 작업 수를 함께 반환합니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  query  string  false  "작업 상태 (pending, running, completed, dead)"  example:"dead"
+// @Param        type    query  string  false  "작업 타입"  example:"spouse_image.generate"
+// @Param        limit   query  int     false  "반환할 최대 작업 수"  default(50)  minimum(1)  maximum(200)
+// @Param        offset  query  int     false  "건너뛸 작업 수"  default(0)
+// @Success      200  {object}  service.JobList  "작업 목록 조회 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 상태 값"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /admin/jobs [get]
+func (h *AdminHandler) GetJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	jobs, err := h.jobAdminService.ListJobs(c.Query("status"), c.Query("type"), limit, offset)
+	if err != nil {
+		if err.Error() == "invalid job status" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetJob godoc
+// @Summary      백그라운드 작업 조회
+// @Description  작업 하나의 payload, 시도 횟수, 마지막 오류를 조회합니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "작업 ID"  example:"1"
+// @Success      200  {object}  models.Job  "작업 조회 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 작업 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      404  {object}  ErrorResponse  "작업이 없음"
+// @Router       /admin/jobs/{id} [get]
+func (h *AdminHandler) GetJob(c *gin.Context) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job id"})
+		return
+	}
+
+	job, err := h.jobAdminService.GetJob(uint(jobID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob godoc
+// @Summary      백그라운드 작업 다시 실행
+// @Description  dead 작업이나 재시도를 기다리는 작업의 시도 횟수를 비우고 바로 다시 실행되게 합니다. 실행 중이거나 완료된 작업은 다시 실행할 수 없습니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "작업 ID"  example:"1"
+// @Success      200  {object}  models.Job  "다시 실행 대기열에 넣음"
+// @Failure      400  {object}  ErrorResponse  "잘못된 작업 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      404  {object}  ErrorResponse  "작업이 없음"
+// @Failure      409  {object}  ErrorResponse  "다시 실행할 수 없는 상태"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /admin/jobs/{id}/retry [post]
+func (h *AdminHandler) RetryJob(c *gin.Context) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job id"})
+		return
+	}
+
+	job, err := h.jobAdminService.RetryJob(uint(jobID))
+	if err != nil {
+		switch err.Error() {
+		case "job not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "job is not retryable":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+type PregenerationRunsResponse struct {
+	Runs []models.PregenerationRun `json:"runs" description:"최근 예약 생성 실행 (날짜 최신순)"`
+}
+
+// GetPregenerationRuns godoc
+// @Summary      오늘의 운세 예약 생성 실행 기록 조회
+// @Description  자정 이후 최근 활동 사용자의 오늘 운세를 미리 만든 실행 기록을 조회합니다. 실행마다 대상 사용자 수, 새로 생성/이미 있음/실패 수, 처리 시간, 중단 후 이어 간 횟수가 기록됩니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit  query  int  false  "반환할 최대 실행 수"  default(14)  minimum(1)  maximum(100)
+// @Success      200  {object}  PregenerationRunsResponse  "실행 기록 조회 성공"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /admin/pregeneration/runs [get]
+func (h *AdminHandler) GetPregenerationRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "14"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 14
+	}
+
+	runs, err := h.pregenerator.GetRuns(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, PregenerationRunsResponse{Runs: runs})
+}
+
+// GetAIUsage godoc
+// @Summary      AI 사용량 보고
+// @Description  AI 백엔드 호출(재시도 포함)을 운세 시간대 기준 날짜와 기능별로 묶어 호출 수, 성공/실패 수, 사용자 수, 입력/출력 토큰 수, 평균 응답 시간을 조회합니다. 기간 전체의 기능별 합계와 설정된 사용량 한도를 함께 반환합니다. 기간을 주지 않으면 오늘까지 30일이며 최대 92일까지 조회할 수 있습니다. 관리자만 사용할 수 있습니다.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from     query  string  false  "시작 날짜 (YYYY-MM-DD, 포함)"  example:"2024-01-01"
+// @Param        to       query  string  false  "끝 날짜 (YYYY-MM-DD, 포함)"  example:"2024-01-31"
+// @Param        user_id  query  int     false  "특정 사용자만 조회"  example:"1"
+// @Success      200  {object}  service.AIUsageReport  "사용량 보고 조회 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 기간 또는 사용자 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      403  {object}  ErrorResponse  "관리자 권한 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /admin/ai-usage [get]
+func (h *AdminHandler) GetAIUsage(c *gin.Context) {
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+			return
+		}
+		userID = parsed
+	}
+
+	report, err := h.aiUsageService.GetReport(c.Query("from"), c.Query("to"), uint(userID))
+	if err != nil {
+		if err.Error() == "invalid date range" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dothefortune_server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AIQuotaExceededResponse struct {
+	Error   string    `json:"error" example:"ai quota exceeded"`
+	Scope   string    `json:"scope" example:"user" description:"넘은 한도 (user: 사용자별, global: 서비스 전체)"`
+	Period  string    `json:"period" example:"daily" description:"한도 기간 (daily, monthly)"`
+	Limit   int       `json:"limit" example:"100"`
+	ResetAt time.Time `json:"reset_at" example:"2024-01-02T00:00:00+09:00" description:"한도가 초기화되는 시각"`
+}
+
+// AI 사용량 한도를 넘은 오류면 Retry-After와 초기화 시각을 담아 429로 응답하고 true를 반환한다
+func respondAIQuotaExceeded(c *gin.Context, err error) bool {
+	var quotaErr *service.AIQuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	retryAfter := math.Ceil(time.Until(quotaErr.ResetAt).Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter)))
+	c.JSON(http.StatusTooManyRequests, AIQuotaExceededResponse{
+		Error:   quotaErr.Error(),
+		Scope:   quotaErr.Scope,
+		Period:  quotaErr.Period,
+		Limit:   quotaErr.Limit,
+		ResetAt: quotaErr.ResetAt,
+	})
+	return true
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 저장된 운세 없이 오류만 돌려주는 오늘의 운세 서비스
+type failingFortunes struct {
+	service.FortuneService
+	err error
+}
+
+func (s *failingFortunes) GetTodayFortune(userID uint, locale string, regenerate bool) (*service.TodayFortuneResult, error) {
+	return nil, s.err
+}
+
+func serveTodayFortune(fortunes service.FortuneService) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fortune/today", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("locale", "ko")
+	}, NewFortuneHandler(fortunes).GetTodayFortune)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fortune/today?regenerate=true", nil))
+	return w
+}
+
+func TestAIQuotaExceededRespondsWithRetryAfter(t *testing.T) {
+	resetAt := time.Now().Add(90 * time.Minute).Truncate(time.Second)
+	quotaErr := &service.AIQuotaExceededError{Scope: service.AIQuotaScopeUser, Period: service.AIQuotaPeriodDaily, Limit: 100, ResetAt: resetAt}
+
+	tests := []struct {
+		name  string
+		serve func() *httptest.ResponseRecorder
+	}{
+		{"json", func() *httptest.ResponseRecorder {
+			return serveTodayFortune(&failingFortunes{err: quotaErr})
+		}},
+		// 스트림을 시작하기 전이면 SSE도 같은 429로 응답한다
+		{"stream", func() *httptest.ResponseRecorder {
+			return serveFortuneStream(context.Background(), &streamingFortunes{err: quotaErr})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.serve()
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+			}
+			retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			if err != nil || retryAfter < 89*60 || retryAfter > 90*60 {
+				t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+			}
+			var body AIQuotaExceededResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Error != "ai quota exceeded" || body.Scope != "user" || body.Period != "daily" || body.Limit != 100 || !body.ResetAt.Equal(resetAt) {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+}
+
+func TestAIQuotaExceededRetryAfterIsAtLeastOneSecond(t *testing.T) {
+	w := serveTodayFortune(&failingFortunes{err: &service.AIQuotaExceededError{
+		Scope: service.AIQuotaScopeGlobal, Period: service.AIQuotaPeriodMonthly, Limit: 5000, ResetAt: time.Now().Add(-time.Second),
+	}})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestTodayFortuneErrorsOtherThanQuota(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("fortune info not found"), http.StatusBadRequest},
+		{errors.New("daily regenerate limit exceeded"), http.StatusTooManyRequests},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := serveTodayFortune(&failingFortunes{err: tt.err})
+		if w.Code != tt.want || w.Header().Get("Retry-After") != "" {
+			t.Errorf("%v: status %d, Retry-After %q", tt.err, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+}
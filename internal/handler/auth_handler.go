@@ -2,12 +2,16 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"dothefortune_server/internal/service"
 )
 
+// refresh 토큰 쿠키는 갱신과 로그아웃 경로에만 보낸다
+const refreshTokenCookiePath = "/api/v1/auth"
+
 type AuthHandler struct {
 	authService service.AuthService
 }
@@ -37,9 +41,28 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required" example:"password123" swaggertype:"string"`
 }
 
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" example:"en" swaggertype:"string" description:"운세와 궁합 문장 언어 (ko, en, ja). 빈 문자열이면 설정을 지우고 Accept-Language를 따른다"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"12.Jx3v0G6mQ2bW9sQ1yZ4kR8aL5nP7tU0cE2fH6iK9oM1" swaggertype:"string" description:"로그인 때 받은 refresh 토큰 (생략하면 refresh_token 쿠키)"`
+}
+
 type AuthResponse struct {
-	Token string      `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
-	User  interface{} `json:"user"`
+	Token            string      `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." description:"access 토큰"`
+	ExpiresAt        time.Time   `json:"expires_at" example:"2024-01-01T00:15:00Z" description:"access 토큰 만료 시각"`
+	RefreshToken     string      `json:"refresh_token" example:"12.Jx3v0G6mQ2bW9sQ1yZ4kR8aL5nP7tU0cE2fH6iK9oM1" description:"access 토큰을 다시 받을 때 쓰는 토큰 (한 번 쓰면 새 토큰으로 바뀐다)"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at" example:"2024-01-31T00:00:00Z" description:"이 시각까지 갱신하지 않으면 다시 로그인해야 한다"`
+	User             interface{} `json:"user,omitempty"`
+}
+
+type SessionsResponse struct {
+	Sessions []interface{} `json:"sessions" description:"로그인된 기기 목록 (최근 사용 순, current는 요청을 보낸 기기)"`
+}
+
+type RevokedSessionsResponse struct {
+	Revoked int64 `json:"revoked" example:"2" description:"로그아웃시킨 기기 수"`
 }
 
 type ErrorResponse struct {
@@ -52,7 +75,7 @@ type MessageResponse struct {
 
 // Register godoc
 // @Summary      회원가입
-// @Description  새로운 사용자를 등록합니다. 이메일, 비밀번호(최소 6자), 이름, 성별, 생년월일(양력/음력), 태어난 시간, 태어난 도시명을 입력받아 계정과 사주 정보를 생성하고 JWT 토큰을 발급합니다. 토큰은 쿠키에도 저장됩니다.
+// @Description  새로운 사용자를 등록합니다. 이메일, 비밀번호(최소 6자), 이름, 성별, 생년월일(양력/음력), 태어난 시간, 태어난 도시명을 입력받아 계정과 사주 정보를 생성하고, 이 기기의 세션을 만들어 access 토큰과 refresh 토큰을 발급합니다. 토큰은 쿠키에도 저장됩니다.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -86,23 +109,19 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := h.authService.GenerateToken(user.ID, user.Email)
+	tokens, err := h.authService.CreateSession(user, sessionClient(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.SetCookie("token", token, int(24*time.Hour.Seconds()), "/", "", false, true)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusCreated, newAuthResponse(tokens, user))
 }
 
 // Login godoc
 // @Summary      로그인
-// @Description  이메일과 비밀번호로 로그인합니다. 인증 성공 시 JWT 토큰을 발급하며, 토큰은 응답 본문과 쿠키에 포함됩니다. 토큰은 24시간 동안 유효합니다.
+// @Description  이메일과 비밀번호로 로그인합니다. 인증 성공 시 이 기기의 세션을 만들고 access 토큰과 refresh 토큰을 발급하며, 토큰은 응답 본문과 쿠키에 포함됩니다. access 토큰은 짧게(기본 15분) 유효하므로 만료되면 POST /auth/refresh로 다시 받습니다.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -118,30 +137,83 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.authService.Login(req.Email, req.Password)
+	user, tokens, err := h.authService.Login(req.Email, req.Password, sessionClient(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if err.Error() == "invalid email or password" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, newAuthResponse(tokens, user))
+}
+
+// Refresh godoc
+// @Summary      토큰 갱신
+// @Description  refresh 토큰으로 새 access 토큰과 새 refresh 토큰을 받습니다. 쓴 refresh 토큰은 바로 무효가 되며, 이미 쓴 토큰이 다시 오면 탈취된 것으로 보고 그 기기의 세션을 끊습니다(이후 그 세션의 access 토큰도 거부됩니다). refresh 토큰은 본문이나 refresh_token 쿠키로 보냅니다.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body  RefreshRequest  false  "refresh 토큰 (생략하면 쿠키)"
+// @Success      200      {object}  AuthResponse  "갱신 성공 (user 제외)"
+// @Failure      400      {object}  ErrorResponse  "refresh 토큰 없음"
+// @Failure      401      {object}  ErrorResponse  "유효하지 않거나 만료되었거나 재사용된 refresh 토큰 (다시 로그인 필요)"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	refreshToken, ok := bindRefreshToken(c)
+	if !ok {
+		return
+	}
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 
-	c.SetCookie("token", token, int(24*time.Hour.Seconds()), "/", "", false, true)
+	tokens, err := h.authService.RefreshSession(refreshToken, sessionClient(c))
+	if err != nil {
+		switch err.Error() {
+		case "invalid refresh token", "refresh token reused", "refresh token expired":
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  user,
-	})
+	setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, newAuthResponse(tokens, nil))
 }
 
 // Logout godoc
 // @Summary      로그아웃
-// @Description  로그아웃하고 인증 쿠키를 삭제합니다. 클라이언트 측에서도 토큰을 제거해야 합니다.
+// @Description  요청에 쓴 access 토큰의 세션을 끊고 인증 쿠키를 삭제합니다. 세션이 끊기면 그 기기의 access 토큰과 refresh 토큰은 만료 전이라도 거부됩니다.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
+// @Security     BearerAuth
 // @Success      200  {object}  MessageResponse  "로그아웃 성공"
+// @Failure      401  {object}  ErrorResponse  "인증 실패 또는 이미 끊긴 세션"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
 // @Router       /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	c.SetCookie("token", "", -1, "/", "", false, true)
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.authService.Logout(userID, c.GetUint("session_id")); err != nil {
+		if err.Error() == "session not found" {
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -165,3 +237,175 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"user_id": userID})
 }
 
+
+// UpdateLocale godoc
+// @Summary      언어 설정 변경
+// @Description  오늘의 운세, 궁합 분석, 상담 답변에 쓸 언어를 저장합니다 (ko, en, ja). 설정이 없으면 Accept-Language 헤더를, 헤더도 없으면 한국어를 사용합니다. 빈 문자열을 보내면 설정을 지웁니다.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  UpdateLocaleRequest  true  "언어 설정"
+// @Success      200  {object}  models.User  "변경된 사용자 정보"
+// @Failure      400  {object}  ErrorResponse  "지원하지 않는 언어"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "사용자를 찾을 수 없음"
+// @Router       /auth/me/locale [put]
+func (h *AuthHandler) UpdateLocale(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.SetLocale(userID.(uint), req.Locale)
+	if err != nil {
+		switch err.Error() {
+		case "unsupported locale":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// GetSessions godoc
+// @Summary      로그인된 기기 목록
+// @Description  현재 사용자가 로그인해 있는 기기(세션) 목록을 최근 사용 순으로 반환합니다. 로그아웃했거나 끊었거나 만료된 세션은 포함하지 않으며, current가 true인 항목이 요청을 보낸 기기입니다.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  SessionsResponse  "세션 목록"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /auth/sessions [get]
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	sessions, err := h.authService.GetSessions(userID, c.GetUint("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession godoc
+// @Summary      기기 로그아웃
+// @Description  선택한 기기의 세션을 끊습니다. 그 기기의 access 토큰과 refresh 토큰은 바로 쓸 수 없게 됩니다. 현재 기기를 끊으면 인증 쿠키도 삭제합니다.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "세션 ID"  minimum(1)
+// @Success      200  {object}  MessageResponse  "세션 해제 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 세션 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "세션을 찾을 수 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || sessionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(userID, uint(sessionID)); err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if uint(sessionID) == c.GetUint("session_id") {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions godoc
+// @Summary      다른 기기 모두 로그아웃
+// @Description  요청을 보낸 기기를 뺀 모든 기기의 세션을 끊습니다.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  RevokedSessionsResponse  "해제한 세션 수"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /auth/sessions [delete]
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	revoked, err := h.authService.RevokeOtherSessions(userID, c.GetUint("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+func sessionClient(c *gin.Context) service.SessionClient {
+	return service.SessionClient{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+func newAuthResponse(tokens *service.TokenPair, user interface{}) gin.H {
+	response := gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.AccessExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+	}
+	if user != nil {
+		response["user"] = user
+	}
+	return response
+}
+
+func setAuthCookies(c *gin.Context, tokens *service.TokenPair) {
+	c.SetCookie("token", tokens.AccessToken, int(time.Until(tokens.AccessExpiresAt).Seconds()), "/", "", false, true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, int(time.Until(tokens.RefreshExpiresAt).Seconds()), refreshTokenCookiePath, "", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, refreshTokenCookiePath, "", false, true)
+}
+
+// 본문의 refresh_token, 없으면 refresh_token 쿠키를 읽는다. 본문이 잘못되었으면 400을 응답하고 false를 반환한다
+func bindRefreshToken(c *gin.Context) (string, bool) {
+	var req RefreshRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken, true
+	}
+	refreshToken, _ := c.Cookie("refresh_token")
+	return refreshToken, true
+}
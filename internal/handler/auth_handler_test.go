@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/middleware"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/service"
+	"dothefortune_server/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 저장소 쿼리와 같은 조건으로 세션을 다루는 메모리 저장소
+type memorySessions struct {
+	mu       sync.Mutex
+	nextID   uint
+	sessions map[uint]models.Session
+}
+
+func (r *memorySessions) Create(session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	session.ID = r.nextID
+	r.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessions) FindByID(id uint) (*models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &session, nil
+}
+
+func (r *memorySessions) FindActiveByUserID(userID uint, now time.Time) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessions) IsActive(id uint, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	return ok && session.RevokedAt == nil && session.ExpiresAt.After(now), nil
+}
+
+func (r *memorySessions) Rotate(id uint, oldHash, newHash, userAgent, ip string, now, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.RefreshTokenHash != oldHash || session.RevokedAt != nil {
+		return false, nil
+	}
+	session.PreviousRefreshTokenHash = oldHash
+	session.RefreshTokenHash = newHash
+	session.UserAgent = userAgent
+	session.IP = ip
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	r.sessions[id] = session
+	return true, nil
+}
+
+func (r *memorySessions) Revoke(id uint, reason string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &now
+		session.RevokedReason = reason
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *memorySessions) RevokeByUserID(userID, exceptID uint, reason string, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revoked int64
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != exceptID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			session.RevokedReason = reason
+			r.sessions[id] = session
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+type memoryUsers struct {
+	users []models.User
+}
+
+func (r *memoryUsers) Create(user *models.User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *memoryUsers) FindByID(id uint) (*models.User, error) {
+	for i := range r.users {
+		if r.users[i].ID == id {
+			user := r.users[i]
+			return &user, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryUsers) FindByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	for _, id := range ids {
+		if user, err := r.FindByID(id); err == nil {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUsers) FindByEmail(email string) (*models.User, error) {
+	for i := range r.users {
+		if r.users[i].Email == email {
+			user := r.users[i]
+			return &user, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryUsers) Update(user *models.User) error {
+	for i := range r.users {
+		if r.users[i].ID == user.ID {
+			r.users[i] = *user
+			return nil
+		}
+	}
+	return errors.New("record not found")
+}
+
+// router.go와 같은 인증 경로만 띄운다
+func newAuthTestServer(t *testing.T) (*gin.Engine, *memorySessions) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	utils.InitJWT("test-secret")
+
+	password, err := utils.HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUsers{}
+	users.Create(&models.User{Email: "user@example.com", Password: password})
+	sessions := &memorySessions{sessions: make(map[uint]models.Session)}
+
+	authService := service.NewAuthService(users, nil, sessions, &config.Config{AccessTokenMinutes: 15, RefreshTokenDays: 30})
+	authHandler := NewAuthHandler(authService)
+	authMiddleware := middleware.AuthMiddleware(authService.IsSessionActive)
+
+	r := gin.New()
+	auth := r.Group("/api/v1/auth")
+	auth.POST("/login", authHandler.Login)
+	auth.POST("/refresh", authHandler.Refresh)
+	auth.POST("/logout", authMiddleware, authHandler.Logout)
+	auth.GET("/me", authMiddleware, authHandler.GetMe)
+	return r, sessions
+}
+
+type authTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+}
+
+func doAuthRequest(r *gin.Engine, method, path, accessToken string, body interface{}) (int, authTokens) {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response authTokens
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func login(t *testing.T, r *gin.Engine) authTokens {
+	t.Helper()
+	code, tokens := doAuthRequest(r, http.MethodPost, "/api/v1/auth/login", "", gin.H{"email": "user@example.com", "password": "password123"})
+	if code != http.StatusOK {
+		t.Fatalf("login: status %d (%s)", code, tokens.Error)
+	}
+	return tokens
+}
+
+func TestRefreshRotatesAndRevokesOnReuse(t *testing.T) {
+	r, sessions := newAuthTestServer(t)
+	first := login(t, r)
+
+	code, second := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": first.RefreshToken})
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d (%s)", code, second.Error)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if code, _ := doAuthRequest(r, http.MethodGet, "/api/v1/auth/me", second.Token, nil); code != http.StatusOK {
+		t.Fatalf("new access token: status %d", code)
+	}
+
+	// 이미 바뀐 토큰이 다시 오면 세션이 끊겨 새 토큰들도 쓸 수 없다
+	code, replay := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": first.RefreshToken})
+	if code != http.StatusUnauthorized || replay.Error != "refresh token reused" {
+		t.Fatalf("replayed refresh: status %d (%s)", code, replay.Error)
+	}
+	if code, _ := doAuthRequest(r, http.MethodGet, "/api/v1/auth/me", second.Token, nil); code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse: status %d, want 401", code)
+	}
+	if code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": second.RefreshToken}); code != http.StatusUnauthorized {
+		t.Errorf("rotated token after reuse: status %d, want 401", code)
+	}
+	if session, _ := sessions.FindByID(1); session.RevokedReason != models.SessionRevokedReused {
+		t.Errorf("revoked reason = %q, want %q", session.RevokedReason, models.SessionRevokedReused)
+	}
+}
+
+func TestRefreshWithGuessedSecretKeepsSession(t *testing.T) {
+	r, _ := newAuthTestServer(t)
+	tokens := login(t, r)
+
+	code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": "1.guessed"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("guessed refresh: status %d, want 401", code)
+	}
+	if code, _ := doAuthRequest(r, http.MethodGet, "/api/v1/auth/me", tokens.Token, nil); code != http.StatusOK {
+		t.Errorf("access token after guessed refresh: status %d, want 200", code)
+	}
+	if code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": tokens.RefreshToken}); code != http.StatusOK {
+		t.Errorf("real refresh after guessed refresh: status %d, want 200", code)
+	}
+}
+
+func TestLogoutRevokesAccessTokenSession(t *testing.T) {
+	r, sessions := newAuthTestServer(t)
+	tokens := login(t, r)
+	other := login(t, r)
+
+	if code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/logout", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("logout without access token: status %d, want 401", code)
+	}
+
+	if code, response := doAuthRequest(r, http.MethodPost, "/api/v1/auth/logout", tokens.Token, nil); code != http.StatusOK {
+		t.Fatalf("logout: status %d (%s)", code, response.Error)
+	}
+	if session, _ := sessions.FindByID(1); session.RevokedAt == nil || session.RevokedReason != models.SessionRevokedLogout {
+		t.Fatalf("session not revoked by logout: %+v", session)
+	}
+	if code, _ := doAuthRequest(r, http.MethodGet, "/api/v1/auth/me", tokens.Token, nil); code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: status %d, want 401", code)
+	}
+	if code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": tokens.RefreshToken}); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status %d, want 401", code)
+	}
+	// 이미 끊긴 세션으로는 성공을 돌려주지 않는다
+	if code, _ := doAuthRequest(r, http.MethodPost, "/api/v1/auth/logout", tokens.Token, nil); code != http.StatusUnauthorized {
+		t.Errorf("second logout: status %d, want 401", code)
+	}
+
+	if code, _ := doAuthRequest(r, http.MethodGet, "/api/v1/auth/me", other.Token, nil); code != http.StatusOK {
+		t.Errorf("other device after logout: status %d, want 200", code)
+	}
+}
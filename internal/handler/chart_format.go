@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/service"
+	"dothefortune_server/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 사주 정보에 원국 표시(한글 읽기, 로마자, 오행 등)를 붙인 응답
+type FortuneInfoResponse struct {
+	*models.FortuneInfo
+	Pillars *utils.ChartDisplay `json:"pillars,omitempty" description:"format을 지정했을 때만 포함"`
+}
+
+// 궁합 결과에 두 사람의 원국 표시를 붙인 응답
+type CompatibilityResponse struct {
+	*models.Compatibility
+	User1Pillars *utils.ChartDisplay `json:"user1_pillars,omitempty" description:"format을 지정했을 때만 포함"`
+	User2Pillars *utils.ChartDisplay `json:"user2_pillars,omitempty" description:"format을 지정했을 때만 포함, 비회원 상대도 포함"`
+}
+
+// format 쿼리를 읽는다. 없으면 빈 문자열(원국 표시를 붙이지 않음), 잘못된 값이면 400을 응답하고 false를 반환한다
+func bindChartFormat(c *gin.Context) (string, bool) {
+	format := c.Query("format")
+	if format != "" && !utils.IsValidChartFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format"})
+		return "", false
+	}
+	return format, true
+}
+
+// format이 없으면 기존 응답 그대로 돌려준다
+func newFortuneInfoResponse(info *models.FortuneInfo, format, locale string) interface{} {
+	if format == "" {
+		return info
+	}
+	chart := map[string]string{
+		"year_stem":    info.YearHeavenlyStem,
+		"year_branch":  info.YearEarthlyBranch,
+		"month_stem":   info.MonthHeavenlyStem,
+		"month_branch": info.MonthEarthlyBranch,
+		"day_stem":     info.DayHeavenlyStem,
+		"day_branch":   info.DayEarthlyBranch,
+		"hour_stem":    info.HourHeavenlyStem,
+		"hour_branch":  info.HourEarthlyBranch,
+	}
+	return FortuneInfoResponse{
+		FortuneInfo: info,
+		Pillars:     utils.DescribeChart(chart, format, locale),
+	}
+}
+
+func newCompatibilityResponse(compatibility *models.Compatibility, format, locale string) interface{} {
+	if format == "" || compatibility == nil {
+		return compatibility
+	}
+	response := CompatibilityResponse{Compatibility: compatibility}
+	if compatibility.User1Chart != nil {
+		response.User1Pillars = utils.DescribeChart(compatibility.User1Chart, format, locale)
+	}
+	if compatibility.User2Chart != nil {
+		response.User2Pillars = utils.DescribeChart(compatibility.User2Chart, format, locale)
+	}
+	return response
+}
+
+func newMatchPageResponse(page *service.MatchPage, format, locale string) interface{} {
+	if format == "" {
+		return page
+	}
+	matches := make([]interface{}, len(page.Matches))
+	for i, match := range page.Matches {
+		matches[i] = gin.H{
+			"user":          match.User,
+			"compatibility": newCompatibilityResponse(match.Compatibility, format, locale),
+		}
+	}
+	return CompatibilityMatchesResponse{
+		Matches:      matches,
+		RelationType: page.RelationType,
+		Page:         page.Page,
+		PageSize:     page.PageSize,
+		Total:        page.Total,
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 사주 정보 하나만 돌려주는 운세 서비스
+type fortuneInfoOnly struct {
+	service.FortuneService
+	info *models.FortuneInfo
+}
+
+func (s *fortuneInfoOnly) GetFortuneInfo(userID uint) (*models.FortuneInfo, error) {
+	return s.info, nil
+}
+
+func TestFortuneInfoFormat(t *testing.T) {
+	fortunes := &fortuneInfoOnly{info: &models.FortuneInfo{
+		UserID:           1,
+		YearHeavenlyStem: "庚", YearEarthlyBranch: "午",
+		MonthHeavenlyStem: "辛", MonthEarthlyBranch: "巳",
+		DayHeavenlyStem: "甲", DayEarthlyBranch: "子",
+		HourHeavenlyStem: "丙", HourEarthlyBranch: "寅",
+	}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fortune/info", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("locale", "en")
+	}, NewFortuneHandler(fortunes).GetFortuneInfo)
+
+	tests := []struct {
+		query       string
+		status      int
+		wantPillars bool
+		wantVerbose bool
+	}{
+		{"", http.StatusOK, false, false},
+		{"?format=compact", http.StatusOK, true, false},
+		{"?format=verbose", http.StatusOK, true, true},
+		{"?format=full", http.StatusBadRequest, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fortune/info"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				YearHeavenlyStem string `json:"year_heavenly_stem"`
+				Pillars          *struct {
+					Day struct {
+						Hangul string `json:"hangul"`
+						Branch struct {
+							Romanization string `json:"romanization"`
+							AnimalName   string `json:"animal_name"`
+						} `json:"branch"`
+					} `json:"day"`
+				} `json:"pillars"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			// 원국 표시는 기존 필드 옆에 붙는다
+			if response.YearHeavenlyStem != "庚" || (response.Pillars != nil) != tt.wantPillars {
+				t.Fatalf("body %s", w.Body.String())
+			}
+			if !tt.wantPillars {
+				return
+			}
+			branch := response.Pillars.Day.Branch
+			if response.Pillars.Day.Hangul != "갑자" || (branch.Romanization == "ja" && branch.AnimalName == "Rat") != tt.wantVerbose {
+				t.Errorf("day pillar %+v", response.Pillars.Day)
+			}
+		})
+	}
+}
+
+func TestCompatibilityResponseFormat(t *testing.T) {
+	compatibility := &models.Compatibility{
+		User1Chart: map[string]string{"year_stem": "庚", "year_branch": "午", "month_stem": "辛", "month_branch": "巳", "day_stem": "甲", "day_branch": "子"},
+		User2Chart: map[string]string{"year_stem": "壬", "year_branch": "申", "month_stem": "癸", "month_branch": "丑", "day_stem": "戊", "day_branch": "午", "hour_stem": "丁", "hour_branch": "巳"},
+	}
+
+	if response := newCompatibilityResponse(compatibility, "", "ko"); response != compatibility {
+		t.Errorf("without format = %+v, want the compatibility unchanged", response)
+	}
+
+	// 비회원 상대도 저장된 사주로 원국 표시를 붙인다
+	response, ok := newCompatibilityResponse(compatibility, "compact", "ko").(CompatibilityResponse)
+	if !ok {
+		t.Fatal("compact response has no pillars")
+	}
+	if response.User1Pillars.Day.Hangul != "갑자" || response.User1Pillars.Hour != nil || response.User2Pillars.Hour.Hangul != "정사" {
+		t.Errorf("pillars = %+v, %+v", response.User1Pillars, response.User2Pillars)
+	}
+}
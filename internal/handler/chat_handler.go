@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"dothefortune_server/internal/service"
+)
+
+type ChatHandler struct {
+	chatService service.ChatService
+}
+
+func NewChatHandler(chatService service.ChatService) *ChatHandler {
+	return &ChatHandler{
+		chatService: chatService,
+	}
+}
+
+type CreateConversationRequest struct {
+	Title string `json:"title" example:"이직 고민" binding:"max=100"`
+}
+
+type ChatMessageRequest struct {
+	Content string `json:"content" example:"이번 달에 이직해도 괜찮을까요?" binding:"required"`
+}
+
+type ConversationsResponse struct {
+	Conversations []interface{} `json:"conversations" description:"대화 목록 (최근 대화 순)"`
+}
+
+func chatErrorStatus(err error) int {
+	switch err.Error() {
+	case "conversation not found":
+		return http.StatusNotFound
+	case "message is required", "message too long", "fortune info not found":
+		return http.StatusBadRequest
+	case "daily message limit exceeded":
+		return http.StatusTooManyRequests
+	case "counselor unavailable":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func parseConversationID(c *gin.Context) (uint, bool) {
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || conversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conversation id"})
+		return 0, false
+	}
+	return uint(conversationID), true
+}
+
+// CreateConversation godoc
+// @Summary      상담 대화 시작
+// @Description  AI 사주 상담 대화를 새로 만듭니다. 제목을 비워 두면 첫 질문으로 제목을 정합니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  CreateConversationRequest  false  "대화 제목"
+// @Success      201  {object}  models.Conversation  "대화 생성 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 요청"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /chat/conversations [post]
+func (h *ChatHandler) CreateConversation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req CreateConversationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversation, err := h.chatService.CreateConversation(userID, req.Title)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+// GetConversations godoc
+// @Summary      상담 대화 목록
+// @Description  사용자의 AI 사주 상담 대화 목록을 최근 대화 순으로 조회합니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit  query  int  false  "반환할 최대 대화 수"  default(20)  minimum(1)  maximum(100)
+// @Success      200  {object}  ConversationsResponse  "대화 목록 조회 성공"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /chat/conversations [get]
+func (h *ChatHandler) GetConversations(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	conversations, err := h.chatService.GetConversations(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
+}
+
+// GetConversation godoc
+// @Summary      상담 대화 조회
+// @Description  상담 대화와 전체 메시지를 오래된 순으로 조회합니다. 각 메시지에는 추정 토큰 수가 포함됩니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "대화 ID"  minimum(1)
+// @Success      200  {object}  service.ConversationDetail  "대화 조회 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 대화 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "대화를 찾을 수 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /chat/conversations/{id} [get]
+func (h *ChatHandler) GetConversation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	conversationID, ok := parseConversationID(c)
+	if !ok {
+		return
+	}
+
+	detail, err := h.chatService.GetConversation(userID, conversationID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// DeleteConversation godoc
+// @Summary      상담 대화 삭제
+// @Description  상담 대화를 삭제합니다. 삭제해도 오늘 보낸 질문 수는 그대로 유지됩니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "대화 ID"  minimum(1)
+// @Success      200  {object}  MessageResponse  "삭제 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 대화 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "대화를 찾을 수 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /chat/conversations/{id} [delete]
+func (h *ChatHandler) DeleteConversation(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	conversationID, ok := parseConversationID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.chatService.DeleteConversation(userID, conversationID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted successfully"})
+}
+
+// SendMessage godoc
+// @Summary      상담 질문 보내기
+// @Description  상담 대화에 질문을 보내고 답변을 받습니다. 답변은 사용자의 사주 정보, 올해/이번 달/오늘의 운, 최근 운세 기록을 근거로 작성됩니다. 대화가 길어지면 앞부분은 요약되어 참고됩니다. 하루에 보낼 수 있는 질문 수가 정해져 있으며, 답변 생성에 실패하면 질문은 저장되지 않고 횟수도 차감되지 않습니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                 true  "대화 ID"  minimum(1)
+// @Param        request  body  ChatMessageRequest  true  "질문"
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200  {object}  service.ChatReply  "질문과 답변, 오늘 남은 질문 수"
+// @Failure      400  {object}  ErrorResponse  "잘못된 요청 또는 사주 정보가 등록되지 않음"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "대화를 찾을 수 없음"
+// @Failure      429  {object}  AIQuotaExceededResponse  "오늘 질문 가능 횟수 또는 AI 사용량 한도 초과 (한도 초과면 Retry-After와 reset_at 포함)"
+// @Failure      503  {object}  ErrorResponse  "AI 상담을 일시적으로 사용할 수 없음"
+// @Router       /chat/conversations/{id}/messages [post]
+func (h *ChatHandler) SendMessage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	conversationID, ok := parseConversationID(c)
+	if !ok {
+		return
+	}
+
+	var req ChatMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reply, err := h.chatService.SendMessage(c.Request.Context(), userID, conversationID, c.GetString("locale"), req.Content, nil)
+	if err != nil {
+		if respondAIQuotaExceeded(c, err) {
+			return
+		}
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reply)
+}
+
+// StreamMessage godoc
+// @Summary      상담 질문 보내기 (스트리밍)
+// @Description  /chat/conversations/{id}/messages와 같지만 답변을 Server-Sent Events로 받습니다. 답변은 token 이벤트({"text": "..."})로 도착하는 대로 보내고, 마지막에 result 이벤트로 저장된 질문과 답변, 오늘 남은 질문 수를 보냅니다. 스트리밍 중 오류는 error 이벤트로 전달되며, 연결을 끊으면 AI 호출도 취소되고 질문은 저장되지 않습니다.
+// @Tags         chat
+// @Accept       json
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        id       path  int                 true  "대화 ID"  minimum(1)
+// @Param        request  body  ChatMessageRequest  true  "질문"
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200  {object}  service.ChatReply  "result 이벤트의 데이터"
+// @Failure      400  {object}  ErrorResponse  "잘못된 요청 또는 사주 정보가 등록되지 않음"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "대화를 찾을 수 없음"
+// @Failure      429  {object}  AIQuotaExceededResponse  "오늘 질문 가능 횟수 또는 AI 사용량 한도 초과 (한도 초과면 Retry-After와 reset_at 포함)"
+// @Failure      503  {object}  ErrorResponse  "AI 상담을 일시적으로 사용할 수 없음"
+// @Router       /chat/conversations/{id}/messages/stream [post]
+func (h *ChatHandler) StreamMessage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	conversationID, ok := parseConversationID(c)
+	if !ok {
+		return
+	}
+
+	var req ChatMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := newSSEStream(c)
+	reply, err := h.chatService.SendMessage(c.Request.Context(), userID, conversationID, c.GetString("locale"), req.Content, stream.sendToken)
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			stream.fail(chatErrorStatus(err), err)
+		}
+		return
+	}
+
+	stream.send("result", reply)
+}
@@ -3,9 +3,11 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"dothefortune_server/internal/service"
+	"dothefortune_server/internal/utils"
 )
 
 type CompatibilityHandler struct {
@@ -20,13 +22,16 @@ func NewCompatibilityHandler(compatibilityService service.CompatibilityService)
 
 // CalculateCompatibility godoc
 // @Summary      궁합 계산
-// @Description  현재 사용자와 다른 사용자 간의 궁합을 계산합니다. 두 사용자의 사주 정보를 비교하여 궁합 점수(0-100)와 분석 결과를 반환합니다. 계산 결과는 데이터베이스에 저장되며, 이후 조회 시 재계산 없이 저장된 결과를 반환합니다.
+// @Description  현재 사용자와 다른 사용자 간의 궁합을 계산합니다. 두 사용자의 사주 정보를 비교하여 궁합 점수(0-100)와 분석 결과를 반환합니다. 관계 유형(연인, 친구, 사업, 가족)마다 가중치와 카테고리, 분석 문구가 다르며, 계산 결과는 관계 유형별로 데이터베이스에 저장되어 이후 조회 시 재계산 없이 저장된 결과를 반환합니다. AI 분석 모드에서는 대화/감정/생활/주의 문단을 AI가 두 사람의 사주에 맞춰 작성하며(narrative_source: ai), AI를 사용할 수 없으면 고정 문구(narrative_source: template)를 사용합니다.
 // @Tags         compatibility
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        user2_id  query  int  true  "상대방 사용자 ID"  minimum(1)
-// @Success      200       {object}  models.Compatibility  "궁합 계산 성공"
+// @Param        relation_type  query  string  false  "관계 유형 (romantic, friend, business, family)"  default(romantic)  Enums(romantic, friend, business, family)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200       {object}  CompatibilityResponse  "궁합 계산 성공 (format을 지정하면 user1_pillars, user2_pillars 포함)"
 // @Failure      400       {object}  ErrorResponse  "잘못된 요청 (자기 자신과의 궁합 계산 시도 등)"
 // @Failure      401       {object}  ErrorResponse  "인증 실패"
 // @Failure      500       {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
@@ -46,24 +51,36 @@ func (h *CompatibilityHandler) CalculateCompatibility(c *gin.Context) {
 		return
 	}
 
-	compatibility, err := h.compatibilityService.CalculateCompatibility(user1ID, uint(user2ID))
+	relationType, ok := bindRelationType(c)
+	if !ok {
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
+
+	compatibility, err := h.compatibilityService.CalculateCompatibility(user1ID, uint(user2ID), relationType, c.GetString("locale"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, compatibility)
+	c.JSON(http.StatusOK, newCompatibilityResponse(compatibility, format, c.GetString("locale")))
 }
 
 // GetCompatibility godoc
 // @Summary      궁합 조회
-// @Description  현재 사용자와 다른 사용자 간의 저장된 궁합 정보를 조회합니다. 저장된 궁합이 없으면 자동으로 계산하여 반환합니다.
+// @Description  현재 사용자와 다른 사용자 간의 저장된 궁합 정보를 조회합니다. 저장된 궁합이 없으면 자동으로 계산하여 반환합니다. 저장된 궁합의 언어(locale)가 요청 언어와 다르면 요청 언어로 다시 만들어 저장합니다.
 // @Tags         compatibility
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        user2_id  query  int  true  "상대방 사용자 ID"  minimum(1)
-// @Success      200       {object}  models.Compatibility  "궁합 정보 조회 성공"
+// @Param        relation_type  query  string  false  "관계 유형 (romantic, friend, business, family)"  default(romantic)  Enums(romantic, friend, business, family)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200       {object}  CompatibilityResponse  "궁합 정보 조회 성공 (format을 지정하면 user1_pillars, user2_pillars 포함)"
 // @Failure      400       {object}  ErrorResponse  "잘못된 요청"
 // @Failure      401       {object}  ErrorResponse  "인증 실패"
 // @Failure      500       {object}  ErrorResponse  "서버 내부 오류"
@@ -83,76 +100,555 @@ func (h *CompatibilityHandler) GetCompatibility(c *gin.Context) {
 		return
 	}
 
-	compatibility, err := h.compatibilityService.GetCompatibility(user1ID, uint(user2ID))
+	relationType, ok := bindRelationType(c)
+	if !ok {
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
+
+	compatibility, err := h.compatibilityService.GetCompatibility(user1ID, uint(user2ID), relationType, c.GetString("locale"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, compatibility)
+	c.JSON(http.StatusOK, newCompatibilityResponse(compatibility, format, c.GetString("locale")))
+}
+
+type CompatibilityNarrativeResponse struct {
+	Compatibility interface{} `json:"compatibility" description:"궁합 결과"`
+	Narrative     string      `json:"narrative" example:"두 사람은 서로의 부족한 점을 채워주는 관계예요." description:"AI 궁합 이야기"`
+	RecordID      uint        `json:"record_id" example:"1" description:"저장된 compatibility_narrative 기록 ID"`
+}
+
+// StreamCompatibilityNarrative godoc
+// @Summary      AI 궁합 이야기 스트리밍
+// @Description  현재 사용자와 다른 사용자의 궁합을 바탕으로 AI가 풀어 쓴 궁합 이야기를 Server-Sent Events로 받습니다. 문장은 token 이벤트({"text": "..."})로 도착하는 대로 보내고, 마지막에 result 이벤트로 궁합 결과, 전체 이야기, 저장된 기록 ID를 보냅니다. AI 호출이 실패하면 템플릿 분석 문구를 보내며, 연결을 끊으면 AI 호출도 취소됩니다.
+// @Tags         compatibility
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        user2_id  query  int  true  "상대방 사용자 ID"  minimum(1)
+// @Param        relation_type  query  string  false  "관계 유형 (romantic, friend, business, family)"  default(romantic)  Enums(romantic, friend, business, family)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200       {object}  CompatibilityNarrativeResponse  "result 이벤트의 데이터"
+// @Failure      400       {object}  ErrorResponse  "잘못된 요청"
+// @Failure      401       {object}  ErrorResponse  "인증 실패"
+// @Failure      429       {object}  AIQuotaExceededResponse  "AI 사용량 한도 초과 (Retry-After와 reset_at 포함)"
+// @Failure      500       {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
+// @Router       /compatibility/narrative/stream [get]
+func (h *CompatibilityHandler) StreamCompatibilityNarrative(c *gin.Context) {
+	user1ID := c.MustGet("user_id").(uint)
+
+	user2ID, err := strconv.ParseUint(c.Query("user2_id"), 10, 32)
+	if err != nil || user2ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user2_id"})
+		return
+	}
+
+	relationType, ok := bindRelationType(c)
+	if !ok {
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
+
+	stream := newSSEStream(c)
+	narrative, err := h.compatibilityService.StreamCompatibilityNarrative(c.Request.Context(), user1ID, uint(user2ID), relationType, c.GetString("locale"), stream.sendToken)
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			status := http.StatusInternalServerError
+			if err.Error() == "cannot calculate compatibility with yourself" {
+				status = http.StatusBadRequest
+			}
+			stream.fail(status, err)
+		}
+		return
+	}
+
+	stream.send("result", CompatibilityNarrativeResponse{
+		Compatibility: newCompatibilityResponse(narrative.Compatibility, format, c.GetString("locale")),
+		Narrative:     narrative.Narrative,
+		RecordID:      narrative.RecordID,
+	})
+}
+
+// relation_type 쿼리를 읽는다. 없으면 romantic, 잘못된 값이면 400을 응답하고 false를 반환한다
+func bindRelationType(c *gin.Context) (string, bool) {
+	relationType := c.DefaultQuery("relation_type", utils.RelationRomantic)
+	if !utils.IsValidRelationType(relationType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid relation_type"})
+		return "", false
+	}
+	return relationType, true
 }
 
 type CompatibilityMatchesResponse struct {
-	Matches []interface{} `json:"matches" description:"궁합 정보 목록"`
+	Matches      []interface{} `json:"matches" description:"사용자와 궁합 정보 목록"`
+	RelationType string        `json:"relation_type" example:"romantic" description:"적용된 관계 유형"`
+	Page         int           `json:"page" example:"1" description:"현재 페이지"`
+	PageSize     int           `json:"page_size" example:"10" description:"페이지 크기"`
+	Total        int           `json:"total" example:"42" description:"조건에 맞는 전체 후보 수"`
+}
+
+// 매칭 목록 공통 쿼리 (page, limit과 매칭 조건)를 읽는다. 잘못된 값이면 400을 응답하고 false를 반환한다
+func bindMatchQuery(c *gin.Context) (service.MatchQuery, int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query, ok := bindMatchOverrides(c)
+	if !ok {
+		return query, 0, 0, false
+	}
+
+	return query, page, limit, true
+}
+
+// 저장된 매칭 선호를 덮어쓸 쿼리 (gender, min_age, max_age, relation_type)를 읽는다.
+// gender=any면 성별 제한을 풀고, 나이에 0을 주면 해당 제한을 푼다. 잘못된 값이면 400을 응답하고 false를 반환한다
+func bindMatchOverrides(c *gin.Context) (service.MatchQuery, bool) {
+	var query service.MatchQuery
+
+	if genders := c.Query("gender"); genders != "" {
+		query.Genders = []string{}
+		if genders != "any" {
+			for _, gender := range strings.Split(genders, ",") {
+				gender = strings.TrimSpace(gender)
+				if gender != "M" && gender != "F" {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid gender"})
+					return query, false
+				}
+				query.Genders = append(query.Genders, gender)
+			}
+		}
+	}
+
+	if minAgeStr := c.Query("min_age"); minAgeStr != "" {
+		minAge, err := strconv.Atoi(minAgeStr)
+		if err != nil || minAge < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_age"})
+			return query, false
+		}
+		query.MinAge = &minAge
+	}
+	if maxAgeStr := c.Query("max_age"); maxAgeStr != "" {
+		maxAge, err := strconv.Atoi(maxAgeStr)
+		if err != nil || maxAge < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_age"})
+			return query, false
+		}
+		query.MaxAge = &maxAge
+	}
+	if query.MinAge != nil && query.MaxAge != nil && *query.MinAge > 0 && *query.MaxAge > 0 && *query.MinAge > *query.MaxAge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_age must not exceed max_age"})
+		return query, false
+	}
+
+	if relationType := c.Query("relation_type"); relationType != "" {
+		if !utils.IsValidRelationType(relationType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid relation_type"})
+			return query, false
+		}
+		query.RelationType = relationType
+	}
+
+	return query, true
 }
 
 // GetBestMatches godoc
 // @Summary      최고 궁합 목록 조회
-// @Description  현재 사용자와 가장 좋은 궁합을 가진 사용자들을 궁합 점수 순으로 반환합니다. 점수가 높은 순서대로 정렬되어 반환됩니다.
+// @Description  매칭을 거부하지 않은 전체 사용자 중 현재 사용자와 가장 좋은 궁합을 가진 사용자들을 찾아 궁합 점수가 높은 순으로 페이지 단위로 반환합니다. 저장된 매칭 선호(상대 성별, 나이 범위, 찾는 관계)를 적용하며, 쿼리로 요청마다 덮어쓸 수 있습니다.
 // @Tags         compatibility
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        limit  query  int  false  "반환할 최대 개수"  default(10)  minimum(1)  maximum(100)
+// @Param        page     query  int     false  "페이지 번호"  default(1)  minimum(1)
+// @Param        limit    query  int     false  "페이지 크기"  default(10)  minimum(1)  maximum(100)
+// @Param        relation_type  query  string  false  "관계 유형 (생략 시 저장된 매칭 선호의 intent)"  Enums(romantic, friend, business, family)
+// @Param        gender   query  string  false  "상대 성별 (M, F, 쉼표로 여러 개, any는 제한 없음). 생략 시 저장된 매칭 선호"
+// @Param        min_age  query  int     false  "상대 최소 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        max_age  query  int     false  "상대 최대 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
 // @Success      200    {object}  CompatibilityMatchesResponse  "최고 궁합 목록"
+// @Failure      400    {object}  ErrorResponse  "잘못된 필터 값"
 // @Failure      401    {object}  ErrorResponse  "인증 실패"
-// @Failure      500    {object}  ErrorResponse  "서버 내부 오류"
+// @Failure      500    {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
 // @Router       /compatibility/best [get]
 func (h *CompatibilityHandler) GetBestMatches(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 10
+	query, page, limit, ok := bindMatchQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
 	}
 
-	matches, err := h.compatibilityService.GetBestMatches(userID, limit)
+	matches, err := h.compatibilityService.GetBestMatches(userID, c.GetString("locale"), query, page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"matches": matches})
+	c.JSON(http.StatusOK, newMatchPageResponse(matches, format, c.GetString("locale")))
 }
 
 // GetWorstMatches godoc
 // @Summary      최악 궁합 목록 조회
-// @Description  현재 사용자와 가장 나쁜 궁합을 가진 사용자들을 궁합 점수 순으로 반환합니다. 점수가 낮은 순서대로 정렬되어 반환됩니다.
+// @Description  매칭을 거부하지 않은 전체 사용자 중 현재 사용자와 가장 나쁜 궁합을 가진 사용자들을 찾아 궁합 점수가 낮은 순으로 페이지 단위로 반환합니다. 저장된 매칭 선호(상대 성별, 나이 범위, 찾는 관계)를 적용하며, 쿼리로 요청마다 덮어쓸 수 있습니다.
 // @Tags         compatibility
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        limit  query  int  false  "반환할 최대 개수"  default(10)  minimum(1)  maximum(100)
+// @Param        page     query  int     false  "페이지 번호"  default(1)  minimum(1)
+// @Param        limit    query  int     false  "페이지 크기"  default(10)  minimum(1)  maximum(100)
+// @Param        relation_type  query  string  false  "관계 유형 (생략 시 저장된 매칭 선호의 intent)"  Enums(romantic, friend, business, family)
+// @Param        gender   query  string  false  "상대 성별 (M, F, 쉼표로 여러 개, any는 제한 없음). 생략 시 저장된 매칭 선호"
+// @Param        min_age  query  int     false  "상대 최소 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        max_age  query  int     false  "상대 최대 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
 // @Success      200    {object}  CompatibilityMatchesResponse  "최악 궁합 목록"
+// @Failure      400    {object}  ErrorResponse  "잘못된 필터 값"
 // @Failure      401    {object}  ErrorResponse  "인증 실패"
-// @Failure      500    {object}  ErrorResponse  "서버 내부 오류"
+// @Failure      500    {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
 // @Router       /compatibility/worst [get]
 func (h *CompatibilityHandler) GetWorstMatches(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 10
+	query, page, limit, ok := bindMatchQuery(c)
+	if !ok {
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
 	}
 
-	matches, err := h.compatibilityService.GetWorstMatches(userID, limit)
+	matches, err := h.compatibilityService.GetWorstMatches(userID, c.GetString("locale"), query, page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"matches": matches})
+	c.JSON(http.StatusOK, newMatchPageResponse(matches, format, c.GetString("locale")))
+}
+
+type MatchOptOutRequest struct {
+	OptOut bool `json:"opt_out" example:"true" swaggertype:"boolean" description:"true면 다른 사용자의 궁합 매칭 후보에서 제외"`
+}
+
+// SetMatchOptOut godoc
+// @Summary      궁합 매칭 노출 설정
+// @Description  다른 사용자의 최고/최악 궁합 목록에 내가 후보로 노출될지 설정합니다. opt_out이 true면 매칭 후보에서 제외됩니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  MatchOptOutRequest  true  "노출 설정"
+// @Success      200      {object}  MessageResponse  "설정 성공"
+// @Failure      400      {object}  ErrorResponse  "잘못된 요청"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /compatibility/opt-out [put]
+func (h *CompatibilityHandler) SetMatchOptOut(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req MatchOptOutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.compatibilityService.SetMatchOptOut(userID, req.OptOut); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Match visibility updated successfully"})
+}
+
+type MatchPreferenceRequest struct {
+	TargetGenders []string `json:"target_genders" binding:"omitempty,dive,oneof=M F" example:"F" swaggertype:"array,string" description:"상대 성별 (M, F), 비우면 제한 없음"`
+	MinAge        int      `json:"min_age" binding:"min=0" example:"25" swaggertype:"integer" minimum:"0" description:"상대 최소 나이, 0이면 제한 없음"`
+	MaxAge        int      `json:"max_age" binding:"min=0" example:"35" swaggertype:"integer" minimum:"0" description:"상대 최대 나이, 0이면 제한 없음"`
+	Intent        string   `json:"intent" binding:"omitempty,oneof=romantic friend business family" example:"romantic" swaggertype:"string" description:"찾는 관계 (romantic, friend, business, family), 기본값 romantic"`
 }
 
+// GetMatchPreference godoc
+// @Summary      매칭 선호 조회
+// @Description  유사 사주 친구와 최고/최악 궁합 목록에 적용되는 나의 매칭 선호(상대 성별, 나이 범위, 찾는 관계)를 반환합니다. 저장한 적이 없으면 제한 없음과 romantic을 반환합니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200      {object}  models.MatchPreference  "매칭 선호"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /compatibility/preferences [get]
+func (h *CompatibilityHandler) GetMatchPreference(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	preference, err := h.compatibilityService.GetMatchPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preference)
+}
+
+// UpdateMatchPreference godoc
+// @Summary      매칭 선호 저장
+// @Description  나의 매칭 선호를 저장합니다. 나이는 출생 연도 기준이며, 저장한 값은 이후 유사 사주 친구와 최고/최악 궁합 목록의 기본 조건이 됩니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  MatchPreferenceRequest  true  "매칭 선호"
+// @Success      200      {object}  models.MatchPreference  "저장된 매칭 선호"
+// @Failure      400      {object}  ErrorResponse  "잘못된 요청"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /compatibility/preferences [put]
+func (h *CompatibilityHandler) UpdateMatchPreference(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req MatchPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preference, err := h.compatibilityService.UpdateMatchPreference(userID, service.MatchPreferenceInput{
+		TargetGenders: req.TargetGenders,
+		MinAge:        req.MinAge,
+		MaxAge:        req.MaxAge,
+		Intent:        req.Intent,
+	})
+	if err != nil {
+		switch err.Error() {
+		case "invalid gender", "invalid age range", "min_age must not exceed max_age", "invalid relation type":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, preference)
+}
+
+type PartnerBirthRequest struct {
+	Nickname    string `json:"nickname" example:"짝사랑" swaggertype:"string" description:"상대방 별명 (연락처로 저장할 때 필수)"`
+	Gender      string `json:"gender" binding:"required,oneof=M F" example:"F" swaggertype:"string" description:"성별 (M: 남성, F: 여성)"`
+	BirthYear   int    `json:"birth_year" binding:"required" example:"2000" swaggertype:"integer" minimum:"1900" maximum:"2100" description:"출생 연도"`
+	BirthMonth  int    `json:"birth_month" binding:"required" example:"1" swaggertype:"integer" minimum:"1" maximum:"12" description:"출생 월"`
+	BirthDay    int    `json:"birth_day" binding:"required" example:"1" swaggertype:"integer" minimum:"1" maximum:"31" description:"출생 일"`
+	BirthHour   int    `json:"birth_hour" example:"12" swaggertype:"integer" minimum:"0" maximum:"23" description:"출생 시각 (0-23), unknown_time이 true면 무시됨"`
+	BirthMinute int    `json:"birth_minute" example:"0" swaggertype:"integer" minimum:"0" maximum:"59" description:"출생 분 (0-59), unknown_time이 true면 무시됨"`
+	UnknownTime bool   `json:"unknown_time" example:"false" swaggertype:"boolean" description:"출생 시각을 모를 경우 true"`
+	IsLunar     bool   `json:"is_lunar" example:"false" swaggertype:"boolean" description:"양력(false) 또는 음력(true)"`
+}
+
+type GuestCompatibilityRequest struct {
+	Partner      PartnerBirthRequest `json:"partner" binding:"required"`
+	RelationType string              `json:"relation_type" binding:"omitempty,oneof=romantic friend business family" example:"romantic" swaggertype:"string" description:"관계 유형 (romantic, friend, business, family), 기본값 romantic"`
+	SaveContact  bool                `json:"save_contact" example:"true" swaggertype:"boolean" description:"상대방을 연락처로 저장할지 여부"`
+}
+
+type GuestCompatibilityResponse struct {
+	Compatibility interface{} `json:"compatibility" description:"궁합 결과"`
+	Contact       interface{} `json:"contact,omitempty" description:"저장된 상대방 연락처"`
+}
+
+type PartnerContactsResponse struct {
+	Contacts []interface{} `json:"contacts" description:"저장된 상대방 연락처 목록"`
+}
+
+// CalculateGuestCompatibility godoc
+// @Summary      비회원 상대와 궁합 계산
+// @Description  가입하지 않은 상대(짝사랑, 부모님, 연예인 등)의 출생 정보를 직접 입력받아 궁합을 계산합니다. save_contact가 true면 상대방을 별명과 함께 연락처로 저장해 이후 다시 궁합을 볼 수 있습니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  GuestCompatibilityRequest  true  "상대방 출생 정보"
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200      {object}  GuestCompatibilityResponse  "궁합 계산 성공"
+// @Failure      400      {object}  ErrorResponse  "잘못된 요청 (필수 필드 누락, 연락처 저장 시 별명 누락 등)"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
+// @Router       /compatibility/guest [post]
+func (h *CompatibilityHandler) CalculateGuestCompatibility(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req GuestCompatibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
+
+	partner := service.PartnerBirthInfo{
+		Nickname:     req.Partner.Nickname,
+		Gender:       req.Partner.Gender,
+		BirthYear:    req.Partner.BirthYear,
+		BirthMonth:   req.Partner.BirthMonth,
+		BirthDay:     req.Partner.BirthDay,
+		BirthHour:    req.Partner.BirthHour,
+		BirthMinute:  req.Partner.BirthMinute,
+		UnknownTime:  req.Partner.UnknownTime,
+		IsLunar:      req.Partner.IsLunar,
+		RelationType: req.RelationType,
+		Locale:       c.GetString("locale"),
+	}
+
+	compatibility, contact, err := h.compatibilityService.CalculateGuestCompatibility(userID, partner, req.SaveContact)
+	if err != nil {
+		if err.Error() == "nickname is required to save contact" || err.Error() == "invalid relation type" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	response := gin.H{"compatibility": newCompatibilityResponse(compatibility, format, c.GetString("locale"))}
+	if contact != nil {
+		response["contact"] = contact
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetContacts godoc
+// @Summary      저장된 상대방 목록 조회
+// @Description  비회원 궁합에서 저장한 상대방 연락처 목록을 최신순으로 반환합니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  PartnerContactsResponse  "연락처 목록"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /compatibility/contacts [get]
+func (h *CompatibilityHandler) GetContacts(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	contacts, err := h.compatibilityService.GetContacts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"contacts": contacts})
+}
+
+// CalculateContactCompatibility godoc
+// @Summary      저장된 상대방과 궁합 다시 보기
+// @Description  저장해 둔 상대방의 출생 정보로 현재 사용자와의 궁합을 다시 계산합니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "연락처 ID"  minimum(1)
+// @Param        relation_type  query  string  false  "관계 유형 (생략 시 연락처에 저장된 관계 유형)"  Enums(romantic, friend, business, family)
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200  {object}  GuestCompatibilityResponse  "궁합 계산 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 연락처 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "연락처를 찾을 수 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류 또는 사주 정보 없음"
+// @Router       /compatibility/contacts/{id} [get]
+func (h *CompatibilityHandler) CalculateContactCompatibility(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	contactID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	relationType := c.Query("relation_type")
+	if relationType != "" && !utils.IsValidRelationType(relationType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid relation_type"})
+		return
+	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
+
+	compatibility, contact, err := h.compatibilityService.CalculateContactCompatibility(userID, uint(contactID), relationType, c.GetString("locale"))
+	if err != nil {
+		if err.Error() == "contact not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"compatibility": newCompatibilityResponse(compatibility, format, c.GetString("locale")),
+		"contact":       contact,
+	})
+}
+
+// DeleteContact godoc
+// @Summary      저장된 상대방 삭제
+// @Description  저장해 둔 상대방 연락처를 삭제합니다.
+// @Tags         compatibility
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "연락처 ID"  minimum(1)
+// @Success      200  {object}  MessageResponse  "삭제 성공"
+// @Failure      400  {object}  ErrorResponse  "잘못된 연락처 ID"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      404  {object}  ErrorResponse  "연락처를 찾을 수 없음"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /compatibility/contacts/{id} [delete]
+func (h *CompatibilityHandler) DeleteContact(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	contactID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	if err := h.compatibilityService.DeleteContact(userID, uint(contactID)); err != nil {
+		if err.Error() == "contact not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact deleted successfully"})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"dothefortune_server/internal/service"
+	"dothefortune_server/internal/utils"
 )
 
 type FortuneHandler struct {
@@ -36,15 +37,22 @@ type TodayFortuneResponse struct {
 	LuckyColor      string   `json:"lucky_color" example:"초록"`
 	LuckyColorHex   string   `json:"lucky_color_hex" example:"#4CAF50"`
 	LuckyNumbers    []int    `json:"lucky_numbers"`
+	FortuneDate         string `json:"fortune_date" example:"2024-01-01" description:"운세 기준 날짜"`
+	RegenerateRemaining int    `json:"regenerate_remaining" example:"3" description:"오늘 남은 재생성 횟수"`
+	RecordID            uint   `json:"record_id" example:"1" description:"저장된 today_fortune 기록 ID (AI 생성에 실패해 규칙 기반 문장을 저장하지 않고 보여준 경우 0)"`
+	Locale              string `json:"locale" example:"ko" description:"운세 문장의 언어 (ko, en, ja)"`
 }
 
 type SimilarUsersResponse struct {
-	Users []UserWithScore `json:"users"`
+	Users      []UserWithScore `json:"users"`
+	NextCursor string          `json:"next_cursor" example:"NzUwOjQy" description:"다음 페이지 커서, 마지막 페이지면 빈 문자열"`
 }
 
 type UserWithScore struct {
-	User  interface{} `json:"user"`
-	Score float64     `json:"similarity_score" example:"85.5" description:"유사도 점수 (0-100)"`
+	User      interface{}               `json:"user"`
+	Score     float64                   `json:"similarity_score" example:"85.5" description:"유사도 점수 (0-100)"`
+	Rank      int                       `json:"rank" example:"1" description:"유사도 순위 (같은 점수는 같은 순위)"`
+	Breakdown utils.SimilarityBreakdown `json:"breakdown" description:"기둥별로 일치한 항목과 유사한 이유"`
 }
 
 // CreateOrUpdateFortuneInfo godoc
@@ -55,7 +63,9 @@ type UserWithScore struct {
 // @Produce      json
 // @Security     BearerAuth
 // @Param        request  body  CreateFortuneInfoRequest  true  "사주 정보"
-// @Success      200      {object}  models.FortuneInfo  "사주 정보 저장 성공"
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "verbose의 오행/음양/띠 이름 언어 (ko, en, ja)"
+// @Success      200      {object}  FortuneInfoResponse  "사주 정보 저장 성공 (format을 지정하면 pillars 포함)"
 // @Failure      400      {object}  ErrorResponse  "잘못된 요청 (필수 필드 누락, 잘못된 날짜 형식 등)"
 // @Failure      401      {object}  ErrorResponse  "인증 실패"
 // @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
@@ -68,6 +78,10 @@ func (h *FortuneHandler) CreateOrUpdateFortuneInfo(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
 
 	fortuneInfo, err := h.fortuneService.CreateOrUpdateFortuneInfo(
 		userID,
@@ -84,7 +98,7 @@ func (h *FortuneHandler) CreateOrUpdateFortuneInfo(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, fortuneInfo)
+	c.JSON(http.StatusOK, newFortuneInfoResponse(fortuneInfo, format, c.GetString("locale")))
 }
 
 // GetFortuneInfo godoc
@@ -94,12 +108,18 @@ func (h *FortuneHandler) CreateOrUpdateFortuneInfo(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {object}  models.FortuneInfo  "사주 정보 조회 성공"
+// @Param        format  query  string  false  "원국 표시 형식 (compact: 한글 읽기와 오행, verbose: 로마자, 음양, 띠, 오행 색까지). 생략 시 기존 응답"  Enums(compact, verbose)
+// @Param        Accept-Language  header  string  false  "verbose의 오행/음양/띠 이름 언어 (ko, en, ja)"
+// @Success      200  {object}  FortuneInfoResponse  "사주 정보 조회 성공 (format을 지정하면 pillars 포함)"
 // @Failure      401  {object}  ErrorResponse  "인증 실패"
 // @Failure      404  {object}  ErrorResponse  "사주 정보를 찾을 수 없음"
 // @Router       /fortune/info [get]
 func (h *FortuneHandler) GetFortuneInfo(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
+	format, ok := bindChartFormat(c)
+	if !ok {
+		return
+	}
 
 	fortuneInfo, err := h.fortuneService.GetFortuneInfo(userID)
 	if err != nil {
@@ -107,41 +127,96 @@ func (h *FortuneHandler) GetFortuneInfo(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, fortuneInfo)
+	c.JSON(http.StatusOK, newFortuneInfoResponse(fortuneInfo, format, c.GetString("locale")))
 }
 
 // GetTodayFortune godoc
 // @Summary      오늘의 운세 조회
-// @Description  사용자의 사주 정보를 기반으로 오늘의 운세를 제공합니다. 총운, 재물운, 애정운, 건강운과 행운의 컬러, 행운의 숫자를 포함합니다. 사주 정보가 등록되어 있어야 하며, 조회 시 기록이 자동으로 저장됩니다.
+// @Description  사용자의 사주 정보를 기반으로 오늘의 운세를 제공합니다. 총운, 재물운, 애정운, 건강운과 행운의 컬러, 행운의 숫자를 포함합니다. 사주 정보가 등록되어 있어야 합니다. 하루에 한 번 생성해 저장하고 같은 날 다시 조회하면 같은 운세를 돌려주며 (언어별로 따로 저장), 기록도 하루에 하나만 남습니다. regenerate=true로 요청하면 하루 허용 횟수 안에서 운세를 새로 만듭니다.
 // @Tags         fortune
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        regenerate  query  bool  false  "오늘의 운세를 새로 생성"  default(false)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
 // @Success      200  {object}  TodayFortuneResponse  "오늘의 운세 조회 성공"
 // @Failure      400  {object}  ErrorResponse  "사주 정보가 등록되지 않음"
 // @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      429  {object}  AIQuotaExceededResponse  "오늘 재생성 허용 횟수 또는 다시 만들기 중 AI 사용량 한도 초과 (한도 초과면 Retry-After와 reset_at 포함). 오늘 운세가 아직 없으면 한도를 넘어도 규칙 기반 운세를 반환한다"
+// @Failure      500  {object}  ErrorResponse  "서버 내부 오류"
 // @Router       /fortune/today [get]
 func (h *FortuneHandler) GetTodayFortune(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 
-	fortune, err := h.fortuneService.GetTodayFortune(userID)
+	regenerate, _ := strconv.ParseBool(c.DefaultQuery("regenerate", "false"))
+
+	fortune, err := h.fortuneService.GetTodayFortune(userID, c.GetString("locale"), regenerate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondAIQuotaExceeded(c, err) {
+			return
+		}
+		c.JSON(todayFortuneErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, fortune)
 }
 
+func todayFortuneErrorStatus(err error) int {
+	switch err.Error() {
+	case "fortune info not found":
+		return http.StatusBadRequest
+	case "daily regenerate limit exceeded":
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// StreamTodayFortune godoc
+// @Summary      오늘의 운세 스트리밍
+// @Description  /fortune/today와 같은 운세를 Server-Sent Events로 받습니다. 새로 생성할 때는 AI가 만드는 문장을 token 이벤트({"text": "..."})로 도착하는 대로 보내고, 마지막에 result 이벤트로 TodayFortuneResponse(저장된 기록 ID 포함)를 보냅니다. 저장된 운세를 돌려줄 때는 result 이벤트만 보냅니다. 스트리밍 중 오류는 error 이벤트로 전달되며, 연결을 끊으면 AI 호출도 취소됩니다.
+// @Tags         fortune
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        regenerate  query  bool  false  "오늘의 운세를 새로 생성"  default(false)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
+// @Success      200  {object}  TodayFortuneResponse  "result 이벤트의 데이터"
+// @Failure      400  {object}  ErrorResponse  "사주 정보가 등록되지 않음"
+// @Failure      401  {object}  ErrorResponse  "인증 실패"
+// @Failure      429  {object}  AIQuotaExceededResponse  "오늘 재생성 허용 횟수 또는 다시 만들기 중 AI 사용량 한도 초과 (한도 초과면 Retry-After와 reset_at 포함). 오늘 운세가 아직 없으면 한도를 넘어도 규칙 기반 운세를 반환한다"
+// @Router       /fortune/today/stream [get]
+func (h *FortuneHandler) StreamTodayFortune(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	regenerate, _ := strconv.ParseBool(c.DefaultQuery("regenerate", "false"))
+
+	stream := newSSEStream(c)
+	fortune, err := h.fortuneService.StreamTodayFortune(c.Request.Context(), userID, c.GetString("locale"), regenerate, stream.sendToken)
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			stream.fail(todayFortuneErrorStatus(err), err)
+		}
+		return
+	}
+
+	stream.send("result", fortune)
+}
+
 // GetSimilarUsers godoc
 // @Summary      유사 사주 친구 찾기
-// @Description  현재 사용자와 유사한 사주를 가진 다른 사용자들을 찾아 반환합니다. 일간 천간 또는 지지를 기준으로 유사도를 계산하며, 유사도 점수(0-100)와 함께 반환됩니다.
+// @Description  현재 사용자와 유사한 사주를 가진 다른 사용자들을 유사도 점수(0-100)가 높은 순으로 순위와 함께 반환합니다. 같은 점수는 같은 순위를 받고 사용자 ID 순으로 정렬되며, 각 항목에는 기둥별로 글자나 오행이 일치한 내역과 "같은 일주 甲子" 같은 유사한 이유가 포함됩니다. 일주(50%), 월주(30%), 연주(20%)의 천간과 지지가 같으면 만점, 오행이 같으면 절반을 주며, 전체 사용자를 대상으로 데이터베이스에서 순위를 매깁니다. 저장된 매칭 선호의 상대 성별과 나이 범위를 적용하며 쿼리로 덮어쓸 수 있습니다. 응답의 next_cursor를 cursor로 넘기면 다음 페이지를 받을 수 있습니다.
 // @Tags         fortune
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        limit  query  int  false  "반환할 최대 사용자 수"  default(10)  minimum(1)  maximum(100)
+// @Param        limit   query  int     false  "반환할 최대 사용자 수"  default(10)  minimum(1)  maximum(100)
+// @Param        cursor  query  string  false  "이전 응답의 next_cursor"
+// @Param        gender   query  string  false  "상대 성별 (M, F, 쉼표로 여러 개, any는 제한 없음). 생략 시 저장된 매칭 선호"
+// @Param        min_age  query  int     false  "상대 최소 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        max_age  query  int     false  "상대 최대 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        Accept-Language  header  string  false  "응답 언어 (ko, en, ja). 언어 설정이 저장되어 있으면 설정을 따른다"
 // @Success      200    {object}  SimilarUsersResponse  "유사 사용자 목록"
+// @Failure      400    {object}  ErrorResponse  "잘못된 커서 또는 필터 값"
 // @Failure      401    {object}  ErrorResponse  "인증 실패"
 // @Failure      500    {object}  ErrorResponse  "서버 내부 오류"
 // @Router       /fortune/similar [get]
@@ -153,23 +228,41 @@ func (h *FortuneHandler) GetSimilarUsers(c *gin.Context) {
 	if err != nil || limit <= 0 {
 		limit = 10
 	}
+	if limit > 100 {
+		limit = 100
+	}
 
-	users, scores, err := h.fortuneService.GetSimilarUsers(userID, limit)
+	query, ok := bindMatchOverrides(c)
+	if !ok {
+		return
+	}
+
+	similarUsers, nextCursor, err := h.fortuneService.GetSimilarUsers(userID, c.GetString("locale"), query, c.Query("cursor"), limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	result := make([]UserWithScore, len(users))
-	for i, user := range users {
+	result := make([]UserWithScore, len(similarUsers))
+	for i, similar := range similarUsers {
+		user := similar.User
 		user.FortuneInfo = nil
 		result[i] = UserWithScore{
-			User:  user,
-			Score: scores[i],
+			User:      user,
+			Score:     similar.Score,
+			Rank:      similar.Rank,
+			Breakdown: similar.Breakdown,
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": result})
+	c.JSON(http.StatusOK, gin.H{
+		"users":       result,
+		"next_cursor": nextCursor,
+	})
 }
 
 type SimilarUserMatchesResponse struct {
@@ -180,19 +273,29 @@ type SimilarUserMatchesResponse struct {
 
 // GetSimilarUserMatches godoc
 // @Summary      유사 사주 친구 매칭 (가장 비슷한, 잘 맞는, 잘 안 맞는)
-// @Description  현재 사용자와 가장 비슷한 사주, 잘 맞는 사주, 잘 안 맞는 사주를 가진 친구를 각각 1명씩 찾아 반환합니다. 기획서 기준의 상세한 매칭 로직을 사용합니다.
+// @Description  현재 사용자와 가장 비슷한 사주, 잘 맞는 사주, 잘 안 맞는 사주를 가진 친구를 각각 1명씩 찾아 반환합니다. 기획서 기준의 상세한 매칭 로직을 사용합니다. 저장된 매칭 선호(상대 성별, 나이 범위, 찾는 관계)에 맞는 후보 중에서 고르며, 쿼리로 요청마다 덮어쓸 수 있습니다.
 // @Tags         fortune
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        relation_type  query  string  false  "잘 맞는 친구를 고를 관계 유형 (생략 시 저장된 매칭 선호의 intent)"  Enums(romantic, friend, business, family)
+// @Param        gender   query  string  false  "상대 성별 (M, F, 쉼표로 여러 개, any는 제한 없음). 생략 시 저장된 매칭 선호"
+// @Param        min_age  query  int     false  "상대 최소 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
+// @Param        max_age  query  int     false  "상대 최대 나이 (0은 제한 없음). 생략 시 저장된 매칭 선호"  minimum(0)
 // @Success      200    {object}  SimilarUserMatchesResponse  "유사 사주 친구 매칭 결과"
+// @Failure      400    {object}  ErrorResponse  "잘못된 필터 값"
 // @Failure      401    {object}  ErrorResponse  "인증 실패"
 // @Failure      500    {object}  ErrorResponse  "서버 내부 오류"
 // @Router       /fortune/similar-matches [get]
 func (h *FortuneHandler) GetSimilarUserMatches(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 
-	similar, best, worst, err := h.fortuneService.GetSimilarUserMatches(userID)
+	query, ok := bindMatchOverrides(c)
+	if !ok {
+		return
+	}
+
+	similar, best, worst, err := h.fortuneService.GetSimilarUserMatches(userID, query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 준비한 조각을 흘려보낸 뒤 result 또는 err로 끝나는 오늘의 운세 서비스
+type streamingFortunes struct {
+	service.FortuneService
+	deltas   []string
+	result   *service.TodayFortuneResult
+	err      error
+	deltaErr error // onDelta가 마지막으로 반환한 오류
+}
+
+func (s *streamingFortunes) StreamTodayFortune(ctx context.Context, userID uint, locale string, regenerate bool, onDelta func(delta string) error) (*service.TodayFortuneResult, error) {
+	for _, delta := range s.deltas {
+		if s.deltaErr = onDelta(delta); s.deltaErr != nil {
+			return nil, s.deltaErr
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.result, nil
+}
+
+type sseEvent struct {
+	event string
+	data  string
+}
+
+func parseSSE(body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(body, "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if strings.HasPrefix(line, "event:") {
+				event.event = strings.TrimPrefix(line, "event:")
+			} else if strings.HasPrefix(line, "data:") {
+				event.data = strings.TrimPrefix(line, "data:")
+			}
+		}
+		if event.event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func serveFortuneStream(ctx context.Context, fortunes *streamingFortunes) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fortune/today/stream", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("locale", "ko")
+	}, NewFortuneHandler(fortunes).StreamTodayFortune)
+
+	req := httptest.NewRequest(http.MethodGet, "/fortune/today/stream", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStreamTodayFortuneSendsTokensThenResult(t *testing.T) {
+	fortunes := &streamingFortunes{
+		deltas: []string{"총운: 오늘은 ", "좋아요."},
+		result: &service.TodayFortuneResult{TotalFortune: "오늘은 좋아요.", RecordID: 7, Locale: "ko"},
+	}
+	w := serveFortuneStream(context.Background(), fortunes)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	events := parseSSE(w.Body.String())
+	if len(events) != 3 || events[0].event != "token" || events[1].event != "token" || events[2].event != "result" {
+		t.Fatalf("events = %+v", events)
+	}
+	var token struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal([]byte(events[0].data), &token)
+	if token.Text != "총운: 오늘은 " {
+		t.Errorf("first token %q", token.Text)
+	}
+	var result service.TodayFortuneResult
+	json.Unmarshal([]byte(events[2].data), &result)
+	if result.RecordID != 7 || result.TotalFortune != "오늘은 좋아요." {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestStreamTodayFortuneErrors(t *testing.T) {
+	// 첫 이벤트 전의 오류는 일반 JSON 응답이다
+	w := serveFortuneStream(context.Background(), &streamingFortunes{err: errors.New("fortune info not found")})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"error":"fortune info not found"`) {
+		t.Errorf("before streaming: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 스트리밍을 시작한 뒤의 오류는 error 이벤트로 보낸다
+	w = serveFortuneStream(context.Background(), &streamingFortunes{deltas: []string{"총운: "}, err: errors.New("upstream unavailable")})
+	events := parseSSE(w.Body.String())
+	if w.Code != http.StatusOK || len(events) != 2 || events[1].event != "error" || !strings.Contains(events[1].data, "upstream unavailable") {
+		t.Errorf("after streaming: status %d, events %+v", w.Code, events)
+	}
+}
+
+func TestStreamTodayFortuneStopsOnDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fortunes := &streamingFortunes{deltas: []string{"총운: ", "오늘은 좋아요."}}
+	w := serveFortuneStream(ctx, fortunes)
+
+	// 끊긴 뒤에는 생성을 멈추고 error 이벤트도 보내지 않는다
+	if !errors.Is(fortunes.deltaErr, context.Canceled) {
+		t.Errorf("onDelta error = %v, want context.Canceled", fortunes.deltaErr)
+	}
+	if events := parseSSE(w.Body.String()); len(events) != 1 || events[0].event != "token" {
+		t.Errorf("events = %+v", events)
+	}
+}
@@ -9,12 +9,14 @@ import (
 )
 
 type RecordHandler struct {
-	recordService service.RecordService
+	recordService      service.RecordService
+	spouseImageService service.SpouseImageService
 }
 
-func NewRecordHandler(recordService service.RecordService) *RecordHandler {
+func NewRecordHandler(recordService service.RecordService, spouseImageService service.SpouseImageService) *RecordHandler {
 	return &RecordHandler{
-		recordService: recordService,
+		recordService:      recordService,
+		spouseImageService: spouseImageService,
 	}
 }
 
@@ -90,7 +92,7 @@ type GetSpouseImageResponse struct {
 
 // GetSpouseImage godoc
 // @Summary      배우자 이미지 조회
-// @Description  사용자의 사주 정보에 저장된 미리 생성된 배우자 이미지 URL을 조회합니다.
+// @Description  사용자의 사주 정보에 저장된 배우자 이미지 URL을 조회합니다. 이미지는 POST /records/spouse-image로 생성합니다.
 // @Tags         records
 // @Accept       json
 // @Produce      json
@@ -119,6 +121,69 @@ func (h *RecordHandler) GetSpouseImage(c *gin.Context) {
 	})
 }
 
+// RequestSpouseImage godoc
+// @Summary      배우자 이미지 생성 요청
+// @Description  사주의 배우자궁(일지)과 배우자성(남: 재성, 여: 관성)으로 배우자 특징을 뽑아 AI 이미지를 생성합니다. 생성은 백그라운드에서 진행되며, 반환된 작업 ID로 상태를 조회합니다. 이미 진행 중인 작업이 있으면 그 작업을 반환합니다. 완료되면 사주 정보의 배우자 이미지 URL이 바뀌고 ai_spouse 기록이 생성됩니다.
+// @Tags         records
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202      {object}  models.SpouseImageJob  "생성 작업 접수"
+// @Failure      400      {object}  ErrorResponse  "사주 정보가 등록되지 않음"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      429      {object}  AIQuotaExceededResponse  "AI 사용량 한도 초과 (Retry-After와 reset_at 포함)"
+// @Failure      500      {object}  ErrorResponse  "서버 내부 오류"
+// @Router       /records/spouse-image [post]
+func (h *RecordHandler) RequestSpouseImage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	job, err := h.spouseImageService.RequestSpouseImage(userID, c.GetString("locale"))
+	if err != nil {
+		if respondAIQuotaExceeded(c, err) {
+			return
+		}
+		if err.Error() == "fortune info not found" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetSpouseImageJob godoc
+// @Summary      배우자 이미지 생성 상태 조회
+// @Description  배우자 이미지 생성 작업의 상태(pending, running, completed, failed)를 조회합니다. completed이면 image_url과 record_id가 채워집니다.
+// @Tags         records
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  int  true  "작업 ID"  example:"1"
+// @Success      200      {object}  models.SpouseImageJob  "작업 상태"
+// @Failure      400      {object}  ErrorResponse  "잘못된 작업 ID"
+// @Failure      401      {object}  ErrorResponse  "인증 실패"
+// @Failure      404      {object}  ErrorResponse  "작업이 없음"
+// @Router       /records/spouse-image/jobs/{id} [get]
+func (h *RecordHandler) GetSpouseImageJob(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job id"})
+		return
+	}
+
+	job, err := h.spouseImageService.GetSpouseImageJob(userID, uint(jobID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
 type CreateRecordRequest struct {
 	Type     string `json:"type" binding:"required" example:"compatibility" description:"기록 타입 (compatibility, ai_spouse, today_fortune 등)"`
 	Content  string `json:"content" binding:"required" example:"궁합 결과: 85점" description:"기록 내용"`
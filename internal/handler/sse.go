@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server-Sent Events 응답. 첫 이벤트를 보낼 때 헤더를 쓰므로 그 전에 난 오류는 일반 JSON으로 응답할 수 있다
+type sseStream struct {
+	c       *gin.Context
+	started bool
+}
+
+func newSSEStream(c *gin.Context) *sseStream {
+	return &sseStream{c: c}
+}
+
+// 이벤트를 바로 내보낸다. 클라이언트가 끊겼으면 요청 컨텍스트의 오류를 반환해 생성을 멈추게 한다
+func (s *sseStream) send(event string, data interface{}) error {
+	if !s.started {
+		header := s.c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		s.c.Status(http.StatusOK)
+		s.started = true
+	}
+
+	s.c.SSEvent(event, data)
+	s.c.Writer.Flush()
+	return s.c.Request.Context().Err()
+}
+
+func (s *sseStream) sendToken(delta string) error {
+	return s.send("token", gin.H{"text": delta})
+}
+
+// 스트림을 시작하기 전이면 status로 JSON 오류를 (AI 사용량 한도 초과는 429와 초기화 시각을), 시작한 뒤면 error 이벤트를 보낸다
+func (s *sseStream) fail(status int, err error) {
+	if !s.started {
+		if respondAIQuotaExceeded(s.c, err) {
+			return
+		}
+		s.c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.send("error", gin.H{"error": err.Error()})
+}
@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 지원하는 로케일. 카탈로그는 locales/{로케일}.json에 메시지 ID → 문구로 둔다
+const (
+	LocaleKorean   = "ko"
+	LocaleEnglish  = "en"
+	LocaleJapanese = "ja"
+
+	// 요청 로케일에 메시지가 없을 때 쓰는 로케일
+	DefaultLocale = LocaleKorean
+)
+
+var Locales = []string{LocaleKorean, LocaleEnglish, LocaleJapanese}
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	loaded := make(map[string]map[string]string, len(Locales))
+	for _, locale := range Locales {
+		data, err := catalogFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			log.Fatalf("Failed to read %s message catalog: %v", locale, err)
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			log.Fatalf("Failed to parse %s message catalog: %v", locale, err)
+		}
+		loaded[locale] = messages
+	}
+	return loaded
+}
+
+func IsSupported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// "en-US", "ja_JP" 같은 값을 지원하는 로케일로 바꾼다. 지원하지 않으면 빈 문자열
+func Normalize(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	if IsSupported(locale) {
+		return locale
+	}
+	return ""
+}
+
+// Accept-Language 헤더에서 q 값이 가장 높은 지원 로케일을 고른다. 없으면 빈 문자열
+func MatchAcceptLanguage(header string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		locale := Normalize(fields[0])
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{locale, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].locale
+}
+
+// 요청 로케일 → 기본 로케일 순으로 메시지를 찾는다
+func Lookup(locale, id string) (string, bool) {
+	if message, ok := catalogs[locale][id]; ok {
+		return message, true
+	}
+	message, ok := catalogs[DefaultLocale][id]
+	return message, ok
+}
+
+// 메시지 ID의 문구. 어느 로케일에도 없으면 ID를 그대로 돌려준다. args가 있으면 문구를 형식 문자열로 쓴다
+func T(locale, id string, args ...interface{}) string {
+	message, ok := Lookup(locale, id)
+	if !ok {
+		return id
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// 메시지 ID가 있는 모든 로케일의 문구 (응답 파싱처럼 어느 언어로 올지 모를 때 사용)
+func All(id string) []string {
+	var messages []string
+	for _, locale := range Locales {
+		if message, ok := catalogs[locale][id]; ok {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"ko", "ko"},
+		{"en-US", "en"},
+		{" JA_jp ", "ja"},
+		{"zh-CN", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.locale); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"ja-JP,ja;q=0.9,en;q=0.8", "ja"},
+		{"fr-FR, en;q=0.5, ko;q=0.7", "ko"},
+		{"en;q=0.5, ja;q=0.5", "en"},
+		{"ko;q=0, en;q=0.1", "en"},
+		{"fr, de", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MatchAcceptLanguage(tt.header); got != tt.want {
+			t.Errorf("MatchAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTFallsBackToDefaultLocale(t *testing.T) {
+	if got := T(LocaleJapanese, "fortune.section.love"); got != "恋愛運" {
+		t.Errorf("ja love = %q", got)
+	}
+	if got := T("fr", "fortune.section.love"); got != "애정운" {
+		t.Errorf("unsupported locale = %q, want the default locale", got)
+	}
+	if got := T(LocaleEnglish, "missing.message.id"); got != "missing.message.id" {
+		t.Errorf("missing id = %q", got)
+	}
+	if got := All("fortune.section.total"); len(got) != len(Locales) || got[0] != "총운" {
+		t.Errorf("All = %q", got)
+	}
+}
+
+var formatVerb = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0-9.]*[a-zA-Z%]`)
+
+// 문구가 쓰는 인자 위치 (%[n]s로 순서를 바꾼 번역도 같은 인자를 써야 한다)
+func argumentPositions(message string) map[int]bool {
+	positions := make(map[int]bool)
+	next := 1
+	for _, match := range formatVerb.FindAllStringSubmatch(message, -1) {
+		if match[0] == "%%" {
+			continue
+		}
+		if match[1] != "" {
+			next, _ = strconv.Atoi(match[1])
+		}
+		positions[next] = true
+		next++
+	}
+	return positions
+}
+
+func TestCatalogsHaveTheSameMessages(t *testing.T) {
+	for _, locale := range Locales {
+		if locale == DefaultLocale {
+			continue
+		}
+		for id, message := range catalogs[DefaultLocale] {
+			translated, ok := catalogs[locale][id]
+			if !ok {
+				t.Errorf("%s is missing %s", locale, id)
+				continue
+			}
+			want, got := argumentPositions(message), argumentPositions(translated)
+			if len(want) != len(got) {
+				t.Errorf("%s %s uses arguments %v, want %v", locale, id, got, want)
+				continue
+			}
+			for position := range want {
+				if !got[position] {
+					t.Errorf("%s %s uses arguments %v, want %v", locale, id, got, want)
+					break
+				}
+			}
+		}
+		for id := range catalogs[locale] {
+			if _, ok := catalogs[DefaultLocale][id]; !ok {
+				t.Errorf("%s has %s that the default locale does not", locale, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthMiddleware 뒤에 두어 관리자 이메일로 로그인한 사용자만 통과시킨다
+func AdminMiddleware(adminEmails []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.GetString("email")
+		for _, adminEmail := range adminEmails {
+			if email != "" && strings.EqualFold(email, adminEmail) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		c.Abort()
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"dothefortune_server/internal/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// 토큰의 세션이 로그아웃이나 기기 해제로 끊겼으면 토큰이 만료되지 않았어도 거부한다
+func AuthMiddleware(sessionActive func(sessionID uint) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var tokenString string
 
@@ -40,8 +41,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if !sessionActive(claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
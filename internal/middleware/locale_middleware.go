@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"dothefortune_server/internal/i18n"
+)
+
+// AuthMiddleware 뒤에 두어 요청 언어를 정한다. 사용자 설정 → Accept-Language → 기본 로케일 순이며
+// 핸들러는 c.GetString("locale")로 읽는다. 정한 언어는 Content-Language 헤더로 알려준다
+func LocaleMiddleware(preferredLocale func(userID uint) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := ""
+		if userID := c.GetUint("user_id"); userID != 0 {
+			locale = i18n.Normalize(preferredLocale(userID))
+		}
+		if locale == "" {
+			locale = i18n.MatchAcceptLanguage(c.GetHeader("Accept-Language"))
+		}
+		if locale == "" {
+			locale = i18n.DefaultLocale
+		}
+
+		c.Set("locale", locale)
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
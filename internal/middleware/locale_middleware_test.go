@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLocaleMiddleware(t *testing.T) {
+	preferences := map[uint]string{1: "ja", 2: "", 3: "fr"}
+
+	tests := []struct {
+		name           string
+		userID         uint
+		acceptLanguage string
+		want           string
+	}{
+		{"user setting wins", 1, "en-US,en;q=0.9", "ja"},
+		{"no setting uses Accept-Language", 2, "en-US,en;q=0.9", "en"},
+		{"unsupported setting uses Accept-Language", 3, "ja-JP", "ja"},
+		{"guest", 0, "en", "en"},
+		{"default", 2, "fr-FR", "ko"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			var got string
+			r.GET("/", func(c *gin.Context) {
+				if tt.userID != 0 {
+					c.Set("user_id", tt.userID)
+				}
+			}, LocaleMiddleware(func(userID uint) string {
+				return preferences[userID]
+			}), func(c *gin.Context) {
+				got = c.GetString("locale")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got != tt.want || w.Header().Get("Content-Language") != tt.want {
+				t.Errorf("locale %q, Content-Language %q, want %q", got, w.Header().Get("Content-Language"), tt.want)
+			}
+		})
+	}
+}
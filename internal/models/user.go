@@ -16,6 +16,8 @@ type User struct {
 	Name     string `gorm:"not null" json:"name" example:"홍길동"`
 	Password string `gorm:"not null" json:"-"`
 	Gender   string `gorm:"not null" json:"gender" example:"M" description:"성별 (M: 남성, F: 여성)"`
+	MatchOptOut bool `gorm:"default:false" json:"match_opt_out" example:"false" description:"궁합 매칭 후보에서 제외 (개인정보 보호)"`
+	Locale   string `gorm:"size:8" json:"locale" example:"ko" description:"운세와 궁합 문장 언어 (ko, en, ja). 비어 있으면 Accept-Language를 따른다"`
 
 	FortuneInfo *FortuneInfo `gorm:"foreignKey:UserID" json:"fortune_info,omitempty"`
 }
@@ -46,6 +48,22 @@ type FortuneInfo struct {
 	HourEarthlyBranch string `json:"hour_earthly_branch" example:"子"`
 	
 	SpouseImageURL string `json:"spouse_image_url" example:"https://example.com/spouse-image.jpg" description:"미리 생성된 배우자 이미지 URL"`
+
+	// 유사 사주 검색용 특징 (천간 0-9, 지지 0-11 인덱스와 木火土金水 개수). 저장할 때 저장소에서 채운다
+	YearStemIdx    int  `gorm:"index:idx_fortune_year_pillar" json:"-"`
+	YearBranchIdx  int  `gorm:"index:idx_fortune_year_pillar" json:"-"`
+	MonthStemIdx   int  `gorm:"index:idx_fortune_month_pillar" json:"-"`
+	MonthBranchIdx int  `gorm:"index:idx_fortune_month_pillar" json:"-"`
+	DayStemIdx     int  `gorm:"index:idx_fortune_day_pillar" json:"-"`
+	DayBranchIdx   int  `gorm:"index:idx_fortune_day_pillar" json:"-"`
+	HourStemIdx    int  `json:"-"`
+	HourBranchIdx  int  `json:"-"`
+	WoodCount      int  `json:"-"`
+	FireCount      int  `json:"-"`
+	EarthCount     int  `json:"-"`
+	MetalCount     int  `json:"-"`
+	WaterCount     int  `json:"-"`
+	ChartEncoded   bool `gorm:"default:false;index" json:"-"`
 }
 
 type FortuneRecord struct {
@@ -59,6 +77,7 @@ type FortuneRecord struct {
 	Content   string `gorm:"type:text" json:"content"`
 	ImageURL  string `json:"image_url"`
 	Metadata  string `gorm:"type:jsonb" json:"metadata"`
+	JobID     *uint  `gorm:"uniqueIndex" json:"-"` // 작업 큐로 만든 기록이면 그 작업 ID (재시도해도 한 번만 저장된다)
 }
 
 type Compatibility struct {
@@ -69,14 +88,265 @@ type Compatibility struct {
 
 	User1ID    uint    `gorm:"not null;index" json:"user1_id" example:"1"`
 	User2ID    uint    `gorm:"not null;index" json:"user2_id" example:"2"`
+	RelationType string `gorm:"not null;default:romantic;index" json:"relation_type" example:"romantic" description:"관계 유형 (romantic, friend, business, family)"`
 	Score      float64 `gorm:"not null" json:"score" example:"85.5" description:"궁합 점수 (0-100)"`
 	Analysis   string  `gorm:"type:text" json:"analysis" example:"두 사람은 매우 좋은 궁합을 가지고 있습니다."`
 	CompatibilityType string `gorm:"not null" json:"compatibility_type" example:"excellent" description:"궁합 타입 (excellent, good, normal, poor)"`
+	Locale     string  `gorm:"size:8;not null;default:ko" json:"locale" example:"ko" description:"분석 문장과 카테고리 이름의 언어 (ko, en, ja)"`
 	
 	// 카테고리별 분석 (기획서 기준)
 	CommunicationAnalysis string `gorm:"type:text" json:"communication_analysis" example:"말하지 않아도 통하는 텔레파시가 있어요." description:"🗣️ 대화/가치관"`
 	EmotionAnalysis       string `gorm:"type:text" json:"emotion_analysis" example:"서로의 부족한 점을 감싸주는 안정감을 느껴요." description:"💖 감정/성격"`
 	LifestyleAnalysis     string `gorm:"type:text" json:"lifestyle_analysis" example:"함께 무언가를 도모하면 손발이 척척 맞아요." description:"🏠 목표/생활 방식"`
 	CautionAnalysis       string `gorm:"type:text" json:"caution_analysis" example:"특별히 주의할 점은 없으나, 서로 예의를 지키는 게 중요해요." description:"⚡ 주의할 점"`
+
+	// 카테고리별 분석을 쓴 방식 (template: 고정 문구, ai: AI가 두 사주에 맞춰 작성)
+	NarrativeSource        string `gorm:"size:16;not null;default:template" json:"narrative_source" example:"ai" description:"카테고리별 분석 작성 방식 (template, ai)"`
+	NarrativePromptVersion string `gorm:"size:64" json:"-"`
+
+	// 레이더 차트용 상세 데이터
+	User1Elements  map[string]int     `gorm:"type:jsonb;serializer:json" json:"user1_elements" description:"user1 오행 분포 (木, 火, 土, 金, 水)"`
+	User2Elements  map[string]int     `gorm:"type:jsonb;serializer:json" json:"user2_elements" description:"user2 오행 분포 (木, 火, 土, 金, 水)"`
+	CategoryScores map[string]float64 `gorm:"type:jsonb;serializer:json" json:"category_scores" description:"카테고리 코드별 점수 (연인: talk, emotion, wealth, health / 관계 유형마다 다름)"`
+	CategoryLabels map[string]string  `gorm:"-" json:"category_labels" description:"카테고리 코드별 표시 이름 (응답 언어)"`
+
+	// 계산 당시 두 사람의 사주 지문. 사주 정보가 바뀌면 Stale로 표시되고 다음 조회 때 다시 계산된다
+	User1Fingerprint string `gorm:"size:16" json:"-"`
+	User2Fingerprint string `gorm:"size:16" json:"-"`
+	Stale            bool   `gorm:"default:false;index" json:"-"`
+
+	// 응답에서 원국을 보여줄 때 쓰는 두 사람의 사주 (저장하지 않는다, 계산하거나 조회할 때 서비스가 채운다)
+	User1Chart map[string]string `gorm:"-" json:"-"`
+	User2Chart map[string]string `gorm:"-" json:"-"`
+}
+
+
+// 가입하지 않은 상대(짝사랑, 부모님, 연예인 등)의 출생 정보를 저장해 두고 궁합을 다시 볼 수 있게 한다
+type PartnerContact struct {
+	ID        uint           `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time      `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID      uint   `gorm:"not null;index" json:"user_id" example:"1"`
+	Nickname    string `gorm:"not null" json:"nickname" example:"짝사랑"`
+	RelationType string `gorm:"not null;default:romantic" json:"relation_type" example:"romantic" description:"관계 유형 (romantic, friend, business, family)"`
+	Gender      string `gorm:"not null" json:"gender" example:"F" description:"성별 (M: 남성, F: 여성)"`
+	BirthYear   int    `gorm:"not null" json:"birth_year" example:"2000"`
+	BirthMonth  int    `gorm:"not null" json:"birth_month" example:"1"`
+	BirthDay    int    `gorm:"not null" json:"birth_day" example:"1"`
+	BirthHour   int    `json:"birth_hour" example:"12"`
+	BirthMinute int    `json:"birth_minute" example:"0"`
+	UnknownTime bool   `gorm:"default:false" json:"unknown_time" example:"false"`
+	IsLunar     bool   `gorm:"default:false" json:"is_lunar" example:"false" description:"양력(false) 또는 음력(true)"`
+
+	YearHeavenlyStem   string `json:"year_heavenly_stem" example:"庚"`
+	YearEarthlyBranch  string `json:"year_earthly_branch" example:"子"`
+	MonthHeavenlyStem  string `json:"month_heavenly_stem" example:"戊"`
+	MonthEarthlyBranch string `json:"month_earthly_branch" example:"寅"`
+	DayHeavenlyStem    string `json:"day_heavenly_stem" example:"甲"`
+	DayEarthlyBranch   string `json:"day_earthly_branch" example:"子"`
+	HourHeavenlyStem   string `json:"hour_heavenly_stem" example:"甲"`
+	HourEarthlyBranch  string `json:"hour_earthly_branch" example:"子"`
+}
+
+// 매칭 선호 (유사 사주/최고·최악 궁합 후보 조건). 요청마다 쿼리로 덮어쓸 수 있다
+type MatchPreference struct {
+	ID        uint           `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time      `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID        uint     `gorm:"uniqueIndex;not null" json:"user_id" example:"1"`
+	TargetGenders []string `gorm:"type:jsonb;serializer:json" json:"target_genders" example:"F" description:"상대 성별 (M, F), 비어 있으면 제한 없음"`
+	MinAge        int      `json:"min_age" example:"25" description:"상대 최소 나이 (출생 연도 기준), 0이면 제한 없음"`
+	MaxAge        int      `json:"max_age" example:"35" description:"상대 최대 나이 (출생 연도 기준), 0이면 제한 없음"`
+	Intent        string   `gorm:"not null;default:romantic" json:"intent" example:"romantic" description:"찾는 관계 (romantic, friend, business, family)"`
+}
+
+// 사용자별 오늘의 운세 캐시. (사용자, 날짜, 사주 버전, 프롬프트 버전)마다 한 번만 생성하고 이후에는 저장된 문장을 돌려준다
+type DailyFortune struct {
+	ID        uint           `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time      `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID        uint   `gorm:"not null;uniqueIndex:idx_daily_fortune_key" json:"user_id" example:"1"`
+	FortuneDate   string `gorm:"size:10;not null;uniqueIndex:idx_daily_fortune_key" json:"fortune_date" example:"2024-01-01" description:"운세 기준 날짜 (서비스 시간대)"`
+	ChartVersion  string `gorm:"size:16;not null;uniqueIndex:idx_daily_fortune_key" json:"-"`
+	PromptVersion string `gorm:"size:64;not null;uniqueIndex:idx_daily_fortune_key" json:"-"` // 로케일이 들어 있어 언어별로 따로 캐시된다
+	Locale        string `gorm:"size:8;not null;default:ko" json:"locale" example:"ko"`
+
+	TotalFortune  string `gorm:"type:text" json:"total_fortune"`
+	WealthFortune string `gorm:"type:text" json:"wealth_fortune"`
+	LoveFortune   string `gorm:"type:text" json:"love_fortune"`
+	HealthFortune string `gorm:"type:text" json:"health_fortune"`
+	LuckyColor    string `json:"lucky_color"`
+	LuckyColorHex string `json:"lucky_color_hex"`
+	LuckyNumbers  []int  `gorm:"type:jsonb;serializer:json" json:"lucky_numbers"`
+
+	RegenerateCount int  `gorm:"default:0" json:"regenerate_count"`
+	RecordID        uint `json:"-"` // 이 날짜의 today_fortune 기록 (하루에 하나)
 }
 
+// AI 사주 상담 대화. 컨텍스트 창을 넘어간 앞부분 메시지는 Summary로 접어 둔다
+type Conversation struct {
+	ID        uint           `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time      `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID        uint       `gorm:"not null;index" json:"user_id" example:"1"`
+	Title         string     `gorm:"size:100" json:"title" example:"이직 고민"`
+	MessageCount  int        `gorm:"default:0" json:"message_count" example:"4"`
+	TotalTokens   int        `gorm:"default:0" json:"total_tokens" example:"812" description:"대화에 쓰인 메시지 토큰 수 합계 (추정치)"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty" example:"2024-01-01T00:00:00Z"`
+
+	Summary           string `gorm:"type:text" json:"-"`
+	SummarizedUntilID uint   `gorm:"default:0" json:"-"` // 이 ID까지의 메시지는 Summary에 포함되어 있다
+}
+
+// 상담 대화의 메시지 (role: user, assistant)
+type ChatMessage struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `gorm:"index" json:"created_at" example:"2024-01-01T00:00:00Z"`
+
+	ConversationID uint   `gorm:"not null;index" json:"conversation_id" example:"1"`
+	UserID         uint   `gorm:"not null;index" json:"-"`
+	Role           string `gorm:"size:16;not null" json:"role" example:"user" description:"user 또는 assistant"`
+	Content        string `gorm:"type:text;not null" json:"content" example:"이번 달에 이직해도 괜찮을까요?"`
+	TokenCount     int    `gorm:"default:0" json:"token_count" example:"24" description:"메시지 토큰 수 (추정치)"`
+	PromptTokens   int    `gorm:"default:0" json:"-"` // assistant 메시지를 만들 때 보낸 프롬프트 토큰 수 (추정치)
+	PromptVersion  string `gorm:"size:64" json:"-"`
+}
+
+// AI 배우자 이미지 생성 작업 (status: pending, running, completed, failed). 클라이언트는 ID로 상태를 조회한다
+type SpouseImageJob struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+
+	UserID      uint       `gorm:"not null;index" json:"user_id" example:"1"`
+	Status      string     `gorm:"size:16;not null;index" json:"status" example:"completed" description:"작업 상태 (pending, running, completed, failed)"`
+	Traits      string     `gorm:"type:jsonb" json:"traits,omitempty" description:"사주에서 뽑은 배우자 특징"`
+	ImageURL    string     `json:"image_url,omitempty" example:"/media/spouse/1/1.png"`
+	RecordID    uint       `json:"record_id,omitempty" example:"12" description:"완료되면 만들어지는 ai_spouse 기록"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" example:"2024-01-01T00:00:00Z"`
+
+	Prompt        string `gorm:"type:text" json:"-"`
+	PromptVersion string `gorm:"size:64" json:"-"`
+	Provider      string `gorm:"size:32" json:"-"`
+}
+
+// 백그라운드 작업 큐 (status: pending, running, completed, dead).
+// 실패한 작업은 attempts를 남긴 채 pending으로 돌아가 run_at 이후에 다시 실행되고, max_attempts를 넘기면 dead가 된다
+type Job struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+
+	Type        string     `gorm:"size:64;not null;index" json:"type" example:"spouse_image.generate"`
+	Payload     string     `gorm:"type:jsonb;not null" json:"payload" example:"{\"spouse_image_job_id\": 1}"`
+	Status      string     `gorm:"size:16;not null;index:idx_jobs_claim,priority:1" json:"status" example:"pending" description:"작업 상태 (pending, running, completed, dead)"`
+	RunAt       time.Time  `gorm:"not null;index:idx_jobs_claim,priority:2" json:"run_at" example:"2024-01-01T00:00:00Z" description:"이 시각 이후에 실행된다"`
+	Attempts    int        `gorm:"default:0" json:"attempts" example:"1"`
+	MaxAttempts int        `gorm:"default:5" json:"max_attempts" example:"5"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	LockedBy    string     `gorm:"size:64" json:"locked_by,omitempty" example:"host-1234-0"`
+	LockedAt    *time.Time `json:"locked_at,omitempty" example:"2024-01-01T00:00:00Z"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" example:"2024-01-01T00:00:00Z"`
+}
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusDead      = "dead"
+)
+
+// 하루 운세 예약 생성 실행 (시간대와 날짜마다 하나). LastUserID까지 처리했으므로 중단되면 그다음부터 이어 간다
+type PregenerationRun struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+
+	FortuneDate string `gorm:"size:10;not null;uniqueIndex:idx_pregeneration_run_key" json:"fortune_date" example:"2024-01-01"`
+	Timezone    string `gorm:"size:64;not null;uniqueIndex:idx_pregeneration_run_key" json:"timezone" example:"Asia/Seoul"`
+	Status      string `gorm:"size:16;not null" json:"status" example:"completed" description:"실행 상태 (running, completed, failed)"`
+	InstanceID  string `gorm:"size:64" json:"instance_id" example:"host-1234"`
+
+	TotalUsers int  `json:"total_users" example:"1200" description:"시작할 때 센 최근 활동 사용자 수"`
+	Processed  int  `json:"processed" example:"1200"`
+	Generated  int  `json:"generated" example:"1100" description:"새로 생성해 캐시에 넣은 수"`
+	Skipped    int  `json:"skipped" example:"95" description:"이미 캐시에 있던 수"`
+	Failed     int  `json:"failed" example:"5"`
+	LastUserID uint `json:"last_user_id" example:"4821"`
+	Attempts   int  `json:"attempts" example:"1" description:"시작하거나 이어 간 횟수"`
+
+	StartedAt  *time.Time `json:"started_at,omitempty" example:"2024-01-01T00:05:00+09:00"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" example:"2024-01-01T00:42:00+09:00"`
+	DurationMs int64      `json:"duration_ms" example:"2220000" description:"이어 간 실행을 포함한 처리 시간 합계"`
+	LastError  string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+// AI 백엔드 호출 한 번 (재시도도 한 번씩 남긴다). 사용량 한도와 관리자 사용량 보고에 쓴다
+type AIUsage struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `gorm:"index;index:idx_ai_usage_user,priority:2" json:"created_at" example:"2024-01-01T00:00:00Z"`
+
+	UserID           uint   `gorm:"index:idx_ai_usage_user,priority:1" json:"user_id" example:"1" description:"호출을 일으킨 사용자 (0이면 시스템)"`
+	Feature          string `gorm:"size:32;not null" json:"feature" example:"daily_fortune"`
+	Provider         string `gorm:"size:16;not null" json:"provider" example:"gemini"`
+	Model            string `gorm:"size:64" json:"model" example:"gemini-2.5-flash"`
+	PromptTokens     int    `json:"prompt_tokens" example:"850"`
+	CompletionTokens int    `json:"completion_tokens" example:"320"`
+	Estimated        bool   `json:"estimated" example:"false" description:"백엔드가 토큰 수를 주지 않아 글자 수로 추정했는지"`
+	LatencyMs        int64  `json:"latency_ms" example:"2300"`
+	Outcome          string `gorm:"size:16;not null" json:"outcome" example:"success" description:"결과 (success, error, timeout, canceled)"`
+	Error            string `gorm:"type:text" json:"error,omitempty"`
+}
+
+const (
+	AIUsageSuccess  = "success"
+	AIUsageError    = "error"
+	AIUsageTimeout  = "timeout"
+	AIUsageCanceled = "canceled"
+)
+
+// 로그인한 기기 하나. refresh 토큰은 해시만 저장하고 쓸 때마다 새 토큰으로 바꾼다.
+// 이미 바뀐 토큰이 다시 오면 탈취로 보고 세션을 끊는다
+type Session struct {
+	ID        uint      `gorm:"primarykey" json:"id" example:"1"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z" description:"로그인 시각"`
+	UpdatedAt time.Time `json:"-"`
+
+	UserID           uint   `gorm:"not null;index" json:"-"`
+	RefreshTokenHash string `gorm:"size:64;not null" json:"-"`
+	// 직전 갱신에서 바뀐 토큰의 해시. 이 토큰이 다시 와야만 재사용으로 보고 세션을 끊는다
+	PreviousRefreshTokenHash string     `gorm:"size:64" json:"-"`
+	UserAgent                string     `gorm:"size:255" json:"user_agent" example:"DoTheFortune/1.4.0 (iPhone; iOS 17.5)" description:"로그인하거나 마지막으로 토큰을 갱신한 기기"`
+	IP                       string     `gorm:"size:64" json:"ip" example:"203.0.113.7"`
+	LastUsedAt               time.Time  `json:"last_used_at" example:"2024-01-01T00:00:00Z" description:"마지막으로 토큰을 갱신한 시각"`
+	ExpiresAt                time.Time  `gorm:"not null" json:"expires_at" example:"2024-01-31T00:00:00Z" description:"이 시각까지 토큰을 갱신하지 않으면 로그아웃된다"`
+	RevokedAt                *time.Time `gorm:"index" json:"-"`
+	RevokedReason            string     `gorm:"size:32" json:"-"`
+
+	// 요청을 보낸 세션인지 (저장하지 않는다)
+	Current bool `gorm:"-" json:"current" example:"true"`
+}
+
+const (
+	SessionRevokedLogout = "logout"
+	SessionRevokedByUser = "revoked"
+	SessionRevokedReused = "refresh_token_reused"
+)
+
+// 궁합 순 매칭에 쓰는 점수 조회표 한 칸. TableKey(사주, 관계, 성별)마다 한 번 채워 두고
+// 후보의 특징 값으로 Position을 계산해 조인한다
+type MatchScoreLookup struct {
+	TableKey string  `gorm:"primaryKey;size:64"`
+	Term     int     `gorm:"primaryKey;autoIncrement:false"`
+	Position int     `gorm:"primaryKey;autoIncrement:false"`
+	Value    float64 `gorm:"not null"`
+}
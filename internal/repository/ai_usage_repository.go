@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+// 하루·기능별 사용량 합계
+type AIUsageAggregate struct {
+	Day              string
+	Feature          string
+	Calls            int64
+	Succeeded        int64
+	Failed           int64
+	Users            int64
+	PromptTokens     int64
+	CompletionTokens int64
+	AvgLatencyMs     float64
+}
+
+type AIUsageRepository interface {
+	Create(usage *models.AIUsage) error
+	// dayStart, monthStart 이후 성공한 호출 수 (한도 확인용)
+	CountUserSuccess(userID uint, dayStart, monthStart time.Time) (int64, int64, error)
+	CountGlobalSuccess(dayStart, monthStart time.Time) (int64, int64, error)
+	// [from, to) 구간을 timezone 기준 날짜와 기능으로 묶는다. userID가 0이면 전체 사용자
+	Aggregate(from, to time.Time, timezone string, userID uint) ([]AIUsageAggregate, error)
+}
+
+type aiUsageRepository struct{}
+
+func NewAIUsageRepository() AIUsageRepository {
+	return &aiUsageRepository{}
+}
+
+func (r *aiUsageRepository) Create(usage *models.AIUsage) error {
+	return database.DB.Create(usage).Error
+}
+
+func (r *aiUsageRepository) CountUserSuccess(userID uint, dayStart, monthStart time.Time) (int64, int64, error) {
+	return r.countSuccess(database.DB.Where("user_id = ?", userID), dayStart, monthStart)
+}
+
+func (r *aiUsageRepository) CountGlobalSuccess(dayStart, monthStart time.Time) (int64, int64, error) {
+	return r.countSuccess(database.DB, dayStart, monthStart)
+}
+
+// 이번 달 행만 읽어 오늘 것과 이번 달 것을 한 번에 센다 (오늘은 항상 이번 달 안에 있다)
+func (r *aiUsageRepository) countSuccess(query *gorm.DB, dayStart, monthStart time.Time) (int64, int64, error) {
+	var counts struct {
+		Daily   int64
+		Monthly int64
+	}
+	err := query.Model(&models.AIUsage{}).
+		Select("COUNT(*) FILTER (WHERE created_at >= ?) AS daily, COUNT(*) AS monthly", dayStart).
+		Where("outcome = ? AND created_at >= ?", models.AIUsageSuccess, monthStart).
+		Scan(&counts).Error
+	return counts.Daily, counts.Monthly, err
+}
+
+func (r *aiUsageRepository) Aggregate(from, to time.Time, timezone string, userID uint) ([]AIUsageAggregate, error) {
+	query := database.DB.Model(&models.AIUsage{}).
+		Select(`to_char(created_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day,
+			feature,
+			COUNT(*) AS calls,
+			COUNT(*) FILTER (WHERE outcome = ?) AS succeeded,
+			COUNT(*) FILTER (WHERE outcome <> ?) AS failed,
+			COUNT(DISTINCT user_id) AS users,
+			COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+			COALESCE(AVG(latency_ms), 0) AS avg_latency_ms`,
+			timezone, models.AIUsageSuccess, models.AIUsageSuccess).
+		Where("created_at >= ? AND created_at < ?", from, to)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var rows []AIUsageAggregate
+	err := query.Group("day, feature").Order("day ASC, feature ASC").Scan(&rows).Error
+	return rows, err
+}
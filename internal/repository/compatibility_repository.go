@@ -7,9 +7,11 @@ import (
 
 type CompatibilityRepository interface {
 	Create(compatibility *models.Compatibility) error
-	FindByUserPair(user1ID, user2ID uint) (*models.Compatibility, error)
-	FindBestMatches(userID uint, limit int) ([]models.Compatibility, error)
-	FindWorstMatches(userID uint, limit int) ([]models.Compatibility, error)
+	Update(compatibility *models.Compatibility) error
+	FindByUserPair(user1ID, user2ID uint, relationType string) (*models.Compatibility, error)
+	FindBestMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error)
+	FindWorstMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error)
+	MarkStaleByUserID(userID uint) error
 }
 
 type compatibilityRepository struct{}
@@ -22,11 +24,16 @@ func (r *compatibilityRepository) Create(compatibility *models.Compatibility) er
 	return database.DB.Create(compatibility).Error
 }
 
-func (r *compatibilityRepository) FindByUserPair(user1ID, user2ID uint) (*models.Compatibility, error) {
+func (r *compatibilityRepository) Update(compatibility *models.Compatibility) error {
+	return database.DB.Save(compatibility).Error
+}
+
+func (r *compatibilityRepository) FindByUserPair(user1ID, user2ID uint, relationType string) (*models.Compatibility, error) {
 	var compatibility models.Compatibility
 	err := database.DB.
 		Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)",
 			user1ID, user2ID, user2ID, user1ID).
+		Where("relation_type = ?", relationType).
 		First(&compatibility).Error
 	if err != nil {
 		return nil, err
@@ -34,23 +41,32 @@ func (r *compatibilityRepository) FindByUserPair(user1ID, user2ID uint) (*models
 	return &compatibility, nil
 }
 
-func (r *compatibilityRepository) FindBestMatches(userID uint, limit int) ([]models.Compatibility, error) {
+func (r *compatibilityRepository) FindBestMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error) {
 	var compatibilities []models.Compatibility
 	err := database.DB.
 		Where("user1_id = ? OR user2_id = ?", userID, userID).
+		Where("relation_type = ?", relationType).
 		Order("score DESC").
 		Limit(limit).
 		Find(&compatibilities).Error
 	return compatibilities, err
 }
 
-func (r *compatibilityRepository) FindWorstMatches(userID uint, limit int) ([]models.Compatibility, error) {
+func (r *compatibilityRepository) FindWorstMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error) {
 	var compatibilities []models.Compatibility
 	err := database.DB.
 		Where("user1_id = ? OR user2_id = ?", userID, userID).
+		Where("relation_type = ?", relationType).
 		Order("score ASC").
 		Limit(limit).
 		Find(&compatibilities).Error
 	return compatibilities, err
 }
 
+
+func (r *compatibilityRepository) MarkStaleByUserID(userID uint) error {
+	return database.DB.
+		Model(&models.Compatibility{}).
+		Where("user1_id = ? OR user2_id = ?", userID, userID).
+		Update("stale", true).Error
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type ConversationRepository interface {
+	Create(conversation *models.Conversation) error
+	Update(conversation *models.Conversation) error
+	FindByID(userID, conversationID uint) (*models.Conversation, error)
+	FindByUserID(userID uint, limit int) ([]models.Conversation, error)
+	Delete(userID, conversationID uint) error
+	CreateMessage(message *models.ChatMessage) error
+	// afterID 다음 메시지부터 오래된 순으로
+	FindMessages(conversationID, afterID uint) ([]models.ChatMessage, error)
+	// since 이후 보낸 질문이 limit개보다 적을 때만 질문을 저장하고, 그 전까지 보낸 개수와 저장 여부를 반환한다.
+	// 사용자 행을 잠근 트랜잭션 안에서 세고 저장하므로 동시에 보내도 한도를 넘지 않는다
+	CreateUserMessageWithinLimit(message *models.ChatMessage, since time.Time, limit int) (int64, bool, error)
+	DeleteMessage(id uint) error
+}
+
+type conversationRepository struct{}
+
+func NewConversationRepository() ConversationRepository {
+	return &conversationRepository{}
+}
+
+func (r *conversationRepository) Create(conversation *models.Conversation) error {
+	return database.DB.Create(conversation).Error
+}
+
+func (r *conversationRepository) Update(conversation *models.Conversation) error {
+	return database.DB.Save(conversation).Error
+}
+
+func (r *conversationRepository) FindByID(userID, conversationID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := database.DB.Where("id = ? AND user_id = ?", conversationID, userID).First(&conversation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func (r *conversationRepository) FindByUserID(userID uint, limit int) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	err := database.DB.
+		Where("user_id = ?", userID).
+		Order("updated_at DESC").
+		Limit(limit).
+		Find(&conversations).Error
+	return conversations, err
+}
+
+func (r *conversationRepository) Delete(userID, conversationID uint) error {
+	return database.DB.Where("id = ? AND user_id = ?", conversationID, userID).Delete(&models.Conversation{}).Error
+}
+
+func (r *conversationRepository) CreateMessage(message *models.ChatMessage) error {
+	return database.DB.Create(message).Error
+}
+
+func (r *conversationRepository) FindMessages(conversationID, afterID uint) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	err := database.DB.
+		Where("conversation_id = ? AND id > ?", conversationID, afterID).
+		Order("id ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *conversationRepository) CreateUserMessageWithinLimit(message *models.ChatMessage, since time.Time, limit int) (int64, bool, error) {
+	var count int64
+	created := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var users []models.User
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", message.UserID).
+			Find(&users).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Model(&models.ChatMessage{}).
+			Where("user_id = ? AND role = ? AND created_at >= ?", message.UserID, "user", since).
+			Count(&count).Error
+		if err != nil || int(count) >= limit {
+			return err
+		}
+
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return count, created, err
+}
+
+func (r *conversationRepository) DeleteMessage(id uint) error {
+	return database.DB.Delete(&models.ChatMessage{}, id).Error
+}
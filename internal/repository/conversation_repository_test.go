@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+// 쿼리를 실행하지 않는 연결 (DryRun이라 호출되지 않는다)
+type dryRunConn struct {
+	statements *[]string
+}
+
+func (c dryRunConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errors.New("not used")
+}
+
+func (c dryRunConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errors.New("not used")
+}
+
+func (c dryRunConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("not used")
+}
+
+func (c dryRunConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+// 트랜잭션 시작과 끝을 statements에 남긴다
+type recordingConnPool struct {
+	dryRunConn
+}
+
+func (p *recordingConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	*p.statements = append(*p.statements, "BEGIN")
+	return &recordingTx{p.dryRunConn}, nil
+}
+
+// *sql.Tx처럼 그 안에서 다시 트랜잭션을 시작하지 않는다
+type recordingTx struct {
+	dryRunConn
+}
+
+func (tx *recordingTx) Commit() error {
+	*tx.statements = append(*tx.statements, "COMMIT")
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	*tx.statements = append(*tx.statements, "ROLLBACK")
+	return nil
+}
+
+// database.DB를 SQL만 만드는 연결로 바꾸고, 만든 SQL과 트랜잭션 경계를 순서대로 남긴다
+func recordStatements(t *testing.T) *[]string {
+	t.Helper()
+	statements := &[]string{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &recordingConnPool{dryRunConn{statements: statements}}}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(d *gorm.DB) {
+		*statements = append(*statements, d.Dialector.Explain(d.Statement.SQL.String(), d.Statement.Vars...))
+	}
+	db.Callback().Query().After("gorm:query").Register("test:record_query", record)
+	db.Callback().Create().After("gorm:create").Register("test:record_create", record)
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return statements
+}
+
+func TestCreateUserMessageWithinLimitLocksUserRow(t *testing.T) {
+	statements := recordStatements(t)
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	message := &models.ChatMessage{ConversationID: 9, UserID: 3, Role: "user", Content: "이직해도 될까요?"}
+	if _, _, err := (&conversationRepository{}).CreateUserMessageWithinLimit(message, since, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	// 사용자 행을 잠근 뒤 그 사용자가 오늘 보낸 질문을 세고, 같은 트랜잭션에서 저장한다
+	want := []string{
+		"BEGIN",
+		`SELECT "id" FROM "users" WHERE id = 3 AND "users"."deleted_at" IS NULL FOR UPDATE`,
+		`SELECT count(*) FROM "chat_messages" WHERE user_id = 3 AND role = 'user' AND created_at >= '2024-01-01 00:00:00'`,
+		`INSERT INTO "chat_messages"`,
+		"COMMIT",
+	}
+	if len(*statements) != len(want) {
+		t.Fatalf("statements = %q", *statements)
+	}
+	for i, statement := range *statements {
+		if !strings.HasPrefix(statement, want[i]) {
+			t.Errorf("statement %d = %s\nwant %s", i, statement, want[i])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type DailyFortuneRepository interface {
+	Create(fortune *models.DailyFortune) error
+	Update(fortune *models.DailyFortune) error
+	FindByKey(userID uint, fortuneDate, chartVersion, promptVersion string) (*models.DailyFortune, error)
+	FindLatestByDate(userID uint, fortuneDate string) (*models.DailyFortune, error)
+	CountRegenerations(userID uint, fortuneDate string) (int, error)
+	FindActiveUserIDs(sinceDate string, afterUserID uint, limit int) ([]uint, error)
+	CountActiveUsers(sinceDate string) (int, error)
+}
+
+type dailyFortuneRepository struct{}
+
+func NewDailyFortuneRepository() DailyFortuneRepository {
+	return &dailyFortuneRepository{}
+}
+
+func (r *dailyFortuneRepository) Create(fortune *models.DailyFortune) error {
+	return database.DB.Create(fortune).Error
+}
+
+func (r *dailyFortuneRepository) Update(fortune *models.DailyFortune) error {
+	return database.DB.Save(fortune).Error
+}
+
+// 없으면 nil, nil을 반환한다
+func (r *dailyFortuneRepository) FindByKey(userID uint, fortuneDate, chartVersion, promptVersion string) (*models.DailyFortune, error) {
+	var fortunes []models.DailyFortune
+	err := database.DB.
+		Where("user_id = ? AND fortune_date = ? AND chart_version = ? AND prompt_version = ?", userID, fortuneDate, chartVersion, promptVersion).
+		Limit(1).
+		Find(&fortunes).Error
+	if err != nil || len(fortunes) == 0 {
+		return nil, err
+	}
+	return &fortunes[0], nil
+}
+
+// 사주나 프롬프트 버전과 관계없이 그 날짜에 가장 최근 생성된 운세. 없으면 nil, nil을 반환한다
+func (r *dailyFortuneRepository) FindLatestByDate(userID uint, fortuneDate string) (*models.DailyFortune, error) {
+	var fortunes []models.DailyFortune
+	err := database.DB.
+		Where("user_id = ? AND fortune_date = ?", userID, fortuneDate).
+		Order("updated_at DESC").
+		Limit(1).
+		Find(&fortunes).Error
+	if err != nil || len(fortunes) == 0 {
+		return nil, err
+	}
+	return &fortunes[0], nil
+}
+
+func (r *dailyFortuneRepository) CountRegenerations(userID uint, fortuneDate string) (int, error) {
+	var count int
+	err := database.DB.
+		Model(&models.DailyFortune{}).
+		Select("COALESCE(SUM(regenerate_count), 0)").
+		Where("user_id = ? AND fortune_date = ?", userID, fortuneDate).
+		Scan(&count).Error
+	return count, err
+}
+
+// sinceDate 이후에 오늘의 운세를 본 적이 있고 사주 정보가 남아 있는 사용자 (user_id 오름차순)
+func (r *dailyFortuneRepository) FindActiveUserIDs(sinceDate string, afterUserID uint, limit int) ([]uint, error) {
+	var userIDs []uint
+	err := activeUsersQuery(sinceDate).
+		Where("daily_fortunes.user_id > ?", afterUserID).
+		Distinct("daily_fortunes.user_id").
+		Order("daily_fortunes.user_id ASC").
+		Limit(limit).
+		Pluck("daily_fortunes.user_id", &userIDs).Error
+	return userIDs, err
+}
+
+func (r *dailyFortuneRepository) CountActiveUsers(sinceDate string) (int, error) {
+	var count int64
+	err := activeUsersQuery(sinceDate).
+		Distinct("daily_fortunes.user_id").
+		Count(&count).Error
+	return int(count), err
+}
+
+func activeUsersQuery(sinceDate string) *gorm.DB {
+	return database.DB.
+		Model(&models.DailyFortune{}).
+		Joins("INNER JOIN fortune_infos ON fortune_infos.user_id = daily_fortunes.user_id AND fortune_infos.deleted_at IS NULL").
+		Where("daily_fortunes.fortune_date >= ?", sinceDate)
+}
@@ -1,15 +1,52 @@
 package repository
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"dothefortune_server/internal/database"
 	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/utils"
 )
 
+// 매칭 후보 필터. 비어 있는 조건은 적용하지 않는다
+type MatchCandidateFilter struct {
+	Genders      []string
+	MinBirthYear int
+	MaxBirthYear int
+}
+
+// 유사 사주 목록의 커서 (마지막으로 받은 점수와 사용자 ID)
+type SimilarityCursor struct {
+	ScoreTenths int
+	UserID      uint
+}
+
+type SimilarUserScore struct {
+	UserID         uint
+	ScoreTenths    int
+	SimilarityRank int // 같은 점수는 같은 순위 (dense rank)
+}
+
+type MatchScore struct {
+	UserID uint
+	Score  float64
+}
+
 type FortuneRepository interface {
 	Create(fortune *models.FortuneInfo) error
 	FindByUserID(userID uint) (*models.FortuneInfo, error)
 	Update(fortune *models.FortuneInfo) error
-	FindSimilarUsers(userID uint, limit int) ([]models.User, error)
+	UpdateSpouseImageURL(userID uint, imageURL string) error
+	FindSimilarUsers(userID uint, features utils.ChartFeatures, filter MatchCandidateFilter, cursor *SimilarityCursor, limit int) ([]SimilarUserScore, error)
+	BackfillChartFeatures() error
+	FindMatchCandidates(userID uint, filter MatchCandidateFilter, afterID uint, batchSize int) ([]models.User, error)
+	// 궁합 점수 순으로 후보 한 페이지와 조건에 맞는 전체 후보 수. 같은 점수는 사용자 ID 순이다.
+	// lookups는 key로 한 번 저장해 두고 다음 조회부터는 저장된 표를 쓴다
+	FindMatchScores(userID uint, key string, lookups []utils.ScoreLookup, filter MatchCandidateFilter, descending bool, limit, offset int) ([]MatchScore, int64, error)
 }
 
 type fortuneRepository struct{}
@@ -19,6 +56,7 @@ func NewFortuneRepository() FortuneRepository {
 }
 
 func (r *fortuneRepository) Create(fortune *models.FortuneInfo) error {
+	setChartFeatures(fortune)
 	return database.DB.Create(fortune).Error
 }
 
@@ -32,29 +70,269 @@ func (r *fortuneRepository) FindByUserID(userID uint) (*models.FortuneInfo, erro
 }
 
 func (r *fortuneRepository) Update(fortune *models.FortuneInfo) error {
+	setChartFeatures(fortune)
 	return database.DB.Save(fortune).Error
 }
 
-func (r *fortuneRepository) FindSimilarUsers(userID uint, limit int) ([]models.User, error) {
-	var user models.User
-	if err := database.DB.Preload("FortuneInfo").Where("id = ?", userID).First(&user).Error; err != nil {
-		return nil, err
+// 유사도 점수를 10배한 정수로 다룬다 (일 50% + 월 30% + 연 20%, 최대 1000)
+func (r *fortuneRepository) FindSimilarUsers(userID uint, features utils.ChartFeatures, filter MatchCandidateFilter, cursor *SimilarityCursor, limit int) ([]SimilarUserScore, error) {
+	scoreExpr := fmt.Sprintf("5 * %s + 3 * %s + 2 * %s",
+		pillarSimilaritySQL("day_stem_idx", "day_branch_idx", features.DayStem, features.DayBranch),
+		pillarSimilaritySQL("month_stem_idx", "month_branch_idx", features.MonthStem, features.MonthBranch),
+		pillarSimilaritySQL("year_stem_idx", "year_branch_idx", features.YearStem, features.YearBranch),
+	)
+
+	ranked := database.DB.
+		Table("fortune_infos").
+		Select("fortune_infos.user_id AS user_id, ("+scoreExpr+") AS score_tenths, "+
+			"DENSE_RANK() OVER (ORDER BY ("+scoreExpr+") DESC) AS similarity_rank").
+		Joins("INNER JOIN users ON users.id = fortune_infos.user_id AND users.deleted_at IS NULL").
+		Where("fortune_infos.deleted_at IS NULL").
+		Where("fortune_infos.chart_encoded = ?", true).
+		Where("fortune_infos.user_id != ?", userID).
+		Where("users.match_opt_out = ?", false)
+	ranked = applyCandidateFilter(ranked, filter)
+
+	query := database.DB.Table("(?) AS ranked", ranked)
+	if cursor != nil {
+		query = query.Where("score_tenths < ? OR (score_tenths = ? AND user_id > ?)",
+			cursor.ScoreTenths, cursor.ScoreTenths, cursor.UserID)
 	}
 
-	if user.FortuneInfo == nil {
-		return []models.User{}, nil
+	var results []SimilarUserScore
+	err := query.
+		Order("score_tenths DESC, user_id ASC").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
+
+// 같은 글자면 50, 같은 오행이면 25 (천간 오행 = 인덱스 / 2, 지지 오행은 배열 조회).
+// 모르는 글자(-1)는 어느 쪽이든 점수를 받지 않는다. -1 / 2는 0(木)이 되므로 비교 전에 걸러야 한다
+func pillarSimilaritySQL(stemColumn, branchColumn string, stemIdx, branchIdx int) string {
+	branchElements := utils.BranchElementIndexes()
+
+	stemScore := "0"
+	if stemIdx >= 0 {
+		stemScore = fmt.Sprintf(
+			"CASE WHEN fortune_infos.%[1]s = %[2]d THEN 50 WHEN fortune_infos.%[1]s >= 0 AND fortune_infos.%[1]s / 2 = %[3]d THEN 25 ELSE 0 END",
+			stemColumn, stemIdx, stemIdx/2,
+		)
 	}
 
-	var users []models.User
-	err := database.DB.
+	branchScore := "0"
+	if branchIdx >= 0 && branchIdx < len(branchElements) {
+		elementArray := make([]string, len(branchElements))
+		for i, element := range branchElements {
+			elementArray[i] = strconv.Itoa(element)
+		}
+		branchScore = fmt.Sprintf(
+			"CASE WHEN fortune_infos.%[1]s = %[2]d THEN 50 WHEN fortune_infos.%[1]s >= 0 AND (ARRAY[%[4]s])[fortune_infos.%[1]s + 1] = %[3]d THEN 25 ELSE 0 END",
+			branchColumn, branchIdx, branchElements[branchIdx], strings.Join(elementArray, ","),
+		)
+	}
+
+	return "(" + stemScore + " + " + branchScore + ")"
+}
+
+// 특징이 채워지지 않은 기존 사주 정보를 배치로 인코딩한다
+func (r *fortuneRepository) BackfillChartFeatures() error {
+	for {
+		var fortunes []models.FortuneInfo
+		if err := database.DB.Where("chart_encoded = ?", false).Limit(500).Find(&fortunes).Error; err != nil {
+			return err
+		}
+		if len(fortunes) == 0 {
+			return nil
+		}
+
+		for i := range fortunes {
+			setChartFeatures(&fortunes[i])
+			if err := database.DB.Save(&fortunes[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func setChartFeatures(fortune *models.FortuneInfo) {
+	features := utils.EncodeChartFeatures(map[string]string{
+		"year_stem":    fortune.YearHeavenlyStem,
+		"year_branch":  fortune.YearEarthlyBranch,
+		"month_stem":   fortune.MonthHeavenlyStem,
+		"month_branch": fortune.MonthEarthlyBranch,
+		"day_stem":     fortune.DayHeavenlyStem,
+		"day_branch":   fortune.DayEarthlyBranch,
+		"hour_stem":    fortune.HourHeavenlyStem,
+		"hour_branch":  fortune.HourEarthlyBranch,
+	})
+
+	fortune.YearStemIdx = features.YearStem
+	fortune.YearBranchIdx = features.YearBranch
+	fortune.MonthStemIdx = features.MonthStem
+	fortune.MonthBranchIdx = features.MonthBranch
+	fortune.DayStemIdx = features.DayStem
+	fortune.DayBranchIdx = features.DayBranch
+	fortune.HourStemIdx = features.HourStem
+	fortune.HourBranchIdx = features.HourBranch
+	fortune.WoodCount = features.Elements[0]
+	fortune.FireCount = features.Elements[1]
+	fortune.EarthCount = features.Elements[2]
+	fortune.MetalCount = features.Elements[3]
+	fortune.WaterCount = features.Elements[4]
+	fortune.ChartEncoded = true
+}
+
+// 사주 정보가 있고 매칭을 거부하지 않은 사용자를 ID 순으로 batchSize명씩 가져온다 (afterID 다음부터)
+func (r *fortuneRepository) FindMatchCandidates(userID uint, filter MatchCandidateFilter, afterID uint, batchSize int) ([]models.User, error) {
+	query := database.DB.
 		Preload("FortuneInfo").
-		Joins("INNER JOIN fortune_infos ON fortune_infos.user_id = users.id").
+		Joins("INNER JOIN fortune_infos ON fortune_infos.user_id = users.id AND fortune_infos.deleted_at IS NULL").
 		Where("users.id != ?", userID).
-		Where("fortune_infos.day_heavenly_stem = ? OR fortune_infos.day_earthly_branch = ?",
-			user.FortuneInfo.DayHeavenlyStem, user.FortuneInfo.DayEarthlyBranch).
-		Limit(limit).
-		Find(&users).Error
+		Where("users.id > ?", afterID).
+		Where("users.match_opt_out = ?", false)
+	query = applyCandidateFilter(query, filter)
 
+	var users []models.User
+	err := query.
+		Order("users.id ASC").
+		Limit(batchSize).
+		Find(&users).Error
 	return users, err
 }
 
+// 점수는 조회표마다 후보 특징 값의 칸을 조인해 더한 뒤 0~100으로 자른 값이다 (utils.RelationScoreLookups)
+func (r *fortuneRepository) FindMatchScores(userID uint, key string, lookups []utils.ScoreLookup, filter MatchCandidateFilter, descending bool, limit, offset int) ([]MatchScore, int64, error) {
+	if err := saveScoreLookups(key, lookups); err != nil {
+		return nil, 0, err
+	}
+
+	// Count가 문장에 남긴 SELECT count(*)가 목록 조회로 이어지지 않게 매번 새로 만든다
+	candidates := func() *gorm.DB {
+		query := database.DB.
+			Table("fortune_infos").
+			Joins("INNER JOIN users ON users.id = fortune_infos.user_id AND users.deleted_at IS NULL").
+			Where("fortune_infos.deleted_at IS NULL").
+			Where("fortune_infos.chart_encoded = ?", true).
+			Where("fortune_infos.user_id != ?", userID).
+			Where("users.match_opt_out = ?", false)
+		return applyCandidateFilter(query, filter)
+	}
+
+	var total int64
+	if err := candidates().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query := candidates()
+	terms := make([]string, len(lookups))
+	for i, lookup := range lookups {
+		query = query.Joins(scoreLookupJoinSQL(i, lookup), key)
+		terms[i] = fmt.Sprintf("COALESCE(l%d.value, 0)", i)
+	}
+	scoreExpr := "0"
+	if len(terms) > 0 {
+		scoreExpr = strings.Join(terms, " + ")
+	}
+	order := "score ASC, user_id ASC"
+	if descending {
+		order = "score DESC, user_id ASC"
+	}
+
+	var scores []MatchScore
+	err := query.
+		Select("fortune_infos.user_id AS user_id, LEAST(100, GREATEST(0, " + scoreExpr + "))::float8 AS score").
+		Order(order).
+		Limit(limit).
+		Offset(offset).
+		Scan(&scores).Error
+	return scores, total, err
+}
+
+// 조회표를 저장할 때 한 번에 넣는 행 수
+const scoreLookupBatchSize = 1000
+
+// key의 조회표가 없으면 채운다. 같은 key를 동시에 채워도 같은 값이므로 겹치는 행은 건너뛴다
+func saveScoreLookups(key string, lookups []utils.ScoreLookup) error {
+	var saved int64
+	if err := database.DB.Model(&models.MatchScoreLookup{}).Where("table_key = ?", key).Count(&saved).Error; err != nil {
+		return err
+	}
+	if saved > 0 {
+		return nil
+	}
+
+	var rows []models.MatchScoreLookup
+	for term, lookup := range lookups {
+		for position, value := range lookup.Values {
+			// 0점 칸은 조인이 비어도 COALESCE로 0이 되므로 저장하지 않는다
+			if value == 0 {
+				continue
+			}
+			rows = append(rows, models.MatchScoreLookup{TableKey: key, Term: term, Position: position, Value: value})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, scoreLookupBatchSize).Error
+	})
+}
+
+// term번째 조회표에서 후보 특징 값으로 펼친 칸을 붙인다. 자리표시자 하나에 조회표 키가 들어간다
+func scoreLookupJoinSQL(term int, lookup utils.ScoreLookup) string {
+	index := make([]string, len(lookup.Features))
+	stride := 1
+	for i := len(lookup.Features) - 1; i >= 0; i-- {
+		index[i] = fmt.Sprintf("%s * %d", partnerFeatureSQL(lookup.Features[i]), stride)
+		stride *= utils.PartnerFeatureSize(lookup.Features[i])
+	}
+	return fmt.Sprintf("LEFT JOIN match_score_lookups AS l%d ON l%d.table_key = ? AND l%d.term = %d AND l%d.position = %s",
+		term, term, term, term, term, strings.Join(index, " + "))
+}
+
+// 상대 특징을 0부터 세는 SQL 값으로 바꾼다
+func partnerFeatureSQL(feature string) string {
+	switch feature {
+	case utils.PartnerGender:
+		return "(CASE users.gender WHEN 'M' THEN 1 WHEN 'F' THEN 2 ELSE 0 END)"
+	case utils.PartnerWealthCount:
+		return stemElementCountSQL(utils.StemWealthElementIndexes())
+	case utils.PartnerOfficerCount:
+		return stemElementCountSQL(utils.StemOfficerElementIndexes())
+	}
+	// 천간/지지 인덱스 (없음 -1을 0으로)
+	return "(fortune_infos." + feature + "_idx + 1)"
+}
+
+// 일간마다 정해지는 오행(재성, 관성)의 개수. 일간이 없으면 0
+func stemElementCountSQL(elementIndexes []int) string {
+	positions := make([]string, len(elementIndexes))
+	for i, element := range elementIndexes {
+		positions[i] = strconv.Itoa(element + 1)
+	}
+	return fmt.Sprintf("COALESCE((ARRAY[fortune_infos.wood_count, fortune_infos.fire_count, fortune_infos.earth_count, fortune_infos.metal_count, fortune_infos.water_count])[(ARRAY[%s])[fortune_infos.day_stem_idx + 2]], 0)",
+		strings.Join(positions, ","))
+}
+
+// users, fortune_infos가 조인된 쿼리에 성별과 출생 연도 조건을 건다
+func applyCandidateFilter(query *gorm.DB, filter MatchCandidateFilter) *gorm.DB {
+	if len(filter.Genders) > 0 {
+		query = query.Where("users.gender IN ?", filter.Genders)
+	}
+	if filter.MinBirthYear > 0 {
+		query = query.Where("fortune_infos.birth_year >= ?", filter.MinBirthYear)
+	}
+	if filter.MaxBirthYear > 0 {
+		query = query.Where("fortune_infos.birth_year <= ?", filter.MaxBirthYear)
+	}
+	return query
+}
+
+// 생성이 끝난 배우자 이미지 URL만 바꾼다 (사주 정보 수정과 겹쳐도 다른 컬럼을 덮어쓰지 않게)
+func (r *fortuneRepository) UpdateSpouseImageURL(userID uint, imageURL string) error {
+	return database.DB.Model(&models.FortuneInfo{}).
+		Where("user_id = ?", userID).
+		Update("spouse_image_url", imageURL).Error
+}
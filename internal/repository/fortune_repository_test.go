@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/utils"
+)
+
+func TestPillarSimilaritySQLSkipsUnknownCharacters(t *testing.T) {
+	// 모르는 글자면 그 자리는 상수 0이어야 한다. 열 값과 비교하면 -1 = -1이 같은 글자로 잡힌다
+	if got := pillarSimilaritySQL("day_stem_idx", "day_branch_idx", -1, -1); got != "(0 + 0)" {
+		t.Errorf("unknown pillar: got %s, want (0 + 0)", got)
+	}
+
+	got := pillarSimilaritySQL("day_stem_idx", "day_branch_idx", 2, -1)
+	// 후보의 -1은 -1 / 2 = 0(木)이 되므로 오행 비교 앞에 걸러야 한다
+	if !strings.Contains(got, "fortune_infos.day_stem_idx >= 0 AND fortune_infos.day_stem_idx / 2 = 1") {
+		t.Errorf("stem element comparison is not guarded: %s", got)
+	}
+	if !strings.HasSuffix(got, " + 0)") {
+		t.Errorf("unknown branch should add 0: %s", got)
+	}
+
+	got = pillarSimilaritySQL("day_stem_idx", "day_branch_idx", -1, 6)
+	if !strings.HasPrefix(got, "(0 + ") || !strings.Contains(got, "fortune_infos.day_branch_idx >= 0 AND (ARRAY[") {
+		t.Errorf("branch element comparison is not guarded: %s", got)
+	}
+}
+
+func TestPillarSimilaritySQLElementsMatchUtils(t *testing.T) {
+	// SQL은 천간 오행을 인덱스 / 2로, 지지 오행을 배열로 구한다. utils의 오행과 같아야 한다
+	stems := []string{"甲", "乙", "丙", "丁", "戊", "己", "庚", "辛", "壬", "癸"}
+	for i, stem := range stems {
+		want := fmt.Sprintf("/ 2 = %d THEN 25", utils.ElementIndex(utils.GetElement(stem)))
+		if got := pillarSimilaritySQL("day_stem_idx", "day_branch_idx", i, -1); !strings.Contains(got, want) {
+			t.Errorf("stem %s: %s does not contain %q", stem, got, want)
+		}
+	}
+
+	branches := []string{"子", "丑", "寅", "卯", "辰", "巳", "午", "未", "申", "酉", "戌", "亥"}
+	for i, branch := range branches {
+		want := fmt.Sprintf("+ 1] = %d THEN 25", utils.ElementIndex(utils.GetElement(branch)))
+		if got := pillarSimilaritySQL("day_stem_idx", "day_branch_idx", -1, i); !strings.Contains(got, want) {
+			t.Errorf("branch %s: %s does not contain %q", branch, got, want)
+		}
+	}
+}
+
+func TestScoreLookupJoinSQLUsesStoredTable(t *testing.T) {
+	lookup := utils.ScoreLookup{Features: []string{utils.PartnerDayStem, utils.PartnerDayBranch, utils.PartnerGender}}
+	got := scoreLookupJoinSQL(4, lookup)
+
+	// 값은 match_score_lookups에서 읽고 SQL에는 키 자리표시자만 들어간다
+	if strings.Contains(got, "ARRAY[") || strings.Count(got, "?") != 1 {
+		t.Fatalf("join should reference the stored table by key only: %s", got)
+	}
+	if !strings.HasPrefix(got, "LEFT JOIN match_score_lookups AS l4 ON l4.table_key = ? AND l4.term = 4 AND ") {
+		t.Errorf("unexpected join: %s", got)
+	}
+	// 마지막 특징이 가장 빨리 바뀐다 (utils.newScoreLookup과 같은 순서)
+	want := "l4.position = (fortune_infos.day_stem_idx + 1) * 39 + (fortune_infos.day_branch_idx + 1) * 3 + (CASE users.gender WHEN 'M' THEN 1 WHEN 'F' THEN 2 ELSE 0 END) * 1"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("position expression:\n got %s\nwant suffix %s", got, want)
+	}
+}
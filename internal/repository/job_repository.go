@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+var ErrJobLockLost = errors.New("job lock lost")
+
+type JobFilter struct {
+	Status string
+	Type   string
+}
+
+type JobRepository interface {
+	Create(job *models.Job) error
+	Claim(workerID string, types []string, now time.Time) (*models.Job, error)
+	MarkCompleted(job *models.Job, now time.Time) error
+	MarkRetry(job *models.Job, lastError string, runAt time.Time) error
+	MarkDead(job *models.Job, lastError string, now time.Time) error
+	ReleaseStale(lockedBefore time.Time) (int64, error)
+	FindByID(id uint) (*models.Job, error)
+	FindAll(filter JobFilter, limit, offset int) ([]models.Job, int64, error)
+	CountByStatus() (map[string]int64, error)
+	Retry(id uint, now time.Time) (*models.Job, error)
+}
+
+type jobRepository struct{}
+
+func NewJobRepository() JobRepository {
+	return &jobRepository{}
+}
+
+func (r *jobRepository) Create(job *models.Job) error {
+	return database.DB.Create(job).Error
+}
+
+// 실행할 때가 된 작업 하나를 잠그고 running으로 바꾼다. 다른 워커가 잠근 행은 건너뛰므로
+// 여러 프로세스가 동시에 꺼내도 같은 작업을 두 번 가져가지 않는다. 없으면 nil, nil을 반환한다
+func (r *jobRepository) Claim(workerID string, types []string, now time.Time) (*models.Job, error) {
+	var claimed *models.Job
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var jobs []models.Job
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ? AND type IN ?", models.JobStatusPending, now, types).
+			Order("run_at ASC, id ASC").
+			Limit(1).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		job := &jobs[0]
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedBy = workerID
+		job.LockedAt = &now
+		if err := tx.Save(job).Error; err != nil {
+			return err
+		}
+		claimed = job
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *jobRepository) MarkCompleted(job *models.Job, now time.Time) error {
+	err := r.finish(job, map[string]interface{}{
+		"status":       models.JobStatusCompleted,
+		"last_error":   "",
+		"completed_at": now,
+	})
+	if err != nil {
+		return err
+	}
+	job.Status = models.JobStatusCompleted
+	job.LastError = ""
+	job.CompletedAt = &now
+	return nil
+}
+
+func (r *jobRepository) MarkRetry(job *models.Job, lastError string, runAt time.Time) error {
+	err := r.finish(job, map[string]interface{}{
+		"status":     models.JobStatusPending,
+		"last_error": lastError,
+		"run_at":     runAt,
+	})
+	if err != nil {
+		return err
+	}
+	job.Status = models.JobStatusPending
+	job.LastError = lastError
+	job.RunAt = runAt
+	return nil
+}
+
+func (r *jobRepository) MarkDead(job *models.Job, lastError string, now time.Time) error {
+	err := r.finish(job, map[string]interface{}{
+		"status":       models.JobStatusDead,
+		"last_error":   lastError,
+		"completed_at": now,
+	})
+	if err != nil {
+		return err
+	}
+	job.Status = models.JobStatusDead
+	job.LastError = lastError
+	job.CompletedAt = &now
+	return nil
+}
+
+// 이 워커가 아직 잠그고 있을 때만 결과를 쓰고 잠금을 푼다. 잠금이 만료돼 다른 워커가 다시 가져간 작업은
+// 그 워커의 결과를 덮어쓰지 않도록 ErrJobLockLost를 반환한다
+func (r *jobRepository) finish(job *models.Job, updates map[string]interface{}) error {
+	updates["locked_by"] = ""
+	updates["locked_at"] = nil
+	result := database.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobStatusRunning, job.LockedBy).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLockLost
+	}
+	job.LockedBy = ""
+	job.LockedAt = nil
+	return nil
+}
+
+// 워커가 죽어 running으로 남은 작업을 다시 pending으로 돌린다 (이미 센 시도 횟수는 그대로 둔다)
+func (r *jobRepository) ReleaseStale(lockedBefore time.Time) (int64, error) {
+	result := database.DB.Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", models.JobStatusRunning, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":     models.JobStatusPending,
+			"locked_by":  "",
+			"locked_at":  nil,
+			"last_error": "worker lock expired",
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *jobRepository) FindByID(id uint) (*models.Job, error) {
+	var job models.Job
+	err := database.DB.Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// 최신순 목록과 필터에 맞는 전체 개수
+func (r *jobRepository) FindAll(filter JobFilter, limit, offset int) ([]models.Job, int64, error) {
+	// Count가 문장에 남긴 SELECT count(*)가 목록 조회로 이어지지 않게 매번 새로 만든다
+	filtered := func() *gorm.DB {
+		query := database.DB.Model(&models.Job{})
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		if filter.Type != "" {
+			query = query.Where("type = ?", filter.Type)
+		}
+		return query
+	}
+
+	var total int64
+	if err := filtered().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []models.Job
+	err := filtered().Order("id DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, total, err
+}
+
+func (r *jobRepository) CountByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := database.DB.Model(&models.Job{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// dead이거나 재시도를 기다리는 작업을 시도 횟수를 비우고 바로 실행되게 한다. 대상이 아니면 nil, nil을 반환한다
+func (r *jobRepository) Retry(id uint, now time.Time) (*models.Job, error) {
+	result := database.DB.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []string{models.JobStatusDead, models.JobStatusPending}).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusPending,
+			"attempts":     0,
+			"run_at":       now,
+			"completed_at": nil,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return r.FindByID(id)
+}
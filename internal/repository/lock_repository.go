@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"dothefortune_server/internal/database"
+)
+
+// Postgres advisory lock으로 여러 서버 중 한 곳에서만 일을 하게 한다
+type LockRepository interface {
+	// 잡으면 release와 true를 반환한다. 세션 단위 잠금이라 잡은 연결이 끊기면(프로세스가 죽어도) 풀린다
+	TryLock(ctx context.Context, key int64) (func(), bool, error)
+}
+
+type lockRepository struct{}
+
+func NewLockRepository() LockRepository {
+	return &lockRepository{}
+}
+
+func (r *lockRepository) TryLock(ctx context.Context, key int64) (func(), bool, error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	// 잠금은 연결에 묶이므로 풀 때까지 같은 연결을 붙잡아 둔다
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// 풀지 못했으면 연결을 풀에 돌려주지 않고 닫아서 서버가 잠금을 정리하게 한다
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
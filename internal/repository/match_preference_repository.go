@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type MatchPreferenceRepository interface {
+	FindByUserID(userID uint) (*models.MatchPreference, error)
+	Save(preference *models.MatchPreference) error
+}
+
+type matchPreferenceRepository struct{}
+
+func NewMatchPreferenceRepository() MatchPreferenceRepository {
+	return &matchPreferenceRepository{}
+}
+
+// 저장된 선호가 없으면 nil, nil을 반환한다
+func (r *matchPreferenceRepository) FindByUserID(userID uint) (*models.MatchPreference, error) {
+	var preferences []models.MatchPreference
+	err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&preferences).Error
+	if err != nil || len(preferences) == 0 {
+		return nil, err
+	}
+	return &preferences[0], nil
+}
+
+func (r *matchPreferenceRepository) Save(preference *models.MatchPreference) error {
+	return database.DB.Save(preference).Error
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type PartnerContactRepository interface {
+	Create(contact *models.PartnerContact) error
+	FindByID(userID, contactID uint) (*models.PartnerContact, error)
+	FindByUserID(userID uint) ([]models.PartnerContact, error)
+	Delete(userID, contactID uint) error
+}
+
+type partnerContactRepository struct{}
+
+func NewPartnerContactRepository() PartnerContactRepository {
+	return &partnerContactRepository{}
+}
+
+func (r *partnerContactRepository) Create(contact *models.PartnerContact) error {
+	return database.DB.Create(contact).Error
+}
+
+func (r *partnerContactRepository) FindByID(userID, contactID uint) (*models.PartnerContact, error) {
+	var contact models.PartnerContact
+	err := database.DB.Where("id = ? AND user_id = ?", contactID, userID).First(&contact).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+func (r *partnerContactRepository) FindByUserID(userID uint) ([]models.PartnerContact, error) {
+	var contacts []models.PartnerContact
+	err := database.DB.
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&contacts).Error
+	return contacts, err
+}
+
+func (r *partnerContactRepository) Delete(userID, contactID uint) error {
+	return database.DB.Where("id = ? AND user_id = ?", contactID, userID).Delete(&models.PartnerContact{}).Error
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type PregenerationRunRepository interface {
+	Create(run *models.PregenerationRun) error
+	Update(run *models.PregenerationRun) error
+	FindByKey(fortuneDate, timezone string) (*models.PregenerationRun, error)
+	FindRecent(limit int) ([]models.PregenerationRun, error)
+}
+
+type pregenerationRunRepository struct{}
+
+func NewPregenerationRunRepository() PregenerationRunRepository {
+	return &pregenerationRunRepository{}
+}
+
+func (r *pregenerationRunRepository) Create(run *models.PregenerationRun) error {
+	return database.DB.Create(run).Error
+}
+
+func (r *pregenerationRunRepository) Update(run *models.PregenerationRun) error {
+	return database.DB.Save(run).Error
+}
+
+// 없으면 nil, nil을 반환한다
+func (r *pregenerationRunRepository) FindByKey(fortuneDate, timezone string) (*models.PregenerationRun, error) {
+	var runs []models.PregenerationRun
+	err := database.DB.
+		Where("fortune_date = ? AND timezone = ?", fortuneDate, timezone).
+		Limit(1).
+		Find(&runs).Error
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func (r *pregenerationRunRepository) FindRecent(limit int) ([]models.PregenerationRun, error) {
+	var runs []models.PregenerationRun
+	err := database.DB.Order("fortune_date DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
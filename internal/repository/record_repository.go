@@ -1,12 +1,19 @@
 package repository
 
 import (
+	"gorm.io/gorm/clause"
+
 	"dothefortune_server/internal/database"
 	"dothefortune_server/internal/models"
 )
 
 type RecordRepository interface {
 	Create(record *models.FortuneRecord) error
+	// record.JobID로 이미 저장한 기록이 있으면 아무것도 하지 않는다
+	CreateForJob(record *models.FortuneRecord) error
+	FindByJobID(jobID uint) (*models.FortuneRecord, error)
+	Update(record *models.FortuneRecord) error
+	FindByID(userID, recordID uint) (*models.FortuneRecord, error)
 	FindByUserID(userID uint, limit int) ([]models.FortuneRecord, error)
 	FindByUserIDAndType(userID uint, recordType string, limit int) ([]models.FortuneRecord, error)
 }
@@ -21,6 +28,35 @@ func (r *recordRepository) Create(record *models.FortuneRecord) error {
 	return database.DB.Create(record).Error
 }
 
+func (r *recordRepository) CreateForJob(record *models.FortuneRecord) error {
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job_id"}},
+		DoNothing: true,
+	}).Create(record).Error
+}
+
+func (r *recordRepository) FindByJobID(jobID uint) (*models.FortuneRecord, error) {
+	var record models.FortuneRecord
+	err := database.DB.Where("job_id = ?", jobID).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *recordRepository) Update(record *models.FortuneRecord) error {
+	return database.DB.Save(record).Error
+}
+
+func (r *recordRepository) FindByID(userID, recordID uint) (*models.FortuneRecord, error) {
+	var record models.FortuneRecord
+	err := database.DB.Where("id = ? AND user_id = ?", recordID, userID).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
 func (r *recordRepository) FindByUserID(userID uint, limit int) ([]models.FortuneRecord, error) {
 	var records []models.FortuneRecord
 	err := database.DB.
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	FindByID(id uint) (*models.Session, error)
+	// 끊기지 않고 만료되지 않은 세션을 최근 사용 순으로
+	FindActiveByUserID(userID uint, now time.Time) ([]models.Session, error)
+	IsActive(id uint, now time.Time) (bool, error)
+	// refresh 토큰 해시가 oldHash일 때만 새 토큰으로 바꾸고 oldHash를 직전 해시로 남긴다. 동시에 같은 토큰으로 갱신하면 한 요청만 true를 받는다
+	Rotate(id uint, oldHash, newHash, userAgent, ip string, now, expiresAt time.Time) (bool, error)
+	Revoke(id uint, reason string, now time.Time) error
+	// exceptID를 뺀 사용자의 모든 세션을 끊고 끊은 개수를 반환한다
+	RevokeByUserID(userID, exceptID uint, reason string, now time.Time) (int64, error)
+}
+
+type sessionRepository struct{}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{}
+}
+
+func (r *sessionRepository) Create(session *models.Session) error {
+	return database.DB.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id uint) (*models.Session, error) {
+	var session models.Session
+	err := database.DB.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) FindActiveByUserID(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepository) IsActive(id uint, now time.Time) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, now).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *sessionRepository) Rotate(id uint, oldHash, newHash, userAgent, ip string, now, expiresAt time.Time) (bool, error) {
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":          newHash,
+			"previous_refresh_token_hash": oldHash,
+			"user_agent":                  userAgent,
+			"ip":                          ip,
+			"last_used_at":                now,
+			"expires_at":                  expiresAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *sessionRepository) Revoke(id uint, reason string, now time.Time) error {
+	return database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error
+}
+
+func (r *sessionRepository) RevokeByUserID(userID, exceptID uint, reason string, now time.Time) (int64, error) {
+	result := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
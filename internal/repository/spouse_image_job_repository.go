@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"dothefortune_server/internal/database"
+	"dothefortune_server/internal/models"
+)
+
+type SpouseImageJobRepository interface {
+	Create(job *models.SpouseImageJob) error
+	Update(job *models.SpouseImageJob) error
+	FindByID(userID, id uint) (*models.SpouseImageJob, error)
+	FindActiveByUserID(userID uint) (*models.SpouseImageJob, error)
+}
+
+type spouseImageJobRepository struct{}
+
+func NewSpouseImageJobRepository() SpouseImageJobRepository {
+	return &spouseImageJobRepository{}
+}
+
+func (r *spouseImageJobRepository) Create(job *models.SpouseImageJob) error {
+	return database.DB.Create(job).Error
+}
+
+func (r *spouseImageJobRepository) Update(job *models.SpouseImageJob) error {
+	return database.DB.Save(job).Error
+}
+
+func (r *spouseImageJobRepository) FindByID(userID, id uint) (*models.SpouseImageJob, error) {
+	var job models.SpouseImageJob
+	err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// 아직 끝나지 않은(pending, running) 작업. 없으면 nil, nil을 반환한다
+func (r *spouseImageJobRepository) FindActiveByUserID(userID uint) (*models.SpouseImageJob, error) {
+	var jobs []models.SpouseImageJob
+	err := database.DB.
+		Where("user_id = ? AND status IN ?", userID, []string{"pending", "running"}).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
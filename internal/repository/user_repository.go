@@ -8,6 +8,7 @@ import (
 type UserRepository interface {
 	Create(user *models.User) error
 	FindByID(id uint) (*models.User, error)
+	FindByIDs(ids []uint) ([]models.User, error)
 	FindByEmail(email string) (*models.User, error)
 	Update(user *models.User) error
 }
@@ -31,6 +32,15 @@ func (r *userRepository) FindByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) FindByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := database.DB.Preload("FortuneInfo").Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
 	err := database.DB.Where("email = ?", email).First(&user).Error
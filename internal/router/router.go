@@ -1,6 +1,9 @@
 package router
 
 import (
+	"context"
+	"log"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -13,7 +16,35 @@ import (
 	"dothefortune_server/internal/service"
 )
 
-func SetupRouter(cfg *config.Config) *gin.Engine {
+// 서버와 함께 도는 백그라운드 작업 (작업 큐 워커, 오늘의 운세 예약 생성)
+type Background struct {
+	WorkerPool   *service.WorkerPool
+	Pregenerator service.DailyPregenerator
+}
+
+func (b *Background) Start(ctx context.Context) {
+	b.WorkerPool.Start(ctx)
+	b.Pregenerator.Start(ctx)
+}
+
+// ctx가 끝날 때까지 멈추지 않으면 기다리지 않고 ctx.Err()를 반환한다. 끝내지 못한 작업은 잠금이 풀린 뒤 다른 워커가 다시 실행한다
+func (b *Background) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.Pregenerator.Stop()
+		b.WorkerPool.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 라우터와 함께 백그라운드 작업을 돌려준다 (시작과 종료는 호출하는 쪽에서 한다)
+func SetupRouter(cfg *config.Config) (*gin.Engine, *Background) {
 	gin.SetMode(cfg.GinMode)
 
 	r := gin.Default()
@@ -21,7 +52,8 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Accept-Language"}
+	corsConfig.ExposeHeaders = []string{"Content-Language"}
 	corsConfig.AllowCredentials = true
 	r.Use(cors.New(corsConfig))
 
@@ -29,17 +61,56 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	fortuneRepo := repository.NewFortuneRepository()
 	recordRepo := repository.NewRecordRepository()
 	compatibilityRepo := repository.NewCompatibilityRepository()
+	partnerContactRepo := repository.NewPartnerContactRepository()
+	matchPreferenceRepo := repository.NewMatchPreferenceRepository()
+	dailyFortuneRepo := repository.NewDailyFortuneRepository()
+	conversationRepo := repository.NewConversationRepository()
+	spouseImageJobRepo := repository.NewSpouseImageJobRepository()
+	jobRepo := repository.NewJobRepository()
+	pregenerationRunRepo := repository.NewPregenerationRunRepository()
+	lockRepo := repository.NewLockRepository()
+	aiUsageRepo := repository.NewAIUsageRepository()
+	sessionRepo := repository.NewSessionRepository()
 
-	authService := service.NewAuthService(userRepo, fortuneRepo)
-	aiService := service.NewAIService(fortuneRepo, userRepo, cfg)
-	fortuneService := service.NewFortuneService(fortuneRepo, recordRepo, aiService)
-	compatibilityService := service.NewCompatibilityService(compatibilityRepo, fortuneRepo, recordRepo)
+	jobQueue := service.NewJobQueue(jobRepo, cfg)
+	authService := service.NewAuthService(userRepo, fortuneRepo, sessionRepo, cfg)
+	aiUsageService := service.NewAIUsageService(aiUsageRepo, cfg)
+	llmProvider, err := service.NewLLMProvider(aiUsageService, cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure LLM provider: %v", err)
+	}
+	promptStore, err := service.NewPromptStore(cfg.PromptsDir, cfg.PromptLocale)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	imageProvider, err := service.NewImageProvider(aiUsageService, cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure image provider: %v", err)
+	}
+	fileStorage, err := service.NewFileStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure file storage: %v", err)
+	}
+	aiService := service.NewAIService(llmProvider, promptStore, cfg)
+	fortuneService := service.NewFortuneService(fortuneRepo, userRepo, recordRepo, compatibilityRepo, matchPreferenceRepo, dailyFortuneRepo, aiService, aiUsageService, cfg)
+	compatibilityService := service.NewCompatibilityService(compatibilityRepo, fortuneRepo, userRepo, recordRepo, partnerContactRepo, matchPreferenceRepo, aiService, aiUsageService, jobQueue, cfg)
 	recordService := service.NewRecordService(recordRepo, fortuneRepo)
+	chatService := service.NewChatService(conversationRepo, fortuneRepo, recordRepo, aiService, aiUsageService, cfg)
+	spouseImageService := service.NewSpouseImageService(spouseImageJobRepo, userRepo, fortuneRepo, recordRepo, promptStore, imageProvider, fileStorage, jobQueue, aiUsageService, cfg)
+	jobAdminService := service.NewJobAdminService(jobRepo)
+
+	workerPool := service.NewWorkerPool(jobRepo, cfg)
+	service.RegisterJobHandlers(workerPool, recordRepo, spouseImageService)
+	pregenerator := service.NewDailyPregenerator(pregenerationRunRepo, dailyFortuneRepo, lockRepo, fortuneService, cfg)
 
 	authHandler := handler.NewAuthHandler(authService)
 	fortuneHandler := handler.NewFortuneHandler(fortuneService)
 	compatibilityHandler := handler.NewCompatibilityHandler(compatibilityService)
-	recordHandler := handler.NewRecordHandler(recordService)
+	recordHandler := handler.NewRecordHandler(recordService, spouseImageService)
+	chatHandler := handler.NewChatHandler(chatService)
+	adminHandler := handler.NewAdminHandler(promptStore, jobAdminService, pregenerator, aiUsageService)
+
+	authMiddleware := middleware.AuthMiddleware(authService.IsSessionActive)
 
 	api := r.Group("/api/v1")
 	{
@@ -47,18 +118,24 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", authHandler.Logout)
-			auth.GET("/me", middleware.AuthMiddleware(), authHandler.GetMe)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authMiddleware, authHandler.Logout)
+			auth.GET("/me", authMiddleware, authHandler.GetMe)
+			auth.PUT("/me/locale", authMiddleware, authHandler.UpdateLocale)
+			auth.GET("/sessions", authMiddleware, authHandler.GetSessions)
+			auth.DELETE("/sessions", authMiddleware, authHandler.RevokeOtherSessions)
+			auth.DELETE("/sessions/:id", authMiddleware, authHandler.RevokeSession)
 		}
 
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(authMiddleware, middleware.LocaleMiddleware(authService.PreferredLocale))
 		{
 			fortune := protected.Group("/fortune")
 			{
 				fortune.POST("/info", fortuneHandler.CreateOrUpdateFortuneInfo)
 				fortune.GET("/info", fortuneHandler.GetFortuneInfo)
 				fortune.GET("/today", fortuneHandler.GetTodayFortune)
+				fortune.GET("/today/stream", fortuneHandler.StreamTodayFortune)
 				fortune.GET("/similar", fortuneHandler.GetSimilarUsers)
 				fortune.GET("/similar-matches", fortuneHandler.GetSimilarUserMatches)
 			}
@@ -69,27 +146,73 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 				compatibility.GET("/", compatibilityHandler.GetCompatibility)
 				compatibility.GET("/best", compatibilityHandler.GetBestMatches)
 				compatibility.GET("/worst", compatibilityHandler.GetWorstMatches)
+				compatibility.GET("/narrative/stream", compatibilityHandler.StreamCompatibilityNarrative)
+				compatibility.PUT("/opt-out", compatibilityHandler.SetMatchOptOut)
+				compatibility.GET("/preferences", compatibilityHandler.GetMatchPreference)
+				compatibility.PUT("/preferences", compatibilityHandler.UpdateMatchPreference)
+				compatibility.POST("/guest", compatibilityHandler.CalculateGuestCompatibility)
+				compatibility.GET("/contacts", compatibilityHandler.GetContacts)
+				compatibility.GET("/contacts/:id", compatibilityHandler.CalculateContactCompatibility)
+				compatibility.DELETE("/contacts/:id", compatibilityHandler.DeleteContact)
 			}
 
 			records := protected.Group("/records")
 			{
 				// 구체적인 경로를 먼저 정의
 				records.GET("/spouse-image", recordHandler.GetSpouseImage)
+				records.POST("/spouse-image", recordHandler.RequestSpouseImage)
+				records.GET("/spouse-image/jobs/:id", recordHandler.GetSpouseImageJob)
 				// 그 다음 정적 경로
 				records.POST("", recordHandler.CreateRecord)
 				records.GET("", recordHandler.GetRecentRecords)
 				// 마지막으로 동적 경로
 				records.GET("/:type", recordHandler.GetRecordsByType)
 			}
+
+			chat := protected.Group("/chat")
+			{
+				chat.POST("/conversations", chatHandler.CreateConversation)
+				chat.GET("/conversations", chatHandler.GetConversations)
+				chat.GET("/conversations/:id", chatHandler.GetConversation)
+				chat.DELETE("/conversations/:id", chatHandler.DeleteConversation)
+				chat.POST("/conversations/:id/messages", chatHandler.SendMessage)
+				chat.POST("/conversations/:id/messages/stream", chatHandler.StreamMessage)
+			}
+
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(cfg.AdminEmails))
+			{
+				admin.GET("/prompts", adminHandler.GetPrompts)
+				admin.POST("/prompts/reload", adminHandler.ReloadPrompts)
+				admin.GET("/jobs", adminHandler.GetJobs)
+				admin.GET("/jobs/:id", adminHandler.GetJob)
+				admin.POST("/jobs/:id/retry", adminHandler.RetryJob)
+				admin.GET("/pregeneration/runs", adminHandler.GetPregenerationRuns)
+				admin.GET("/ai-usage", adminHandler.GetAIUsage)
+			}
 		}
 	}
 
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		health := gin.H{"status": "ok"}
+		// AI 회로가 열려 있어도 서버는 규칙 기반 문장으로 응답하므로 200을 유지한다
+		if reporter, ok := llmProvider.(service.LLMHealthReporter); ok {
+			llmHealth := reporter.Health()
+			health["llm"] = llmHealth
+			if llmHealth.Circuit != service.CircuitClosed {
+				health["status"] = "degraded"
+			}
+		}
+		c.JSON(200, health)
 	})
 
+	// 로컬 저장소에 만든 이미지 파일
+	if cfg.StorageBackend == service.StorageBackendLocal {
+		r.Static(cfg.StoragePublicURL, cfg.StorageDir)
+	}
+
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	return r
+	return r, &Background{WorkerPool: workerPool, Pregenerator: pregenerator}
 }
 
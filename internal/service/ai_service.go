@@ -1,120 +1,542 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
 	"dothefortune_server/internal/config"
-	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/utils"
+)
+
+// 오늘의 운세 생성 방식
+const (
+	AIFortuneModeStructured  = "structured"   // JSON 한 번 호출
+	AIFortuneModePerCategory = "per_category" // 카테고리마다 한 번씩 호출
 )
 
+// 궁합 카테고리별 분석 작성 방식 (설정값이면서 Compatibility.NarrativeSource에 저장되는 값)
+const (
+	AICompatibilityModeTemplate = "template" // 고정 문구
+	AICompatibilityModeAI       = "ai"       // 두 사주와 적용된 규칙으로 AI가 작성
+
+	NarrativeSourceTemplate = AICompatibilityModeTemplate
+	NarrativeSourceAI       = AICompatibilityModeAI
+)
+
+// 한 필드에 허용하는 최대 글자 수 (모델이 장황하게 답해도 잘라낸다)
+const maxFortuneTextRunes = 300
+
+// 프롬프트와 생성 문장 검사는 요청 로케일을 따른다. 궁합은 Compatibility.Locale을 쓴다
 type AIService interface {
-	GenerateFortuneText(fortuneMap map[string]string, todayStem, todayBranch string, category string) (string, error)
+	GenerateFortuneText(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, category, locale string) (string, error)
+	GenerateDailyFortune(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error)
+	DailyFortunePromptVersion(locale string) string
+	StreamDailyFortune(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string, onDelta func(delta string) error) (*DailyFortuneTexts, error)
+	GenerateCompatibilityAnalysis(ctx context.Context, input CompatibilityAnalysisInput) (*CompatibilityAnalysisTexts, error)
+	// 생성한 이야기와 사용한 프롬프트 버전을 반환한다
+	StreamCompatibilityNarrative(ctx context.Context, compatibility *models.Compatibility, onDelta func(delta string) error) (string, string, error)
+	StreamChatReply(ctx context.Context, input ChatPromptInput, onDelta func(delta string) error) (string, string, int, error)
+	SummarizeConversation(ctx context.Context, locale, summary string, messages []models.ChatMessage) (string, error)
+}
+
+// 오늘의 운세 문장. 비어 있는 필드는 호출하는 쪽에서 규칙 기반 문장으로 채운다
+type DailyFortuneTexts struct {
+	TotalFortune  string `json:"total_fortune"`
+	WealthFortune string `json:"wealth_fortune"`
+	LoveFortune   string `json:"love_fortune"`
+	HealthFortune string `json:"health_fortune"`
+
+	PromptVersion string `json:"-"` // 생성에 사용한 프롬프트 템플릿 버전
 }
 
 type aiService struct {
-	cfg *config.Config
+	llmProvider   LLMProvider
+	promptStore   PromptStore
+	fortuneMode   string
+	filterRetries int // 검사를 통과하지 못했을 때 다시 생성하는 횟수
 }
 
-func NewAIService(fortuneRepo repository.FortuneRepository, userRepo repository.UserRepository, cfg *config.Config) AIService {
+func NewAIService(llmProvider LLMProvider, promptStore PromptStore, cfg *config.Config) AIService {
 	return &aiService{
-		cfg: cfg,
+		llmProvider:   llmProvider,
+		promptStore:   promptStore,
+		fortuneMode:   cfg.AIFortuneMode,
+		filterRetries: cfg.AIFilterRetries,
 	}
 }
 
-func (s *aiService) GenerateFortuneText(fortuneMap map[string]string, todayStem, todayBranch string, category string) (string, error) {
-	if s.cfg.GeminiAPIKey == "" {
-		return "", errors.New("Gemini API key not configured")
+func (s *aiService) GenerateFortuneText(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, category, locale string) (string, error) {
+	prompt, _, err := s.promptStore.Render(PromptFortuneCategory, locale, newFortunePromptData(fortuneMap, todayStem, todayBranch, category, locale))
+	if err != nil {
+		return "", err
 	}
 
-	prompt := s.buildFortunePrompt(fortuneMap, todayStem, todayBranch, category)
-	return s.callGemini(prompt)
+	var rejection error
+	for attempt := 0; attempt <= s.filterRetries; attempt++ {
+		text, err := s.llmProvider.Generate(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		checked, err := filterGeneratedText(text, fortuneTextPolicy, locale)
+		if err == nil {
+			return checked, nil
+		}
+		rejection = err
+	}
+	return "", rejection
 }
 
-func (s *aiService) buildFortunePrompt(fortuneMap map[string]string, todayStem, todayBranch string, category string) string {
-	dayStem := fortuneMap["day_stem"]
-	dayBranch := fortuneMap["day_branch"]
+func (s *aiService) dailyFortuneUseCase() string {
+	if s.fortuneMode == AIFortuneModePerCategory {
+		return PromptFortuneCategory
+	}
+	return PromptDailyFortune
+}
+
+// 템플릿 버전(로케일 포함)과 제공자를 합친 버전 (템플릿, 로케일, 제공자가 바뀌면 캐시를 새로 만든다)
+func (s *aiService) DailyFortunePromptVersion(locale string) string {
+	return s.promptStore.ActiveVersion(s.dailyFortuneUseCase(), locale) + "+" + s.llmProvider.Name()
+}
 
-	basePrompt := fmt.Sprintf(
-		"당신은 따뜻하고 희망찬 조언을 주는 운세 AI입니다. 사용자의 사주 정보(일간: %s%s, 오늘의 일진: %s%s)를 바탕으로 %s에 대한 운세를 1~2문장으로 작성해주세요. 말투는 '~해요', '~할 수 있어요' 등 부드러운 경어체를 사용하고, 부정적인 운일 경우 '조심하세요'보다는 '잠시 쉬어가는 게 좋아요'처럼 우회적으로 표현해주세요.",
-		dayStem, dayBranch, todayStem, todayBranch, category,
-	)
+// 총운/재물운/애정운/건강운을 만든다. 일부 필드만 채워졌으면 오류 없이 채워진 만큼 반환한다
+func (s *aiService) GenerateDailyFortune(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+	if s.fortuneMode == AIFortuneModePerCategory {
+		return s.generateDailyFortunePerCategory(ctx, fortuneMap, todayStem, todayBranch, locale)
+	}
 
-	return basePrompt
+	prompt, version, err := s.promptStore.Render(PromptDailyFortune, locale, newFortunePromptData(fortuneMap, todayStem, todayBranch, "", locale))
+	if err != nil {
+		return nil, err
+	}
+	texts := &DailyFortuneTexts{PromptVersion: version}
+	err = s.generateCheckedFields(ctx, prompt, newDailyFortuneSchema(locale), dailyFortuneKeyAliases, texts.fields(), fortuneTextPolicy, locale)
+	if err != nil {
+		return nil, err
+	}
+	return texts, nil
 }
 
-type GeminiRequest struct {
-	Contents []struct {
-		Parts []struct {
-			Text string `json:"text"`
-		} `json:"parts"`
-	} `json:"contents"`
+func (t *DailyFortuneTexts) fields() map[string]*string {
+	return map[string]*string{
+		"total_fortune":  &t.TotalFortune,
+		"wealth_fortune": &t.WealthFortune,
+		"love_fortune":   &t.LoveFortune,
+		"health_fortune": &t.HealthFortune,
+	}
 }
 
-type GeminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
+// 구조화 응답의 필드마다 검사한다. 통과하지 못한 필드가 있으면 다시 생성해 빈 필드만 채운다 (최대 filterRetries번).
+// 끝까지 통과하지 못한 필드는 비워 두어 호출하는 쪽의 대체 문장을 쓰게 한다
+func (s *aiService) generateCheckedFields(ctx context.Context, prompt string, schema ResponseSchema, aliases map[string]string, targets map[string]*string, policy TextPolicy, locale string) error {
+	for attempt := 0; attempt <= s.filterRetries; attempt++ {
+		raw, err := s.llmProvider.GenerateStructured(ctx, prompt, schema)
+		var fields map[string]interface{}
+		if err == nil {
+			fields, err = parseLooseJSONObject(raw)
+		}
+		if err != nil {
+			// 다시 생성하다 실패하면 앞에서 통과한 필드만 쓴다
+			if attempt > 0 {
+				return nil
+			}
+			return err
+		}
+
+		candidates := make(map[string]*string, len(targets))
+		for key := range targets {
+			candidates[key] = new(string)
+		}
+		fillTextFields(fields, aliases, candidates)
+
+		missing := 0
+		for key, field := range targets {
+			if *field == "" && *candidates[key] != "" {
+				if checked, err := filterGeneratedText(*candidates[key], policy, locale); err == nil {
+					*field = checked
+				}
+			}
+			if *field == "" {
+				missing++
+			}
+		}
+		if missing == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// 이미 사용자에게 흘려보낸 문장은 다시 생성할 수 없으므로, 검사를 통과하지 못한 필드만 비운다
+func checkFields(targets map[string]*string, policy TextPolicy, locale string) {
+	for _, field := range targets {
+		if *field == "" {
+			continue
+		}
+		checked, err := filterGeneratedText(*field, policy, locale)
+		if err != nil {
+			checked = ""
+		}
+		*field = checked
+	}
+}
+
+func (s *aiService) generateDailyFortunePerCategory(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+	texts := &DailyFortuneTexts{PromptVersion: s.promptStore.ActiveVersion(PromptFortuneCategory, locale)}
+	fields := texts.fields()
+
+	var lastErr error
+	generated := 0
+	for _, section := range dailyFortuneSections {
+		text, err := s.GenerateFortuneText(ctx, fortuneMap, todayStem, todayBranch, i18n.T(locale, section.label), locale)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		*fields[section.key] = text
+		generated++
+	}
+
+	if generated == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return texts, nil
+}
+
+// 오늘의 운세 네 항목. 라벨은 i18n 메시지 ID (per_category 프롬프트의 항목 이름, 스트리밍 응답의 줄 머리)
+var dailyFortuneSections = []struct {
+	label string
+	key   string
+}{
+	{"fortune.section.total", "total_fortune"},
+	{"fortune.section.wealth", "wealth_fortune"},
+	{"fortune.section.love", "love_fortune"},
+	{"fortune.section.health", "health_fortune"},
 }
 
-func (s *aiService) callGemini(prompt string) (string, error) {
-	reqBody := GeminiRequest{
-		Contents: []struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		}{
-			{
-				Parts: []struct {
-					Text string `json:"text"`
-				}{
-					{Text: prompt},
-				},
+// 필드 설명도 생성 언어로 쓴다
+func newDailyFortuneSchema(locale string) ResponseSchema {
+	return ResponseSchema{
+		Name: "daily_fortune",
+		Schema: &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"total_fortune":  {Type: "string", Description: i18n.T(locale, "fortune.schema.total")},
+				"wealth_fortune": {Type: "string", Description: i18n.T(locale, "fortune.schema.wealth")},
+				"love_fortune":   {Type: "string", Description: i18n.T(locale, "fortune.schema.love")},
+				"health_fortune": {Type: "string", Description: i18n.T(locale, "fortune.schema.health")},
 			},
+			Required: []string{"total_fortune", "wealth_fortune", "love_fortune", "health_fortune"},
 		},
 	}
+}
+
+// 모델이 다른 이름으로 답한 키도 받아준다 (모든 로케일의 항목 라벨 포함)
+var dailyFortuneKeyAliases = withLocalizedAliases(map[string]string{
+	"total_fortune": "total_fortune", "totalfortune": "total_fortune", "total": "total_fortune",
+	"wealth_fortune": "wealth_fortune", "wealthfortune": "wealth_fortune", "wealth": "wealth_fortune",
+	"love_fortune": "love_fortune", "lovefortune": "love_fortune", "love": "love_fortune",
+	"health_fortune": "health_fortune", "healthfortune": "health_fortune", "health": "health_fortune",
+}, dailyFortuneSections)
+
+func withLocalizedAliases(aliases map[string]string, sections []struct {
+	label string
+	key   string
+}) map[string]string {
+	for _, section := range sections {
+		for _, label := range i18n.All(section.label) {
+			aliases[strings.ToLower(label)] = section.key
+		}
+	}
+	return aliases
+}
+
+var trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
 
-	jsonData, err := json.Marshal(reqBody)
+// 코드 펜스, 앞뒤 설명, 끝의 쉼표, 잘린 닫는 괄호를 고쳐가며 JSON 객체를 읽는다
+func parseLooseJSONObject(raw string) (map[string]interface{}, error) {
+	text := strings.TrimSpace(raw)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	start := strings.Index(text, "{")
+	if start < 0 {
+		return nil, errors.New("no JSON object in response")
+	}
+	text = text[start:]
+	if end := strings.LastIndex(text, "}"); end >= 0 {
+		text = text[:end+1]
+	}
+	text = trailingCommaPattern.ReplaceAllString(text, "$1")
+
+	var fields map[string]interface{}
+	var err error
+	for _, candidate := range []string{text, text + "}", text + "\"}"} {
+		if err = json.Unmarshal([]byte(candidate), &fields); err == nil {
+			break
+		}
+	}
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("invalid JSON in response: %w", err)
 	}
+	return fields, nil
+}
+
+// 별칭으로 키를 맞춰 문자열 값을 채운다. 같은 필드에 여러 키가 오면 먼저 읽은 값을 쓴다
+func fillTextFields(fields map[string]interface{}, aliases map[string]string, targets map[string]*string) {
+	for key, value := range fields {
+		field, ok := targets[aliases[strings.ToLower(strings.TrimSpace(key))]]
+		if !ok || *field != "" {
+			continue
+		}
+		if str, ok := value.(string); ok {
+			*field = cleanFortuneText(str)
+		}
+	}
+}
+
+// 공백을 정리하고 너무 긴 문장은 자른다
+func cleanFortuneText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > maxFortuneTextRunes {
+		text = string(runes[:maxFortuneTextRunes])
+	}
+	return text
+}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/gemini-pro:generateContent?key=%s", s.cfg.GeminiAPIKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// 스트리밍은 사람이 읽을 수 있는 "총운: ..." 형식으로 받아 화면에 바로 보여주고, 끝난 뒤 필드로 나눈다
+func (s *aiService) StreamDailyFortune(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string, onDelta func(delta string) error) (*DailyFortuneTexts, error) {
+	prompt, version, err := s.promptStore.Render(PromptDailyFortuneStream, locale, newFortunePromptData(fortuneMap, todayStem, todayBranch, "", locale))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	stream := newFilteredStream(dailyFortuneStreamPolicy, locale, onDelta)
+	if _, err := s.llmProvider.GenerateStream(ctx, prompt, stream.write); err != nil {
+		return nil, err
+	}
+	// 거절된 문장 뒤로는 보내지 않았으므로 보낸 부분만 필드로 나눈다. 채우지 못한 필드는 규칙 기반 문장이 채운다
+	if _, err := stream.finish(); err != nil {
+		if _, ok := err.(*TextRejection); !ok {
+			return nil, err
+		}
 	}
+	texts := parseDailyFortuneSections(stream.sentText())
+	texts.PromptVersion = version
+	checkFields(texts.fields(), fortuneTextPolicy, locale)
+	return texts, nil
+}
+
+// "총운: ...", "**총운**: ...", "[총운] ..." 형식의 줄을 필드로 나눈다 (라벨은 어느 로케일이든 받는다). 라벨 없는 줄은 앞 필드에 이어 붙인다
+func parseDailyFortuneSections(text string) *DailyFortuneTexts {
+	sections := make(map[string]string)
+	current := ""
 
-	req.Header.Set("Content-Type", "application/json")
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(strings.TrimSpace(line), "-*#[ ")
+		matched := false
+		for _, section := range dailyFortuneSections {
+			label, ok := matchSectionLabel(trimmed, section.label)
+			if !ok {
+				continue
+			}
+			rest := strings.TrimLeft(trimmed[len(label):], "*]):： ")
+			current = section.key
+			sections[current] = rest
+			matched = true
+			break
+		}
+		if !matched && current != "" && strings.TrimSpace(line) != "" {
+			sections[current] += " " + strings.TrimSpace(line)
+		}
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return &DailyFortuneTexts{
+		TotalFortune:  cleanFortuneText(sections["total_fortune"]),
+		WealthFortune: cleanFortuneText(sections["wealth_fortune"]),
+		LoveFortune:   cleanFortuneText(sections["love_fortune"]),
+		HealthFortune: cleanFortuneText(sections["health_fortune"]),
+	}
+}
+
+// 줄이 어느 로케일의 라벨로 시작하는지 (영어 라벨은 대소문자를 가리지 않는다)
+func matchSectionLabel(line, labelID string) (string, bool) {
+	for _, label := range i18n.All(labelID) {
+		if len(line) >= len(label) && strings.EqualFold(line[:len(label)], label) {
+			return line[:len(label)], true
+		}
+	}
+	return "", false
+}
+
+func (s *aiService) StreamCompatibilityNarrative(ctx context.Context, compatibility *models.Compatibility, onDelta func(delta string) error) (string, string, error) {
+	prompt, version, err := s.promptStore.Render(PromptCompatibilityNarrative, compatibility.Locale, newCompatibilityPromptData(compatibility))
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	stream := newFilteredStream(narrativeTextPolicy, compatibility.Locale, onDelta)
+	if _, err := s.llmProvider.GenerateStream(ctx, prompt, stream.write); err != nil {
+		// 도중에 끊겼어도 이미 보낸 문장이 있으면 그 뒤에 템플릿 문구를 잇지 않고 보낸 만큼을 쓴다
+		if ctx.Err() != nil || strings.TrimSpace(stream.sentText()) == "" {
+			return "", version, err
+		}
+		log.Printf("Compatibility narrative stream ended early: %v", err)
+	}
+	// 검사를 통과한 앞부분을 보냈으면 그것을 이야기로 쓴다. 하나도 보내지 못했을 때만 오류를 돌려 템플릿 문구를 보내게 한다
+	checked, err := stream.finish()
+	if checked == "" {
+		return "", version, err
 	}
-	defer resp.Body.Close()
+	return checked, version, nil
+}
+
+// 궁합 프롬프트 템플릿에 넘기는 값. 사주와 규칙은 카테고리별 분석을 쓸 때만 채운다
+type compatibilityPromptData struct {
+	RelationLabel         string
+	Score                 float64
+	CompatibilityType     string
+	Categories            []string // "항목 점수점" 형식, 코드순
+	Analysis              string
+	CommunicationAnalysis string
+	EmotionAnalysis       string
+	LifestyleAnalysis     string
+	CautionAnalysis       string
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Gemini API error: %s", string(body))
+	Person1 chartPromptData
+	Person2 chartPromptData
+	Rules   []string
+}
+
+type chartPromptData struct {
+	Gender         string // 남성, 여성 또는 빈 값
+	YearPillar     string
+	MonthPillar    string
+	DayPillar      string
+	HourPillar     string
+	DayElement     string
+	ElementSummary string
+}
+
+func newChartPromptData(fortuneMap map[string]string, gender, locale string) chartPromptData {
+	genderLabel := ""
+	if gender == "M" || gender == "F" {
+		genderLabel = i18n.T(locale, "gender."+gender)
 	}
 
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", err
+	return chartPromptData{
+		Gender:         genderLabel,
+		YearPillar:     fortuneMap["year_stem"] + fortuneMap["year_branch"],
+		MonthPillar:    fortuneMap["month_stem"] + fortuneMap["month_branch"],
+		DayPillar:      fortuneMap["day_stem"] + fortuneMap["day_branch"],
+		HourPillar:     fortuneMap["hour_stem"] + fortuneMap["hour_branch"],
+		DayElement:     utils.GetElement(fortuneMap["day_stem"]),
+		ElementSummary: elementSummary(utils.GetFiveElements(fortuneMap)),
+	}
+}
+
+func newCompatibilityPromptData(compatibility *models.Compatibility) compatibilityPromptData {
+	codes := make([]string, 0, len(compatibility.CategoryScores))
+	for code := range compatibility.CategoryScores {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	categories := make([]string, len(codes))
+	for i, code := range codes {
+		categories[i] = i18n.T(compatibility.Locale, "category.score", i18n.T(compatibility.Locale, "category."+code), compatibility.CategoryScores[code])
+	}
+
+	return compatibilityPromptData{
+		RelationLabel:         i18n.T(compatibility.Locale, "relation."+compatibility.RelationType),
+		Score:                 compatibility.Score,
+		CompatibilityType:     compatibility.CompatibilityType,
+		Categories:            categories,
+		Analysis:              compatibility.Analysis,
+		CommunicationAnalysis: compatibility.CommunicationAnalysis,
+		EmotionAnalysis:       compatibility.EmotionAnalysis,
+		LifestyleAnalysis:     compatibility.LifestyleAnalysis,
+		CautionAnalysis:       compatibility.CautionAnalysis,
 	}
+}
+
+// 궁합 카테고리별 분석 생성에 넘기는 값 (Compatibility는 점수와 카테고리 점수가 채워진 상태)
+type CompatibilityAnalysisInput struct {
+	Compatibility *models.Compatibility
+	Fortune1      map[string]string
+	Fortune2      map[string]string
+	Gender1       string
+	Gender2       string
+	Rules         []string // 점수와 문구 선택에 적용된 규칙 (Compatibility.Locale로 쓴 문구)
+}
+
+// 대화/감정/생활/주의 문단. 비어 있는 필드는 호출하는 쪽에서 템플릿 문구를 그대로 쓴다
+type CompatibilityAnalysisTexts struct {
+	Communication string `json:"communication"`
+	Emotion       string `json:"emotion"`
+	Lifestyle     string `json:"lifestyle"`
+	Caution       string `json:"caution"`
+
+	PromptVersion string `json:"-"`
+}
+
+func (s *aiService) GenerateCompatibilityAnalysis(ctx context.Context, input CompatibilityAnalysisInput) (*CompatibilityAnalysisTexts, error) {
+	data := newCompatibilityPromptData(input.Compatibility)
+	locale := input.Compatibility.Locale
+	data.Person1 = newChartPromptData(input.Fortune1, input.Gender1, locale)
+	data.Person2 = newChartPromptData(input.Fortune2, input.Gender2, locale)
+	data.Rules = input.Rules
 
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", errors.New("no text in response")
+	prompt, version, err := s.promptStore.Render(PromptCompatibilityAnalysis, locale, data)
+	if err != nil {
+		return nil, err
 	}
+	texts := &CompatibilityAnalysisTexts{PromptVersion: version}
+	err = s.generateCheckedFields(ctx, prompt, newCompatibilityAnalysisSchema(locale), compatibilityAnalysisKeyAliases, map[string]*string{
+		"communication": &texts.Communication,
+		"emotion":       &texts.Emotion,
+		"lifestyle":     &texts.Lifestyle,
+		"caution":       &texts.Caution,
+	}, analysisTextPolicy, locale)
+	if err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
 
-	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+func newCompatibilityAnalysisSchema(locale string) ResponseSchema {
+	return ResponseSchema{
+		Name: "compatibility_analysis",
+		Schema: &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"communication": {Type: "string", Description: i18n.T(locale, "compat.schema.communication")},
+				"emotion":       {Type: "string", Description: i18n.T(locale, "compat.schema.emotion")},
+				"lifestyle":     {Type: "string", Description: i18n.T(locale, "compat.schema.lifestyle")},
+				"caution":       {Type: "string", Description: i18n.T(locale, "compat.schema.caution")},
+			},
+			Required: []string{"communication", "emotion", "lifestyle", "caution"},
+		},
+	}
 }
 
+var compatibilityAnalysisKeyAliases = withLocalizedAliases(map[string]string{
+	"communication": "communication", "communication_analysis": "communication",
+	"emotion": "emotion", "emotion_analysis": "emotion",
+	"lifestyle": "lifestyle", "lifestyle_analysis": "lifestyle",
+	"caution": "caution", "caution_analysis": "caution",
+}, []struct {
+	label string
+	key   string
+}{
+	{"compat.section.communication", "communication"},
+	{"compat.section.emotion", "emotion"},
+	{"compat.section.lifestyle", "lifestyle"},
+	{"compat.section.caution", "caution"},
+})
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/utils"
+)
+
+// 구조화 응답을 차례로 돌려주는 백엔드. 준비한 응답이 떨어지면 마지막 것을 되풀이한다
+type structuredReplies struct {
+	replies []string
+	err     error
+	calls   int
+	prompts []string
+	schemas []ResponseSchema
+}
+
+func (p *structuredReplies) Name() string { return "scripted" }
+
+func (p *structuredReplies) Generate(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("not used")
+}
+
+func (p *structuredReplies) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	p.calls++
+	p.prompts = append(p.prompts, prompt)
+	p.schemas = append(p.schemas, schema)
+	if p.err != nil {
+		return "", p.err
+	}
+	if p.calls > len(p.replies) {
+		return p.replies[len(p.replies)-1], nil
+	}
+	return p.replies[p.calls-1], nil
+}
+
+func (p *structuredReplies) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	return "", errors.New("not used")
+}
+
+func newTestAIService(t *testing.T, provider LLMProvider, cfg *config.Config) AIService {
+	t.Helper()
+	store, err := NewPromptStore(filepath.Join("..", "..", "prompts"), i18n.DefaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAIService(provider, store, cfg)
+}
+
+var testChart = map[string]string{
+	"year_stem": "庚", "year_branch": "午",
+	"month_stem": "辛", "month_branch": "巳",
+	"day_stem": "甲", "day_branch": "子",
+	"hour_stem": "丙", "hour_branch": "寅",
+}
+
+func TestParseLooseJSONObject(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"plain", `{"total": "좋아요"}`},
+		{"code fence", "```json\n{\"total\": \"좋아요\"}\n```"},
+		{"surrounding prose", `오늘의 운세입니다: {"total": "좋아요"} 참고하세요.`},
+		{"trailing comma", `{"total": "좋아요",}`},
+		{"missing closing brace", `{"total": "좋아요"`},
+		{"cut inside a string", `{"total": "좋아요`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := parseLooseJSONObject(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fields["total"] != "좋아요" {
+				t.Errorf("fields = %v", fields)
+			}
+		})
+	}
+
+	if _, err := parseLooseJSONObject("오늘은 좋은 날이에요"); err == nil {
+		t.Error("text without an object should fail")
+	}
+}
+
+func TestGenerateDailyFortuneInOneStructuredCall(t *testing.T) {
+	const (
+		total  = "오늘은 차분하게 하루를 시작하면 좋은 흐름이 이어져요."
+		wealth = "작은 지출을 줄이면 재물이 조금씩 모여요."
+		love   = "가까운 사람에게 먼저 안부를 전해보세요. 마음이 통해요."
+		health = "가벼운 산책으로 몸을 풀어주면 한결 가벼워져요."
+	)
+	provider := &structuredReplies{replies: []string{
+		// 라벨 키, 다른 이름, 코드 펜스도 받아준다
+		"```json\n{\"총운\": \"" + total + "\", \"wealth\": \"" + wealth + "\", \"LoveFortune\": \"" + love + "\", \"health_fortune\": \"" + health + "\",}\n```",
+	}}
+	service := newTestAIService(t, provider, &config.Config{AIFortuneMode: AIFortuneModeStructured, AIFilterRetries: 1})
+
+	texts, err := service.GenerateDailyFortune(context.Background(), testChart, "丙", "午", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("calls = %d, want one structured call", provider.calls)
+	}
+	if texts.TotalFortune != total || texts.WealthFortune != wealth || texts.LoveFortune != love || texts.HealthFortune != health {
+		t.Errorf("texts = %+v", texts)
+	}
+	if !strings.HasPrefix(texts.PromptVersion, PromptDailyFortune+"/ko/") {
+		t.Errorf("prompt version %q", texts.PromptVersion)
+	}
+	if schema := provider.schemas[0]; schema.Name != "daily_fortune" || len(schema.Schema.Required) != 4 {
+		t.Errorf("schema = %+v", schema)
+	}
+}
+
+func TestGenerateDailyFortuneRepairsMissingFields(t *testing.T) {
+	const (
+		total  = "오늘은 차분하게 하루를 시작하면 좋은 흐름이 이어져요."
+		wealth = "작은 지출을 줄이면 재물이 조금씩 모여요."
+		health = "가벼운 산책으로 몸을 풀어주면 한결 가벼워져요."
+		other  = "다시 만든 총운은 쓰지 않아요. 먼저 통과한 문장을 지켜요."
+	)
+
+	t.Run("retry fills only the empty fields", func(t *testing.T) {
+		provider := &structuredReplies{replies: []string{
+			// 애정운이 없고 건강운은 반말이라 검사를 통과하지 못한다
+			`{"total_fortune": "` + total + `", "wealth_fortune": "` + wealth + `", "health_fortune": "운동을 해라."}`,
+			`{"total_fortune": "` + other + `", "health_fortune": "` + health + `"}`,
+		}}
+		service := newTestAIService(t, provider, &config.Config{AIFilterRetries: 1})
+
+		texts, err := service.GenerateDailyFortune(context.Background(), testChart, "丙", "午", "ko")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if provider.calls != 2 {
+			t.Errorf("calls = %d, want 2", provider.calls)
+		}
+		if texts.TotalFortune != total || texts.WealthFortune != wealth || texts.HealthFortune != health || texts.LoveFortune != "" {
+			t.Errorf("texts = %+v", texts)
+		}
+	})
+
+	t.Run("broken retry keeps earlier fields", func(t *testing.T) {
+		provider := &structuredReplies{replies: []string{`{"total_fortune": "` + total + `"}`, "죄송해요"}}
+		service := newTestAIService(t, provider, &config.Config{AIFilterRetries: 1})
+
+		texts, err := service.GenerateDailyFortune(context.Background(), testChart, "丙", "午", "ko")
+		if err != nil || texts.TotalFortune != total || texts.WealthFortune != "" {
+			t.Errorf("texts = %+v, err = %v", texts, err)
+		}
+	})
+
+	t.Run("first reply unusable", func(t *testing.T) {
+		provider := &structuredReplies{replies: []string{"죄송해요, 지금은 답할 수 없어요"}}
+		service := newTestAIService(t, provider, &config.Config{AIFilterRetries: 1})
+
+		if _, err := service.GenerateDailyFortune(context.Background(), testChart, "丙", "午", "ko"); err == nil {
+			t.Error("reply without JSON should fail so the caller falls back")
+		}
+	})
+}
+
+func TestDailyFortuneFallsBackPerField(t *testing.T) {
+	service := &fortuneService{}
+	const wealth = "작은 지출을 줄이면 재물이 조금씩 모여요."
+
+	// 빠진 필드만 규칙 기반 문장으로 채운다
+	daily, version := service.generateDailyFortune(context.Background(), 1, testChart, "丙", "午", "ko",
+		func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+			return &DailyFortuneTexts{WealthFortune: wealth, PromptVersion: "daily_fortune/ko/v1"}, nil
+		})
+	if version != "daily_fortune/ko/v1" {
+		t.Errorf("prompt version %q", version)
+	}
+	if daily.WealthFortune != wealth {
+		t.Errorf("AI wealth fortune replaced: %q", daily.WealthFortune)
+	}
+	if daily.TotalFortune != utils.GetTodayFortune(testChart, "ko") || daily.LoveFortune != i18n.T("ko", "fortune.fallback.love") || daily.HealthFortune != i18n.T("ko", "fortune.fallback.health") {
+		t.Errorf("fallbacks = %+v", daily)
+	}
+	if daily.LuckyColor == "" || len(daily.LuckyNumbers) == 0 {
+		t.Errorf("lucky values missing: %+v", daily)
+	}
+
+	// 아무것도 만들지 못했으면 프롬프트 버전을 비워 AI 문장이 아님을 알린다
+	_, version = service.generateDailyFortune(context.Background(), 1, testChart, "丙", "午", "en",
+		func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+			return nil, errors.New("upstream unavailable")
+		})
+	if version != "" {
+		t.Errorf("prompt version %q after failed generation", version)
+	}
+}
+
+func TestParseDailyFortuneSections(t *testing.T) {
+	text := "**총운**: 오늘은 차분하게 시작하세요.\n" +
+		"이어지는 줄은 앞 항목에 붙어요.\n" +
+		"[Wealth] 지출을 줄이면 좋아요.\n" +
+		"- 恋愛運： 마음을 먼저 전해보세요.\n" +
+		"\n" +
+		"# HEALTH) 가벼운 산책이 좋아요."
+	texts := parseDailyFortuneSections(text)
+
+	want := DailyFortuneTexts{
+		TotalFortune:  "오늘은 차분하게 시작하세요. 이어지는 줄은 앞 항목에 붙어요.",
+		WealthFortune: "지출을 줄이면 좋아요.",
+		LoveFortune:   "마음을 먼저 전해보세요.",
+		HealthFortune: "가벼운 산책이 좋아요.",
+	}
+	if *texts != want {
+		t.Errorf("got %+v\nwant %+v", *texts, want)
+	}
+}
+
+func TestStreamDailyFortuneForwardsAndSplitsSections(t *testing.T) {
+	const (
+		total  = "오늘은 차분하게 하루를 시작하면 좋은 흐름이 이어져요."
+		wealth = "작은 지출을 줄이면 재물이 조금씩 모여요."
+		love   = "가까운 사람에게 먼저 안부를 전해보세요."
+		health = "가벼운 산책으로 몸을 풀어주면 한결 가벼워져요."
+	)
+	reply := "총운: " + total + "\n재물운: " + wealth + "\n애정운: " + love + "\n건강운: " + health
+	provider := streamFunc(func(onDelta func(string) error) (string, error) {
+		// 여러 글자씩 잘라 보낸다
+		runes := []rune(reply)
+		for start := 0; start < len(runes); start += 7 {
+			end := start + 7
+			if end > len(runes) {
+				end = len(runes)
+			}
+			if err := onDelta(string(runes[start:end])); err != nil {
+				return "", err
+			}
+		}
+		return reply, nil
+	})
+	service := newTestAIService(t, provider, &config.Config{})
+
+	var sent strings.Builder
+	texts, err := service.StreamDailyFortune(context.Background(), testChart, "丙", "午", "ko", func(delta string) error {
+		sent.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(sent.String()) != reply {
+		t.Errorf("sent %q, want %q", sent.String(), reply)
+	}
+	if texts.TotalFortune != total || texts.WealthFortune != wealth || texts.LoveFortune != love || texts.HealthFortune != health {
+		t.Errorf("texts = %+v", texts)
+	}
+	if !strings.HasPrefix(texts.PromptVersion, PromptDailyFortuneStream+"/ko/") {
+		t.Errorf("prompt version %q", texts.PromptVersion)
+	}
+}
+
+func TestGenerateCompatibilityAnalysisPrompt(t *testing.T) {
+	const (
+		communication = "두 분은 서로의 말을 끝까지 들어주는 편이라 대화가 편안해요."
+		caution       = "바쁜 시기에는 서운함을 쌓아두지 말고 바로 이야기하는 게 좋아요."
+	)
+	provider := &structuredReplies{replies: []string{
+		`{"대화": "` + communication + `", "caution_analysis": "` + caution + `"}`,
+	}}
+	service := newTestAIService(t, provider, &config.Config{})
+	partner := map[string]string{
+		"year_stem": "壬", "year_branch": "申",
+		"month_stem": "癸", "month_branch": "丑",
+		"day_stem": "戊", "day_branch": "午",
+	}
+	compatibility := &models.Compatibility{
+		Score:          78.5,
+		RelationType:   utils.RelationRomantic,
+		Locale:         "ko",
+		CategoryScores: map[string]float64{"communication": 80, "emotion": 70, "lifestyle": 65, "caution": 55},
+	}
+
+	texts, err := service.GenerateCompatibilityAnalysis(context.Background(), CompatibilityAnalysisInput{
+		Compatibility: compatibility,
+		Fortune1:      testChart,
+		Fortune2:      partner,
+		Gender1:       "M",
+		Gender2:       "F",
+		Rules:         []string{"일지 子午충"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 통과한 문단만 채우고 나머지는 템플릿 문구를 쓰도록 비워 둔다
+	if texts.Communication != communication || texts.Caution != caution || texts.Emotion != "" || texts.Lifestyle != "" {
+		t.Errorf("texts = %+v", texts)
+	}
+	if !strings.HasPrefix(texts.PromptVersion, PromptCompatibilityAnalysis+"/ko/") {
+		t.Errorf("prompt version %q", texts.PromptVersion)
+	}
+
+	// 두 사주, 점수, 적용된 규칙이 모두 프롬프트에 들어간다
+	prompt := provider.prompts[0]
+	for _, part := range []string{"일주 甲子", "일주 戊午", "시주 丙寅", "시주 모름", "78.5점", "일지 子午충", i18n.T("ko", "gender.F")} {
+		if !strings.Contains(prompt, part) {
+			t.Errorf("prompt does not contain %q:\n%s", part, prompt)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// AI 호출을 일으킨 기능 (사용량 보고에서 묶는 단위)
+const (
+	AIFeatureDailyFortune           = "daily_fortune"
+	AIFeatureDailyPregeneration     = "daily_pregeneration"
+	AIFeatureCompatibilityAnalysis  = "compatibility_analysis"
+	AIFeatureCompatibilityNarrative = "compatibility_narrative"
+	AIFeatureChat                   = "chat"
+	AIFeatureChatSummary            = "chat_summary"
+	AIFeatureSpouseImage            = "spouse_image"
+)
+
+const (
+	AIQuotaScopeUser      = "user"
+	AIQuotaScopeGlobal    = "global"
+	AIQuotaPeriodDaily    = "daily"
+	AIQuotaPeriodMonthly  = "monthly"
+	aiUsageErrorMaxLength = 500
+	aiReportMaxDays       = 92
+)
+
+// 전체 한도는 모든 요청이 같은 값을 보므로 잠시 캐시해 두고 쓴다
+const globalQuotaCacheTTL = 30 * time.Second
+
+// 사용량 한도를 넘은 경우. 핸들러는 429와 초기화 시각으로 응답한다
+type AIQuotaExceededError struct {
+	Scope   string // user 또는 global
+	Period  string // daily 또는 monthly
+	Limit   int
+	ResetAt time.Time
+}
+
+func (e *AIQuotaExceededError) Error() string {
+	return "ai quota exceeded"
+}
+
+type AIUsageService interface {
+	// 백엔드 호출 한 번을 남긴다. 저장에 실패해도 호출 결과에는 영향을 주지 않는다
+	Record(usage *models.AIUsage)
+	// 사용자와 전체 한도를 확인하고, 넘었으면 *AIQuotaExceededError를 반환한다
+	CheckQuota(userID uint) error
+	// from, to(YYYY-MM-DD, 운세 시간대 기준, 양 끝 포함)를 날짜와 기능으로 묶은 보고. userID가 0이면 전체 사용자
+	GetReport(from, to string, userID uint) (*AIUsageReport, error)
+}
+
+// 하루·기능별 사용량
+type AIUsageReportRow struct {
+	Date             string  `json:"date,omitempty" example:"2024-01-01"`
+	Feature          string  `json:"feature" example:"daily_fortune"`
+	Calls            int64   `json:"calls" example:"1520"`
+	Succeeded        int64   `json:"succeeded" example:"1498"`
+	Failed           int64   `json:"failed" example:"22"`
+	Users            int64   `json:"users,omitempty" example:"1200"`
+	PromptTokens     int64   `json:"prompt_tokens" example:"1292000"`
+	CompletionTokens int64   `json:"completion_tokens" example:"486400"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms" example:"2310.5"`
+}
+
+type AIUsageReport struct {
+	From     string             `json:"from" example:"2024-01-01"`
+	To       string             `json:"to" example:"2024-01-31"`
+	Timezone string             `json:"timezone" example:"Asia/Seoul"`
+	UserID   uint               `json:"user_id,omitempty" example:"1"`
+	Rows     []AIUsageReportRow `json:"rows"`
+	Totals   []AIUsageReportRow `json:"totals" description:"기간 전체의 기능별 합계"`
+	Quota    AIQuotaLimits      `json:"quota"`
+}
+
+// 설정된 한도 (0이면 제한 없음)
+type AIQuotaLimits struct {
+	UserDaily     int `json:"user_daily" example:"100"`
+	UserMonthly   int `json:"user_monthly" example:"1500"`
+	GlobalDaily   int `json:"global_daily" example:"0"`
+	GlobalMonthly int `json:"global_monthly" example:"0"`
+}
+
+type aiUsageService struct {
+	usageRepo repository.AIUsageRepository
+	location  *time.Location
+	limits    AIQuotaLimits
+
+	mu           sync.Mutex
+	globalDaily  int64
+	globalMonth  int64
+	globalDay    time.Time // 캐시한 값을 센 날의 시작 (날이 바뀌면 다시 센다)
+	globalExpiry time.Time
+}
+
+func NewAIUsageService(usageRepo repository.AIUsageRepository, cfg *config.Config) AIUsageService {
+	return &aiUsageService{
+		usageRepo: usageRepo,
+		location:  loadFortuneLocation(cfg.FortuneTimezone),
+		limits: AIQuotaLimits{
+			UserDaily:     cfg.AIQuotaUserDaily,
+			UserMonthly:   cfg.AIQuotaUserMonthly,
+			GlobalDaily:   cfg.AIQuotaGlobalDaily,
+			GlobalMonthly: cfg.AIQuotaGlobalMonthly,
+		},
+	}
+}
+
+func (s *aiUsageService) Record(usage *models.AIUsage) {
+	if err := s.usageRepo.Create(usage); err != nil {
+		log.Printf("Failed to record AI usage (%s, user %d): %v", usage.Feature, usage.UserID, err)
+	}
+}
+
+// 동시에 들어온 요청은 한도를 조금 넘길 수 있다 (호출 전에 세므로)
+func (s *aiUsageService) CheckQuota(userID uint) error {
+	now := time.Now().In(s.location)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, s.location)
+
+	if userID != 0 && (s.limits.UserDaily > 0 || s.limits.UserMonthly > 0) {
+		daily, monthly, err := s.usageRepo.CountUserSuccess(userID, dayStart, monthStart)
+		if err != nil {
+			return err
+		}
+		if err := s.checkLimits(AIQuotaScopeUser, daily, monthly, s.limits.UserDaily, s.limits.UserMonthly, dayStart, monthStart); err != nil {
+			return err
+		}
+	}
+
+	if s.limits.GlobalDaily > 0 || s.limits.GlobalMonthly > 0 {
+		daily, monthly, err := s.globalCounts(dayStart, monthStart)
+		if err != nil {
+			return err
+		}
+		return s.checkLimits(AIQuotaScopeGlobal, daily, monthly, s.limits.GlobalDaily, s.limits.GlobalMonthly, dayStart, monthStart)
+	}
+	return nil
+}
+
+// 한 달 한도를 먼저 본다 (둘 다 넘었으면 더 늦게 풀리는 쪽을 알려준다)
+func (s *aiUsageService) checkLimits(scope string, daily, monthly int64, dailyLimit, monthlyLimit int, dayStart, monthStart time.Time) error {
+	if monthlyLimit > 0 && monthly >= int64(monthlyLimit) {
+		return &AIQuotaExceededError{Scope: scope, Period: AIQuotaPeriodMonthly, Limit: monthlyLimit, ResetAt: monthStart.AddDate(0, 1, 0)}
+	}
+	if dailyLimit > 0 && daily >= int64(dailyLimit) {
+		return &AIQuotaExceededError{Scope: scope, Period: AIQuotaPeriodDaily, Limit: dailyLimit, ResetAt: dayStart.AddDate(0, 0, 1)}
+	}
+	return nil
+}
+
+func (s *aiUsageService) globalCounts(dayStart, monthStart time.Time) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.globalDay.Equal(dayStart) && time.Now().Before(s.globalExpiry) {
+		return s.globalDaily, s.globalMonth, nil
+	}
+	daily, monthly, err := s.usageRepo.CountGlobalSuccess(dayStart, monthStart)
+	if err != nil {
+		return 0, 0, err
+	}
+	s.globalDaily, s.globalMonth = daily, monthly
+	s.globalDay = dayStart
+	s.globalExpiry = time.Now().Add(globalQuotaCacheTTL)
+	return daily, monthly, nil
+}
+
+func (s *aiUsageService) GetReport(from, to string, userID uint) (*AIUsageReport, error) {
+	today := time.Now().In(s.location)
+	toDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, s.location)
+	if to != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", to, s.location)
+		if err != nil {
+			return nil, errors.New("invalid date range")
+		}
+		toDate = parsed
+	}
+	fromDate := toDate.AddDate(0, 0, -29)
+	if from != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", from, s.location)
+		if err != nil {
+			return nil, errors.New("invalid date range")
+		}
+		fromDate = parsed
+	}
+	if fromDate.After(toDate) || toDate.Sub(fromDate) >= aiReportMaxDays*24*time.Hour {
+		return nil, errors.New("invalid date range")
+	}
+
+	aggregates, err := s.usageRepo.Aggregate(fromDate, toDate.AddDate(0, 0, 1), s.location.String(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AIUsageReport{
+		From:     fromDate.Format("2006-01-02"),
+		To:       toDate.Format("2006-01-02"),
+		Timezone: s.location.String(),
+		UserID:   userID,
+		Rows:     make([]AIUsageReportRow, 0, len(aggregates)),
+		Totals:   []AIUsageReportRow{},
+		Quota:    s.limits,
+	}
+	totalIndex := make(map[string]int)
+	for _, aggregate := range aggregates {
+		row := AIUsageReportRow{
+			Date:             aggregate.Day,
+			Feature:          aggregate.Feature,
+			Calls:            aggregate.Calls,
+			Succeeded:        aggregate.Succeeded,
+			Failed:           aggregate.Failed,
+			Users:            aggregate.Users,
+			PromptTokens:     aggregate.PromptTokens,
+			CompletionTokens: aggregate.CompletionTokens,
+			AvgLatencyMs:     aggregate.AvgLatencyMs,
+		}
+		report.Rows = append(report.Rows, row)
+
+		// 사용자 수는 날짜마다 겹치므로 합계에는 넣지 않는다. 평균 지연은 호출 수로 가중한다
+		index, ok := totalIndex[row.Feature]
+		if !ok {
+			index = len(report.Totals)
+			totalIndex[row.Feature] = index
+			report.Totals = append(report.Totals, AIUsageReportRow{Feature: row.Feature})
+		}
+		total := &report.Totals[index]
+		if calls := total.Calls + row.Calls; calls > 0 {
+			total.AvgLatencyMs = (total.AvgLatencyMs*float64(total.Calls) + row.AvgLatencyMs*float64(row.Calls)) / float64(calls)
+		}
+		total.Calls += row.Calls
+		total.Succeeded += row.Succeeded
+		total.Failed += row.Failed
+		total.PromptTokens += row.PromptTokens
+		total.CompletionTokens += row.CompletionTokens
+	}
+	return report, nil
+}
+
+type aiUsageScopeKey struct{}
+
+type aiUsageScope struct {
+	userID  uint
+	feature string
+}
+
+// 이 컨텍스트로 하는 AI 호출을 userID와 feature로 남긴다
+func WithAIUsage(ctx context.Context, userID uint, feature string) context.Context {
+	return context.WithValue(ctx, aiUsageScopeKey{}, aiUsageScope{userID: userID, feature: feature})
+}
+
+type tokenUsageKey struct{}
+
+// 백엔드가 응답에 담아 준 토큰 수. 없으면 미터링에서 글자 수로 추정한다
+type tokenUsage struct {
+	prompt     int
+	completion int
+	reported   bool
+}
+
+// 스트리밍은 조각마다 누적 값을 주므로 마지막으로 알린 값이 합계가 된다
+func reportTokenUsage(ctx context.Context, prompt, completion int) {
+	if usage, ok := ctx.Value(tokenUsageKey{}).(*tokenUsage); ok {
+		usage.prompt = prompt
+		usage.completion = completion
+		usage.reported = true
+	}
+}
+
+// 실제 백엔드 호출 하나하나를 사용량으로 남긴다. 재시도를 각각 세도록 재시도 계층 안쪽에 씌운다
+type meteredProvider struct {
+	inner LLMProvider
+	model string
+	usage AIUsageService
+}
+
+func newMeteredProvider(inner LLMProvider, model string, usage AIUsageService) LLMProvider {
+	return &meteredProvider{inner: inner, model: model, usage: usage}
+}
+
+func (p *meteredProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *meteredProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.meter(ctx, prompt, func(ctx context.Context) (string, error) {
+		return p.inner.Generate(ctx, prompt)
+	})
+}
+
+func (p *meteredProvider) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	return p.meter(ctx, prompt, func(ctx context.Context) (string, error) {
+		return p.inner.GenerateStructured(ctx, prompt, schema)
+	})
+}
+
+func (p *meteredProvider) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	return p.meter(ctx, prompt, func(ctx context.Context) (string, error) {
+		return p.inner.GenerateStream(ctx, prompt, onDelta)
+	})
+}
+
+func (p *meteredProvider) meter(ctx context.Context, prompt string, call func(ctx context.Context) (string, error)) (string, error) {
+	tokens := &tokenUsage{}
+	started := time.Now()
+	text, err := call(context.WithValue(ctx, tokenUsageKey{}, tokens))
+
+	usage := newAIUsage(ctx, p.inner.Name(), p.model, time.Since(started), err)
+	if tokens.reported {
+		usage.PromptTokens = tokens.prompt
+		usage.CompletionTokens = tokens.completion
+	} else {
+		usage.PromptTokens = EstimateTokens(prompt)
+		usage.CompletionTokens = EstimateTokens(text)
+		usage.Estimated = true
+	}
+	p.usage.Record(usage)
+	return text, err
+}
+
+// 이미지 생성 호출을 사용량으로 남긴다 (출력 토큰은 없다)
+type meteredImageProvider struct {
+	inner ImageProvider
+	model string
+	usage AIUsageService
+}
+
+func newMeteredImageProvider(inner ImageProvider, model string, usage AIUsageService) ImageProvider {
+	return &meteredImageProvider{inner: inner, model: model, usage: usage}
+}
+
+func (p *meteredImageProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *meteredImageProvider) GenerateImage(ctx context.Context, prompt string) (*GeneratedImage, error) {
+	started := time.Now()
+	image, err := p.inner.GenerateImage(ctx, prompt)
+
+	usage := newAIUsage(ctx, p.inner.Name(), p.model, time.Since(started), err)
+	usage.PromptTokens = EstimateTokens(prompt)
+	usage.Estimated = true
+	p.usage.Record(usage)
+	return image, err
+}
+
+// 컨텍스트에 사용자와 기능이 없으면 사용자 0(시스템)으로 남긴다
+func newAIUsage(ctx context.Context, provider, model string, latency time.Duration, err error) *models.AIUsage {
+	scope, _ := ctx.Value(aiUsageScopeKey{}).(aiUsageScope)
+	usage := &models.AIUsage{
+		UserID:    scope.userID,
+		Feature:   scope.feature,
+		Provider:  provider,
+		Model:     model,
+		LatencyMs: latency.Milliseconds(),
+		Outcome:   models.AIUsageSuccess,
+	}
+	if usage.Feature == "" {
+		usage.Feature = "unknown"
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		usage.Outcome = models.AIUsageTimeout
+	case errors.Is(err, context.Canceled):
+		usage.Outcome = models.AIUsageCanceled
+	default:
+		usage.Outcome = models.AIUsageError
+	}
+	if err != nil {
+		usage.Error = truncateRunes(err.Error(), aiUsageErrorMaxLength)
+	}
+	return usage
+}
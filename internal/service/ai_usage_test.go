@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 오늘과 이번 달에 성공한 호출 수를 정해 두는 사용량 저장소
+type usageCounts struct {
+	user        map[uint][2]int64
+	global      [2]int64
+	globalReads int
+	aggregates  []repository.AIUsageAggregate
+}
+
+func (r *usageCounts) Create(usage *models.AIUsage) error {
+	return errors.New("not used")
+}
+
+func (r *usageCounts) CountUserSuccess(userID uint, dayStart, monthStart time.Time) (int64, int64, error) {
+	counts := r.user[userID]
+	return counts[0], counts[1], nil
+}
+
+func (r *usageCounts) CountGlobalSuccess(dayStart, monthStart time.Time) (int64, int64, error) {
+	r.globalReads++
+	return r.global[0], r.global[1], nil
+}
+
+func (r *usageCounts) Aggregate(from, to time.Time, timezone string, userID uint) ([]repository.AIUsageAggregate, error) {
+	return r.aggregates, nil
+}
+
+func newTestAIUsageService(counts *usageCounts, userDaily, userMonthly, globalDaily, globalMonthly int) *aiUsageService {
+	return NewAIUsageService(counts, &config.Config{
+		FortuneTimezone:      "Asia/Seoul",
+		AIQuotaUserDaily:     userDaily,
+		AIQuotaUserMonthly:   userMonthly,
+		AIQuotaGlobalDaily:   globalDaily,
+		AIQuotaGlobalMonthly: globalMonthly,
+	}).(*aiUsageService)
+}
+
+func TestCheckQuota(t *testing.T) {
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+	now := time.Now().In(seoul)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, seoul)
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, seoul)
+
+	tests := []struct {
+		name   string
+		counts usageCounts
+		userID uint
+		limits [4]int // 사용자 하루, 사용자 한 달, 전체 하루, 전체 한 달
+		want   *AIQuotaExceededError
+	}{
+		{"no limits", usageCounts{user: map[uint][2]int64{1: {500, 5000}}}, 1, [4]int{}, nil},
+		{"under limits", usageCounts{user: map[uint][2]int64{1: {9, 99}}}, 1, [4]int{10, 100, 0, 0}, nil},
+		{"user daily", usageCounts{user: map[uint][2]int64{1: {10, 50}}}, 1, [4]int{10, 100, 0, 0},
+			&AIQuotaExceededError{Scope: AIQuotaScopeUser, Period: AIQuotaPeriodDaily, Limit: 10, ResetAt: tomorrow}},
+		// 둘 다 넘었으면 더 늦게 풀리는 한 달 한도를 알려준다
+		{"user monthly first", usageCounts{user: map[uint][2]int64{1: {10, 100}}}, 1, [4]int{10, 100, 0, 0},
+			&AIQuotaExceededError{Scope: AIQuotaScopeUser, Period: AIQuotaPeriodMonthly, Limit: 100, ResetAt: nextMonth}},
+		{"other user", usageCounts{user: map[uint][2]int64{1: {10, 100}}}, 2, [4]int{10, 100, 0, 0}, nil},
+		{"global daily", usageCounts{global: [2]int64{1000, 1000}}, 1, [4]int{10, 100, 1000, 0},
+			&AIQuotaExceededError{Scope: AIQuotaScopeGlobal, Period: AIQuotaPeriodDaily, Limit: 1000, ResetAt: tomorrow}},
+		// 시스템 호출(사용자 0)은 전체 한도만 본다
+		{"system call", usageCounts{user: map[uint][2]int64{0: {10, 100}}}, 0, [4]int{10, 100, 1000, 0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestAIUsageService(&tt.counts, tt.limits[0], tt.limits[1], tt.limits[2], tt.limits[3])
+			err := service.CheckQuota(tt.userID)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			var quotaErr *AIQuotaExceededError
+			if !errors.As(err, &quotaErr) {
+				t.Fatalf("err = %v, want quota exceeded", err)
+			}
+			if quotaErr.Scope != tt.want.Scope || quotaErr.Period != tt.want.Period || quotaErr.Limit != tt.want.Limit || !quotaErr.ResetAt.Equal(tt.want.ResetAt) {
+				t.Errorf("err = %+v, want %+v", quotaErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckQuotaCachesGlobalCounts(t *testing.T) {
+	counts := &usageCounts{global: [2]int64{5, 5}}
+	service := newTestAIUsageService(counts, 0, 0, 10, 0)
+
+	for i := 0; i < 3; i++ {
+		if err := service.CheckQuota(uint(i + 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counts.globalReads != 1 {
+		t.Errorf("global counts read %d times, want 1", counts.globalReads)
+	}
+
+	// 캐시가 만료되면 다시 센다
+	service.globalExpiry = time.Now().Add(-time.Second)
+	counts.global = [2]int64{10, 10}
+	if err := service.CheckQuota(1); err == nil || counts.globalReads != 2 {
+		t.Errorf("after expiry: err %v, %d reads", err, counts.globalReads)
+	}
+}
+
+func TestGetAIUsageReport(t *testing.T) {
+	counts := &usageCounts{aggregates: []repository.AIUsageAggregate{
+		{Day: "2024-01-01", Feature: AIFeatureChat, Calls: 10, Succeeded: 9, Failed: 1, Users: 4, PromptTokens: 1000, CompletionTokens: 300, AvgLatencyMs: 100},
+		{Day: "2024-01-01", Feature: AIFeatureDailyFortune, Calls: 5, Succeeded: 5, Users: 5, PromptTokens: 500, CompletionTokens: 200, AvgLatencyMs: 2000},
+		{Day: "2024-01-02", Feature: AIFeatureChat, Calls: 30, Succeeded: 30, Users: 6, PromptTokens: 3000, CompletionTokens: 900, AvgLatencyMs: 200},
+	}}
+	service := newTestAIUsageService(counts, 100, 1500, 0, 0)
+
+	report, err := service.GetReport("2024-01-01", "2024-01-31", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2024-01-01" || report.To != "2024-01-31" || report.Timezone != "Asia/Seoul" || len(report.Rows) != 3 || report.Quota.UserMonthly != 1500 {
+		t.Errorf("report = %+v", report)
+	}
+	// 기능별 합계의 평균 지연은 호출 수로 가중한다
+	if len(report.Totals) != 2 {
+		t.Fatalf("totals = %+v", report.Totals)
+	}
+	chat := report.Totals[0]
+	if chat.Feature != AIFeatureChat || chat.Calls != 40 || chat.Succeeded != 39 || chat.Failed != 1 || chat.PromptTokens != 4000 || chat.AvgLatencyMs != 175 || chat.Users != 0 {
+		t.Errorf("chat total = %+v", chat)
+	}
+
+	for _, tt := range []struct{ from, to string }{
+		{"2024-01-31", "2024-01-01"},
+		{"2024-01-01", "2024-06-01"},
+		{"2024/01/01", ""},
+	} {
+		if _, err := service.GetReport(tt.from, tt.to, 0); err == nil || err.Error() != "invalid date range" {
+			t.Errorf("GetReport(%q, %q) err = %v", tt.from, tt.to, err)
+		}
+	}
+
+	// 기간을 비우면 오늘까지 30일
+	report, _ = service.GetReport("", "", 7)
+	today := time.Now().In(service.location)
+	if report.To != today.Format("2006-01-02") || report.From != today.AddDate(0, 0, -29).Format("2006-01-02") || report.UserID != 7 {
+		t.Errorf("default range %s ~ %s", report.From, report.To)
+	}
+}
+
+// 프롬프트를 받아 한 번에 응답하는 백엔드
+type generateFunc func(ctx context.Context, prompt string) (string, error)
+
+func (f generateFunc) Name() string { return "generate" }
+
+func (f generateFunc) Generate(ctx context.Context, prompt string) (string, error) {
+	return f(ctx, prompt)
+}
+
+func (f generateFunc) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	return f(ctx, prompt)
+}
+
+func (f generateFunc) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	return "", errors.New("not used")
+}
+
+func TestMeteredProviderRecordsEveryCall(t *testing.T) {
+	tests := []struct {
+		name           string
+		backend        generateFunc
+		wantOutcome    string
+		wantPrompt     int
+		wantCompletion int
+		wantEstimated  bool
+	}{
+		{"reported tokens", func(ctx context.Context, prompt string) (string, error) {
+			reportTokenUsage(ctx, 850, 320)
+			return "좋은 하루예요.", nil
+		}, models.AIUsageSuccess, 850, 320, false},
+		{"estimated tokens", func(ctx context.Context, prompt string) (string, error) {
+			return "좋은 하루예요.", nil
+		}, models.AIUsageSuccess, EstimateTokens("오늘의 운세"), EstimateTokens("좋은 하루예요."), true},
+		{"timeout", func(ctx context.Context, prompt string) (string, error) {
+			return "", fmt.Errorf("request: %w", context.DeadlineExceeded)
+		}, models.AIUsageTimeout, EstimateTokens("오늘의 운세"), 0, true},
+		{"canceled", func(ctx context.Context, prompt string) (string, error) {
+			return "", context.Canceled
+		}, models.AIUsageCanceled, EstimateTokens("오늘의 운세"), 0, true},
+		{"error", func(ctx context.Context, prompt string) (string, error) {
+			return "", errors.New("status 500")
+		}, models.AIUsageError, EstimateTokens("오늘의 운세"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := &recordedUsage{}
+			newMeteredProvider(tt.backend, "test-model", usage).Generate(WithAIUsage(context.Background(), 3, AIFeatureChat), "오늘의 운세")
+
+			if len(usage.usages) != 1 {
+				t.Fatalf("usages = %+v", usage.usages)
+			}
+			got := usage.usages[0]
+			if got.UserID != 3 || got.Feature != AIFeatureChat || got.Provider != "generate" || got.Model != "test-model" || got.Outcome != tt.wantOutcome {
+				t.Errorf("usage = %+v", got)
+			}
+			if got.PromptTokens != tt.wantPrompt || got.CompletionTokens != tt.wantCompletion || got.Estimated != tt.wantEstimated {
+				t.Errorf("tokens %d/%d estimated %v, want %d/%d %v", got.PromptTokens, got.CompletionTokens, got.Estimated, tt.wantPrompt, tt.wantCompletion, tt.wantEstimated)
+			}
+			if (tt.wantOutcome == models.AIUsageSuccess) != (got.Error == "") {
+				t.Errorf("error = %q", got.Error)
+			}
+		})
+	}
+
+	// 사용자와 기능을 모르는 호출은 시스템 호출로 남긴다
+	usage := &recordedUsage{}
+	newMeteredProvider(generateFunc(func(ctx context.Context, prompt string) (string, error) { return "", nil }), "test-model", usage).Generate(context.Background(), "")
+	if got := usage.usages[0]; got.UserID != 0 || got.Feature != "unknown" {
+		t.Errorf("unscoped usage = %+v", got)
+	}
+}
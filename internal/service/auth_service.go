@@ -2,6 +2,11 @@ package service
 
 import (
 	"errors"
+	"sync"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
 	"dothefortune_server/internal/models"
 	"dothefortune_server/internal/repository"
 	"dothefortune_server/internal/utils"
@@ -9,19 +14,49 @@ import (
 
 type AuthService interface {
 	Register(email, password, name, gender string, birthYear, birthMonth, birthDay, birthHour, birthMinute int, isLunar bool, birthPlace string) (*models.User, error)
-	Login(email, password string) (*models.User, string, error)
-	GenerateToken(userID uint, email string) (string, error)
+	// 로그인에 성공하면 기기 세션을 만들고 access/refresh 토큰을 발급한다
+	Login(email, password string, client SessionClient) (*models.User, *TokenPair, error)
+	CreateSession(user *models.User, client SessionClient) (*TokenPair, error)
+	// refresh 토큰을 새 토큰으로 바꾼다. 이미 바뀐 토큰이 오면 세션을 끊는다
+	RefreshSession(refreshToken string, client SessionClient) (*TokenPair, error)
+	// access 토큰의 세션을 끊는다. 그 사용자의 살아 있는 세션이 아니면 "session not found"
+	Logout(userID, sessionID uint) error
+	GetSessions(userID, currentSessionID uint) ([]models.Session, error)
+	RevokeSession(userID, sessionID uint) error
+	// 현재 세션을 뺀 모든 세션을 끊고 끊은 개수를 반환한다
+	RevokeOtherSessions(userID, currentSessionID uint) (int64, error)
+	IsSessionActive(sessionID uint) bool
+	// 운세와 궁합 문장 언어를 저장한다. 빈 문자열이면 설정을 지우고 Accept-Language를 따른다
+	SetLocale(userID uint, locale string) (*models.User, error)
+	// 저장된 언어 설정 (없으면 빈 문자열)
+	PreferredLocale(userID uint) string
+}
+
+// 요청마다 사용자를 읽지 않도록 언어 설정을 잠시 들고 있는다. 다른 인스턴스에서 바꾼 값도 이 시간이 지나면 반영된다
+const localeCacheTTL = 5 * time.Minute
+
+type cachedLocale struct {
+	locale    string
+	expiresAt time.Time
 }
 
 type authService struct {
 	userRepo    repository.UserRepository
 	fortuneRepo repository.FortuneRepository
+	sessionRepo repository.SessionRepository
+	cfg         *config.Config
+
+	localeMu sync.Mutex
+	locales  map[uint]cachedLocale
 }
 
-func NewAuthService(userRepo repository.UserRepository, fortuneRepo repository.FortuneRepository) AuthService {
+func NewAuthService(userRepo repository.UserRepository, fortuneRepo repository.FortuneRepository, sessionRepo repository.SessionRepository, cfg *config.Config) AuthService {
 	return &authService{
 		userRepo:    userRepo,
 		fortuneRepo: fortuneRepo,
+		sessionRepo: sessionRepo,
+		cfg:         cfg,
+		locales:     make(map[uint]cachedLocale),
 	}
 }
 
@@ -83,25 +118,70 @@ func (s *authService) Register(email, password, name, gender string, birthYear,
 	return newUser, nil
 }
 
-func (s *authService) Login(email, password string) (*models.User, string, error) {
+func (s *authService) Login(email, password string, client SessionClient) (*models.User, *TokenPair, error) {
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return nil, "", errors.New("invalid email or password")
+		return nil, nil, errors.New("invalid email or password")
 	}
 
 	if !utils.CheckPasswordHash(password, user.Password) {
-		return nil, "", errors.New("invalid email or password")
+		return nil, nil, errors.New("invalid email or password")
 	}
 
-	token, err := s.GenerateToken(user.ID, user.Email)
+	tokens, err := s.CreateSession(user, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+
+func (s *authService) SetLocale(userID uint, locale string) (*models.User, error) {
+	if locale != "" && !i18n.IsSupported(locale) {
+		return nil, errors.New("unsupported locale")
 	}
 
-	return user, token, nil
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	user.Locale = locale
+	user.FortuneInfo = nil
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	s.cacheLocale(userID, locale)
+	return user, nil
 }
 
-func (s *authService) GenerateToken(userID uint, email string) (string, error) {
-	return utils.GenerateToken(userID, email)
+func (s *authService) PreferredLocale(userID uint) string {
+	s.localeMu.Lock()
+	cached, ok := s.locales[userID]
+	s.localeMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.locale
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ""
+	}
+	s.cacheLocale(userID, user.Locale)
+	return user.Locale
 }
 
+func (s *authService) cacheLocale(userID uint, locale string) {
+	s.localeMu.Lock()
+	defer s.localeMu.Unlock()
+	now := time.Now()
+	// 만료된 항목은 쌓이지 않도록 가끔 비운다
+	if len(s.locales) >= 10000 {
+		for id, cached := range s.locales {
+			if !now.Before(cached.expiresAt) {
+				delete(s.locales, id)
+			}
+		}
+	}
+	s.locales[userID] = cachedLocale{locale: locale, expiresAt: now.Add(localeCacheTTL)}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+)
+
+// 사용자를 몇 번 읽었는지 센다
+type countingUsers struct {
+	*serviceUsers
+	reads int
+}
+
+func (r *countingUsers) FindByID(id uint) (*models.User, error) {
+	r.reads++
+	return r.serviceUsers.FindByID(id)
+}
+
+func TestPreferredLocaleIsCached(t *testing.T) {
+	users := &countingUsers{serviceUsers: &serviceUsers{users: make(map[uint]models.User)}}
+	user := &models.User{Locale: "ja"}
+	users.Create(user)
+	service := NewAuthService(users, nil, nil, &config.Config{}).(*authService)
+
+	// 요청마다 사용자를 읽지 않는다
+	for i := 0; i < 3; i++ {
+		if got := service.PreferredLocale(user.ID); got != "ja" {
+			t.Fatalf("PreferredLocale = %q", got)
+		}
+	}
+	if users.reads != 1 {
+		t.Errorf("user read %d times, want 1", users.reads)
+	}
+
+	// 바꾼 설정은 바로 반영한다
+	if _, err := service.SetLocale(user.ID, "en"); err != nil {
+		t.Fatal(err)
+	}
+	reads := users.reads
+	if got := service.PreferredLocale(user.ID); got != "en" || users.reads != reads {
+		t.Errorf("after SetLocale: %q with %d new reads", got, users.reads-reads)
+	}
+
+	// 다른 인스턴스에서 바꾼 값은 캐시가 만료되면 반영된다
+	stored := users.users[user.ID]
+	stored.Locale = "ko"
+	users.users[user.ID] = stored
+	service.locales[user.ID] = cachedLocale{locale: "en", expiresAt: time.Now().Add(-time.Second)}
+	if got := service.PreferredLocale(user.ID); got != "ko" {
+		t.Errorf("after expiry = %q, want ko", got)
+	}
+
+	if _, err := service.SetLocale(user.ID, "fr"); err == nil || err.Error() != "unsupported locale" {
+		t.Errorf("unsupported locale: err = %v", err)
+	}
+	if got := service.PreferredLocale(999); got != "" {
+		t.Errorf("unknown user = %q", got)
+	}
+}
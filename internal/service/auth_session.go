@@ -0,0 +1,200 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/utils"
+)
+
+// 세션을 만들거나 갱신한 기기 정보
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// refresh 토큰은 "<세션 ID>.<비밀값>" 형식이고 서버에는 비밀값의 해시만 남긴다
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+	SessionID        uint
+}
+
+func (s *authService) CreateSession(user *models.User, client SessionClient) (*TokenPair, error) {
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshSecret(secret),
+		UserAgent:        truncateRunes(client.UserAgent, 254),
+		IP:               client.IP,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL()),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session.ID, secret, now, session.ExpiresAt)
+}
+
+func (s *authService) RefreshSession(refreshToken string, client SessionClient) (*TokenPair, error) {
+	sessionID, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	now := time.Now()
+	hash := hashRefreshSecret(secret)
+	if hash != session.RefreshTokenHash {
+		// 이미 바뀐 토큰만 재사용으로 본다. 세션 ID만 맞춘 엉터리 토큰으로는 남의 세션을 끊을 수 없다
+		if session.PreviousRefreshTokenHash != "" && hash == session.PreviousRefreshTokenHash {
+			s.revokeReusedSession(session)
+			return nil, errors.New("refresh token reused")
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+	if !session.ExpiresAt.After(now) {
+		return nil, errors.New("refresh token expired")
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	newSecret, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(s.refreshTTL())
+	rotated, err := s.sessionRepo.Rotate(session.ID, hash, hashRefreshSecret(newSecret), truncateRunes(client.UserAgent, 254), client.IP, now, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	// 그 사이 다른 요청이 같은 토큰으로 먼저 갱신했다
+	if !rotated {
+		s.revokeReusedSession(session)
+		return nil, errors.New("refresh token reused")
+	}
+
+	return s.issueTokens(user, session.ID, newSecret, now, expiresAt)
+}
+
+// 한 번 쓴 refresh 토큰이 다시 왔다면 둘 중 하나는 탈취된 토큰이므로 세션을 통째로 끊는다
+func (s *authService) revokeReusedSession(session *models.Session) {
+	log.Printf("Refresh token reuse detected: user=%d session=%d", session.UserID, session.ID)
+	if err := s.sessionRepo.Revoke(session.ID, models.SessionRevokedReused, time.Now()); err != nil {
+		log.Printf("Failed to revoke session %d: %v", session.ID, err)
+	}
+}
+
+// access 토큰의 세션을 끊는다. 끊을 세션이 없으면 성공으로 보지 않는다
+func (s *authService) Logout(userID, sessionID uint) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return errors.New("session not found")
+	}
+	return s.sessionRepo.Revoke(session.ID, models.SessionRevokedLogout, time.Now())
+}
+
+func (s *authService) GetSessions(userID, currentSessionID uint) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.FindActiveByUserID(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *authService) RevokeSession(userID, sessionID uint) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return errors.New("session not found")
+	}
+	return s.sessionRepo.Revoke(session.ID, models.SessionRevokedByUser, time.Now())
+}
+
+func (s *authService) RevokeOtherSessions(userID, currentSessionID uint) (int64, error) {
+	return s.sessionRepo.RevokeByUserID(userID, currentSessionID, models.SessionRevokedByUser, time.Now())
+}
+
+func (s *authService) IsSessionActive(sessionID uint) bool {
+	if sessionID == 0 {
+		return false
+	}
+	active, err := s.sessionRepo.IsActive(sessionID, time.Now())
+	if err != nil {
+		log.Printf("Failed to check session %d: %v", sessionID, err)
+		return false
+	}
+	return active
+}
+
+func (s *authService) issueTokens(user *models.User, sessionID uint, secret string, now, refreshExpiresAt time.Time) (*TokenPair, error) {
+	accessExpiresAt := now.Add(time.Duration(s.cfg.AccessTokenMinutes) * time.Minute)
+	accessToken, err := utils.GenerateToken(user.ID, user.Email, sessionID, accessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     fmt.Sprintf("%d.%s", sessionID, secret),
+		RefreshExpiresAt: refreshExpiresAt,
+		SessionID:        sessionID,
+	}, nil
+}
+
+// 토큰을 갱신할 때마다 만료 시각이 뒤로 밀린다
+func (s *authService) refreshTTL() time.Duration {
+	return time.Duration(s.cfg.RefreshTokenDays) * 24 * time.Hour
+}
+
+func newRefreshSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseRefreshToken(token string) (uint, string, bool) {
+	idPart, secret, found := strings.Cut(token, ".")
+	if !found || secret == "" {
+		return 0, "", false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil || id == 0 {
+		return 0, "", false
+	}
+	return uint(id), secret, true
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/utils"
+)
+
+// 프롬프트에 넣는 최근 기록 한 건의 최대 글자 수
+const maxChatRecordRunes = 120
+
+// 상담 답변 생성에 넘기는 값
+type ChatPromptInput struct {
+	FortuneMap    map[string]string
+	Now           time.Time // 서비스 시간대 기준 현재 시각
+	RecentRecords []models.FortuneRecord
+	Summary       string               // 컨텍스트 창 밖으로 접힌 앞부분 대화 요약
+	History       []models.ChatMessage // 요약 이후 메시지, 오래된 순
+	Message       string
+	Locale        string
+}
+
+type chatPromptData struct {
+	fortunePromptData
+
+	CurrentDate     string
+	YearLuckPillar  string // 올해 간지 (세운)
+	MonthLuckPillar string // 이번 달 간지 (월운)
+	Records         []chatRecordLine
+	Summary         string
+	History         []chatLine
+	Message         string
+}
+
+type chatRecordLine struct {
+	Date    string
+	Type    string
+	Content string
+}
+
+type chatLine struct {
+	Speaker string
+	Content string
+}
+
+func newChatPromptData(input ChatPromptInput) chatPromptData {
+	now := input.Now
+	todayStem, todayBranch := utils.CalculateDayPillarAt(now)
+	yearStem, yearBranch, monthStem, monthBranch, _, _, _, _ :=
+		utils.CalculateFortunePillars(now.Year(), int(now.Month()), now.Day(), now.Hour())
+
+	data := chatPromptData{
+		fortunePromptData: newFortunePromptData(input.FortuneMap, todayStem, todayBranch, "", input.Locale),
+		CurrentDate:       now.Format("2006-01-02"),
+		YearLuckPillar:    yearStem + yearBranch,
+		MonthLuckPillar:   monthStem + monthBranch,
+		Summary:           input.Summary,
+		History:           chatLines(input.History, input.Locale),
+		Message:           input.Message,
+	}
+
+	for _, record := range input.RecentRecords {
+		label, ok := i18n.Lookup(input.Locale, "record_type."+record.Type)
+		if !ok {
+			label = record.Type
+		}
+		data.Records = append(data.Records, chatRecordLine{
+			Date:    record.CreatedAt.In(now.Location()).Format("2006-01-02"),
+			Type:    label,
+			Content: truncateRunes(record.Content, maxChatRecordRunes),
+		})
+	}
+	return data
+}
+
+func chatLines(messages []models.ChatMessage, locale string) []chatLine {
+	lines := make([]chatLine, len(messages))
+	for i, message := range messages {
+		lines[i] = chatLine{Speaker: i18n.T(locale, "chat.speaker."+message.Role), Content: message.Content}
+	}
+	return lines
+}
+
+// 상담 답변을 onDelta로 흘려보내고, 답변과 프롬프트 버전, 프롬프트 토큰 수(추정치)를 반환한다
+func (s *aiService) StreamChatReply(ctx context.Context, input ChatPromptInput, onDelta func(delta string) error) (string, string, int, error) {
+	prompt, version, err := s.promptStore.Render(PromptChatCounselor, input.Locale, newChatPromptData(input))
+	if err != nil {
+		return "", "", 0, err
+	}
+	stream := newFilteredStream(chatTextPolicy, input.Locale, onDelta)
+	if _, err := s.llmProvider.GenerateStream(ctx, prompt, stream.write); err != nil {
+		return "", version, 0, err
+	}
+	// 검사를 통과한 앞부분까지만 보냈으므로 그만큼을 답변으로 저장한다. 하나도 보내지 못했으면 안내 문장을 보낸다
+	checked, err := stream.finish()
+	if checked == "" {
+		if _, ok := err.(*TextRejection); !ok && err != nil {
+			return "", version, 0, err
+		}
+		checked = i18n.T(input.Locale, "chat.fallback_reply")
+		if err := onDelta(checked); err != nil {
+			return "", version, 0, err
+		}
+	}
+	return checked, version, EstimateTokens(prompt), nil
+}
+
+// 이전 요약에 messages를 합쳐 새 요약을 만든다
+func (s *aiService) SummarizeConversation(ctx context.Context, locale, summary string, messages []models.ChatMessage) (string, error) {
+	prompt, _, err := s.promptStore.Render(PromptChatSummary, locale, struct {
+		Summary string
+		History []chatLine
+	}{summary, chatLines(messages, locale)})
+	if err != nil {
+		return "", err
+	}
+	text, err := s.llmProvider.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// 토크나이저 없이 어림한 토큰 수. 영문/숫자는 4바이트에 1토큰, 한글 등은 글자마다 1토큰으로 센다
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+func truncateRunes(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 질문 한 건의 최대 글자 수
+const maxChatMessageRunes = 1000
+
+// 프롬프트에 넣는 최근 운세 기록 수
+const chatRecentRecordLimit = 5
+
+// 요약할 때 최근 메시지는 이 수만큼 남겨 대화의 흐름을 유지한다
+const chatKeepRecentMessages = 4
+
+type ChatService interface {
+	CreateConversation(userID uint, title string) (*models.Conversation, error)
+	GetConversations(userID uint, limit int) ([]models.Conversation, error)
+	GetConversation(userID, conversationID uint) (*ConversationDetail, error)
+	DeleteConversation(userID, conversationID uint) error
+	// 질문을 보내고 locale로 쓴 답변을 onDelta로 흘려보낸다 (onDelta가 nil이면 답변이 끝난 뒤 한 번에 반환한다)
+	SendMessage(ctx context.Context, userID, conversationID uint, locale, content string, onDelta func(delta string) error) (*ChatReply, error)
+}
+
+type ConversationDetail struct {
+	Conversation *models.Conversation `json:"conversation"`
+	Messages     []models.ChatMessage `json:"messages"`
+}
+
+type ChatReply struct {
+	Conversation      *models.Conversation `json:"conversation"`
+	UserMessage       *models.ChatMessage  `json:"user_message"`
+	Reply             *models.ChatMessage  `json:"reply"`
+	RemainingMessages int                  `json:"remaining_messages"`
+	Locale            string               `json:"locale"`
+}
+
+type chatService struct {
+	conversationRepo repository.ConversationRepository
+	fortuneRepo      repository.FortuneRepository
+	recordRepo       repository.RecordRepository
+	aiService        AIService
+	aiUsage          AIUsageService
+	location         *time.Location
+	dailyLimit       int
+	contextTokens    int
+}
+
+func NewChatService(conversationRepo repository.ConversationRepository, fortuneRepo repository.FortuneRepository, recordRepo repository.RecordRepository, aiService AIService, aiUsage AIUsageService, cfg *config.Config) ChatService {
+	return &chatService{
+		conversationRepo: conversationRepo,
+		fortuneRepo:      fortuneRepo,
+		recordRepo:       recordRepo,
+		aiService:        aiService,
+		aiUsage:          aiUsage,
+		location:         loadFortuneLocation(cfg.FortuneTimezone),
+		dailyLimit:       cfg.ChatDailyMessageLimit,
+		contextTokens:    cfg.ChatContextTokens,
+	}
+}
+
+func (s *chatService) CreateConversation(userID uint, title string) (*models.Conversation, error) {
+	conversation := &models.Conversation{
+		UserID: userID,
+		Title:  truncateRunes(title, 100),
+	}
+	if err := s.conversationRepo.Create(conversation); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+func (s *chatService) GetConversations(userID uint, limit int) ([]models.Conversation, error) {
+	return s.conversationRepo.FindByUserID(userID, limit)
+}
+
+func (s *chatService) GetConversation(userID, conversationID uint) (*ConversationDetail, error) {
+	conversation, err := s.conversationRepo.FindByID(userID, conversationID)
+	if err != nil {
+		return nil, errors.New("conversation not found")
+	}
+	messages, err := s.conversationRepo.FindMessages(conversation.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ConversationDetail{Conversation: conversation, Messages: messages}, nil
+}
+
+func (s *chatService) DeleteConversation(userID, conversationID uint) error {
+	if _, err := s.conversationRepo.FindByID(userID, conversationID); err != nil {
+		return errors.New("conversation not found")
+	}
+	return s.conversationRepo.Delete(userID, conversationID)
+}
+
+// 질문을 먼저 저장해 하루 질문 수를 차지하고, 답변이 끝까지 생성되면 답변을 저장한다. AI 호출이 실패하거나 클라이언트가 끊기면 질문을 지워 하루 질문 수도 차감하지 않는다
+func (s *chatService) SendMessage(ctx context.Context, userID, conversationID uint, locale, content string, onDelta func(delta string) error) (*ChatReply, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("message is required")
+	}
+	if len([]rune(content)) > maxChatMessageRunes {
+		return nil, errors.New("message too long")
+	}
+	if onDelta == nil {
+		onDelta = func(string) error { return nil }
+	}
+
+	conversation, err := s.conversationRepo.FindByID(userID, conversationID)
+	if err != nil {
+		return nil, errors.New("conversation not found")
+	}
+
+	now := time.Now().In(s.location)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+	userMessage := &models.ChatMessage{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		Role:           "user",
+		Content:        content,
+		TokenCount:     EstimateTokens(content),
+	}
+	sentToday, created, err := s.conversationRepo.CreateUserMessageWithinLimit(userMessage, startOfDay, s.dailyLimit)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, errors.New("daily message limit exceeded")
+	}
+	saved := false
+	defer func() {
+		if saved {
+			return
+		}
+		if err := s.conversationRepo.DeleteMessage(userMessage.ID); err != nil {
+			log.Printf("Failed to delete unanswered chat message %d: %v", userMessage.ID, err)
+		}
+	}()
+
+	if err := s.aiUsage.CheckQuota(userID); err != nil {
+		return nil, err
+	}
+
+	fortuneInfo, err := s.fortuneRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, errors.New("fortune info not found")
+	}
+
+	messages, err := s.conversationRepo.FindMessages(conversation.ID, conversation.SummarizedUntilID)
+	if err != nil {
+		return nil, err
+	}
+	// 방금 저장한 질문은 Message로 따로 넘긴다
+	history := make([]models.ChatMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.ID != userMessage.ID {
+			history = append(history, message)
+		}
+	}
+	history = s.fitContext(WithAIUsage(ctx, userID, AIFeatureChatSummary), conversation, history, locale)
+
+	records, err := s.recordRepo.FindByUserID(userID, chatRecentRecordLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, promptVersion, promptTokens, err := s.aiService.StreamChatReply(WithAIUsage(ctx, userID, AIFeatureChat), ChatPromptInput{
+		FortuneMap:    fortuneInfoToMap(fortuneInfo),
+		Now:           now,
+		RecentRecords: records,
+		Summary:       conversation.Summary,
+		History:       history,
+		Message:       content,
+		Locale:        locale,
+	}, onDelta)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil || reply == "" {
+		log.Printf("Failed to generate chat reply for conversation %d: %v", conversation.ID, err)
+		return nil, errors.New("counselor unavailable")
+	}
+
+	replyMessage := &models.ChatMessage{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		Role:           "assistant",
+		Content:        reply,
+		TokenCount:     EstimateTokens(reply),
+		PromptTokens:   promptTokens,
+		PromptVersion:  promptVersion,
+	}
+	if err := s.conversationRepo.CreateMessage(replyMessage); err != nil {
+		return nil, err
+	}
+	saved = true
+
+	if conversation.Title == "" {
+		conversation.Title = truncateRunes(content, 30)
+	}
+	conversation.MessageCount += 2
+	conversation.TotalTokens += userMessage.TokenCount + replyMessage.TokenCount
+	conversation.LastMessageAt = &replyMessage.CreatedAt
+	if err := s.conversationRepo.Update(conversation); err != nil {
+		return nil, err
+	}
+
+	return &ChatReply{
+		Conversation:      conversation,
+		UserMessage:       userMessage,
+		Reply:             replyMessage,
+		RemainingMessages: s.dailyLimit - int(sentToday) - 1,
+		Locale:            locale,
+	}, nil
+}
+
+// 대화 기록이 컨텍스트 토큰 한도를 넘으면 최근 메시지만 남기고 앞부분을 요약에 합친다.
+// 요약에 실패하면 이번 요청에서만 앞부분을 빼고 보낸다 (다음 요청에서 다시 요약을 시도한다)
+func (s *chatService) fitContext(ctx context.Context, conversation *models.Conversation, history []models.ChatMessage, locale string) []models.ChatMessage {
+	total := EstimateTokens(conversation.Summary)
+	for _, message := range history {
+		total += message.TokenCount
+	}
+	if total <= s.contextTokens || len(history) <= chatKeepRecentMessages {
+		return history
+	}
+
+	// 요약 뒤에 남길 메시지가 한도의 절반 안에 들어오도록 자른다
+	cut := len(history) - chatKeepRecentMessages
+	kept := 0
+	for i := len(history) - 1; i >= cut; i-- {
+		kept += history[i].TokenCount
+	}
+	for cut < len(history)-1 && kept > s.contextTokens/2 {
+		kept -= history[cut].TokenCount
+		cut++
+	}
+	folded, recent := history[:cut], history[cut:]
+
+	summary, err := s.aiService.SummarizeConversation(ctx, locale, conversation.Summary, folded)
+	if err != nil || summary == "" {
+		log.Printf("Failed to summarize conversation %d: %v", conversation.ID, err)
+		return recent
+	}
+	conversation.Summary = summary
+	conversation.SummarizedUntilID = folded[len(folded)-1].ID
+	if err := s.conversationRepo.Update(conversation); err != nil {
+		log.Printf("Failed to save summary for conversation %d: %v", conversation.ID, err)
+	}
+	return recent
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+)
+
+// 사용자 행 잠금 대신 mutex로 세고 저장하는 대화 저장소
+type memoryConversations struct {
+	mu            sync.Mutex
+	conversations map[uint]*models.Conversation
+	messages      []models.ChatMessage
+	nextID        uint
+}
+
+func (r *memoryConversations) Create(conversation *models.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	conversation.ID = r.nextID
+	copied := *conversation
+	r.conversations[conversation.ID] = &copied
+	return nil
+}
+
+func (r *memoryConversations) Update(conversation *models.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *conversation
+	r.conversations[conversation.ID] = &copied
+	return nil
+}
+
+func (r *memoryConversations) FindByID(userID, conversationID uint) (*models.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversation, ok := r.conversations[conversationID]
+	if !ok || conversation.UserID != userID {
+		return nil, errors.New("record not found")
+	}
+	copied := *conversation
+	return &copied, nil
+}
+
+func (r *memoryConversations) FindByUserID(userID uint, limit int) ([]models.Conversation, error) {
+	return nil, errors.New("not used")
+}
+
+func (r *memoryConversations) Delete(userID, conversationID uint) error {
+	return errors.New("not used")
+}
+
+func (r *memoryConversations) CreateMessage(message *models.ChatMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.createMessage(message)
+	return nil
+}
+
+func (r *memoryConversations) createMessage(message *models.ChatMessage) {
+	r.nextID++
+	message.ID = r.nextID
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, *message)
+}
+
+func (r *memoryConversations) FindMessages(conversationID, afterID uint) ([]models.ChatMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []models.ChatMessage
+	for _, message := range r.messages {
+		if message.ConversationID == conversationID && message.ID > afterID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryConversations) CreateUserMessageWithinLimit(message *models.ChatMessage, since time.Time, limit int) (int64, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sent int64
+	for _, existing := range r.messages {
+		if existing.UserID == message.UserID && existing.Role == "user" && !existing.CreatedAt.Before(since) {
+			sent++
+		}
+	}
+	if sent >= int64(limit) {
+		return sent, false, nil
+	}
+	r.createMessage(message)
+	return sent, true, nil
+}
+
+func (r *memoryConversations) DeleteMessage(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, message := range r.messages {
+		if message.ID == id {
+			r.messages = append(r.messages[:i], r.messages[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *memoryConversations) count(role string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, message := range r.messages {
+		if message.Role == role {
+			n++
+		}
+	}
+	return n
+}
+
+// 정해진 답변을 조각으로 흘려보내고 받은 입력과 요약 요청을 남기는 상담 AI
+type chatAI struct {
+	AIService
+	mu         sync.Mutex
+	deltas     []string
+	err        error
+	summary    string
+	inputs     []ChatPromptInput
+	summarized [][]models.ChatMessage
+}
+
+func (a *chatAI) StreamChatReply(ctx context.Context, input ChatPromptInput, onDelta func(delta string) error) (string, string, int, error) {
+	a.mu.Lock()
+	a.inputs = append(a.inputs, input)
+	a.mu.Unlock()
+	reply := ""
+	for _, delta := range a.deltas {
+		if err := onDelta(delta); err != nil {
+			return reply, "", 0, err
+		}
+		reply += delta
+	}
+	if a.err != nil {
+		return "", "", 0, a.err
+	}
+	return reply, "chat/" + input.Locale + "/v1", 120, nil
+}
+
+func (a *chatAI) SummarizeConversation(ctx context.Context, locale, summary string, messages []models.ChatMessage) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.summarized = append(a.summarized, messages)
+	if a.summary == "" {
+		return "", errors.New("upstream unavailable")
+	}
+	return a.summary, nil
+}
+
+type chatFixture struct {
+	service        *chatService
+	conversations  *memoryConversations
+	ai             *chatAI
+	userID         uint
+	conversationID uint
+}
+
+func newChatFixture(dailyLimit, contextTokens int) *chatFixture {
+	charts := newCompatibilityFixture()
+	f := &chatFixture{
+		conversations: &memoryConversations{conversations: make(map[uint]*models.Conversation)},
+		ai:            &chatAI{deltas: []string{"이번 달은 ", "준비에 집중하세요."}},
+		userID:        charts.addUser("M", 1990, 5, 15, 14),
+	}
+	f.service = NewChatService(f.conversations, charts.fortunes, &jobRecordStore{}, f.ai, &recordedUsage{}, &config.Config{
+		FortuneTimezone:       "Asia/Seoul",
+		ChatDailyMessageLimit: dailyLimit,
+		ChatContextTokens:     contextTokens,
+	}).(*chatService)
+	conversation, _ := f.service.CreateConversation(f.userID, "")
+	f.conversationID = conversation.ID
+	return f
+}
+
+func (f *chatFixture) send(ctx context.Context, content string) (*ChatReply, error) {
+	return f.service.SendMessage(ctx, f.userID, f.conversationID, "ko", content, nil)
+}
+
+func TestSendMessageSavesReplyAndCountsRemaining(t *testing.T) {
+	f := newChatFixture(3, 4000)
+
+	var streamed string
+	reply, err := f.service.SendMessage(context.Background(), f.userID, f.conversationID, "ko", "  이직해도 될까요?  ", func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed != "이번 달은 준비에 집중하세요." || reply.Reply.Content != streamed || reply.Reply.PromptVersion != "chat/ko/v1" {
+		t.Errorf("reply = %+v, streamed %q", reply.Reply, streamed)
+	}
+	if reply.UserMessage.Content != "이직해도 될까요?" || reply.RemainingMessages != 2 {
+		t.Errorf("user message %q, remaining %d", reply.UserMessage.Content, reply.RemainingMessages)
+	}
+	conversation, _ := f.conversations.FindByID(f.userID, f.conversationID)
+	if conversation.Title != "이직해도 될까요?" || conversation.MessageCount != 2 || conversation.LastMessageAt == nil {
+		t.Errorf("conversation = %+v", conversation)
+	}
+
+	reply, err = f.send(context.Background(), "그럼 다음 달은요?")
+	if err != nil || reply.RemainingMessages != 1 {
+		t.Fatalf("second message: remaining %v, err %v", reply, err)
+	}
+	// 앞선 질문과 답변은 기록으로, 새 질문은 Message로 넘긴다
+	input := f.ai.inputs[1]
+	if len(input.History) != 2 || input.History[0].Content != "이직해도 될까요?" || input.Message != "그럼 다음 달은요?" {
+		t.Errorf("history %+v, message %q", input.History, input.Message)
+	}
+}
+
+func TestSendMessageRejectsInvalidInput(t *testing.T) {
+	f := newChatFixture(3, 4000)
+
+	tests := []struct {
+		name           string
+		conversationID uint
+		content        string
+		want           string
+	}{
+		{"empty", f.conversationID, "   ", "message is required"},
+		{"too long", f.conversationID, strings.Repeat("운", maxChatMessageRunes+1), "message too long"},
+		{"unknown conversation", f.conversationID + 100, "안녕하세요", "conversation not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.SendMessage(context.Background(), f.userID, tt.conversationID, "ko", tt.content, nil)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+	if n := f.conversations.count("user"); n != 0 {
+		t.Errorf("%d questions saved", n)
+	}
+}
+
+func TestSendMessageConcurrentlyNeverExceedsDailyLimit(t *testing.T) {
+	f := newChatFixture(3, 4000)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.send(context.Background(), "오늘 연락해도 될까요?")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	sent, limited := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			sent++
+		case err.Error() == "daily message limit exceeded":
+			limited++
+		default:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if sent != 3 || limited != 7 || f.conversations.count("user") != 3 {
+		t.Errorf("sent %d, limited %d, saved %d", sent, limited, f.conversations.count("user"))
+	}
+}
+
+func TestSendMessageReleasesSlotWhenUnanswered(t *testing.T) {
+	f := newChatFixture(1, 4000)
+
+	// AI가 실패하면 질문을 지워 하루 질문 수를 차지하지 않는다
+	f.ai.err = errors.New("upstream unavailable")
+	if _, err := f.send(context.Background(), "이직해도 될까요?"); err == nil || err.Error() != "counselor unavailable" {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 답변 도중 클라이언트가 끊겨도 마찬가지다
+	f.ai.err = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := f.service.SendMessage(ctx, f.userID, f.conversationID, "ko", "이직해도 될까요?", func(delta string) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if f.conversations.count("user") != 0 || f.conversations.count("assistant") != 0 {
+		t.Fatalf("messages left behind: %+v", f.conversations.messages)
+	}
+
+	reply, err := f.send(context.Background(), "이직해도 될까요?")
+	if err != nil || reply.RemainingMessages != 0 {
+		t.Fatalf("after release: reply %v, err %v", reply, err)
+	}
+	if _, err := f.send(context.Background(), "하나만 더요"); err == nil || err.Error() != "daily message limit exceeded" {
+		t.Errorf("over limit: err = %v", err)
+	}
+}
+
+func TestSendMessageSummarizesOldMessages(t *testing.T) {
+	tests := []struct {
+		name        string
+		summary     string
+		wantSummary string
+		wantUntil   uint
+	}{
+		{"summarized", "직장 고민을 나눴다", "직장 고민을 나눴다", 106},
+		// 요약에 실패하면 이번 요청에서만 앞부분을 뺀다
+		{"summary failed", "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newChatFixture(20, 100)
+			f.ai.summary = tt.summary
+			for i := 0; i < 8; i++ {
+				role := []string{"user", "assistant"}[i%2]
+				f.conversations.messages = append(f.conversations.messages, models.ChatMessage{
+					ID: uint(100 + i), ConversationID: f.conversationID, UserID: f.userID, Role: role, Content: role, TokenCount: 30,
+					CreatedAt: time.Now().AddDate(0, 0, -1),
+				})
+			}
+			f.conversations.nextID = 200
+
+			if _, err := f.send(context.Background(), "그럼 언제가 좋을까요?"); err != nil {
+				t.Fatal(err)
+			}
+
+			// 240토큰 중 한도의 절반(50)에 들어오는 마지막 메시지만 남기고 앞의 7개를 요약한다
+			if len(f.ai.summarized) != 1 || len(f.ai.summarized[0]) != 7 || f.ai.summarized[0][6].ID != 106 {
+				t.Fatalf("summarized %+v", f.ai.summarized)
+			}
+			input := f.ai.inputs[0]
+			if len(input.History) != 1 || input.History[0].ID != 107 || input.Summary != tt.wantSummary {
+				t.Errorf("history %+v, summary %q", input.History, input.Summary)
+			}
+			conversation, _ := f.conversations.FindByID(f.userID, f.conversationID)
+			if conversation.Summary != tt.wantSummary || conversation.SummarizedUntilID != tt.wantUntil {
+				t.Errorf("saved summary %q until %d", conversation.Summary, conversation.SummarizedUntilID)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+// 한 번에 읽어 점수를 매기는 후보 수
+const matchBatchSize = 500
+
+// 매칭 후보 조건. 0이나 빈 값은 적용하지 않는다
+type MatchFilter struct {
+	Genders []string
+	MinAge  int
+	MaxAge  int
+}
+
+type MatchResult struct {
+	User          models.User           `json:"user"`
+	Compatibility *models.Compatibility `json:"compatibility"`
+}
+
+type MatchPage struct {
+	Matches      []MatchResult `json:"matches"`
+	RelationType string        `json:"relation_type"`
+	Page         int           `json:"page"`
+	PageSize     int           `json:"page_size"`
+	Total        int           `json:"total"`
+}
+
+// 나이는 출생 연도 기준 만 나이로 근사한다
+func (f MatchFilter) candidateFilter(now time.Time) repository.MatchCandidateFilter {
+	filter := repository.MatchCandidateFilter{Genders: f.Genders}
+	if f.MinAge > 0 {
+		filter.MaxBirthYear = now.Year() - f.MinAge
+	}
+	if f.MaxAge > 0 {
+		filter.MinBirthYear = now.Year() - f.MaxAge
+	}
+	return filter
+}
+
+// 저장된 선호와 요청 조건에 맞는 후보를 DB에서 궁합 점수 순으로 정렬해 요청한 페이지만 상세 결과로 만든다
+func (s *compatibilityService) discoverMatches(userID uint, locale string, query MatchQuery, best bool, page, pageSize int) (*MatchPage, error) {
+	filter, relationType, err := loadMatchFilter(s.matchPreferenceRepo, userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	fortune, err := s.fortuneRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, errors.New("fortune info not found")
+	}
+	myMap := fortuneInfoToMap(fortune)
+	myGender := s.userGender(userID)
+
+	key := utils.RelationScoreKey(myMap, relationType, myGender)
+	lookups := utils.RelationScoreLookups(myMap, relationType, myGender)
+	scores, total, err := s.fortuneRepo.FindMatchScores(userID, key, lookups, filter.candidateFilter(time.Now()), best, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MatchPage{
+		Matches:      []MatchResult{},
+		RelationType: relationType,
+		Page:         page,
+		PageSize:     pageSize,
+		Total:        int(total),
+	}
+
+	ids := make([]uint, len(scores))
+	for i, score := range scores {
+		ids[i] = score.UserID
+	}
+	users, err := s.userRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	for _, score := range scores {
+		user, ok := byID[score.UserID]
+		if !ok || user.FortuneInfo == nil {
+			continue
+		}
+		compatibility, _ := buildCompatibility(myMap, fortuneInfoToMap(user.FortuneInfo), relationType, myGender, user.Gender, locale)
+		compatibility.User1ID = userID
+		compatibility.User2ID = user.ID
+
+		user.FortuneInfo = nil
+		result.Matches = append(result.Matches, MatchResult{
+			User:          user,
+			Compatibility: compatibility,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"dothefortune_server/internal/models"
+)
+
+type CompatibilityNarrative struct {
+	Compatibility *models.Compatibility `json:"compatibility"`
+	Narrative     string                `json:"narrative"`
+	RecordID      uint                  `json:"record_id"`
+}
+
+// 궁합을 계산(또는 저장된 결과를 조회)한 뒤 AI 궁합 이야기를 onDelta로 흘려보내고 기록으로 남긴다.
+// AI 문장은 문장마다 검사를 통과한 것만 보내며, 하나도 보내지 못하고 실패하면 템플릿 분석 문구를 한 번에 보낸다. 클라이언트가 끊기면 기록을 남기지 않는다.
+// 사용량 한도를 넘었으면 *AIQuotaExceededError를 반환한다
+func (s *compatibilityService) StreamCompatibilityNarrative(ctx context.Context, user1ID, user2ID uint, relationType, locale string, onDelta func(delta string) error) (*CompatibilityNarrative, error) {
+	compatibility, err := s.GetCompatibility(user1ID, user2ID, relationType, locale)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.aiUsage.CheckQuota(user1ID); err != nil {
+		return nil, err
+	}
+
+	ctx = WithAIUsage(ctx, user1ID, AIFeatureCompatibilityNarrative)
+	narrative, promptVersion, err := s.aiService.StreamCompatibilityNarrative(ctx, compatibility, onDelta)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil || narrative == "" {
+		log.Printf("Failed to generate compatibility narrative for users %d, %d: %v", user1ID, user2ID, err)
+		narrative = templateNarrative(compatibility)
+		promptVersion = ""
+		if err := onDelta(narrative); err != nil {
+			return nil, err
+		}
+	}
+
+	record := &models.FortuneRecord{
+		UserID:  user1ID,
+		Type:    "compatibility_narrative",
+		Content: narrative,
+		Metadata: fmt.Sprintf(`{"user2_id": %d, "compatibility_id": %d, "score": %.1f, "relation_type": "%s", "prompt_version": "%s"}`,
+			user2ID, compatibility.ID, compatibility.Score, compatibility.RelationType, promptVersion),
+	}
+	if err := s.recordRepo.Create(record); err != nil {
+		return nil, err
+	}
+
+	return &CompatibilityNarrative{
+		Compatibility: compatibility,
+		Narrative:     narrative,
+		RecordID:      record.ID,
+	}, nil
+}
+
+func templateNarrative(compatibility *models.Compatibility) string {
+	parts := []string{
+		compatibility.Analysis,
+		compatibility.CommunicationAnalysis,
+		compatibility.EmotionAnalysis,
+		compatibility.LifestyleAnalysis,
+		compatibility.CautionAnalysis,
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
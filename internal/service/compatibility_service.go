@@ -1,41 +1,92 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"slices"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
 	"dothefortune_server/internal/models"
 	"dothefortune_server/internal/repository"
 	"dothefortune_server/internal/utils"
 )
 
+// 가입하지 않은 상대의 출생 정보
+type PartnerBirthInfo struct {
+	Nickname     string
+	Gender       string
+	BirthYear    int
+	BirthMonth   int
+	BirthDay     int
+	BirthHour    int
+	BirthMinute  int
+	UnknownTime  bool
+	IsLunar      bool
+	RelationType string
+	Locale       string
+}
+
 type CompatibilityService interface {
-	CalculateCompatibility(user1ID, user2ID uint) (*models.Compatibility, error)
-	GetCompatibility(user1ID, user2ID uint) (*models.Compatibility, error)
-	GetBestMatches(userID uint, limit int) ([]models.Compatibility, error)
-	GetWorstMatches(userID uint, limit int) ([]models.Compatibility, error)
+	// 분석 문장과 카테고리 이름은 locale로 쓴다. 저장된 궁합의 언어가 다르면 다시 만들어 갱신한다
+	CalculateCompatibility(user1ID, user2ID uint, relationType, locale string) (*models.Compatibility, error)
+	GetCompatibility(user1ID, user2ID uint, relationType, locale string) (*models.Compatibility, error)
+	GetBestMatches(userID uint, locale string, query MatchQuery, page, pageSize int) (*MatchPage, error)
+	GetWorstMatches(userID uint, locale string, query MatchQuery, page, pageSize int) (*MatchPage, error)
+	SetMatchOptOut(userID uint, optOut bool) error
+	GetMatchPreference(userID uint) (*models.MatchPreference, error)
+	UpdateMatchPreference(userID uint, input MatchPreferenceInput) (*models.MatchPreference, error)
+	StreamCompatibilityNarrative(ctx context.Context, user1ID, user2ID uint, relationType, locale string, onDelta func(delta string) error) (*CompatibilityNarrative, error)
+	CalculateGuestCompatibility(userID uint, partner PartnerBirthInfo, saveContact bool) (*models.Compatibility, *models.PartnerContact, error)
+	CalculateContactCompatibility(userID, contactID uint, relationType, locale string) (*models.Compatibility, *models.PartnerContact, error)
+	GetContacts(userID uint) ([]models.PartnerContact, error)
+	DeleteContact(userID, contactID uint) error
 }
 
 type compatibilityService struct {
-	compatibilityRepo repository.CompatibilityRepository
-	fortuneRepo       repository.FortuneRepository
-	recordRepo        repository.RecordRepository
+	compatibilityRepo   repository.CompatibilityRepository
+	fortuneRepo         repository.FortuneRepository
+	userRepo            repository.UserRepository
+	recordRepo          repository.RecordRepository
+	partnerContactRepo  repository.PartnerContactRepository
+	matchPreferenceRepo repository.MatchPreferenceRepository
+	aiService           AIService
+	aiUsage             AIUsageService
+	jobQueue            JobQueue
+	aiAnalysis          bool // 카테고리별 분석을 AI로 작성할지
 }
 
-func NewCompatibilityService(compatibilityRepo repository.CompatibilityRepository, fortuneRepo repository.FortuneRepository, recordRepo repository.RecordRepository) CompatibilityService {
+func NewCompatibilityService(compatibilityRepo repository.CompatibilityRepository, fortuneRepo repository.FortuneRepository, userRepo repository.UserRepository, recordRepo repository.RecordRepository, partnerContactRepo repository.PartnerContactRepository, matchPreferenceRepo repository.MatchPreferenceRepository, aiService AIService, aiUsage AIUsageService, jobQueue JobQueue, cfg *config.Config) CompatibilityService {
 	return &compatibilityService{
-		compatibilityRepo: compatibilityRepo,
-		fortuneRepo:       fortuneRepo,
-		recordRepo:        recordRepo,
+		compatibilityRepo:   compatibilityRepo,
+		fortuneRepo:         fortuneRepo,
+		userRepo:            userRepo,
+		recordRepo:          recordRepo,
+		partnerContactRepo:  partnerContactRepo,
+		matchPreferenceRepo: matchPreferenceRepo,
+		aiService:           aiService,
+		aiUsage:             aiUsage,
+		jobQueue:            jobQueue,
+		aiAnalysis:          cfg.AICompatibilityMode == AICompatibilityModeAI,
 	}
 }
 
-func (s *compatibilityService) CalculateCompatibility(user1ID, user2ID uint) (*models.Compatibility, error) {
+func (s *compatibilityService) CalculateCompatibility(user1ID, user2ID uint, relationType, locale string) (*models.Compatibility, error) {
 	if user1ID == user2ID {
 		return nil, errors.New("cannot calculate compatibility with yourself")
 	}
+	if !utils.IsValidRelationType(relationType) {
+		return nil, errors.New("invalid relation type")
+	}
 
-	existing, err := s.compatibilityRepo.FindByUserPair(user1ID, user2ID)
+	existing, err := s.compatibilityRepo.FindByUserPair(user1ID, user2ID, relationType)
 	if err == nil && existing != nil {
+		if err := s.refreshIfStale(existing, locale); err != nil {
+			return nil, err
+		}
 		return existing, nil
 	}
 
@@ -49,29 +100,318 @@ func (s *compatibilityService) CalculateCompatibility(user1ID, user2ID uint) (*m
 		return nil, errors.New("user2 fortune info not found")
 	}
 
-	fortune1Map := map[string]string{
-		"year_stem":   fortune1.YearHeavenlyStem,
-		"year_branch": fortune1.YearEarthlyBranch,
-		"month_stem":  fortune1.MonthHeavenlyStem,
-		"month_branch": fortune1.MonthEarthlyBranch,
-		"day_stem":    fortune1.DayHeavenlyStem,
-		"day_branch":  fortune1.DayEarthlyBranch,
-		"hour_stem":   fortune1.HourHeavenlyStem,
-		"hour_branch": fortune1.HourEarthlyBranch,
+	fortune1Map := fortuneInfoToMap(fortune1)
+	fortune2Map := fortuneInfoToMap(fortune2)
+	gender1, gender2 := s.userGender(user1ID), s.userGender(user2ID)
+	compatibility, rules := buildCompatibility(fortune1Map, fortune2Map, relationType, gender1, gender2, locale)
+	s.personalizeAnalysis(user1ID, compatibility, fortune1Map, fortune2Map, gender1, gender2, rules)
+	compatibility.User1ID = user1ID
+	compatibility.User2ID = user2ID
+
+	if err := s.compatibilityRepo.Create(compatibility); err != nil {
+		return nil, err
+	}
+
+	record := &models.FortuneRecord{
+		UserID:  user1ID,
+		Type:    "compatibility",
+		Content: fmt.Sprintf("Compatibility with user %d: %.1f%%", user2ID, compatibility.Score),
+		Metadata: fmt.Sprintf(`{"user2_id": %d, "score": %.1f, "type": "%s", "relation_type": "%s"}`, user2ID, compatibility.Score, compatibility.CompatibilityType, relationType),
+	}
+
+	enqueueRecord(s.jobQueue, record)
+
+	return compatibility, nil
+}
+
+func (s *compatibilityService) GetCompatibility(user1ID, user2ID uint, relationType, locale string) (*models.Compatibility, error) {
+	compatibility, err := s.compatibilityRepo.FindByUserPair(user1ID, user2ID, relationType)
+	if err != nil {
+		return s.CalculateCompatibility(user1ID, user2ID, relationType, locale)
+	}
+	if err := s.refreshIfStale(compatibility, locale); err != nil {
+		return nil, err
+	}
+	return compatibility, nil
+}
+
+func (s *compatibilityService) GetBestMatches(userID uint, locale string, query MatchQuery, page, pageSize int) (*MatchPage, error) {
+	return s.discoverMatches(userID, locale, query, true, page, pageSize)
+}
+
+func (s *compatibilityService) GetWorstMatches(userID uint, locale string, query MatchQuery, page, pageSize int) (*MatchPage, error) {
+	return s.discoverMatches(userID, locale, query, false, page, pageSize)
+}
+
+func (s *compatibilityService) SetMatchOptOut(userID uint, optOut bool) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	user.MatchOptOut = optOut
+	user.FortuneInfo = nil
+	return s.userRepo.Update(user)
+}
+
+// 사주 정보가 바뀌었거나 상세 데이터가 없거나 요청 언어와 다른 궁합은 현재 사주로 다시 계산해 같은 행을 갱신한다
+func (s *compatibilityService) refreshIfStale(compatibility *models.Compatibility, locale string) error {
+	fortune1, err := s.fortuneRepo.FindByUserID(compatibility.User1ID)
+	if err != nil {
+		return errors.New("user1 fortune info not found")
+	}
+	fortune2, err := s.fortuneRepo.FindByUserID(compatibility.User2ID)
+	if err != nil {
+		return errors.New("user2 fortune info not found")
+	}
+
+	fortune1Map := fortuneInfoToMap(fortune1)
+	fortune2Map := fortuneInfoToMap(fortune2)
+	compatibility.User1Chart = fortune1Map
+	compatibility.User2Chart = fortune2Map
+
+	gender1, gender2 := s.userGender(compatibility.User1ID), s.userGender(compatibility.User2ID)
+	if !compatibility.Stale &&
+		compatibility.CategoryScores != nil &&
+		compatibility.User1Fingerprint == utils.ChartFingerprint(fortune1Map) &&
+		compatibility.User2Fingerprint == utils.ChartFingerprint(fortune2Map) {
+		// 카테고리 점수 키가 코드가 아니던 예전 결과는 점수만 다시 계산해 둔다
+		if !hasCategoryCodes(compatibility.CategoryScores, compatibility.RelationType) {
+			detail := utils.CalculateRelationCompatibilityScore(fortune1Map, fortune2Map, compatibility.RelationType, gender1, gender2)
+			compatibility.CategoryScores = categoryScores(detail.Categories)
+			if err := s.compatibilityRepo.Update(compatibility); err != nil {
+				return err
+			}
+		}
+		// 언어만 다르면 저장된 결과는 그대로 두고 응답만 요청 언어의 템플릿 문장으로 바꾼다 (AI를 다시 부르지 않는다)
+		if compatibility.Locale != locale {
+			localized, _ := buildCompatibility(fortune1Map, fortune2Map, compatibility.RelationType, gender1, gender2, locale)
+			compatibility.Analysis = localized.Analysis
+			compatibility.CommunicationAnalysis = localized.CommunicationAnalysis
+			compatibility.EmotionAnalysis = localized.EmotionAnalysis
+			compatibility.LifestyleAnalysis = localized.LifestyleAnalysis
+			compatibility.CautionAnalysis = localized.CautionAnalysis
+			compatibility.NarrativeSource = localized.NarrativeSource
+			compatibility.Locale = locale
+		}
+		compatibility.CategoryLabels = categoryLabels(compatibility.CategoryScores, locale)
+		return nil
+	}
+
+	fresh, rules := buildCompatibility(fortune1Map, fortune2Map, compatibility.RelationType, gender1, gender2, locale)
+	s.personalizeAnalysis(compatibility.User1ID, fresh, fortune1Map, fortune2Map, gender1, gender2, rules)
+
+	compatibility.Score = fresh.Score
+	compatibility.Analysis = fresh.Analysis
+	compatibility.CompatibilityType = fresh.CompatibilityType
+	compatibility.CommunicationAnalysis = fresh.CommunicationAnalysis
+	compatibility.EmotionAnalysis = fresh.EmotionAnalysis
+	compatibility.LifestyleAnalysis = fresh.LifestyleAnalysis
+	compatibility.CautionAnalysis = fresh.CautionAnalysis
+	compatibility.NarrativeSource = fresh.NarrativeSource
+	compatibility.NarrativePromptVersion = fresh.NarrativePromptVersion
+	compatibility.User1Elements = fresh.User1Elements
+	compatibility.User2Elements = fresh.User2Elements
+	compatibility.CategoryScores = fresh.CategoryScores
+	compatibility.CategoryLabels = fresh.CategoryLabels
+	compatibility.User1Fingerprint = fresh.User1Fingerprint
+	compatibility.User2Fingerprint = fresh.User2Fingerprint
+	compatibility.Locale = fresh.Locale
+	compatibility.Stale = false
+
+	return s.compatibilityRepo.Update(compatibility)
+}
+
+func (s *compatibilityService) CalculateGuestCompatibility(userID uint, partner PartnerBirthInfo, saveContact bool) (*models.Compatibility, *models.PartnerContact, error) {
+	if partner.RelationType == "" {
+		partner.RelationType = utils.RelationRomantic
+	}
+	if !utils.IsValidRelationType(partner.RelationType) {
+		return nil, nil, errors.New("invalid relation type")
 	}
 
-	fortune2Map := map[string]string{
-		"year_stem":   fortune2.YearHeavenlyStem,
-		"year_branch": fortune2.YearEarthlyBranch,
-		"month_stem":  fortune2.MonthHeavenlyStem,
-		"month_branch": fortune2.MonthEarthlyBranch,
-		"day_stem":    fortune2.DayHeavenlyStem,
-		"day_branch":  fortune2.DayEarthlyBranch,
-		"hour_stem":   fortune2.HourHeavenlyStem,
-		"hour_branch": fortune2.HourEarthlyBranch,
+	fortune, err := s.fortuneRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.New("fortune info not found")
 	}
 
-	score := utils.CalculateCompatibilityScore(fortune1Map, fortune2Map)
+	contact := newPartnerContact(userID, partner)
+	if saveContact {
+		if contact.Nickname == "" {
+			return nil, nil, errors.New("nickname is required to save contact")
+		}
+		if err := s.partnerContactRepo.Create(contact); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	compatibility := s.buildPartnerCompatibility(userID, fortune, contact, partner.RelationType, partner.Locale)
+
+	s.createPartnerRecord(userID, contact, compatibility)
+
+	if !saveContact {
+		return compatibility, nil, nil
+	}
+	return compatibility, contact, nil
+}
+
+func (s *compatibilityService) CalculateContactCompatibility(userID, contactID uint, relationType, locale string) (*models.Compatibility, *models.PartnerContact, error) {
+	contact, err := s.partnerContactRepo.FindByID(userID, contactID)
+	if err != nil {
+		return nil, nil, errors.New("contact not found")
+	}
+
+	// 관계 유형을 지정하지 않으면 저장할 때의 관계 유형으로 다시 본다
+	if relationType == "" {
+		relationType = contact.RelationType
+	}
+	if !utils.IsValidRelationType(relationType) {
+		return nil, nil, errors.New("invalid relation type")
+	}
+
+	fortune, err := s.fortuneRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.New("fortune info not found")
+	}
+
+	compatibility := s.buildPartnerCompatibility(userID, fortune, contact, relationType, locale)
+
+	s.createPartnerRecord(userID, contact, compatibility)
+
+	return compatibility, contact, nil
+}
+
+func (s *compatibilityService) GetContacts(userID uint) ([]models.PartnerContact, error) {
+	return s.partnerContactRepo.FindByUserID(userID)
+}
+
+func (s *compatibilityService) DeleteContact(userID, contactID uint) error {
+	if _, err := s.partnerContactRepo.FindByID(userID, contactID); err != nil {
+		return errors.New("contact not found")
+	}
+	return s.partnerContactRepo.Delete(userID, contactID)
+}
+
+func (s *compatibilityService) buildPartnerCompatibility(userID uint, fortune *models.FortuneInfo, contact *models.PartnerContact, relationType, locale string) *models.Compatibility {
+	fortune1Map := fortuneInfoToMap(fortune)
+	fortune2Map := partnerContactToMap(contact)
+	gender1 := s.userGender(userID)
+
+	compatibility, rules := buildCompatibility(fortune1Map, fortune2Map, relationType, gender1, contact.Gender, locale)
+	s.personalizeAnalysis(userID, compatibility, fortune1Map, fortune2Map, gender1, contact.Gender, rules)
+	compatibility.User1ID = userID
+	return compatibility
+}
+
+// AI 분석 모드면 대화/감정/생활/주의 문단을 AI가 두 사주에 맞춰 다시 쓴다.
+// AI 호출이 실패하거나 비워 둔 문단은 템플릿 문구를 그대로 둔다. 궁합 계산 자체는 AI 없이 되므로
+// 사용량 한도를 넘었을 때도 오류 대신 템플릿 문구로 응답한다
+func (s *compatibilityService) personalizeAnalysis(userID uint, compatibility *models.Compatibility, fortune1Map, fortune2Map map[string]string, gender1, gender2 string, rules []string) {
+	if !s.aiAnalysis {
+		return
+	}
+	if err := s.aiUsage.CheckQuota(userID); err != nil {
+		log.Printf("Skipping AI compatibility analysis for user %d: %v", userID, err)
+		return
+	}
+
+	ctx := WithAIUsage(context.Background(), userID, AIFeatureCompatibilityAnalysis)
+	texts, err := s.aiService.GenerateCompatibilityAnalysis(ctx, CompatibilityAnalysisInput{
+		Compatibility: compatibility,
+		Fortune1:      fortune1Map,
+		Fortune2:      fortune2Map,
+		Gender1:       gender1,
+		Gender2:       gender2,
+		Rules:         rules,
+	})
+	if err != nil {
+		log.Printf("Failed to generate compatibility analysis, using templates: %v", err)
+		return
+	}
+
+	replaced := false
+	for _, target := range []struct {
+		text  string
+		field *string
+	}{
+		{texts.Communication, &compatibility.CommunicationAnalysis},
+		{texts.Emotion, &compatibility.EmotionAnalysis},
+		{texts.Lifestyle, &compatibility.LifestyleAnalysis},
+		{texts.Caution, &compatibility.CautionAnalysis},
+	} {
+		if target.text != "" {
+			*target.field = target.text
+			replaced = true
+		}
+	}
+	if replaced {
+		compatibility.NarrativeSource = NarrativeSourceAI
+		compatibility.NarrativePromptVersion = texts.PromptVersion
+	}
+}
+
+func (s *compatibilityService) createPartnerRecord(userID uint, contact *models.PartnerContact, compatibility *models.Compatibility) {
+	name := contact.Nickname
+	if name == "" {
+		name = "guest partner"
+	}
+	nickname, _ := json.Marshal(contact.Nickname)
+	record := &models.FortuneRecord{
+		UserID:   userID,
+		Type:     "compatibility",
+		Content:  fmt.Sprintf("Compatibility with %s: %.1f%%", name, compatibility.Score),
+		Metadata: fmt.Sprintf(`{"partner_contact_id": %d, "nickname": %s, "score": %.1f, "type": "%s", "relation_type": "%s"}`, contact.ID, nickname, compatibility.Score, compatibility.CompatibilityType, compatibility.RelationType),
+	}
+
+	enqueueRecord(s.jobQueue, record)
+}
+
+// 성별을 알 수 없으면 빈 문자열 (배우자성 가산점만 빠진다)
+func (s *compatibilityService) userGender(userID uint) string {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ""
+	}
+	return user.Gender
+}
+
+func newPartnerContact(userID uint, partner PartnerBirthInfo) *models.PartnerContact {
+	if partner.UnknownTime {
+		partner.BirthHour = 12
+		partner.BirthMinute = 0
+	}
+
+	yearStem, yearBranch, monthStem, monthBranch, dayStem, dayBranch, hourStem, hourBranch :=
+		utils.CalculateFortunePillars(partner.BirthYear, partner.BirthMonth, partner.BirthDay, partner.BirthHour)
+
+	return &models.PartnerContact{
+		UserID:             userID,
+		Nickname:           partner.Nickname,
+		RelationType:       partner.RelationType,
+		Gender:             partner.Gender,
+		BirthYear:          partner.BirthYear,
+		BirthMonth:         partner.BirthMonth,
+		BirthDay:           partner.BirthDay,
+		BirthHour:          partner.BirthHour,
+		BirthMinute:        partner.BirthMinute,
+		UnknownTime:        partner.UnknownTime,
+		IsLunar:            partner.IsLunar,
+		YearHeavenlyStem:   yearStem,
+		YearEarthlyBranch:  yearBranch,
+		MonthHeavenlyStem:  monthStem,
+		MonthEarthlyBranch: monthBranch,
+		DayHeavenlyStem:    dayStem,
+		DayEarthlyBranch:   dayBranch,
+		HourHeavenlyStem:   hourStem,
+		HourEarthlyBranch:  hourBranch,
+	}
+}
+
+// 두 사주로 점수, 타입, 카테고리별 분석을 채운 궁합 결과와 적용된 규칙 목록을 만든다 (사용자 ID는 호출 측에서 채움)
+// 분석 문장, 카테고리 이름, 규칙 설명은 locale로 쓴다
+func buildCompatibility(fortune1Map, fortune2Map map[string]string, relationType, gender1, gender2, locale string) (*models.Compatibility, []string) {
+	detail := utils.CalculateRelationCompatibilityScore(fortune1Map, fortune2Map, relationType, gender1, gender2)
+	score := detail.Score
+	templates := getRelationTemplates(relationType, locale)
 
 	compatibilityType := "normal"
 	if score >= 80 {
@@ -82,133 +422,180 @@ func (s *compatibilityService) CalculateCompatibility(user1ID, user2ID uint) (*m
 		compatibilityType = "poor"
 	}
 
-	analysis := generateCompatibilityAnalysis(score, compatibilityType)
-	
-	commAnalysis, emotionAnalysis, lifestyleAnalysis, cautionAnalysis := 
-		generateCategoryAnalysis(fortune1Map, fortune2Map, score)
+	analysis := generateCompatibilityAnalysis(templates, compatibilityType)
+	categories := generateCategoryAnalysis(templates, fortune1Map, fortune2Map)
 
 	compatibility := &models.Compatibility{
-		User1ID:              user1ID,
-		User2ID:              user2ID,
-		Score:                score,
-		Analysis:             analysis,
-		CompatibilityType:    compatibilityType,
-		CommunicationAnalysis: commAnalysis,
-		EmotionAnalysis:      emotionAnalysis,
-		LifestyleAnalysis:    lifestyleAnalysis,
-		CautionAnalysis:      cautionAnalysis,
+		RelationType:          relationType,
+		Score:                 score,
+		Analysis:              analysis,
+		CompatibilityType:     compatibilityType,
+		CommunicationAnalysis: categories.Communication,
+		EmotionAnalysis:       categories.Emotion,
+		LifestyleAnalysis:     categories.Lifestyle,
+		CautionAnalysis:       categories.Caution,
+		NarrativeSource:       NarrativeSourceTemplate,
+		User1Elements:         detail.ElementDistribution,
+		User2Elements:         detail.PartnerElementDistribution,
+		CategoryScores:        categoryScores(detail.Categories),
+		User1Fingerprint:      utils.ChartFingerprint(fortune1Map),
+		User2Fingerprint:      utils.ChartFingerprint(fortune2Map),
+		Locale:                locale,
+		User1Chart:            fortune1Map,
+		User2Chart:            fortune2Map,
 	}
 
-	if err := s.compatibilityRepo.Create(compatibility); err != nil {
-		return nil, err
-	}
+	compatibility.CategoryLabels = categoryLabels(compatibility.CategoryScores, locale)
 
-	record := &models.FortuneRecord{
-		UserID:  user1ID,
-		Type:    "compatibility",
-		Content: fmt.Sprintf("Compatibility with user %d: %.1f%%", user2ID, score),
-		Metadata: fmt.Sprintf(`{"user2_id": %d, "score": %.1f, "type": "%s"}`, user2ID, score, compatibilityType),
+	rules := make([]string, 0, len(detail.Rules)+len(categories.Rules))
+	for _, rule := range detail.Rules {
+		rules = append(rules, i18n.T(locale, rule))
 	}
+	return compatibility, append(rules, categories.Rules...)
+}
 
-	s.recordRepo.Create(record)
+// 카테고리 코드별 점수. 키는 언어와 관계없이 코드를 쓴다 (표시 이름은 categoryLabels)
+func categoryScores(categories map[string]utils.CategoryScore) map[string]float64 {
+	scores := make(map[string]float64, len(categories))
+	for code, category := range categories {
+		scores[code] = category.Score
+	}
+	return scores
+}
 
-	return compatibility, nil
+// 카테고리 코드를 표시 이름으로 바꾼다 (레이더 차트 라벨)
+func categoryLabels(scores map[string]float64, locale string) map[string]string {
+	labels := make(map[string]string, len(scores))
+	for code := range scores {
+		labels[code] = i18n.T(locale, "category."+code)
+	}
+	return labels
 }
 
-func (s *compatibilityService) GetCompatibility(user1ID, user2ID uint) (*models.Compatibility, error) {
-	compatibility, err := s.compatibilityRepo.FindByUserPair(user1ID, user2ID)
-	if err != nil {
-		return s.CalculateCompatibility(user1ID, user2ID)
+func hasCategoryCodes(scores map[string]float64, relationType string) bool {
+	codes := utils.GetRelationCategoryNames(relationType)
+	for key := range scores {
+		if !slices.Contains(codes, key) {
+			return false
+		}
 	}
-	return compatibility, nil
+	return true
 }
 
-func (s *compatibilityService) GetBestMatches(userID uint, limit int) ([]models.Compatibility, error) {
-	return s.compatibilityRepo.FindBestMatches(userID, limit)
+func fortuneInfoToMap(info *models.FortuneInfo) map[string]string {
+	return map[string]string{
+		"year_stem":    info.YearHeavenlyStem,
+		"year_branch":  info.YearEarthlyBranch,
+		"month_stem":   info.MonthHeavenlyStem,
+		"month_branch": info.MonthEarthlyBranch,
+		"day_stem":     info.DayHeavenlyStem,
+		"day_branch":   info.DayEarthlyBranch,
+		"hour_stem":    info.HourHeavenlyStem,
+		"hour_branch":  info.HourEarthlyBranch,
+	}
 }
 
-func (s *compatibilityService) GetWorstMatches(userID uint, limit int) ([]models.Compatibility, error) {
-	return s.compatibilityRepo.FindWorstMatches(userID, limit)
+func partnerContactToMap(contact *models.PartnerContact) map[string]string {
+	return map[string]string{
+		"year_stem":    contact.YearHeavenlyStem,
+		"year_branch":  contact.YearEarthlyBranch,
+		"month_stem":   contact.MonthHeavenlyStem,
+		"month_branch": contact.MonthEarthlyBranch,
+		"day_stem":     contact.DayHeavenlyStem,
+		"day_branch":   contact.DayEarthlyBranch,
+		"hour_stem":    contact.HourHeavenlyStem,
+		"hour_branch":  contact.HourEarthlyBranch,
+	}
 }
 
-func generateCompatibilityAnalysis(score float64, compatibilityType string) string {
-	switch compatibilityType {
-	case "excellent":
-		return "두 사람은 매우 좋은 궁합을 가지고 있습니다. 서로를 잘 이해하고 보완하는 관계가 될 것입니다."
-	case "good":
-		return "두 사람은 좋은 궁합을 가지고 있습니다. 서로 협력하며 발전할 수 있는 관계입니다."
-	case "poor":
-		return "두 사람은 서로 다른 성향을 가지고 있어 이해가 필요합니다. 인내심과 소통이 중요합니다."
-	default:
-		return "두 사람은 평범한 궁합을 가지고 있습니다. 서로의 차이를 존중하며 관계를 발전시켜 나가세요."
+func generateCompatibilityAnalysis(templates compatibilityTemplates, compatibilityType string) string {
+	if summary, ok := templates.Summary[compatibilityType]; ok {
+		return summary
 	}
+	return templates.Summary["normal"]
 }
 
-func generateCategoryAnalysis(fortune1, fortune2 map[string]string, score float64) (commAnalysis, emotionAnalysis, lifestyleAnalysis, cautionAnalysis string) {
+// 카테고리별 분석 문구와, 문구를 고를 때 적용된 규칙
+type categoryAnalysis struct {
+	Communication string
+	Emotion       string
+	Lifestyle     string
+	Caution       string
+	Rules         []string
+}
+
+func generateCategoryAnalysis(templates compatibilityTemplates, fortune1, fortune2 map[string]string) categoryAnalysis {
 	dayStem1 := fortune1["day_stem"]
 	dayStem2 := fortune2["day_stem"]
 	dayBranch1 := fortune1["day_branch"]
 	dayBranch2 := fortune2["day_branch"]
 
-	commAnalysis = analyzeCommunication(dayStem1, dayStem2)
-	emotionAnalysis = analyzeEmotion(fortune1, fortune2)
-	lifestyleAnalysis = analyzeLifestyle(dayBranch1, dayBranch2)
-	cautionAnalysis = analyzeCaution(dayBranch1, dayBranch2)
+	var analysis categoryAnalysis
+	var rules [4]string
+	analysis.Communication, rules[0] = analyzeCommunication(templates, dayStem1, dayStem2)
+	analysis.Emotion, rules[1] = analyzeEmotion(templates, fortune1, fortune2)
+	analysis.Lifestyle, rules[2] = analyzeLifestyle(templates, dayBranch1, dayBranch2)
+	analysis.Caution, rules[3] = analyzeCaution(templates, dayBranch1, dayBranch2)
 
-	return commAnalysis, emotionAnalysis, lifestyleAnalysis, cautionAnalysis
+	for _, rule := range rules {
+		if rule != "" {
+			analysis.Rules = append(analysis.Rules, rule)
+		}
+	}
+	return analysis
 }
 
-func analyzeCommunication(stem1, stem2 string) string {
+// 아래 analyze 함수들은 문구와 함께 적용된 규칙을 반환한다 (기본 문구면 규칙은 비어 있다)
+func analyzeCommunication(templates compatibilityTemplates, stem1, stem2 string) (string, string) {
 	if utils.IsHeavenlyStemPair(stem1, stem2) {
-		return "말하지 않아도 통하는 텔레파시가 있어요."
+		return templates.CommunicationPair, i18n.T(templates.Locale, "rule.day_stem_pair")
 	}
 	if utils.IsHeavenlyStemClash(stem1, stem2) {
-		return "가치관이 달라 논쟁이 될 수 있지만, 새로운 시각을 줘요."
+		return templates.CommunicationClash, i18n.T(templates.Locale, "rule.day_stem_clash")
 	}
 	element1 := utils.GetElement(stem1)
 	element2 := utils.GetElement(stem2)
 	if element1 == element2 && element1 != "" {
-		return "친구처럼 편안하게 대화가 흘러가요."
+		return templates.CommunicationSame, i18n.T(templates.Locale, "rule.same_day_element")
 	}
-	return "서로 다른 관점을 나누며 대화가 이어져요."
+	return templates.CommunicationDefault, ""
 }
 
-func analyzeEmotion(fortune1, fortune2 map[string]string) string {
+func analyzeEmotion(templates compatibilityTemplates, fortune1, fortune2 map[string]string) (string, string) {
 	user1Elements := utils.GetFiveElements(fortune1)
 	user2Elements := utils.GetFiveElements(fortune2)
-	
+
 	complementCount := utils.CountComplementaryElements(user1Elements, user2Elements)
 	if complementCount >= 2 {
-		return "서로의 부족한 점을 감싸주는 안정감을 느껴요."
+		return templates.EmotionComplement, i18n.T(templates.Locale, "rule.complement_elements", complementCount)
 	}
-	
+
 	if utils.HasElementBias(user1Elements, user2Elements) {
-		return "성격이 너무 비슷해서 오히려 부딪힐 때가 있어요."
+		return templates.EmotionBias, i18n.T(templates.Locale, "rule.element_bias")
 	}
-	
-	return "서로의 감정을 잘 이해하고 공감할 수 있어요."
+
+	return templates.EmotionDefault, ""
 }
 
-func analyzeLifestyle(branch1, branch2 string) string {
+func analyzeLifestyle(templates compatibilityTemplates, branch1, branch2 string) (string, string) {
 	if utils.IsEarthlyBranchSixPair(branch1, branch2) {
-		return "함께 무언가를 도모하면 손발이 척척 맞아요."
+		return templates.LifestyleSixPair, i18n.T(templates.Locale, "rule.day_branch_six_pair")
 	}
 	if utils.IsEarthlyBranchThreePair(branch1, branch2) {
-		return "목표와 가치관이 잘 맞아 협력이 잘 돼요."
+		return templates.LifestyleThreePair, i18n.T(templates.Locale, "rule.day_branch_three_pair")
 	}
 	if utils.IsEarthlyBranchClash(branch1, branch2) {
-		return "활동 반경이나 생활 패턴이 달라서 조율이 필요해요."
+		return templates.LifestyleClash, i18n.T(templates.Locale, "rule.day_branch_clash")
 	}
-	return "서로의 생활 방식을 존중하며 조화롭게 지낼 수 있어요."
+	return templates.LifestyleDefault, ""
 }
 
-func analyzeCaution(branch1, branch2 string) string {
+func analyzeCaution(templates compatibilityTemplates, branch1, branch2 string) (string, string) {
 	if utils.IsEarthlyBranchResentment(branch1, branch2) {
-		return "사소한 오해가 감정 싸움으로 번지지 않게 배려가 필요해요."
+		return templates.CautionResentment, i18n.T(templates.Locale, "rule.day_branch_resentment")
 	}
 	if utils.IsEarthlyBranchClash(branch1, branch2) {
-		return "의견 차이가 있을 때 바로 해결하지 않으면 오래가요."
+		return templates.CautionClash, i18n.T(templates.Locale, "rule.day_branch_clash")
 	}
-	return "특별히 주의할 점은 없으나, 서로 예의를 지키는 게 중요해요."
+	return templates.CautionDefault, ""
 }
-
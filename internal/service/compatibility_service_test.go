@@ -0,0 +1,765 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+// 사용자별 사주만 들고 있는 저장소. 나머지 메서드는 쓰지 않는다
+type chartStore struct {
+	repository.FortuneRepository
+	fortunes map[uint]*models.FortuneInfo
+}
+
+func (r *chartStore) FindByUserID(userID uint) (*models.FortuneInfo, error) {
+	fortune, ok := r.fortunes[userID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	copied := *fortune
+	return &copied, nil
+}
+
+func (r *chartStore) Update(fortune *models.FortuneInfo) error {
+	copied := *fortune
+	r.fortunes[fortune.UserID] = &copied
+	return nil
+}
+
+type serviceUsers struct {
+	users map[uint]models.User
+}
+
+func (r *serviceUsers) Create(user *models.User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *serviceUsers) FindByID(id uint) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &user, nil
+}
+
+func (r *serviceUsers) FindByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *serviceUsers) FindByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *serviceUsers) Update(user *models.User) error {
+	if _, ok := r.users[user.ID]; !ok {
+		return errors.New("record not found")
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+type memoryContacts struct {
+	contacts []models.PartnerContact
+}
+
+func (r *memoryContacts) Create(contact *models.PartnerContact) error {
+	contact.ID = uint(len(r.contacts) + 1)
+	r.contacts = append(r.contacts, *contact)
+	return nil
+}
+
+func (r *memoryContacts) FindByID(userID, contactID uint) (*models.PartnerContact, error) {
+	for _, contact := range r.contacts {
+		if contact.ID == contactID && contact.UserID == userID && !contact.DeletedAt.Valid {
+			return &contact, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryContacts) FindByUserID(userID uint) ([]models.PartnerContact, error) {
+	var contacts []models.PartnerContact
+	for _, contact := range r.contacts {
+		if contact.UserID == userID && !contact.DeletedAt.Valid {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts, nil
+}
+
+func (r *memoryContacts) Delete(userID, contactID uint) error {
+	for i := range r.contacts {
+		if r.contacts[i].ID == contactID && r.contacts[i].UserID == userID {
+			r.contacts[i].DeletedAt.Valid = true
+		}
+	}
+	return nil
+}
+
+// 두 사용자 순서와 관계없이 (쌍, 관계 유형)마다 한 행을 찾는 저장소
+type memoryCompatibilities struct {
+	rows []models.Compatibility
+}
+
+func (r *memoryCompatibilities) Create(compatibility *models.Compatibility) error {
+	compatibility.ID = uint(len(r.rows) + 1)
+	r.rows = append(r.rows, *compatibility)
+	return nil
+}
+
+func (r *memoryCompatibilities) Update(compatibility *models.Compatibility) error {
+	r.rows[compatibility.ID-1] = *compatibility
+	return nil
+}
+
+func (r *memoryCompatibilities) FindByUserPair(user1ID, user2ID uint, relationType string) (*models.Compatibility, error) {
+	for _, row := range r.rows {
+		samePair := (row.User1ID == user1ID && row.User2ID == user2ID) || (row.User1ID == user2ID && row.User2ID == user1ID)
+		if samePair && row.RelationType == relationType {
+			return &row, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryCompatibilities) FindBestMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error) {
+	return nil, errors.New("not used")
+}
+
+func (r *memoryCompatibilities) FindWorstMatches(userID uint, relationType string, limit int) ([]models.Compatibility, error) {
+	return nil, errors.New("not used")
+}
+
+func (r *memoryCompatibilities) MarkStaleByUserID(userID uint) error {
+	for i := range r.rows {
+		if r.rows[i].User1ID == userID || r.rows[i].User2ID == userID {
+			r.rows[i].Stale = true
+		}
+	}
+	return nil
+}
+
+// 큐에 넣은 작업만 모아 둔다
+type recordingQueue struct {
+	jobs []models.Job
+}
+
+func (q *recordingQueue) Enqueue(jobType string, payload interface{}) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	q.jobs = append(q.jobs, models.Job{ID: uint(len(q.jobs) + 1), Type: jobType, Payload: string(data)})
+	return &q.jobs[len(q.jobs)-1], nil
+}
+
+// 기록 저장 작업의 payload들
+func (q *recordingQueue) records(t *testing.T) []CreateRecordPayload {
+	t.Helper()
+	var records []CreateRecordPayload
+	for _, job := range q.jobs {
+		if job.Type != JobTypeCreateRecord {
+			continue
+		}
+		var payload CreateRecordPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, payload)
+	}
+	return records
+}
+
+type compatibilityFixture struct {
+	service         *compatibilityService
+	compatibilities *memoryCompatibilities
+	fortunes        *chartStore
+	users           *serviceUsers
+	contacts        *memoryContacts
+	queue           *recordingQueue
+}
+
+// AI 분석 없이 템플릿 문장으로 궁합을 만드는 서비스
+func newCompatibilityFixture() *compatibilityFixture {
+	f := &compatibilityFixture{
+		compatibilities: &memoryCompatibilities{},
+		fortunes:        &chartStore{fortunes: make(map[uint]*models.FortuneInfo)},
+		users:           &serviceUsers{users: make(map[uint]models.User)},
+		contacts:        &memoryContacts{},
+		queue:           &recordingQueue{},
+	}
+	f.service = NewCompatibilityService(f.compatibilities, f.fortunes, f.users, nil, f.contacts, nil, nil, nil, f.queue, &config.Config{}).(*compatibilityService)
+	return f
+}
+
+// 출생 정보로 사주를 계산해 사용자를 등록한다
+func (f *compatibilityFixture) addUser(gender string, year, month, day, hour int) uint {
+	user := &models.User{Gender: gender}
+	f.users.Create(user)
+	yearStem, yearBranch, monthStem, monthBranch, dayStem, dayBranch, hourStem, hourBranch := utils.CalculateFortunePillars(year, month, day, hour)
+	f.fortunes.fortunes[user.ID] = &models.FortuneInfo{
+		UserID:             user.ID,
+		BirthYear:          year,
+		BirthMonth:         month,
+		BirthDay:           day,
+		BirthHour:          hour,
+		YearHeavenlyStem:   yearStem,
+		YearEarthlyBranch:  yearBranch,
+		MonthHeavenlyStem:  monthStem,
+		MonthEarthlyBranch: monthBranch,
+		DayHeavenlyStem:    dayStem,
+		DayEarthlyBranch:   dayBranch,
+		HourHeavenlyStem:   hourStem,
+		HourEarthlyBranch:  hourBranch,
+	}
+	return user.ID
+}
+
+func TestGuestCompatibilityMatchesRegisteredPartner(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	registered := f.addUser("F", 1992, 11, 3, 8)
+
+	compatibility, contact, err := f.service.CalculateGuestCompatibility(me, PartnerBirthInfo{
+		Gender: "F", BirthYear: 1992, BirthMonth: 11, BirthDay: 3, BirthHour: 8, Locale: "ko",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contact != nil || len(f.contacts.contacts) != 0 {
+		t.Errorf("contact saved without save_contact: %+v", contact)
+	}
+
+	// 같은 출생 정보로 가입한 사용자와의 궁합과 같아야 한다
+	myChart, _ := f.fortunes.FindByUserID(me)
+	theirChart, _ := f.fortunes.FindByUserID(registered)
+	want, _ := buildCompatibility(fortuneInfoToMap(myChart), fortuneInfoToMap(theirChart), utils.RelationRomantic, "M", "F", "ko")
+	if compatibility.Score != want.Score || compatibility.CompatibilityType != want.CompatibilityType || compatibility.RelationType != utils.RelationRomantic {
+		t.Errorf("guest result (%v, %s, %s), want (%v, %s, romantic)", compatibility.Score, compatibility.CompatibilityType, compatibility.RelationType, want.Score, want.CompatibilityType)
+	}
+	for _, text := range []string{compatibility.CommunicationAnalysis, compatibility.EmotionAnalysis, compatibility.LifestyleAnalysis, compatibility.CautionAnalysis} {
+		if text == "" {
+			t.Errorf("missing category analysis in %+v", compatibility)
+		}
+	}
+	if compatibility.User1ID != me || compatibility.User2ID != 0 {
+		t.Errorf("users = (%d, %d), want (%d, 0)", compatibility.User1ID, compatibility.User2ID, me)
+	}
+
+	records := f.queue.records(t)
+	if len(records) != 1 || records[0].UserID != me || records[0].Type != "compatibility" {
+		t.Fatalf("records = %+v", records)
+	}
+}
+
+func TestGuestCompatibilityUnknownTimeUsesNoon(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("F", 1990, 5, 15, 14)
+
+	unknown, _, err := f.service.CalculateGuestCompatibility(me, PartnerBirthInfo{
+		Gender: "M", BirthYear: 1988, BirthMonth: 2, BirthDay: 20, BirthHour: 3, UnknownTime: true, Locale: "ko",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noon, _, _ := f.service.CalculateGuestCompatibility(me, PartnerBirthInfo{
+		Gender: "M", BirthYear: 1988, BirthMonth: 2, BirthDay: 20, BirthHour: 12, Locale: "ko",
+	}, false)
+	if unknown.User2Fingerprint != noon.User2Fingerprint {
+		t.Errorf("unknown birth time should be read as noon: %s vs %s", unknown.User2Fingerprint, noon.User2Fingerprint)
+	}
+}
+
+func TestSavedContactCompatibility(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	other := f.addUser("F", 1991, 1, 1, 9)
+	partner := PartnerBirthInfo{Gender: "F", BirthYear: 1995, BirthMonth: 8, BirthDay: 21, BirthHour: 18, RelationType: utils.RelationFriend, Locale: "ko"}
+
+	if _, _, err := f.service.CalculateGuestCompatibility(me, partner, true); err == nil || err.Error() != "nickname is required to save contact" {
+		t.Fatalf("save without nickname: got %v", err)
+	}
+
+	partner.Nickname = "대학 동기"
+	first, contact, err := f.service.CalculateGuestCompatibility(me, partner, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contact == nil || contact.ID == 0 || contact.Nickname != "대학 동기" || contact.RelationType != utils.RelationFriend {
+		t.Fatalf("saved contact = %+v", contact)
+	}
+
+	// 관계 유형을 비우면 저장할 때의 유형으로 다시 본다
+	again, _, err := f.service.CalculateContactCompatibility(me, contact.ID, "", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Score != first.Score || again.RelationType != utils.RelationFriend {
+		t.Errorf("re-check = (%v, %s), want (%v, friend)", again.Score, again.RelationType, first.Score)
+	}
+	business, _, _ := f.service.CalculateContactCompatibility(me, contact.ID, utils.RelationBusiness, "ko")
+	if business.RelationType != utils.RelationBusiness {
+		t.Errorf("relation override ignored: %s", business.RelationType)
+	}
+
+	// 다른 사람의 연락처는 보거나 지울 수 없다
+	if _, _, err := f.service.CalculateContactCompatibility(other, contact.ID, "", "ko"); err == nil || err.Error() != "contact not found" {
+		t.Errorf("other user's contact: got %v", err)
+	}
+	if err := f.service.DeleteContact(other, contact.ID); err == nil || err.Error() != "contact not found" {
+		t.Errorf("delete other user's contact: got %v", err)
+	}
+
+	if err := f.service.DeleteContact(me, contact.ID); err != nil {
+		t.Fatal(err)
+	}
+	if contacts, _ := f.service.GetContacts(me); len(contacts) != 0 {
+		t.Errorf("contacts after delete = %+v", contacts)
+	}
+}
+
+func TestCompatibilityStoredPerRelationType(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+
+	if _, err := f.service.CalculateCompatibility(me, partner, "coworker", "ko"); err == nil || err.Error() != "invalid relation type" {
+		t.Fatalf("unknown relation type: got %v", err)
+	}
+
+	romantic, err := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	business, err := f.service.CalculateCompatibility(me, partner, utils.RelationBusiness, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.compatibilities.rows) != 2 || romantic.ID == business.ID {
+		t.Fatalf("want one stored result per relation type, got %d rows", len(f.compatibilities.rows))
+	}
+	if _, ok := business.CategoryScores[utils.CategoryRole]; !ok {
+		t.Errorf("business categories = %v, want the role category", business.CategoryScores)
+	}
+	if want := getRelationTemplates(utils.RelationBusiness, "ko").Summary[business.CompatibilityType]; business.Analysis != want {
+		t.Errorf("business analysis %q, want the business template %q", business.Analysis, want)
+	}
+
+	// 반대 순서로 물어도 저장된 결과를 돌려주고 기록을 다시 남기지 않는다
+	again, err := f.service.GetCompatibility(partner, me, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != romantic.ID || len(f.compatibilities.rows) != 2 {
+		t.Errorf("reverse lookup returned row %d of %d, want %d", again.ID, len(f.compatibilities.rows), romantic.ID)
+	}
+	if records := f.queue.records(t); len(records) != 2 {
+		t.Errorf("records = %d, want one per calculated relation type", len(records))
+	}
+}
+
+func TestRelationTemplatesAreComplete(t *testing.T) {
+	for _, relationType := range utils.RelationTypes {
+		for _, locale := range []string{"ko", "en", "ja"} {
+			templates := getRelationTemplates(relationType, locale)
+			texts := []string{
+				templates.CommunicationPair, templates.CommunicationClash, templates.CommunicationSame, templates.CommunicationDefault,
+				templates.EmotionComplement, templates.EmotionBias, templates.EmotionDefault,
+				templates.LifestyleSixPair, templates.LifestyleThreePair, templates.LifestyleClash, templates.LifestyleDefault,
+				templates.CautionResentment, templates.CautionClash, templates.CautionDefault,
+			}
+			for _, summary := range templates.Summary {
+				texts = append(texts, summary)
+			}
+			for _, text := range texts {
+				if text == "" || strings.HasPrefix(text, "compat.") {
+					t.Errorf("%s/%s: missing template message %q", relationType, locale, text)
+				}
+			}
+		}
+	}
+
+	if getRelationTemplates(utils.RelationBusiness, "ko").Summary["excellent"] == getRelationTemplates(utils.RelationRomantic, "ko").Summary["excellent"] {
+		t.Error("business and romantic share the same summary text")
+	}
+}
+
+func TestCompatibilityCarriesRadarChartData(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+
+	compatibility, err := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	myChart, _ := f.fortunes.FindByUserID(me)
+	theirChart, _ := f.fortunes.FindByUserID(partner)
+	for name, got := range map[string]map[string]int{"user1": compatibility.User1Elements, "user2": compatibility.User2Elements} {
+		chart := myChart
+		if name == "user2" {
+			chart = theirChart
+		}
+		want := utils.GetFiveElements(fortuneInfoToMap(chart))
+		total := 0
+		for element, count := range want {
+			if got[element] != count {
+				t.Errorf("%s %s = %d, want %d", name, element, got[element], count)
+			}
+			total += got[element]
+		}
+		if total != 8 {
+			t.Errorf("%s elements sum to %d, want 8", name, total)
+		}
+	}
+
+	detail := utils.CalculateRelationCompatibilityScore(fortuneInfoToMap(myChart), fortuneInfoToMap(theirChart), utils.RelationRomantic, "M", "F")
+	if len(compatibility.CategoryScores) != 4 {
+		t.Fatalf("category scores = %v, want four", compatibility.CategoryScores)
+	}
+	for code, category := range detail.Categories {
+		if compatibility.CategoryScores[code] != category.Score {
+			t.Errorf("%s = %v, want %v", code, compatibility.CategoryScores[code], category.Score)
+		}
+	}
+
+	// 저장된 행에도 남아 있어야 다음 조회에서 다시 계산하지 않는다
+	if stored := f.compatibilities.rows[0]; len(stored.CategoryScores) != 4 || len(stored.User1Elements) == 0 {
+		t.Errorf("stored row lost the chart data: %+v", stored)
+	}
+
+	var response map[string]interface{}
+	data, _ := json.Marshal(compatibility)
+	json.Unmarshal(data, &response)
+	for _, field := range []string{"user1_elements", "user2_elements", "category_scores", "category_labels"} {
+		if _, ok := response[field]; !ok {
+			t.Errorf("response is missing %s", field)
+		}
+	}
+}
+
+func TestCompatibilityFillsChartDataForOldRows(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+	compatibility, _ := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+
+	// 상세 데이터 컬럼이 생기기 전에 저장된 결과
+	old := f.compatibilities.rows[0]
+	old.User1Elements, old.User2Elements, old.CategoryScores = nil, nil, nil
+	f.compatibilities.rows[0] = old
+
+	refreshed, err := f.service.GetCompatibility(me, partner, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.ID != compatibility.ID || len(refreshed.CategoryScores) != 4 || len(refreshed.User2Elements) == 0 {
+		t.Fatalf("old row not filled in: %+v", refreshed)
+	}
+	if stored := f.compatibilities.rows[0]; len(stored.CategoryScores) != 4 {
+		t.Errorf("filled data was not saved: %+v", stored.CategoryScores)
+	}
+}
+
+func TestBirthInfoChangeRecomputesCompatibility(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+	fortuneService := NewFortuneService(f.fortunes, f.users, nil, f.compatibilities, nil, nil, nil, nil, &config.Config{})
+
+	before, _ := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+
+	// 출생지만 고치면 팔자가 그대로라 저장된 궁합을 그대로 쓴다
+	if _, err := fortuneService.CreateOrUpdateFortuneInfo(me, 1990, 5, 15, 14, 0, false, "부산"); err != nil {
+		t.Fatal(err)
+	}
+	if f.compatibilities.rows[0].Stale {
+		t.Fatal("unchanged chart marked the compatibility stale")
+	}
+
+	// 태어난 시간을 고치면 시주가 바뀐다
+	if _, err := fortuneService.CreateOrUpdateFortuneInfo(me, 1990, 5, 15, 2, 0, false, "부산"); err != nil {
+		t.Fatal(err)
+	}
+	if !f.compatibilities.rows[0].Stale {
+		t.Fatal("corrected birth time did not mark the compatibility stale")
+	}
+
+	after, err := f.service.GetCompatibility(partner, me, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	myChart, _ := f.fortunes.FindByUserID(me)
+	theirChart, _ := f.fortunes.FindByUserID(partner)
+	want, _ := buildCompatibility(fortuneInfoToMap(myChart), fortuneInfoToMap(theirChart), utils.RelationRomantic, "M", "F", "ko")
+	if after.ID != before.ID || after.Stale || after.Score != want.Score || after.User1Fingerprint != want.User1Fingerprint {
+		t.Errorf("recomputed = (id %d, stale %v, score %v, %s), want (id %d, false, %v, %s)",
+			after.ID, after.Stale, after.Score, after.User1Fingerprint, before.ID, want.Score, want.User1Fingerprint)
+	}
+	if after.User1Fingerprint == before.User1Fingerprint {
+		t.Error("fingerprint did not change with the birth time")
+	}
+	if stored := f.compatibilities.rows[0]; stored.Stale || stored.User1Fingerprint != after.User1Fingerprint || len(f.compatibilities.rows) != 1 {
+		t.Errorf("recomputed result was not saved in place: %+v", stored)
+	}
+}
+
+// 준비한 조각을 흘려보내고 err로 끝나는 궁합 이야기 생성기
+type narrativeAI struct {
+	AIService
+	deltas []string
+	err    error
+}
+
+func (s *narrativeAI) StreamCompatibilityNarrative(ctx context.Context, compatibility *models.Compatibility, onDelta func(delta string) error) (string, string, error) {
+	var sent strings.Builder
+	for _, delta := range s.deltas {
+		if err := onDelta(delta); err != nil {
+			return "", "", err
+		}
+		sent.WriteString(delta)
+	}
+	if s.err != nil {
+		return sent.String(), "", s.err
+	}
+	return sent.String(), "compatibility_narrative/" + compatibility.Locale + "/v1", nil
+}
+
+func TestStreamCompatibilityNarrative(t *testing.T) {
+	tests := []struct {
+		name        string
+		ai          *narrativeAI
+		wantSent    func(template string) string
+		wantVersion string
+	}{
+		{
+			name:        "ai narrative",
+			ai:          &narrativeAI{deltas: []string{"두 분은 ", "서로를 채워줘요."}},
+			wantSent:    func(string) string { return "두 분은 서로를 채워줘요." },
+			wantVersion: "compatibility_narrative/ko/v1",
+		},
+		{
+			// 아무것도 보내지 못하고 실패하면 템플릿 분석 문구를 한 번에 보낸다
+			name:     "template fallback",
+			ai:       &narrativeAI{err: errors.New("upstream unavailable")},
+			wantSent: func(template string) string { return template },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCompatibilityFixture()
+			records := &jobRecordStore{}
+			f.service.aiService, f.service.aiUsage, f.service.recordRepo = tt.ai, &recordedUsage{}, records
+			me := f.addUser("M", 1990, 5, 15, 14)
+			partner := f.addUser("F", 1992, 11, 3, 8)
+
+			var sent strings.Builder
+			narrative, err := f.service.StreamCompatibilityNarrative(context.Background(), me, partner, utils.RelationRomantic, "ko", func(delta string) error {
+				sent.WriteString(delta)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.wantSent(templateNarrative(narrative.Compatibility))
+			if sent.String() != want || narrative.Narrative != want {
+				t.Errorf("sent %q, narrative %q, want %q", sent.String(), narrative.Narrative, want)
+			}
+			if len(records.records) != 1 || narrative.RecordID != records.records[0].ID {
+				t.Fatalf("records = %d, result record %d", len(records.records), narrative.RecordID)
+			}
+			record := records.records[0]
+			if record.Type != "compatibility_narrative" || record.Content != want || !strings.Contains(record.Metadata, `"prompt_version": "`+tt.wantVersion+`"`) {
+				t.Errorf("record = %+v", record)
+			}
+		})
+	}
+}
+
+func TestStreamCompatibilityNarrativeStopsOnDisconnect(t *testing.T) {
+	f := newCompatibilityFixture()
+	records := &jobRecordStore{}
+	f.service.aiService = &narrativeAI{deltas: []string{"두 분은 ", "서로를 채워줘요."}}
+	f.service.aiUsage, f.service.recordRepo = &recordedUsage{}, records
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+
+	// 첫 조각을 보낸 뒤 클라이언트가 끊긴다
+	ctx, cancel := context.WithCancel(context.Background())
+	deltas := 0
+	_, err := f.service.StreamCompatibilityNarrative(ctx, me, partner, utils.RelationRomantic, "ko", func(delta string) error {
+		deltas++
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if deltas != 1 || len(records.records) != 0 {
+		t.Errorf("%d deltas sent and %d records saved after the disconnect", deltas, len(records.records))
+	}
+}
+
+// 궁합 분석 입력을 남기고 정해 둔 문단을 돌려주는 AI
+type analysisAI struct {
+	AIService
+	texts  *CompatibilityAnalysisTexts
+	err    error
+	inputs []CompatibilityAnalysisInput
+}
+
+func (s *analysisAI) GenerateCompatibilityAnalysis(ctx context.Context, input CompatibilityAnalysisInput) (*CompatibilityAnalysisTexts, error) {
+	s.inputs = append(s.inputs, input)
+	if s.err != nil {
+		return nil, s.err
+	}
+	texts := *s.texts
+	return &texts, nil
+}
+
+func TestCompatibilityAIAnalysis(t *testing.T) {
+	const (
+		emotion = "두 분은 감정을 표현하는 속도가 달라 서로 기다려주면 좋아요."
+		caution = "바쁜 시기에는 서운함을 쌓아두지 말고 바로 이야기하는 게 좋아요."
+	)
+	partial := &CompatibilityAnalysisTexts{Emotion: emotion, Caution: caution, PromptVersion: "compatibility_analysis/ko/v1"}
+
+	tests := []struct {
+		name       string
+		mode       string
+		ai         *analysisAI
+		quotaErr   error
+		wantCalls  int
+		wantSource string
+	}{
+		{"ai paragraphs", AICompatibilityModeAI, &analysisAI{texts: partial}, nil, 1, NarrativeSourceAI},
+		{"ai failure", AICompatibilityModeAI, &analysisAI{err: errors.New("upstream unavailable")}, nil, 1, NarrativeSourceTemplate},
+		{"quota exceeded", AICompatibilityModeAI, &analysisAI{texts: partial}, &AIQuotaExceededError{Scope: "user", Period: "daily"}, 0, NarrativeSourceTemplate},
+		{"template mode", AICompatibilityModeTemplate, &analysisAI{texts: partial}, nil, 0, NarrativeSourceTemplate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCompatibilityFixture()
+			f.service.aiAnalysis = tt.mode == AICompatibilityModeAI
+			f.service.aiService, f.service.aiUsage = tt.ai, &recordedUsage{quotaErr: tt.quotaErr}
+			me := f.addUser("M", 1990, 5, 15, 14)
+			partner := f.addUser("F", 1992, 11, 3, 8)
+
+			compatibility, err := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+			if err != nil {
+				t.Fatal(err)
+			}
+			myChart, _ := f.fortunes.FindByUserID(me)
+			theirChart, _ := f.fortunes.FindByUserID(partner)
+			template, rules := buildCompatibility(fortuneInfoToMap(myChart), fortuneInfoToMap(theirChart), utils.RelationRomantic, "M", "F", "ko")
+
+			if len(tt.ai.inputs) != tt.wantCalls || compatibility.NarrativeSource != tt.wantSource {
+				t.Fatalf("%d AI calls, source %q; want %d, %q", len(tt.ai.inputs), compatibility.NarrativeSource, tt.wantCalls, tt.wantSource)
+			}
+			if tt.wantSource == NarrativeSourceAI {
+				// AI가 비워 둔 문단은 템플릿 문구를 그대로 쓴다
+				if compatibility.EmotionAnalysis != emotion || compatibility.CautionAnalysis != caution ||
+					compatibility.CommunicationAnalysis != template.CommunicationAnalysis || compatibility.LifestyleAnalysis != template.LifestyleAnalysis {
+					t.Errorf("paragraphs = %q / %q / %q / %q", compatibility.CommunicationAnalysis, compatibility.EmotionAnalysis, compatibility.LifestyleAnalysis, compatibility.CautionAnalysis)
+				}
+				if compatibility.NarrativePromptVersion != partial.PromptVersion {
+					t.Errorf("prompt version %q", compatibility.NarrativePromptVersion)
+				}
+				input := tt.ai.inputs[0]
+				if input.Fortune1["day_stem"] != myChart.DayHeavenlyStem || input.Fortune2["day_stem"] != theirChart.DayHeavenlyStem ||
+					input.Gender1 != "M" || input.Gender2 != "F" || len(input.Compatibility.CategoryScores) != 4 || strings.Join(input.Rules, "|") != strings.Join(rules, "|") {
+					t.Errorf("AI input = %+v", input)
+				}
+			} else if compatibility.EmotionAnalysis != template.EmotionAnalysis || compatibility.CautionAnalysis != template.CautionAnalysis {
+				t.Errorf("template paragraphs replaced: %q / %q", compatibility.EmotionAnalysis, compatibility.CautionAnalysis)
+			}
+
+			// AI 문단은 궁합 결과와 함께 저장된다
+			if stored := f.compatibilities.rows[0]; stored.EmotionAnalysis != compatibility.EmotionAnalysis || stored.NarrativeSource != tt.wantSource {
+				t.Errorf("stored = %q (%s)", stored.EmotionAnalysis, stored.NarrativeSource)
+			}
+		})
+	}
+}
+
+func TestCompatibilityLocalizedWithoutChangingStoredResult(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+	korean, err := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	english, err := f.service.GetCompatibility(me, partner, utils.RelationRomantic, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 점수 키는 언어와 관계없이 코드이고, 표시 이름만 요청 언어로 바뀐다
+	for _, code := range utils.GetRelationCategoryNames(utils.RelationRomantic) {
+		if english.CategoryScores[code] != korean.CategoryScores[code] {
+			t.Errorf("%s = %v, want %v", code, english.CategoryScores[code], korean.CategoryScores[code])
+		}
+		if english.CategoryLabels[code] != i18n.T("en", "category."+code) || korean.CategoryLabels[code] != i18n.T("ko", "category."+code) {
+			t.Errorf("%s labels: en %q, ko %q", code, english.CategoryLabels[code], korean.CategoryLabels[code])
+		}
+	}
+	if english.Locale != "en" || english.Analysis == korean.Analysis || english.Score != korean.Score {
+		t.Errorf("english = %+v", english)
+	}
+
+	// 저장된 결과는 처음 계산한 언어로 남는다
+	if stored := f.compatibilities.rows[0]; stored.Locale != "ko" || stored.Analysis != korean.Analysis {
+		t.Errorf("stored row changed to %s: %q", stored.Locale, stored.Analysis)
+	}
+}
+
+func TestCompatibilityRekeysOldCategoryScores(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	partner := f.addUser("F", 1992, 11, 3, 8)
+	compatibility, _ := f.service.CalculateCompatibility(me, partner, utils.RelationRomantic, "ko")
+
+	// 카테고리 점수를 표시 이름으로 저장하던 때의 결과
+	old := f.compatibilities.rows[0]
+	old.CategoryScores = make(map[string]float64)
+	for code, score := range compatibility.CategoryScores {
+		old.CategoryScores[i18n.T("ko", "category."+code)] = score
+	}
+	f.compatibilities.rows[0] = old
+
+	refreshed, err := f.service.GetCompatibility(me, partner, utils.RelationRomantic, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, score := range compatibility.CategoryScores {
+		if refreshed.CategoryScores[code] != score || f.compatibilities.rows[0].CategoryScores[code] != score {
+			t.Errorf("%s = %v (stored %v), want %v", code, refreshed.CategoryScores[code], f.compatibilities.rows[0].CategoryScores[code], score)
+		}
+	}
+	if len(f.compatibilities.rows[0].CategoryScores) != 4 || refreshed.Analysis != compatibility.Analysis {
+		t.Errorf("rekeyed row = %+v", f.compatibilities.rows[0].CategoryScores)
+	}
+}
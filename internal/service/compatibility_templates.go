@@ -0,0 +1,63 @@
+package service
+
+import (
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/utils"
+)
+
+// 관계 유형별 궁합 문구. 문구는 i18n 카탈로그의 compat.{관계 유형}.* 메시지에서 가져온다
+type compatibilityTemplates struct {
+	Locale  string            // 문구와 규칙 설명의 언어
+	Summary map[string]string // excellent, good, normal, poor
+
+	CommunicationPair    string
+	CommunicationClash   string
+	CommunicationSame    string
+	CommunicationDefault string
+
+	EmotionComplement string
+	EmotionBias       string
+	EmotionDefault    string
+
+	LifestyleSixPair   string
+	LifestyleThreePair string
+	LifestyleClash     string
+	LifestyleDefault   string
+
+	CautionResentment string
+	CautionClash      string
+	CautionDefault    string
+}
+
+func getRelationTemplates(relationType, locale string) compatibilityTemplates {
+	if !utils.IsValidRelationType(relationType) {
+		relationType = utils.RelationRomantic
+	}
+	message := func(key string) string {
+		return i18n.T(locale, "compat."+relationType+"."+key)
+	}
+
+	return compatibilityTemplates{
+		Locale: locale,
+		Summary: map[string]string{
+			"excellent": message("summary.excellent"),
+			"good":      message("summary.good"),
+			"poor":      message("summary.poor"),
+			"normal":    message("summary.normal"),
+		},
+		CommunicationPair:    message("communication_pair"),
+		CommunicationClash:   message("communication_clash"),
+		CommunicationSame:    message("communication_same"),
+		CommunicationDefault: message("communication_default"),
+		EmotionComplement:    message("emotion_complement"),
+		EmotionBias:          message("emotion_bias"),
+		EmotionDefault:       message("emotion_default"),
+		LifestyleSixPair:     message("lifestyle_six_pair"),
+		LifestyleThreePair:   message("lifestyle_three_pair"),
+		LifestyleClash:       message("lifestyle_clash"),
+		LifestyleDefault:     message("lifestyle_default"),
+		CautionResentment:    message("caution_resentment"),
+		CautionClash:         message("caution_clash"),
+		CautionDefault:       message("caution_default"),
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 아침에 사용자가 몰리기 전에, 자정이 지나면 최근 활동한 사용자의 오늘 운세를 미리 만들어 캐시에 넣는다
+type DailyPregenerator interface {
+	Start(ctx context.Context)
+	Stop()
+	GetRuns(limit int) ([]models.PregenerationRun, error)
+}
+
+const (
+	PregenerationRunning   = "running"
+	PregenerationCompleted = "completed"
+	PregenerationFailed    = "failed"
+)
+
+// AI가 연속으로 이만큼 실패하면 (회로 차단 등) 실행을 멈추고 다음 확인 때 이어 간다
+const pregenerationMaxConsecutiveFailures = 10
+
+const pregenerationCheckInterval = time.Minute
+
+type dailyPregenerator struct {
+	runRepo          repository.PregenerationRunRepository
+	dailyFortuneRepo repository.DailyFortuneRepository
+	lockRepo         repository.LockRepository
+	fortuneService   FortuneService
+	// 사용자별 시간대는 저장하지 않고 운세 날짜·할당량·채팅 한도 모두 FORTUNE_TIMEZONE 하나로 하루를 나누므로,
+	// 그 시간대 하나만 돌린다. 다른 시간대로 만들면 todayFortune이 읽지 않는 날짜로 캐시된다.
+	// 사용자 시간대가 생기면 시간대마다 이 루프를 돌리면 된다 (실행 기록과 잠금은 이미 시간대별로 나뉘어 있다)
+	location    *time.Location
+	activeDays  int
+	startAfter  time.Duration // 자정부터 이만큼 지난 뒤 시작
+	endBefore   time.Duration // 자정부터 이만큼 지나면 더 시작하지 않는다 (사용자가 직접 열기 시작하는 시간)
+	batchSize   int
+	concurrency int
+	instanceID  string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDailyPregenerator(runRepo repository.PregenerationRunRepository, dailyFortuneRepo repository.DailyFortuneRepository, lockRepo repository.LockRepository, fortuneService FortuneService, cfg *config.Config) DailyPregenerator {
+	hostname, _ := os.Hostname()
+	return &dailyPregenerator{
+		runRepo:          runRepo,
+		dailyFortuneRepo: dailyFortuneRepo,
+		lockRepo:         lockRepo,
+		fortuneService:   fortuneService,
+		location:         loadFortuneLocation(cfg.FortuneTimezone),
+		activeDays:       cfg.PregenerateActiveDays,
+		startAfter:       time.Duration(cfg.PregenerateStartMinute) * time.Minute,
+		endBefore:        time.Duration(cfg.PregenerateEndHour) * time.Hour,
+		batchSize:        cfg.PregenerateBatchSize,
+		concurrency:      cfg.PregenerateConcurrency,
+		instanceID:       fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// 1분마다 시작할 시간인지 확인한다. 여러 서버가 함께 확인해도 advisory lock을 잡은 한 곳에서만 실행된다
+func (p *dailyPregenerator) Start(ctx context.Context) {
+	if p.activeDays <= 0 {
+		log.Printf("Daily fortune pregeneration disabled")
+		return
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(pregenerationCheckInterval)
+		defer ticker.Stop()
+		for {
+			p.runIfDue(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 처리 중인 배치를 멈추고 진행 상황을 저장할 때까지 기다린다
+func (p *dailyPregenerator) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	p.wg.Wait()
+}
+
+func (p *dailyPregenerator) GetRuns(limit int) ([]models.PregenerationRun, error) {
+	return p.runRepo.FindRecent(limit)
+}
+
+func (p *dailyPregenerator) runIfDue(ctx context.Context, now time.Time) {
+	local := now.In(p.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, p.location)
+	sinceMidnight := local.Sub(midnight)
+	if sinceMidnight < p.startAfter || sinceMidnight >= p.endBefore {
+		return
+	}
+	fortuneDate := local.Format("2006-01-02")
+	timezone := p.location.String()
+
+	// 이미 끝난 날이면 잠금도 잡지 않는다
+	run, err := p.runRepo.FindByKey(fortuneDate, timezone)
+	if err != nil {
+		log.Printf("Failed to load pregeneration run for %s: %v", fortuneDate, err)
+		return
+	}
+	if run != nil && run.Status == PregenerationCompleted {
+		return
+	}
+
+	release, acquired, err := p.lockRepo.TryLock(ctx, pregenerationLockKey(timezone))
+	if err != nil {
+		log.Printf("Failed to acquire pregeneration lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer release()
+
+	if err := p.run(ctx, fortuneDate, timezone); err != nil {
+		log.Printf("Daily fortune pregeneration for %s (%s) stopped: %v", fortuneDate, timezone, err)
+	}
+}
+
+// 잠금을 잡은 상태에서 실행 기록을 만들거나 이어 간다. running으로 남은 기록은 잠금이 풀려 있었으므로
+// 이전 실행이 죽은 것이고, LastUserID 다음부터 이어 간다
+func (p *dailyPregenerator) run(ctx context.Context, fortuneDate, timezone string) error {
+	run, err := p.runRepo.FindByKey(fortuneDate, timezone)
+	if err != nil {
+		return err
+	}
+	if run != nil && run.Status == PregenerationCompleted {
+		return nil
+	}
+
+	sinceDate := time.Now().In(p.location).AddDate(0, 0, -p.activeDays).Format("2006-01-02")
+	startedAt := time.Now()
+	if run == nil {
+		total, err := p.dailyFortuneRepo.CountActiveUsers(sinceDate)
+		if err != nil {
+			return err
+		}
+		run = &models.PregenerationRun{
+			FortuneDate: fortuneDate,
+			Timezone:    timezone,
+			TotalUsers:  total,
+			StartedAt:   &startedAt,
+		}
+		if err := p.runRepo.Create(run); err != nil {
+			return err
+		}
+		log.Printf("Starting daily fortune pregeneration for %s (%s): %d users", fortuneDate, timezone, total)
+	} else {
+		log.Printf("Resuming daily fortune pregeneration for %s (%s) after user %d (%d/%d done)",
+			fortuneDate, timezone, run.LastUserID, run.Processed, run.TotalUsers)
+	}
+	run.Status = PregenerationRunning
+	run.InstanceID = p.instanceID
+	run.Attempts++
+	run.LastError = ""
+
+	runErr := p.process(ctx, run, sinceDate)
+
+	run.DurationMs += time.Since(startedAt).Milliseconds()
+	switch {
+	case runErr == nil:
+		finishedAt := time.Now()
+		run.Status = PregenerationCompleted
+		run.FinishedAt = &finishedAt
+		log.Printf("Finished daily fortune pregeneration for %s (%s): generated %d, skipped %d, failed %d in %dms",
+			fortuneDate, timezone, run.Generated, run.Skipped, run.Failed, run.DurationMs)
+	case errors.Is(runErr, context.Canceled):
+		// 서버 종료. running으로 두면 다음에 잠금을 잡은 서버가 이어 간다
+	default:
+		run.Status = PregenerationFailed
+		run.LastError = runErr.Error()
+	}
+	if err := p.runRepo.Update(run); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// 사용자 ID 순으로 배치를 나눠 처리하고, 배치가 끝날 때마다 진행 상황을 저장한다
+func (p *dailyPregenerator) process(ctx context.Context, run *models.PregenerationRun, sinceDate string) error {
+	consecutiveFailures := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// 시간대의 날짜가 바뀌었으면 (오래 걸렸거나 하루 넘게 멈춰 있던 실행) 더 만들지 않는다
+		if time.Now().In(p.location).Format("2006-01-02") != run.FortuneDate {
+			return errors.New("fortune date passed")
+		}
+
+		userIDs, err := p.dailyFortuneRepo.FindActiveUserIDs(sinceDate, run.LastUserID, p.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		generated, skipped, failed, lastErr := p.processBatch(ctx, userIDs)
+		if err := ctx.Err(); err != nil {
+			// 배치 중간에 멈췄으면 이 배치는 다음에 다시 처리한다 (이미 만든 운세는 건너뛴다)
+			return err
+		}
+		if failed == len(userIDs) {
+			consecutiveFailures += failed
+		} else {
+			consecutiveFailures = 0
+		}
+		// AI가 멈춘 것으로 보고, 이 배치는 기록하지 않은 채 멈춰 이어 갈 때 다시 처리한다
+		if consecutiveFailures >= pregenerationMaxConsecutiveFailures {
+			return fmt.Errorf("too many consecutive failures: %v", lastErr)
+		}
+
+		run.Generated += generated
+		run.Skipped += skipped
+		run.Failed += failed
+		run.Processed += len(userIDs)
+		run.LastUserID = userIDs[len(userIDs)-1]
+		if err := p.runRepo.Update(run); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *dailyPregenerator) processBatch(ctx context.Context, userIDs []uint) (generated, skipped, failed int, lastErr error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, p.concurrency)
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			created, err := p.fortuneService.PregenerateTodayFortune(ctx, userID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+				lastErr = err
+				log.Printf("Failed to pregenerate daily fortune for user %d: %v", userID, err)
+			case created:
+				generated++
+			default:
+				skipped++
+			}
+		}(userID)
+	}
+	wg.Wait()
+	return generated, skipped, failed, lastErr
+}
+
+// 시간대마다 다른 잠금 키 (pg_try_advisory_lock은 bigint를 받는다)
+func pregenerationLockKey(timezone string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("daily_fortune_pregeneration:" + timezone))
+	return int64(h.Sum64())
+}
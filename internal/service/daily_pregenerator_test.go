@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 날짜와 시간대로 찾는 실행 기록 저장소
+type memoryRuns struct {
+	runs []models.PregenerationRun
+}
+
+func (r *memoryRuns) Create(run *models.PregenerationRun) error {
+	run.ID = uint(len(r.runs) + 1)
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *memoryRuns) Update(run *models.PregenerationRun) error {
+	r.runs[run.ID-1] = *run
+	return nil
+}
+
+func (r *memoryRuns) FindByKey(fortuneDate, timezone string) (*models.PregenerationRun, error) {
+	for _, run := range r.runs {
+		if run.FortuneDate == fortuneDate && run.Timezone == timezone {
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRuns) FindRecent(limit int) ([]models.PregenerationRun, error) {
+	return r.runs, nil
+}
+
+// 다른 서버가 잡은 잠금은 held로 둔다
+type memoryLocks struct {
+	held  map[int64]bool
+	tries int
+}
+
+func (l *memoryLocks) TryLock(ctx context.Context, key int64) (func(), bool, error) {
+	l.tries++
+	if l.held[key] {
+		return nil, false, nil
+	}
+	l.held[key] = true
+	return func() { delete(l.held, key) }, true, nil
+}
+
+// 최근 활동한 사용자 ID만 돌려주는 운세 캐시
+type activeUserStore struct {
+	repository.DailyFortuneRepository
+	userIDs []uint
+}
+
+func (r *activeUserStore) FindActiveUserIDs(sinceDate string, afterUserID uint, limit int) ([]uint, error) {
+	var userIDs []uint
+	for _, userID := range r.userIDs {
+		if userID > afterUserID && len(userIDs) < limit {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (r *activeUserStore) CountActiveUsers(sinceDate string) (int, error) {
+	return len(r.userIDs), nil
+}
+
+// 처음 받은 사용자는 만들고, 이미 만든 사용자는 건너뛴다. failing에 있는 사용자는 실패한다
+type pregeneratingFortunes struct {
+	FortuneService
+	mu      sync.Mutex
+	cached  map[uint]bool
+	failing map[uint]bool
+	calls   []uint
+}
+
+func (s *pregeneratingFortunes) PregenerateTodayFortune(ctx context.Context, userID uint) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, userID)
+	if s.failing[userID] {
+		return false, errors.New("upstream unavailable")
+	}
+	if s.cached[userID] {
+		return false, nil
+	}
+	s.cached[userID] = true
+	return true, nil
+}
+
+type pregeneratorFixture struct {
+	pregenerator *dailyPregenerator
+	runs         *memoryRuns
+	locks        *memoryLocks
+	fortunes     *pregeneratingFortunes
+}
+
+// 시작 시간 제한 없이 하루 종일 실행할 수 있는 예약 생성기
+func newPregeneratorFixture(userIDs ...uint) *pregeneratorFixture {
+	f := &pregeneratorFixture{
+		runs:     &memoryRuns{},
+		locks:    &memoryLocks{held: make(map[int64]bool)},
+		fortunes: &pregeneratingFortunes{cached: make(map[uint]bool), failing: make(map[uint]bool)},
+	}
+	f.pregenerator = NewDailyPregenerator(f.runs, &activeUserStore{userIDs: userIDs}, f.locks, f.fortunes, &config.Config{
+		FortuneTimezone:        "Asia/Seoul",
+		PregenerateActiveDays:  7,
+		PregenerateStartMinute: 0,
+		PregenerateEndHour:     24,
+		PregenerateBatchSize:   2,
+		PregenerateConcurrency: 2,
+	}).(*dailyPregenerator)
+	return f
+}
+
+func (f *pregeneratorFixture) today() string {
+	return time.Now().In(f.pregenerator.location).Format("2006-01-02")
+}
+
+func TestPregenerationRunsOncePerDay(t *testing.T) {
+	f := newPregeneratorFixture(1, 2, 3, 4, 5)
+	f.fortunes.cached[4] = true
+
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+
+	if len(f.runs.runs) != 1 {
+		t.Fatalf("runs = %+v", f.runs.runs)
+	}
+	run := f.runs.runs[0]
+	if run.FortuneDate != f.today() || run.Timezone != "Asia/Seoul" || run.Status != PregenerationCompleted || run.FinishedAt == nil {
+		t.Errorf("run = %+v", run)
+	}
+	if run.TotalUsers != 5 || run.Processed != 5 || run.Generated != 4 || run.Skipped != 1 || run.LastUserID != 5 || run.Attempts != 1 {
+		t.Errorf("metrics = %+v", run)
+	}
+	if len(f.locks.held) != 0 {
+		t.Errorf("lock not released")
+	}
+
+	// 끝난 날에는 잠금도 잡지 않는다
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+	if f.locks.tries != 1 || len(f.fortunes.calls) != 5 {
+		t.Errorf("second check: %d lock tries, %d calls", f.locks.tries, len(f.fortunes.calls))
+	}
+}
+
+func TestPregenerationSkipsWhenAnotherInstanceHoldsTheLock(t *testing.T) {
+	f := newPregeneratorFixture(1, 2, 3)
+	f.locks.held[pregenerationLockKey("Asia/Seoul")] = true
+
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+	if len(f.runs.runs) != 0 || len(f.fortunes.calls) != 0 {
+		t.Errorf("ran without the lock: runs %+v, calls %v", f.runs.runs, f.fortunes.calls)
+	}
+
+	// 시간대마다 다른 잠금이다
+	if pregenerationLockKey("Asia/Seoul") == pregenerationLockKey("Asia/Tokyo") {
+		t.Errorf("lock keys collide across timezones")
+	}
+}
+
+func TestPregenerationOnlyStartsInsideWindow(t *testing.T) {
+	f := newPregeneratorFixture(1)
+	f.pregenerator.startAfter = 5 * time.Minute
+	f.pregenerator.endBefore = 6 * time.Hour
+	now := time.Now().In(f.pregenerator.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, f.pregenerator.location)
+
+	tests := []struct {
+		name          string
+		sinceMidnight time.Duration
+		want          bool
+	}{
+		{"right after midnight", time.Minute, false},
+		{"after start", 10 * time.Minute, true},
+		{"users already awake", 7 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tries := f.locks.tries
+			f.pregenerator.runIfDue(context.Background(), midnight.Add(tt.sinceMidnight))
+			if ran := f.locks.tries > tries; ran != tt.want {
+				t.Errorf("ran = %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestPregenerationResumesAfterCrash(t *testing.T) {
+	f := newPregeneratorFixture(1, 2, 3, 4, 5)
+	// 앞선 서버가 두 명을 처리하고 죽어 running으로 남은 기록
+	f.runs.Create(&models.PregenerationRun{
+		FortuneDate: f.today(), Timezone: "Asia/Seoul", Status: PregenerationRunning,
+		TotalUsers: 5, Processed: 2, Generated: 2, LastUserID: 2, Attempts: 1, DurationMs: 1000,
+	})
+
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+
+	run := f.runs.runs[0]
+	if len(f.fortunes.calls) != 3 || f.fortunes.cached[1] || f.fortunes.cached[2] {
+		t.Errorf("calls = %v, want users after 2 only", f.fortunes.calls)
+	}
+	if run.Status != PregenerationCompleted || run.Processed != 5 || run.Generated != 5 || run.Attempts != 2 || run.DurationMs < 1000 {
+		t.Errorf("run = %+v", run)
+	}
+}
+
+func TestPregenerationStopsAfterConsecutiveFailures(t *testing.T) {
+	f := newPregeneratorFixture(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	f.pregenerator.batchSize = pregenerationMaxConsecutiveFailures
+	for userID := uint(1); userID <= 12; userID++ {
+		f.fortunes.failing[userID] = true
+	}
+
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+
+	// AI가 멈춘 배치는 진행 상황에 넣지 않는다
+	run := f.runs.runs[0]
+	if run.Status != PregenerationFailed || !strings.Contains(run.LastError, "too many consecutive failures") || run.LastUserID != 0 || run.Processed != 0 {
+		t.Fatalf("run = %+v", run)
+	}
+
+	// 실패로 끝난 날은 다음 확인 때 처음부터 다시 이어 간다
+	f.fortunes.failing = map[uint]bool{7: true}
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+	run = f.runs.runs[0]
+	if run.Status != PregenerationCompleted || run.Processed != 12 || run.Generated != 11 || run.Failed != 1 || run.LastError != "" || run.Attempts != 2 {
+		t.Errorf("retried run = %+v", run)
+	}
+}
+
+func TestPregenerationLeavesRunResumableOnShutdown(t *testing.T) {
+	f := newPregeneratorFixture(1, 2, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	f.pregenerator.runIfDue(ctx, time.Now())
+
+	// 서버 종료로 멈춘 실행은 running으로 남아 다음 서버가 이어 간다
+	if run := f.runs.runs[0]; run.Status != PregenerationRunning || run.Processed != 0 || run.FinishedAt != nil {
+		t.Errorf("run = %+v", run)
+	}
+	if len(f.locks.held) != 0 {
+		t.Errorf("lock not released")
+	}
+
+	f.pregenerator.runIfDue(context.Background(), time.Now())
+	if run := f.runs.runs[0]; run.Status != PregenerationCompleted || run.Generated != 3 || run.Attempts != 2 {
+		t.Errorf("resumed run = %+v", run)
+	}
+}
+
+func TestPregenerateTodayFortuneUsesUserLocaleAndCache(t *testing.T) {
+	f := newDailyFortuneFixture()
+	user := f.users.users[f.userID]
+	user.Locale = "ja"
+	f.users.users[f.userID] = user
+
+	created, err := f.service.PregenerateTodayFortune(context.Background(), f.userID)
+	if err != nil || !created {
+		t.Fatalf("created %v, err %v", created, err)
+	}
+	if len(f.daily.rows) != 1 || f.daily.rows[0].Locale != "ja" {
+		t.Fatalf("cached rows = %+v", f.daily.rows)
+	}
+
+	// 사용자가 열면 미리 만든 운세를 그대로 준다
+	if result := f.today(t, "ja", false); f.ai.calls != 1 || result.TotalFortune != f.daily.rows[0].TotalFortune {
+		t.Errorf("%d AI calls, result %+v", f.ai.calls, result)
+	}
+	if created, err := f.service.PregenerateTodayFortune(context.Background(), f.userID); err != nil || created {
+		t.Errorf("already cached: created %v, err %v", created, err)
+	}
+}
+
+func TestPregenerateTodayFortuneCountsFallbackAsFailure(t *testing.T) {
+	f := newDailyFortuneFixture()
+	f.ai.err = errors.New("upstream unavailable")
+
+	// 규칙 기반 운세는 캐시하지 않으므로 만든 것으로 세지 않는다
+	if created, err := f.service.PregenerateTodayFortune(context.Background(), f.userID); err == nil || created {
+		t.Errorf("created %v, err %v", created, err)
+	}
+	if len(f.daily.rows) != 0 || len(f.records.records) != 0 {
+		t.Errorf("fallback stored: %d cached rows, %d records", len(f.daily.rows), len(f.records.records))
+	}
+	if _, err := f.service.PregenerateTodayFortune(context.Background(), 999); err == nil || err.Error() != "fortune info not found" {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/utils"
+)
+
+var promptElementOrder = []string{"木", "火", "土", "金", "水"}
+
+// 운세 프롬프트 템플릿에 넘기는 값. 점수와 행운 오행은 규칙 기반 계산과 같은 값을 넘겨 AI 문장이 어긋나지 않게 한다
+// 문구로 넘기는 값(관계, 힌트, 행운 색)은 프롬프트 로케일로 쓴다
+type fortunePromptData struct {
+	DayStem     string
+	DayBranch   string
+	TodayStem   string
+	TodayBranch string
+	Category    string
+
+	// 원국 (시주를 모르면 비어 있다)
+	YearPillar  string
+	MonthPillar string
+	DayPillar   string
+	HourPillar  string
+
+	DayElement     string
+	Elements       map[string]int
+	ElementSummary string // "木 2, 火 1, 土 3, 金 0, 水 2"
+	GodOfUse       string // 용신
+
+	// 오늘 일진 분석
+	TenStar        string
+	StemRelation   string
+	BranchRelation string
+	NobleInfluence bool // 천을귀인
+	FlyingHorse    bool // 역마
+	EmptyTrunk     bool // 공망
+
+	Score        float64
+	WealthHint   string
+	LoveHint     string
+	HealthHint   string
+	LuckyElement string
+	LuckyColor   string
+	LuckyNumbers string
+}
+
+func newFortunePromptData(fortuneMap map[string]string, todayStem, todayBranch, category, locale string) fortunePromptData {
+	elements := utils.GetFiveElements(fortuneMap)
+	analysis := utils.AnalyzeDailyPillar(fortuneMap, todayStem, todayBranch)
+	prediction := utils.CalculateFortuneForPillar(fortuneMap, todayStem, todayBranch, locale)
+	luckyElement := utils.CalculateLuckyElement(fortuneMap, todayStem, todayBranch)
+	luckyColor, _ := utils.GetLuckyColor(luckyElement, locale)
+
+	return fortunePromptData{
+		DayStem:     fortuneMap["day_stem"],
+		DayBranch:   fortuneMap["day_branch"],
+		TodayStem:   todayStem,
+		TodayBranch: todayBranch,
+		Category:    category,
+
+		YearPillar:  fortuneMap["year_stem"] + fortuneMap["year_branch"],
+		MonthPillar: fortuneMap["month_stem"] + fortuneMap["month_branch"],
+		DayPillar:   fortuneMap["day_stem"] + fortuneMap["day_branch"],
+		HourPillar:  fortuneMap["hour_stem"] + fortuneMap["hour_branch"],
+
+		DayElement:     utils.GetElement(fortuneMap["day_stem"]),
+		Elements:       elements,
+		ElementSummary: elementSummary(elements),
+		GodOfUse:       analysis.GodOfUse,
+
+		TenStar:        analysis.TenStar,
+		StemRelation:   i18n.T(locale, "daily.relation."+analysis.StemRelation),
+		BranchRelation: i18n.T(locale, "daily.relation."+analysis.BranchRelation),
+		NobleInfluence: analysis.HasNobleInfluence,
+		FlyingHorse:    analysis.HasFlyingHorse,
+		EmptyTrunk:     analysis.HasEmptyTrunk,
+
+		Score:        prediction.Score,
+		WealthHint:   prediction.Keywords["재물"],
+		LoveHint:     prediction.Keywords["애정"],
+		HealthHint:   prediction.Keywords["건강"],
+		LuckyElement: luckyElement,
+		LuckyColor:   luckyColor,
+		LuckyNumbers: joinNumbers(utils.GetLuckyNumbers(luckyElement)),
+	}
+}
+
+func joinNumbers(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, number := range numbers {
+		parts[i] = strconv.Itoa(number)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// "木 2, 火 1, 土 3, 金 0, 水 2"
+func elementSummary(elements map[string]int) string {
+	parts := make([]string, len(promptElementOrder))
+	for i, element := range promptElementOrder {
+		parts[i] = fmt.Sprintf("%s %d", element, elements[element])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/utils"
+)
+
+var (
+	testStems    = []string{"甲", "乙", "丙", "丁", "戊", "己", "庚", "辛", "壬", "癸"}
+	testBranches = []string{"子", "丑", "寅", "卯", "辰", "巳", "午", "未", "申", "酉", "戌", "亥"}
+)
+
+func TestFortunePromptDataMatchesRuleBasedFortune(t *testing.T) {
+	failing := func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+		return nil, errors.New("upstream unavailable")
+	}
+
+	for i := 0; i < 60; i++ {
+		todayStem, todayBranch := testStems[i%10], testBranches[i%12]
+		data := newFortunePromptData(testChart, todayStem, todayBranch, "", "ko")
+		analysis := utils.AnalyzeDailyPillar(testChart, todayStem, todayBranch)
+		prediction := utils.CalculateFortuneForPillar(testChart, todayStem, todayBranch, "ko")
+
+		if data.Score != prediction.Score || data.WealthHint != prediction.Keywords["재물"] {
+			t.Errorf("%s%s: score %v, want %v", todayStem, todayBranch, data.Score, prediction.Score)
+		}
+		if data.TenStar != analysis.TenStar || data.GodOfUse != analysis.GodOfUse || data.NobleInfluence != analysis.HasNobleInfluence ||
+			data.FlyingHorse != analysis.HasFlyingHorse || data.EmptyTrunk != analysis.HasEmptyTrunk {
+			t.Errorf("%s%s: prompt data %+v does not match analysis %+v", todayStem, todayBranch, data, analysis)
+		}
+
+		// 프롬프트의 행운 항목은 응답에 붙는 행운 항목과 같아야 한다
+		daily, _ := (&fortuneService{}).generateDailyFortune(context.Background(), 1, testChart, todayStem, todayBranch, "ko", failing)
+		if data.LuckyColor != daily.LuckyColor || data.LuckyNumbers != joinNumbers(daily.LuckyNumbers) {
+			t.Errorf("%s%s: prompt lucky items (%s, %s), response (%s, %v)", todayStem, todayBranch, data.LuckyColor, data.LuckyNumbers, daily.LuckyColor, daily.LuckyNumbers)
+		}
+	}
+
+	data := newFortunePromptData(testChart, "丙", "午", "", "ko")
+	if data.YearPillar != "庚午" || data.DayPillar != "甲子" || data.HourPillar != "丙寅" || data.DayElement != "木" {
+		t.Errorf("chart = %+v", data)
+	}
+	if data.ElementSummary != elementSummary(utils.GetFiveElements(testChart)) || !strings.HasPrefix(data.ElementSummary, "木 ") {
+		t.Errorf("element summary %q", data.ElementSummary)
+	}
+}
+
+func TestDailyFortunePromptFollowsScore(t *testing.T) {
+	store, err := NewPromptStore(filepath.Join("..", "..", "prompts"), i18n.DefaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 60; i++ {
+		data := newFortunePromptData(testChart, testStems[i%10], testBranches[i%12], "", "ko")
+		prompt, _, err := store.Render(PromptDailyFortune, "ko", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			fmt.Sprintf("오늘의 운세 점수: %.0f점", data.Score),
+			"십성: " + data.TenStar,
+			"오행 분포: " + data.ElementSummary,
+			"행운의 색 " + data.LuckyColor,
+		}
+		switch {
+		case data.Score >= 80:
+			want = append(want, "기운이 좋은 날")
+		case data.Score < 50:
+			want = append(want, "쉬어가는 날")
+		default:
+			want = append(want, "무난한 날")
+		}
+		for _, part := range want {
+			if !strings.Contains(prompt, part) {
+				t.Errorf("%s: prompt does not contain %q", data.TodayStem+data.TodayBranch, part)
+			}
+		}
+	}
+}
@@ -1,7 +1,16 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
 	"dothefortune_server/internal/models"
 	"dothefortune_server/internal/repository"
 	"dothefortune_server/internal/utils"
@@ -13,6 +22,14 @@ type SimilarUserResult struct {
 	Type  string // "similar", "best_match", "worst_match"
 }
 
+// 유사 사주 목록의 한 항목 (순위와 유사한 이유 포함)
+type SimilarUser struct {
+	User      models.User
+	Score     float64
+	Rank      int
+	Breakdown utils.SimilarityBreakdown
+}
+
 type TodayFortuneResult struct {
 	TotalFortune    string   `json:"total_fortune"`     // 총운
 	WealthFortune   string   `json:"wealth_fortune"`   // 재물운
@@ -21,27 +38,48 @@ type TodayFortuneResult struct {
 	LuckyColor       string   `json:"lucky_color"`       // 행운의 컬러
 	LuckyColorHex    string   `json:"lucky_color_hex"`   // 행운의 컬러 HEX
 	LuckyNumbers     []int    `json:"lucky_numbers"`     // 행운의 숫자
+	FortuneDate         string `json:"fortune_date"`         // 운세 기준 날짜
+	RegenerateRemaining int    `json:"regenerate_remaining"` // 오늘 남은 재생성 횟수
+	RecordID            uint   `json:"record_id"`            // 저장된 today_fortune 기록 (저장하지 않은 대체 운세면 0)
+	Locale              string `json:"locale"`               // 운세 문장의 언어
 }
 
 type FortuneService interface {
 	CreateOrUpdateFortuneInfo(userID uint, birthYear, birthMonth, birthDay, birthHour, birthMinute int, unknownTime bool, birthPlace string) (*models.FortuneInfo, error)
 	GetFortuneInfo(userID uint) (*models.FortuneInfo, error)
-	GetTodayFortune(userID uint) (*TodayFortuneResult, error)
-	GetSimilarUsers(userID uint, limit int) ([]models.User, []float64, error)
-	GetSimilarUserMatches(userID uint) (*SimilarUserResult, *SimilarUserResult, *SimilarUserResult, error) // 가장 비슷한, 잘 맞는, 잘 안 맞는
+	GetTodayFortune(userID uint, locale string, regenerate bool) (*TodayFortuneResult, error)
+	StreamTodayFortune(ctx context.Context, userID uint, locale string, regenerate bool, onDelta func(delta string) error) (*TodayFortuneResult, error)
+	// 오늘 운세가 캐시에 없으면 사용자 설정 로케일로 만들어 두고 true를 반환한다 (예약 생성용)
+	PregenerateTodayFortune(ctx context.Context, userID uint) (bool, error)
+	GetSimilarUsers(userID uint, locale string, query MatchQuery, cursor string, limit int) ([]SimilarUser, string, error)
+	GetSimilarUserMatches(userID uint, query MatchQuery) (*SimilarUserResult, *SimilarUserResult, *SimilarUserResult, error) // 가장 비슷한, 잘 맞는, 잘 안 맞는
 }
 
 type fortuneService struct {
-	fortuneRepo repository.FortuneRepository
-	recordRepo  repository.RecordRepository
-	aiService   AIService
+	fortuneRepo       repository.FortuneRepository
+	userRepo          repository.UserRepository
+	recordRepo        repository.RecordRepository
+	compatibilityRepo   repository.CompatibilityRepository
+	matchPreferenceRepo repository.MatchPreferenceRepository
+	dailyFortuneRepo    repository.DailyFortuneRepository
+	aiService           AIService
+	aiUsage             AIUsageService
+	location            *time.Location
+	regenerateLimit     int
 }
 
-func NewFortuneService(fortuneRepo repository.FortuneRepository, recordRepo repository.RecordRepository, aiService AIService) FortuneService {
+func NewFortuneService(fortuneRepo repository.FortuneRepository, userRepo repository.UserRepository, recordRepo repository.RecordRepository, compatibilityRepo repository.CompatibilityRepository, matchPreferenceRepo repository.MatchPreferenceRepository, dailyFortuneRepo repository.DailyFortuneRepository, aiService AIService, aiUsage AIUsageService, cfg *config.Config) FortuneService {
 	return &fortuneService{
-		fortuneRepo: fortuneRepo,
-		recordRepo:  recordRepo,
-		aiService:   aiService,
+		fortuneRepo:         fortuneRepo,
+		userRepo:            userRepo,
+		recordRepo:          recordRepo,
+		compatibilityRepo:   compatibilityRepo,
+		matchPreferenceRepo: matchPreferenceRepo,
+		dailyFortuneRepo:    dailyFortuneRepo,
+		aiService:           aiService,
+		aiUsage:             aiUsage,
+		location:            loadFortuneLocation(cfg.FortuneTimezone),
+		regenerateLimit:     cfg.DailyFortuneRegenerateLimit,
 	}
 }
 
@@ -56,6 +94,8 @@ func (s *fortuneService) CreateOrUpdateFortuneInfo(userID uint, birthYear, birth
 
 	existing, err := s.fortuneRepo.FindByUserID(userID)
 	if err == nil && existing != nil {
+		previousFingerprint := utils.ChartFingerprint(fortuneInfoToMap(existing))
+
 		existing.BirthYear = birthYear
 		existing.BirthMonth = birthMonth
 		existing.BirthDay = birthDay
@@ -75,6 +115,13 @@ func (s *fortuneService) CreateOrUpdateFortuneInfo(userID uint, birthYear, birth
 		if err := s.fortuneRepo.Update(existing); err != nil {
 			return nil, err
 		}
+
+		// 사주가 바뀌면 저장된 궁합은 다음 조회 때 다시 계산한다
+		if utils.ChartFingerprint(fortuneInfoToMap(existing)) != previousFingerprint {
+			if err := s.compatibilityRepo.MarkStaleByUserID(userID); err != nil {
+				return nil, err
+			}
+		}
 		return existing, nil
 	}
 
@@ -108,48 +155,208 @@ func (s *fortuneService) GetFortuneInfo(userID uint) (*models.FortuneInfo, error
 	return s.fortuneRepo.FindByUserID(userID)
 }
 
-func (s *fortuneService) GetTodayFortune(userID uint) (*TodayFortuneResult, error) {
+// 같은 날 같은 사주와 프롬프트 버전(로케일 포함)이면 저장된 운세를 돌려주고, regenerate면 하루 허용 횟수 안에서 다시 만든다
+func (s *fortuneService) GetTodayFortune(userID uint, locale string, regenerate bool) (*TodayFortuneResult, error) {
+	ctx := WithAIUsage(context.Background(), userID, AIFeatureDailyFortune)
+	return s.todayFortune(ctx, userID, locale, regenerate, s.aiService.GenerateDailyFortune)
+}
+
+// GetTodayFortune과 같지만 새로 생성할 때 AI 응답을 onDelta로 흘려보낸다. 저장된 운세를 돌려줄 때는 onDelta를 호출하지 않는다
+func (s *fortuneService) StreamTodayFortune(ctx context.Context, userID uint, locale string, regenerate bool, onDelta func(delta string) error) (*TodayFortuneResult, error) {
+	ctx = WithAIUsage(ctx, userID, AIFeatureDailyFortune)
+	return s.todayFortune(ctx, userID, locale, regenerate, func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+		return s.aiService.StreamDailyFortune(ctx, fortuneMap, todayStem, todayBranch, locale, onDelta)
+	})
+}
+
+// AI 생성이 실패하면 todayFortune이 규칙 기반 문장을 저장하지 않으므로 (사용자가 열 때 다시 시도하게) 만들지 못한 것으로 센다
+func (s *fortuneService) PregenerateTodayFortune(ctx context.Context, userID uint) (bool, error) {
+	fortuneInfo, err := s.fortuneRepo.FindByUserID(userID)
+	if err != nil {
+		return false, errors.New("fortune info not found")
+	}
+
+	locale := i18n.DefaultLocale
+	if user, err := s.userRepo.FindByID(userID); err == nil && user.Locale != "" {
+		locale = user.Locale
+	}
+
+	fortuneMap := fortuneInfoToMap(fortuneInfo)
+	fortuneDate := time.Now().In(s.location).Format("2006-01-02")
+	cached, err := s.dailyFortuneRepo.FindByKey(userID, fortuneDate, utils.ChartFingerprint(fortuneMap), s.aiService.DailyFortunePromptVersion(locale))
+	if err != nil || cached != nil {
+		return false, err
+	}
+
+	ctx = WithAIUsage(ctx, userID, AIFeatureDailyPregeneration)
+	var generateErr error
+	result, err := s.todayFortune(ctx, userID, locale, false, func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+		texts, err := s.aiService.GenerateDailyFortune(ctx, fortuneMap, todayStem, todayBranch, locale)
+		generateErr = err
+		return texts, err
+	})
+	if generateErr != nil {
+		return false, generateErr
+	}
+	if err != nil {
+		return false, err
+	}
+	// 저장하지 않은 대체 운세는 기록 ID가 없다
+	if result.RecordID == 0 {
+		return false, errors.New("daily fortune generation produced no AI text")
+	}
+	return true, nil
+}
+
+// 운세 날짜를 나누는 시간대. 알 수 없는 이름이면 서버 로컬 시간대를 쓴다
+func loadFortuneLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown fortune timezone %q, using local time: %v", name, err)
+		return time.Local
+	}
+	return location
+}
+
+type dailyFortuneGenerator func(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error)
+
+func (s *fortuneService) todayFortune(ctx context.Context, userID uint, locale string, regenerate bool, generate dailyFortuneGenerator) (*TodayFortuneResult, error) {
 	fortuneInfo, err := s.fortuneRepo.FindByUserID(userID)
 	if err != nil {
 		return nil, errors.New("fortune info not found")
 	}
 
-	fortuneMap := map[string]string{
-		"year_stem":   fortuneInfo.YearHeavenlyStem,
-		"year_branch": fortuneInfo.YearEarthlyBranch,
-		"month_stem":  fortuneInfo.MonthHeavenlyStem,
-		"month_branch": fortuneInfo.MonthEarthlyBranch,
-		"day_stem":    fortuneInfo.DayHeavenlyStem,
-		"day_branch":  fortuneInfo.DayEarthlyBranch,
-		"hour_stem":   fortuneInfo.HourHeavenlyStem,
-		"hour_branch": fortuneInfo.HourEarthlyBranch,
-	}
-
-	todayStem, todayBranch := utils.CalculateTodayPillar()
-	
-	totalFortune, _ := s.aiService.GenerateFortuneText(fortuneMap, todayStem, todayBranch, "총운")
-	wealthFortune, _ := s.aiService.GenerateFortuneText(fortuneMap, todayStem, todayBranch, "재물운")
-	loveFortune, _ := s.aiService.GenerateFortuneText(fortuneMap, todayStem, todayBranch, "애정운")
-	healthFortune, _ := s.aiService.GenerateFortuneText(fortuneMap, todayStem, todayBranch, "건강운")
-	
+	fortuneMap := fortuneInfoToMap(fortuneInfo)
+	now := time.Now().In(s.location)
+	fortuneDate := now.Format("2006-01-02")
+	chartVersion := utils.ChartFingerprint(fortuneMap)
+	promptVersion := s.aiService.DailyFortunePromptVersion(locale)
+
+	cached, err := s.dailyFortuneRepo.FindByKey(userID, fortuneDate, chartVersion, promptVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	regenerations, err := s.dailyFortuneRepo.CountRegenerations(userID, fortuneDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && !regenerate {
+		return s.dailyFortuneToResult(cached, regenerations), nil
+	}
+	if cached != nil && regenerations >= s.regenerateLimit {
+		return nil, errors.New("daily regenerate limit exceeded")
+	}
+	// 저장된 운세는 한도와 관계없이 보여주고, 새로 만들 때만 AI 사용량 한도를 확인한다.
+	// 한도를 넘었어도 오늘 운세가 아직 없으면 AI를 부르지 않고 규칙 기반 운세를 보여준다 (429는 다시 만들기에만)
+	if err := s.aiUsage.CheckQuota(userID); err != nil {
+		var quotaErr *AIQuotaExceededError
+		if cached != nil || !errors.As(err, &quotaErr) {
+			return nil, err
+		}
+		generate = func(context.Context, map[string]string, string, string, string) (*DailyFortuneTexts, error) {
+			return nil, quotaErr
+		}
+	}
+
+	todayStem, todayBranch := utils.CalculateDayPillarAt(now)
+	daily, usedPromptVersion := s.generateDailyFortune(ctx, userID, fortuneMap, todayStem, todayBranch, locale, generate)
+	// 클라이언트가 끊겼으면 대체 문장으로 하루 캐시를 채우지 않는다
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// AI 생성이 실패해 규칙 기반 문장뿐이면 저장하지 않고 보여주기만 한다 (다음 요청에서 AI를 다시 시도한다).
+	// 다시 만들기였으면 저장된 운세를 그대로 두고 재생성 횟수도 차감하지 않는다
+	if usedPromptVersion == "" {
+		if cached != nil {
+			return s.dailyFortuneToResult(cached, regenerations), nil
+		}
+		daily.FortuneDate = fortuneDate
+		daily.Locale = locale
+		return s.dailyFortuneToResult(daily, regenerations), nil
+	}
+
+	if cached != nil {
+		cached.TotalFortune = daily.TotalFortune
+		cached.WealthFortune = daily.WealthFortune
+		cached.LoveFortune = daily.LoveFortune
+		cached.HealthFortune = daily.HealthFortune
+		cached.LuckyColor = daily.LuckyColor
+		cached.LuckyColorHex = daily.LuckyColorHex
+		cached.LuckyNumbers = daily.LuckyNumbers
+		cached.RegenerateCount++
+		regenerations++
+		daily = cached
+	} else {
+		daily.UserID = userID
+		daily.FortuneDate = fortuneDate
+		daily.ChartVersion = chartVersion
+		daily.PromptVersion = promptVersion
+		daily.Locale = locale
+
+		// 같은 날 사주나 프롬프트가 바뀌어 새로 만든 경우에도 기록은 그날 것 하나를 이어 쓴다
+		previous, err := s.dailyFortuneRepo.FindLatestByDate(userID, fortuneDate)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			daily.RecordID = previous.RecordID
+		}
+
+		if err := s.dailyFortuneRepo.Create(daily); err != nil {
+			// 동시에 들어온 요청이 먼저 저장했으면 그 결과를 쓴다
+			if existing, findErr := s.dailyFortuneRepo.FindByKey(userID, fortuneDate, chartVersion, promptVersion); findErr == nil && existing != nil {
+				return s.dailyFortuneToResult(existing, regenerations), nil
+			}
+			return nil, err
+		}
+	}
+
+	if err := s.saveDailyRecord(daily, usedPromptVersion); err != nil {
+		return nil, err
+	}
+	if err := s.dailyFortuneRepo.Update(daily); err != nil {
+		return nil, err
+	}
+
+	return s.dailyFortuneToResult(daily, regenerations), nil
+}
+
+// AI가 채우지 못한 필드만 규칙 기반 문장으로 채운다. AI 호출이 실패했거나 AI 문장이 하나도 남지 않았으면 프롬프트 버전은 비어 있다
+func (s *fortuneService) generateDailyFortune(ctx context.Context, userID uint, fortuneMap map[string]string, todayStem, todayBranch, locale string, generate dailyFortuneGenerator) (*models.DailyFortune, string) {
+	texts, err := generate(ctx, fortuneMap, todayStem, todayBranch, locale)
+	if err != nil {
+		log.Printf("Failed to generate daily fortune for user %d: %v", userID, err)
+		texts = &DailyFortuneTexts{}
+	}
+	totalFortune := texts.TotalFortune
+	wealthFortune := texts.WealthFortune
+	loveFortune := texts.LoveFortune
+	healthFortune := texts.HealthFortune
+	promptVersion := texts.PromptVersion
+	if totalFortune == "" && wealthFortune == "" && loveFortune == "" && healthFortune == "" {
+		promptVersion = ""
+	}
+
 	if totalFortune == "" {
-		totalFortune = utils.GetTodayFortune(fortuneMap)
+		totalFortune = utils.GetTodayFortune(fortuneMap, locale)
 	}
 	if wealthFortune == "" {
-		wealthFortune = "오늘은 재물운이 안정적인 하루입니다. 계획적인 소비가 도움이 될 거예요."
+		wealthFortune = i18n.T(locale, "fortune.fallback.wealth")
 	}
 	if loveFortune == "" {
-		loveFortune = "인연운이 평범한 날입니다. 자연스러운 만남을 기대해보세요."
+		loveFortune = i18n.T(locale, "fortune.fallback.love")
 	}
 	if healthFortune == "" {
-		healthFortune = "건강운이 양호한 하루입니다. 무리하지 말고 적당한 휴식이 필요해요."
+		healthFortune = i18n.T(locale, "fortune.fallback.health")
 	}
-	
+
 	luckyElement := utils.CalculateLuckyElement(fortuneMap, todayStem, todayBranch)
-	luckyColor, luckyColorHex := utils.GetLuckyColor(luckyElement)
+	luckyColor, luckyColorHex := utils.GetLuckyColor(luckyElement, locale)
 	luckyNumbers := utils.GetLuckyNumbers(luckyElement)
 
-	result := &TodayFortuneResult{
+	return &models.DailyFortune{
 		TotalFortune:  totalFortune,
 		WealthFortune: wealthFortune,
 		LoveFortune:   loveFortune,
@@ -157,138 +364,253 @@ func (s *fortuneService) GetTodayFortune(userID uint) (*TodayFortuneResult, erro
 		LuckyColor:    luckyColor,
 		LuckyColorHex: luckyColorHex,
 		LuckyNumbers:  luckyNumbers,
+	}, promptVersion
+}
+
+// today_fortune 기록은 하루에 하나만 남기고, 다시 만들면 그 기록을 최신 내용으로 고친다
+func (s *fortuneService) saveDailyRecord(daily *models.DailyFortune, promptVersion string) error {
+	metadata := `{"lucky_color": "` + daily.LuckyColor + `", "lucky_numbers": ` + utils.IntSliceToJSON(daily.LuckyNumbers) +
+		`, "fortune_date": "` + daily.FortuneDate + `", "prompt_version": "` + promptVersion + `"}`
+
+	if daily.RecordID != 0 {
+		if record, err := s.recordRepo.FindByID(daily.UserID, daily.RecordID); err == nil {
+			record.Content = daily.TotalFortune
+			record.Metadata = metadata
+			return s.recordRepo.Update(record)
+		}
 	}
 
 	record := &models.FortuneRecord{
-		UserID:  userID,
-		Type:    "today_fortune",
-		Content: totalFortune,
-		Metadata: `{"lucky_color": "` + luckyColor + `", "lucky_numbers": ` + utils.IntSliceToJSON(luckyNumbers) + `}`,
+		UserID:   daily.UserID,
+		Type:     "today_fortune",
+		Content:  daily.TotalFortune,
+		Metadata: metadata,
 	}
+	if err := s.recordRepo.Create(record); err != nil {
+		return err
+	}
+	daily.RecordID = record.ID
+	return nil
+}
 
-	s.recordRepo.Create(record)
-
-	return result, nil
+func (s *fortuneService) dailyFortuneToResult(daily *models.DailyFortune, regenerations int) *TodayFortuneResult {
+	remaining := s.regenerateLimit - regenerations
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &TodayFortuneResult{
+		TotalFortune:        daily.TotalFortune,
+		WealthFortune:       daily.WealthFortune,
+		LoveFortune:         daily.LoveFortune,
+		HealthFortune:       daily.HealthFortune,
+		LuckyColor:          daily.LuckyColor,
+		LuckyColorHex:       daily.LuckyColorHex,
+		LuckyNumbers:        daily.LuckyNumbers,
+		FortuneDate:         daily.FortuneDate,
+		RegenerateRemaining: remaining,
+		RecordID:            daily.RecordID,
+		Locale:              daily.Locale,
+	}
 }
 
-func (s *fortuneService) GetSimilarUsers(userID uint, limit int) ([]models.User, []float64, error) {
-	users, err := s.fortuneRepo.FindSimilarUsers(userID, limit)
+// 저장된 매칭 선호와 요청 조건의 성별/나이 범위 안에서만 찾는다 (관계 유형은 유사도에 영향을 주지 않는다)
+func (s *fortuneService) GetSimilarUsers(userID uint, locale string, query MatchQuery, cursor string, limit int) ([]SimilarUser, string, error) {
+	currentFortune, err := s.fortuneRepo.FindByUserID(userID)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", errors.New("current user fortune info not found")
 	}
 
-	currentFortune, err := s.fortuneRepo.FindByUserID(userID)
+	filter, _, err := loadMatchFilter(s.matchPreferenceRepo, userID, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var after *repository.SimilarityCursor
+	if cursor != "" {
+		after, err = decodeSimilarityCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	currentMap := fortuneInfoToMap(currentFortune)
+	ranked, err := s.fortuneRepo.FindSimilarUsers(userID, utils.EncodeChartFeatures(currentMap), filter.candidateFilter(time.Now()), after, limit)
 	if err != nil {
-		return nil, nil, errors.New("current user fortune info not found")
+		return nil, "", err
+	}
+	if len(ranked) == 0 {
+		return []SimilarUser{}, "", nil
 	}
 
-	currentMap := map[string]string{
-		"year_stem":   currentFortune.YearHeavenlyStem,
-		"year_branch": currentFortune.YearEarthlyBranch,
-		"month_stem":  currentFortune.MonthHeavenlyStem,
-		"month_branch": currentFortune.MonthEarthlyBranch,
-		"day_stem":    currentFortune.DayHeavenlyStem,
-		"day_branch":  currentFortune.DayEarthlyBranch,
-		"hour_stem":   currentFortune.HourHeavenlyStem,
-		"hour_branch": currentFortune.HourEarthlyBranch,
+	users, err := s.loadRankedUsers(ranked)
+	if err != nil {
+		return nil, "", err
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
 	}
 
-	scores := make([]float64, len(users))
-	for i, user := range users {
-		if user.FortuneInfo == nil {
-			continue
+	items := make([]utils.SimilarityResultItem, len(ranked))
+	for i, item := range ranked {
+		items[i] = utils.SimilarityResultItem{
+			Score:  float64(item.ScoreTenths) / 10,
+			UserID: item.UserID,
+		}
+	}
+	// 순위는 전체 기준 dense rank라서 페이지 첫 항목의 순위부터 이어서 매긴다
+	items = utils.HandleSimilarityTie(items, ranked[0].SimilarityRank)
+
+	results := make([]SimilarUser, len(items))
+	for i, item := range items {
+		user := byID[item.UserID]
+		breakdown := utils.SimilarityBreakdown{}
+		if user.FortuneInfo != nil {
+			breakdown = utils.ExplainSimilarity(currentMap, fortuneInfoToMap(user.FortuneInfo), locale)
 		}
-		userMap := map[string]string{
-			"year_stem":   user.FortuneInfo.YearHeavenlyStem,
-			"year_branch": user.FortuneInfo.YearEarthlyBranch,
-			"month_stem":  user.FortuneInfo.MonthHeavenlyStem,
-			"month_branch": user.FortuneInfo.MonthEarthlyBranch,
-			"day_stem":    user.FortuneInfo.DayHeavenlyStem,
-			"day_branch":  user.FortuneInfo.DayEarthlyBranch,
-			"hour_stem":   user.FortuneInfo.HourHeavenlyStem,
-			"hour_branch": user.FortuneInfo.HourEarthlyBranch,
+		results[i] = SimilarUser{
+			User:      user,
+			Score:     item.Score,
+			Rank:      item.Rank,
+			Breakdown: breakdown,
 		}
-		scores[i] = utils.CalculateSimilarityScore(currentMap, userMap)
 	}
 
-	return users, scores, nil
+	nextCursor := ""
+	if len(ranked) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeSimilarityCursor(repository.SimilarityCursor{
+			ScoreTenths: int(math.Round(last.Score * 10)),
+			UserID:      last.UserID,
+		})
+	}
+
+	return results, nextCursor, nil
+}
+
+// 점수 순서를 유지한 채 사용자 정보를 채운다
+func (s *fortuneService) loadRankedUsers(ranked []repository.SimilarUserScore) ([]models.User, error) {
+	ids := make([]uint, len(ranked))
+	for i, item := range ranked {
+		ids[i] = item.UserID
+	}
+
+	found, err := s.userRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+
+	users := make([]models.User, len(ranked))
+	for i, item := range ranked {
+		users[i] = byID[item.UserID]
+	}
+	return users, nil
+}
+
+func encodeSimilarityCursor(cursor repository.SimilarityCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", cursor.ScoreTenths, cursor.UserID)))
 }
 
-func (s *fortuneService) GetSimilarUserMatches(userID uint) (*SimilarUserResult, *SimilarUserResult, *SimilarUserResult, error) {
-	allUsers, err := s.fortuneRepo.FindSimilarUsers(userID, 100)
+func decodeSimilarityCursor(cursor string) (*repository.SimilarityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, errors.New("invalid cursor")
+	}
+
+	var decoded repository.SimilarityCursor
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &decoded.ScoreTenths, &decoded.UserID); err != nil {
+		return nil, errors.New("invalid cursor")
 	}
+	return &decoded, nil
+}
 
+// 세 명 모두 저장된 매칭 선호와 요청 조건에 맞는 후보 중에서 고르고, 잘 맞는 친구는 선호 관계 유형의 궁합 점수로 정한다
+func (s *fortuneService) GetSimilarUserMatches(userID uint, query MatchQuery) (*SimilarUserResult, *SimilarUserResult, *SimilarUserResult, error) {
 	currentFortune, err := s.fortuneRepo.FindByUserID(userID)
 	if err != nil {
 		return nil, nil, nil, errors.New("current user fortune info not found")
 	}
 
-	currentMap := map[string]string{
-		"year_stem":   currentFortune.YearHeavenlyStem,
-		"year_branch": currentFortune.YearEarthlyBranch,
-		"month_stem":  currentFortune.MonthHeavenlyStem,
-		"month_branch": currentFortune.MonthEarthlyBranch,
-		"day_stem":    currentFortune.DayHeavenlyStem,
-		"day_branch":  currentFortune.DayEarthlyBranch,
-		"hour_stem":   currentFortune.HourHeavenlyStem,
-		"hour_branch": currentFortune.HourEarthlyBranch,
+	filter, relationType, err := loadMatchFilter(s.matchPreferenceRepo, userID, query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	candidateFilter := filter.candidateFilter(time.Now())
+
+	currentMap := fortuneInfoToMap(currentFortune)
+	currentGender := ""
+	if currentUser, err := s.userRepo.FindByID(userID); err == nil {
+		currentGender = currentUser.Gender
 	}
 
 	var similarUser *SimilarUserResult
 	var bestMatchUser *SimilarUserResult
 	var worstMatchUser *SimilarUserResult
 
-	maxSimilarity := -1.0
+	top, err := s.fortuneRepo.FindSimilarUsers(userID, utils.EncodeChartFeatures(currentMap), candidateFilter, nil, 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(top) > 0 {
+		users, err := s.loadRankedUsers(top)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		similarUser = &SimilarUserResult{
+			User:  users[0],
+			Score: float64(top[0].ScoreTenths) / 10,
+			Type:  "similar",
+		}
+	}
+
 	maxCompatibility := -1.0
 	minConflict := 101.0
 
-	for _, user := range allUsers {
-		if user.FortuneInfo == nil || user.ID == userID {
-			continue
+	// 잘 맞는/안 맞는 친구는 전체 후보를 배치로 훑으며 고른다
+	var afterID uint
+	for {
+		batch, err := s.fortuneRepo.FindMatchCandidates(userID, candidateFilter, afterID, matchBatchSize)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
-		userMap := map[string]string{
-			"year_stem":   user.FortuneInfo.YearHeavenlyStem,
-			"year_branch": user.FortuneInfo.YearEarthlyBranch,
-			"month_stem":  user.FortuneInfo.MonthHeavenlyStem,
-			"month_branch": user.FortuneInfo.MonthEarthlyBranch,
-			"day_stem":    user.FortuneInfo.DayHeavenlyStem,
-			"day_branch":  user.FortuneInfo.DayEarthlyBranch,
-			"hour_stem":   user.FortuneInfo.HourHeavenlyStem,
-			"hour_branch": user.FortuneInfo.HourEarthlyBranch,
-		}
+		for _, user := range batch {
+			if user.FortuneInfo == nil {
+				continue
+			}
+
+			userMap := fortuneInfoToMap(user.FortuneInfo)
 
-		similarityScore := utils.CalculateSimilarityScore(currentMap, userMap)
-		if similarityScore > maxSimilarity {
-			maxSimilarity = similarityScore
-			similarUser = &SimilarUserResult{
-				User:  user,
-				Score: similarityScore,
-				Type:  "similar",
+			compatibilityScore := utils.CalculateRelationCompatibilityScore(currentMap, userMap, relationType, currentGender, user.Gender).Score
+			if compatibilityScore > maxCompatibility {
+				maxCompatibility = compatibilityScore
+				bestMatchUser = &SimilarUserResult{
+					User:  user,
+					Score: compatibilityScore,
+					Type:  "best_match",
+				}
 			}
-		}
 
-		compatibilityScore := utils.CalculateCompatibilityScore(currentMap, userMap)
-		if compatibilityScore > maxCompatibility {
-			maxCompatibility = compatibilityScore
-			bestMatchUser = &SimilarUserResult{
-				User:  user,
-				Score: compatibilityScore,
-				Type:  "best_match",
+			conflictScore := utils.CalculateConflictScore(currentMap, userMap)
+			if conflictScore < minConflict {
+				minConflict = conflictScore
+				worstMatchUser = &SimilarUserResult{
+					User:  user,
+					Score: conflictScore,
+					Type:  "worst_match",
+				}
 			}
 		}
 
-		conflictScore := utils.CalculateConflictScore(currentMap, userMap)
-		if conflictScore < minConflict {
-			minConflict = conflictScore
-			worstMatchUser = &SimilarUserResult{
-				User:  user,
-				Score: conflictScore,
-				Type:  "worst_match",
-			}
+		if len(batch) < matchBatchSize {
+			break
 		}
+		afterID = batch[len(batch)-1].ID
 	}
 
 	return similarUser, bestMatchUser, worstMatchUser, nil
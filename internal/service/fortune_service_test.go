@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+func TestSimilarityCursorRoundTrip(t *testing.T) {
+	cursor := repository.SimilarityCursor{ScoreTenths: 875, UserID: 42}
+
+	decoded, err := decodeSimilarityCursor(encodeSimilarityCursor(cursor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != cursor {
+		t.Errorf("got %+v, want %+v", *decoded, cursor)
+	}
+
+	for _, invalid := range []string{"not base64!", "bm90LWEtY3Vyc29y"} {
+		if _, err := decodeSimilarityCursor(invalid); err == nil || err.Error() != "invalid cursor" {
+			t.Errorf("decodeSimilarityCursor(%q) error = %v, want invalid cursor", invalid, err)
+		}
+	}
+}
+
+// 저장소 순위 조회 결과를 정해 두고 받은 조건을 남긴다
+type rankedFortunes struct {
+	*chartStore
+	ranked     []repository.SimilarUserScore
+	candidates []models.User // ID 순
+	lastFilter repository.MatchCandidateFilter
+	lastCursor *repository.SimilarityCursor
+}
+
+func (r *rankedFortunes) FindSimilarUsers(userID uint, features utils.ChartFeatures, filter repository.MatchCandidateFilter, cursor *repository.SimilarityCursor, limit int) ([]repository.SimilarUserScore, error) {
+	r.lastFilter = filter
+	r.lastCursor = cursor
+	if len(r.ranked) > limit {
+		return r.ranked[:limit], nil
+	}
+	return r.ranked, nil
+}
+
+func (r *rankedFortunes) FindMatchCandidates(userID uint, filter repository.MatchCandidateFilter, afterID uint, batchSize int) ([]models.User, error) {
+	r.lastFilter = filter
+	var batch []models.User
+	for _, user := range r.candidates {
+		if user.ID > afterID && len(batch) < batchSize {
+			batch = append(batch, user)
+		}
+	}
+	return batch, nil
+}
+
+type memoryPreferences struct {
+	preferences map[uint]models.MatchPreference
+}
+
+func (r *memoryPreferences) FindByUserID(userID uint) (*models.MatchPreference, error) {
+	preference, ok := r.preferences[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &preference, nil
+}
+
+func (r *memoryPreferences) Save(preference *models.MatchPreference) error {
+	r.preferences[preference.UserID] = *preference
+	return nil
+}
+
+func TestGetSimilarUsersRanksAndExplains(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	twin := f.addUser("F", 1990, 5, 15, 14)
+	first := f.addUser("F", 1985, 3, 2, 9)
+	second := f.addUser("F", 1993, 7, 30, 20)
+	for id, user := range f.users.users {
+		user.FortuneInfo, _ = f.fortunes.FindByUserID(id)
+		f.users.users[id] = user
+	}
+
+	// 두 번째 페이지: 전체 순위 3위부터, 동점 두 명은 ID가 큰 쪽이 먼저 왔다
+	fortunes := &rankedFortunes{chartStore: f.fortunes, ranked: []repository.SimilarUserScore{
+		{UserID: twin, ScoreTenths: 1000, SimilarityRank: 3},
+		{UserID: second, ScoreTenths: 625, SimilarityRank: 4},
+		{UserID: first, ScoreTenths: 625, SimilarityRank: 4},
+	}}
+	service := NewFortuneService(fortunes, f.users, nil, nil, &memoryPreferences{preferences: map[uint]models.MatchPreference{}}, nil, nil, nil, &config.Config{})
+
+	cursor := encodeSimilarityCursor(repository.SimilarityCursor{ScoreTenths: 1000, UserID: 1})
+	results, next, err := service.GetSimilarUsers(me, "ko", MatchQuery{}, cursor, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fortunes.lastCursor == nil || *fortunes.lastCursor != (repository.SimilarityCursor{ScoreTenths: 1000, UserID: 1}) {
+		t.Errorf("cursor passed to the repository = %+v", fortunes.lastCursor)
+	}
+
+	want := []struct {
+		userID uint
+		score  float64
+		rank   int
+	}{{twin, 100, 3}, {first, 62.5, 4}, {second, 62.5, 4}}
+	for i, w := range want {
+		if results[i].User.ID != w.userID || results[i].Score != w.score || results[i].Rank != w.rank {
+			t.Errorf("result %d = (user %d, %v, rank %d), want (user %d, %v, rank %d)", i, results[i].User.ID, results[i].Score, results[i].Rank, w.userID, w.score, w.rank)
+		}
+	}
+
+	myChart, _ := f.fortunes.FindByUserID(me)
+	if reasons := results[0].Breakdown.Reasons; len(reasons) != 3 || reasons[0] != "같은 일주 "+myChart.DayHeavenlyStem+myChart.DayEarthlyBranch {
+		t.Errorf("identical chart reasons = %v", reasons)
+	}
+
+	// 한 페이지를 꽉 채우면 마지막 항목에서 이어지는 커서를 준다
+	decoded, err := decodeSimilarityCursor(next)
+	if err != nil || *decoded != (repository.SimilarityCursor{ScoreTenths: 625, UserID: second}) {
+		t.Errorf("next cursor = %+v (%v)", decoded, err)
+	}
+	if _, next, _ := service.GetSimilarUsers(me, "ko", MatchQuery{}, "", 5); next != "" {
+		t.Errorf("short page returned a next cursor %q", next)
+	}
+}
+
+// dailyFortuneRepository처럼 (사용자, 날짜, 사주, 프롬프트 버전)으로 오늘의 운세를 저장한다
+type memoryDailyFortunes struct {
+	rows    []models.DailyFortune
+	updates int
+}
+
+func (r *memoryDailyFortunes) touch(fortune *models.DailyFortune) {
+	r.updates++
+	fortune.UpdatedAt = time.Unix(int64(r.updates), 0)
+}
+
+func (r *memoryDailyFortunes) Create(fortune *models.DailyFortune) error {
+	if existing, _ := r.FindByKey(fortune.UserID, fortune.FortuneDate, fortune.ChartVersion, fortune.PromptVersion); existing != nil {
+		return errors.New("duplicate key value violates unique constraint")
+	}
+	fortune.ID = uint(len(r.rows) + 1)
+	r.touch(fortune)
+	r.rows = append(r.rows, *fortune)
+	return nil
+}
+
+func (r *memoryDailyFortunes) Update(fortune *models.DailyFortune) error {
+	r.touch(fortune)
+	r.rows[fortune.ID-1] = *fortune
+	return nil
+}
+
+func (r *memoryDailyFortunes) FindByKey(userID uint, fortuneDate, chartVersion, promptVersion string) (*models.DailyFortune, error) {
+	for _, row := range r.rows {
+		if row.UserID == userID && row.FortuneDate == fortuneDate && row.ChartVersion == chartVersion && row.PromptVersion == promptVersion {
+			return &row, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryDailyFortunes) FindLatestByDate(userID uint, fortuneDate string) (*models.DailyFortune, error) {
+	var latest *models.DailyFortune
+	for _, row := range r.rows {
+		if row.UserID == userID && row.FortuneDate == fortuneDate && (latest == nil || row.UpdatedAt.After(latest.UpdatedAt)) {
+			copied := row
+			latest = &copied
+		}
+	}
+	return latest, nil
+}
+
+func (r *memoryDailyFortunes) CountRegenerations(userID uint, fortuneDate string) (int, error) {
+	count := 0
+	for _, row := range r.rows {
+		if row.UserID == userID && row.FortuneDate == fortuneDate {
+			count += row.RegenerateCount
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryDailyFortunes) FindActiveUserIDs(sinceDate string, afterUserID uint, limit int) ([]uint, error) {
+	return nil, errors.New("not used")
+}
+
+func (r *memoryDailyFortunes) CountActiveUsers(sinceDate string) (int, error) {
+	return 0, errors.New("not used")
+}
+
+// 부를 때마다 다른 총운을 만드는 AI. err가 있으면 생성에 실패한다
+type dailyFortuneAI struct {
+	AIService
+	err   error
+	calls int
+}
+
+func (s *dailyFortuneAI) DailyFortunePromptVersion(locale string) string {
+	return "daily_fortune/" + locale + "/v1+scripted"
+}
+
+func (s *dailyFortuneAI) GenerateDailyFortune(ctx context.Context, fortuneMap map[string]string, todayStem, todayBranch, locale string) (*DailyFortuneTexts, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &DailyFortuneTexts{
+		TotalFortune:  fmt.Sprintf("%d번째로 만든 총운이에요.", s.calls),
+		WealthFortune: "재물운이 좋아요.",
+		LoveFortune:   "애정운이 좋아요.",
+		HealthFortune: "건강운이 좋아요.",
+		PromptVersion: "daily_fortune/" + locale + "/v1",
+	}, nil
+}
+
+type dailyFortuneFixture struct {
+	*compatibilityFixture
+	service *fortuneService
+	ai      *dailyFortuneAI
+	daily   *memoryDailyFortunes
+	records *jobRecordStore
+	usage   *recordedUsage
+	userID  uint
+}
+
+func newDailyFortuneFixture() *dailyFortuneFixture {
+	f := &dailyFortuneFixture{
+		compatibilityFixture: newCompatibilityFixture(),
+		ai:                   &dailyFortuneAI{},
+		daily:                &memoryDailyFortunes{},
+		records:              &jobRecordStore{},
+		usage:                &recordedUsage{},
+	}
+	f.userID = f.addUser("F", 1992, 8, 21, 7)
+	f.service = NewFortuneService(f.fortunes, f.users, f.records, f.compatibilities, nil, f.daily, f.ai, f.usage,
+		&config.Config{FortuneTimezone: "Asia/Seoul", DailyFortuneRegenerateLimit: 2}).(*fortuneService)
+	return f
+}
+
+func (f *dailyFortuneFixture) today(t *testing.T, locale string, regenerate bool) *TodayFortuneResult {
+	t.Helper()
+	result, err := f.service.GetTodayFortune(f.userID, locale, regenerate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestTodayFortuneServedFromDailyCache(t *testing.T) {
+	f := newDailyFortuneFixture()
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+
+	first := f.today(t, "ko", false)
+	if first.FortuneDate != time.Now().In(seoul).Format("2006-01-02") || first.RecordID == 0 || first.RegenerateRemaining != 2 {
+		t.Fatalf("first result = %+v", first)
+	}
+
+	// 새로고침은 저장된 문장을 그대로 주고 AI와 기록을 다시 만들지 않는다
+	again := f.today(t, "ko", false)
+	if f.ai.calls != 1 || again.TotalFortune != first.TotalFortune || again.RecordID != first.RecordID {
+		t.Errorf("repeat request: %d AI calls, result %+v", f.ai.calls, again)
+	}
+
+	// 다른 언어는 따로 캐시하지만 그날의 기록은 하나를 이어 쓴다
+	english := f.today(t, "en", false)
+	if f.ai.calls != 2 || len(f.daily.rows) != 2 || english.Locale != "en" {
+		t.Errorf("english: %d AI calls, %d cached rows, result %+v", f.ai.calls, len(f.daily.rows), english)
+	}
+	if len(f.records.records) != 1 || english.RecordID != first.RecordID || f.records.records[0].Content != english.TotalFortune {
+		t.Errorf("records = %d, want the day's record updated in place", len(f.records.records))
+	}
+
+	// 같은 날 출생 정보가 바뀌면 새로 만든다
+	fortune := f.fortunes.fortunes[f.userID]
+	fortune.DayHeavenlyStem, fortune.DayEarthlyBranch = "庚", "申"
+	if changed := f.today(t, "en", false); f.ai.calls != 3 || changed.RecordID != first.RecordID {
+		t.Errorf("changed chart: %d AI calls, result %+v", f.ai.calls, changed)
+	}
+	if len(f.records.records) != 1 {
+		t.Errorf("records = %d, want 1", len(f.records.records))
+	}
+}
+
+func TestTodayFortuneRegenerateQuota(t *testing.T) {
+	f := newDailyFortuneFixture()
+	first := f.today(t, "ko", false)
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		regenerated := f.today(t, "ko", true)
+		if regenerated.TotalFortune == first.TotalFortune || regenerated.RegenerateRemaining != remaining || regenerated.RecordID != first.RecordID {
+			t.Errorf("regenerate with %d left: %+v", remaining, regenerated)
+		}
+		if record := f.records.records[0]; record.Content != regenerated.TotalFortune {
+			t.Errorf("record content %q, want %q", record.Content, regenerated.TotalFortune)
+		}
+	}
+
+	if _, err := f.service.GetTodayFortune(f.userID, "ko", true); err == nil || err.Error() != "daily regenerate limit exceeded" {
+		t.Errorf("regenerate over the limit: err = %v", err)
+	}
+	if f.ai.calls != 3 || len(f.records.records) != 1 {
+		t.Errorf("%d AI calls and %d records, want 3 and 1", f.ai.calls, len(f.records.records))
+	}
+	if cached := f.today(t, "ko", false); cached.RegenerateRemaining != 0 {
+		t.Errorf("cached result after the limit: %+v", cached)
+	}
+}
+
+func TestTodayFortuneDoesNotCacheRuleBasedFallback(t *testing.T) {
+	f := newDailyFortuneFixture()
+	fortuneMap := fortuneInfoToMap(f.fortunes.fortunes[f.userID])
+	f.ai.err = errors.New("upstream unavailable")
+
+	// AI가 실패하면 규칙 기반 운세를 보여주기만 하고 저장하지 않는다
+	fallback := f.today(t, "ko", false)
+	if fallback.TotalFortune != utils.GetTodayFortune(fortuneMap, "ko") || fallback.RecordID != 0 || fallback.FortuneDate == "" {
+		t.Errorf("fallback = %+v", fallback)
+	}
+	if len(f.daily.rows) != 0 || len(f.records.records) != 0 {
+		t.Fatalf("fallback stored: %d cached rows, %d records", len(f.daily.rows), len(f.records.records))
+	}
+
+	// 다음 요청은 AI를 다시 시도한다
+	f.ai.err = nil
+	generated := f.today(t, "ko", false)
+	if f.ai.calls != 2 || generated.RecordID == 0 || len(f.daily.rows) != 1 {
+		t.Fatalf("retry after failure: %d AI calls, result %+v", f.ai.calls, generated)
+	}
+
+	// 다시 만들기가 실패하면 저장된 운세를 그대로 두고 횟수도 차감하지 않는다
+	f.ai.err = errors.New("upstream unavailable")
+	kept := f.today(t, "ko", true)
+	if kept.TotalFortune != generated.TotalFortune || kept.RegenerateRemaining != 2 {
+		t.Errorf("failed regenerate: %+v", kept)
+	}
+	if f.daily.rows[0].RegenerateCount != 0 || f.records.records[0].Content != generated.TotalFortune {
+		t.Errorf("failed regenerate changed the cache: %+v", f.daily.rows[0])
+	}
+}
+
+func TestTodayFortuneOverAIQuota(t *testing.T) {
+	f := newDailyFortuneFixture()
+	fortuneMap := fortuneInfoToMap(f.fortunes.fortunes[f.userID])
+	f.usage.quotaErr = &AIQuotaExceededError{Scope: AIQuotaScopeUser, Period: AIQuotaPeriodDaily, Limit: 10, ResetAt: time.Now().Add(time.Hour)}
+
+	// 오늘 운세가 없으면 429 대신 AI를 부르지 않고 규칙 기반 운세를 보여준다
+	fallback := f.today(t, "ko", false)
+	if f.ai.calls != 0 || fallback.TotalFortune != utils.GetTodayFortune(fortuneMap, "ko") || len(f.daily.rows) != 0 {
+		t.Errorf("%d AI calls, %d cached rows, result %+v", f.ai.calls, len(f.daily.rows), fallback)
+	}
+
+	// 저장된 운세는 한도와 관계없이 보여주고, 다시 만들기만 막는다
+	f.usage.quotaErr = nil
+	cached := f.today(t, "ko", false)
+	f.usage.quotaErr = &AIQuotaExceededError{Scope: AIQuotaScopeUser, Period: AIQuotaPeriodDaily, Limit: 10, ResetAt: time.Now().Add(time.Hour)}
+	if again := f.today(t, "ko", false); again.TotalFortune != cached.TotalFortune || f.ai.calls != 1 {
+		t.Errorf("cached over quota: %d AI calls, result %+v", f.ai.calls, again)
+	}
+	var quotaErr *AIQuotaExceededError
+	if _, err := f.service.GetTodayFortune(f.userID, "ko", true); !errors.As(err, &quotaErr) || f.ai.calls != 1 {
+		t.Errorf("regenerate over quota: err %v, %d AI calls", err, f.ai.calls)
+	}
+}
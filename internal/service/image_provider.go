@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"dothefortune_server/internal/config"
+)
+
+// 이미지 생성 모델 백엔드
+type ImageProvider interface {
+	Name() string
+	GenerateImage(ctx context.Context, prompt string) (*GeneratedImage, error)
+}
+
+type GeneratedImage struct {
+	Data        []byte
+	ContentType string
+}
+
+const (
+	ImageProviderOpenAI = "openai"
+	ImageProviderFake   = "fake"
+)
+
+// 호출마다 usage에 남긴다
+func NewImageProvider(usage AIUsageService, cfg *config.Config) (ImageProvider, error) {
+	switch strings.ToLower(cfg.ImageProvider) {
+	case ImageProviderOpenAI:
+		apiKey := cfg.ImageAPIKey
+		if apiKey == "" {
+			apiKey = cfg.LLMAPIKey
+		}
+		model := cfg.ImageModel
+		if model == "" {
+			model = defaultOpenAIImageModel
+		}
+		return newMeteredImageProvider(newOpenAIImageProvider(cfg.ImageBaseURL, apiKey, model, &http.Client{}), model, usage), nil
+	case ImageProviderFake:
+		return newMeteredImageProvider(newFakeImageProvider(), ImageProviderFake, usage), nil
+	default:
+		return nil, fmt.Errorf("unknown image provider: %s", cfg.ImageProvider)
+	}
+}
+
+const defaultOpenAIImageModel = "dall-e-3"
+
+// OpenAI images/generations 백엔드. base64로 받아 바로 저장소에 넘긴다
+type openAIImageProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func newOpenAIImageProvider(baseURL, apiKey, model string, client *http.Client) ImageProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = defaultOpenAIImageModel
+	}
+	return &openAIImageProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  client,
+	}
+}
+
+type OpenAIImageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size"`
+	ResponseFormat string `json:"response_format"`
+}
+
+type OpenAIImageResponse struct {
+	Data []struct {
+		B64JSON string `json:"b64_json"`
+	} `json:"data"`
+}
+
+func (p *openAIImageProvider) Name() string {
+	return ImageProviderOpenAI
+}
+
+func (p *openAIImageProvider) GenerateImage(ctx context.Context, prompt string) (*GeneratedImage, error) {
+	jsonData, err := json.Marshal(OpenAIImageRequest{
+		Model:          p.model,
+		Prompt:         prompt,
+		N:              1,
+		Size:           "1024x1024",
+		ResponseFormat: "b64_json",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/images/generations", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newLLMHTTPError("OpenAI image", resp)
+	}
+
+	var imageResp OpenAIImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&imageResp); err != nil {
+		return nil, err
+	}
+	if len(imageResp.Data) == 0 || imageResp.Data[0].B64JSON == "" {
+		return nil, errors.New("no image in response")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(imageResp.Data[0].B64JSON)
+	if err != nil {
+		return nil, err
+	}
+	return &GeneratedImage{Data: data, ContentType: "image/png"}, nil
+}
+
+const fakeImageSize = 512
+
+// 외부 호출 없이 자리표시 PNG(그라데이션 배경과 사람 실루엣)를 그리는 개발용 백엔드.
+// 같은 프롬프트에는 항상 같은 색을 쓴다
+type fakeImageProvider struct{}
+
+func newFakeImageProvider() ImageProvider {
+	return &fakeImageProvider{}
+}
+
+func (p *fakeImageProvider) Name() string {
+	return ImageProviderFake
+}
+
+func (p *fakeImageProvider) GenerateImage(ctx context.Context, prompt string) (*GeneratedImage, error) {
+	h := fnv.New32a()
+	h.Write([]byte(prompt))
+	seed := h.Sum32()
+	top := color.RGBA{R: uint8(seed), G: uint8(seed >> 8), B: uint8(seed >> 16), A: 255}
+	bottom := color.RGBA{R: 255 - top.R/2, G: 255 - top.G/2, B: 255 - top.B/2, A: 255}
+	silhouette := color.RGBA{R: top.R / 3, G: top.G / 3, B: top.B / 3, A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, fakeImageSize, fakeImageSize))
+	for y := 0; y < fakeImageSize; y++ {
+		background := mixColor(top, bottom, float64(y)/fakeImageSize)
+		for x := 0; x < fakeImageSize; x++ {
+			if inSilhouette(x, y) {
+				img.SetRGBA(x, y, silhouette)
+			} else {
+				img.SetRGBA(x, y, background)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return &GeneratedImage{Data: buf.Bytes(), ContentType: "image/png"}, nil
+}
+
+func mixColor(from, to color.RGBA, ratio float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*ratio)
+	}
+	return color.RGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 255}
+}
+
+// 머리(원)와 어깨(아래쪽 반타원)
+func inSilhouette(x, y int) bool {
+	cx := float64(x - fakeImageSize/2)
+	headY := float64(y - fakeImageSize*2/5)
+	if cx*cx+headY*headY <= 80*80 {
+		return true
+	}
+	bodyY := float64(y - fakeImageSize)
+	return bodyY <= 0 && (cx*cx)/(180*180)+(bodyY*bodyY)/(200*200) <= 1
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image/png"
+	"testing"
+
+	"dothefortune_server/internal/config"
+)
+
+func TestFakeImageProviderDrawsPlaceholderPNG(t *testing.T) {
+	provider := newFakeImageProvider()
+	first, err := provider.GenerateImage(context.Background(), "a calm woman, fire element")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(first.Data))
+	if err != nil || first.ContentType != "image/png" {
+		t.Fatalf("content type %s, decode err %v", first.ContentType, err)
+	}
+	if size := img.Bounds().Size(); size.X != fakeImageSize || size.Y != fakeImageSize {
+		t.Errorf("size = %v", size)
+	}
+	// 가운데 아래는 실루엣, 왼쪽 위는 배경이다
+	if img.At(fakeImageSize/2, fakeImageSize-1) == img.At(0, 0) {
+		t.Error("silhouette is not drawn")
+	}
+
+	// 같은 프롬프트는 같은 그림, 다른 프롬프트는 다른 색이다
+	again, _ := provider.GenerateImage(context.Background(), "a calm woman, fire element")
+	other, _ := provider.GenerateImage(context.Background(), "a bold man, water element")
+	if !bytes.Equal(first.Data, again.Data) || bytes.Equal(first.Data, other.Data) {
+		t.Error("placeholder is not determined by the prompt")
+	}
+}
+
+func TestOpenAIImageProviderRequest(t *testing.T) {
+	image := base64.StdEncoding.EncodeToString([]byte("png bytes"))
+	server, captured := newCapturingServer(t, "application/json", `{"data":[{"b64_json":"`+image+`"}]}`)
+	defer server.Close()
+
+	generated, err := newOpenAIImageProvider(server.URL, "image-key", "", server.Client()).GenerateImage(context.Background(), "portrait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(generated.Data) != "png bytes" || generated.ContentType != "image/png" {
+		t.Errorf("image = %+v", generated)
+	}
+	if captured.path != "/images/generations" || captured.header.Get("Authorization") != "Bearer image-key" {
+		t.Errorf("request %s with %q", captured.path, captured.header.Get("Authorization"))
+	}
+	if captured.body["model"] != defaultOpenAIImageModel || captured.body["prompt"] != "portrait" || captured.body["response_format"] != "b64_json" {
+		t.Errorf("body = %v", captured.body)
+	}
+}
+
+func TestNewImageProvider(t *testing.T) {
+	usage := &recordedUsage{}
+	provider, err := NewImageProvider(usage, &config.Config{ImageProvider: "FAKE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.GenerateImage(WithAIUsage(context.Background(), 4, AIFeatureSpouseImage), "portrait"); err != nil {
+		t.Fatal(err)
+	}
+	// 이미지 생성도 사용량으로 남긴다
+	if len(usage.usages) != 1 || usage.usages[0].UserID != 4 || usage.usages[0].Feature != AIFeatureSpouseImage || usage.usages[0].Provider != ImageProviderFake {
+		t.Errorf("usages = %+v", usage.usages)
+	}
+
+	if _, err := NewImageProvider(usage, &config.Config{ImageProvider: "midjourney"}); err == nil {
+		t.Error("unknown provider should fail")
+	}
+}
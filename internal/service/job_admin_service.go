@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 관리자가 작업 큐를 들여다보고 dead 작업을 다시 실행한다
+type JobAdminService interface {
+	ListJobs(status, jobType string, limit, offset int) (*JobList, error)
+	GetJob(id uint) (*models.Job, error)
+	RetryJob(id uint) (*models.Job, error)
+}
+
+type JobList struct {
+	Jobs   []models.Job     `json:"jobs"`
+	Total  int64            `json:"total" example:"42" description:"필터에 맞는 전체 작업 수"`
+	Counts map[string]int64 `json:"counts" description:"상태별 작업 수 (필터와 관계없이 전체)"`
+}
+
+var jobStatuses = map[string]bool{
+	models.JobStatusPending:   true,
+	models.JobStatusRunning:   true,
+	models.JobStatusCompleted: true,
+	models.JobStatusDead:      true,
+}
+
+type jobAdminService struct {
+	jobRepo repository.JobRepository
+}
+
+func NewJobAdminService(jobRepo repository.JobRepository) JobAdminService {
+	return &jobAdminService{
+		jobRepo: jobRepo,
+	}
+}
+
+func (s *jobAdminService) ListJobs(status, jobType string, limit, offset int) (*JobList, error) {
+	if status != "" && !jobStatuses[status] {
+		return nil, errors.New("invalid job status")
+	}
+
+	jobs, total, err := s.jobRepo.FindAll(repository.JobFilter{Status: status, Type: jobType}, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.jobRepo.CountByStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobList{Jobs: jobs, Total: total, Counts: counts}, nil
+}
+
+func (s *jobAdminService) GetJob(id uint) (*models.Job, error) {
+	job, err := s.jobRepo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("job not found")
+	}
+	return job, nil
+}
+
+// dead 작업이나 재시도를 기다리는 작업만 다시 실행할 수 있다 (실행 중이거나 끝난 작업은 건드리지 않는다)
+func (s *jobAdminService) RetryJob(id uint) (*models.Job, error) {
+	if _, err := s.GetJob(id); err != nil {
+		return nil, err
+	}
+
+	job, err := s.jobRepo.Retry(id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("job is not retryable")
+	}
+	return job, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 요청 처리 중에 하지 않아도 되는 일(AI 이미지 생성, 기록 저장 같은 부수 효과)을 jobs 테이블에 넣는다
+type JobQueue interface {
+	Enqueue(jobType string, payload interface{}) (*models.Job, error)
+}
+
+type jobQueue struct {
+	jobRepo     repository.JobRepository
+	maxAttempts int
+}
+
+func NewJobQueue(jobRepo repository.JobRepository, cfg *config.Config) JobQueue {
+	return &jobQueue{
+		jobRepo:     jobRepo,
+		maxAttempts: cfg.JobMaxAttempts,
+	}
+}
+
+func (q *jobQueue) Enqueue(jobType string, payload interface{}) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobStatusPending,
+		RunAt:       time.Now(),
+		MaxAttempts: q.maxAttempts,
+	}
+	if err := q.jobRepo.Create(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// 작업 하나를 처리한다. 에러를 반환하면 재시도하고, PermanentJobError로 감싸면 바로 dead로 보낸다
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// payload를 T로 풀어 넘긴다. 풀 수 없는 payload는 다시 시도해도 소용없으므로 바로 dead로 보낸다
+func TypedJobHandler[T any](handle func(ctx context.Context, job *models.Job, payload T) error) JobHandler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload T
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return PermanentJobError(fmt.Errorf("invalid payload: %w", err))
+		}
+		return handle(ctx, job, payload)
+	}
+}
+
+type permanentJobError struct {
+	err error
+}
+
+func (e *permanentJobError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentJobError) Unwrap() error {
+	return e.err
+}
+
+// 재시도하지 않을 실패
+func PermanentJobError(err error) error {
+	return &permanentJobError{err: err}
+}
+
+// jobs 테이블을 폴링해 등록된 타입의 작업을 동시에 Concurrency개까지 처리한다
+type WorkerPool struct {
+	jobRepo      repository.JobRepository
+	handlers     map[string]JobHandler
+	concurrency  int
+	pollInterval time.Duration
+	jobTimeout   time.Duration
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	workerPrefix string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWorkerPool(jobRepo repository.JobRepository, cfg *config.Config) *WorkerPool {
+	hostname, _ := os.Hostname()
+	return &WorkerPool{
+		jobRepo:      jobRepo,
+		handlers:     make(map[string]JobHandler),
+		concurrency:  cfg.WorkerConcurrency,
+		pollInterval: time.Duration(cfg.JobPollIntervalSeconds) * time.Second,
+		jobTimeout:   time.Duration(cfg.JobTimeoutSeconds) * time.Second,
+		backoff:      time.Duration(cfg.JobBackoffSeconds) * time.Second,
+		maxBackoff:   time.Hour,
+		workerPrefix: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Start 전에 등록해야 한다
+func (p *WorkerPool) Register(jobType string, handler JobHandler) {
+	p.handlers[jobType] = handler
+}
+
+func (p *WorkerPool) Start(ctx context.Context) {
+	if p.concurrency <= 0 || len(p.handlers) == 0 {
+		log.Printf("Job workers disabled")
+		return
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	types := make([]string, 0, len(p.handlers))
+	for jobType := range p.handlers {
+		types = append(types, jobType)
+	}
+
+	for i := 0; i < p.concurrency; i++ {
+		p.wg.Add(1)
+		go p.work(ctx, fmt.Sprintf("%s-%d", p.workerPrefix, i), types)
+	}
+	p.wg.Add(1)
+	go p.reapStale(ctx)
+
+	log.Printf("Started %d job workers for %v", p.concurrency, types)
+}
+
+// 새 작업을 더 꺼내지 않고, 처리 중인 작업이 끝날 때까지 기다린다
+func (p *WorkerPool) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	p.wg.Wait()
+}
+
+func (p *WorkerPool) work(ctx context.Context, workerID string, types []string) {
+	defer p.wg.Done()
+
+	for ctx.Err() == nil {
+		job, err := p.jobRepo.Claim(workerID, types, time.Now())
+		if err != nil {
+			log.Printf("Worker %s failed to claim job: %v", workerID, err)
+		}
+		if job == nil {
+			// 할 일이 없거나 DB 오류면 잠시 쉬었다가 다시 본다
+			select {
+			case <-ctx.Done():
+			case <-time.After(p.pollInterval):
+			}
+			continue
+		}
+		p.process(job)
+	}
+}
+
+// 종료 신호를 받아도 이미 꺼낸 작업은 제한 시간 안에서 끝까지 처리한다
+func (p *WorkerPool) process(job *models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.jobTimeout)
+	defer cancel()
+
+	err := p.run(ctx, job)
+	now := time.Now()
+	switch {
+	case err == nil:
+		err = p.jobRepo.MarkCompleted(job, now)
+	case isPermanentJobError(err) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) dead after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		err = p.jobRepo.MarkDead(job, err.Error(), now)
+	default:
+		runAt := now.Add(p.retryDelay(job.Attempts))
+		log.Printf("Job %d (%s) attempt %d failed, retrying at %s: %v", job.ID, job.Type, job.Attempts, runAt.Format(time.RFC3339), err)
+		err = p.jobRepo.MarkRetry(job, err.Error(), runAt)
+	}
+	if err != nil {
+		log.Printf("Failed to update job %d: %v", job.ID, err)
+	}
+}
+
+func (p *WorkerPool) run(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := p.handlers[job.Type]
+	if !ok {
+		return PermanentJobError(fmt.Errorf("no handler for job type %s", job.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %d (%s) panicked: %v\n%s", job.ID, job.Type, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// 지수 백오프 (backoff, 2배, 4배, ... 최대 maxBackoff)
+func (p *WorkerPool) retryDelay(attempts int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < attempts && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+	return delay
+}
+
+// 잠근 지 제한 시간이 한참 지난 running 작업은 워커가 죽은 것으로 보고 다시 실행되게 한다
+func (p *WorkerPool) reapStale(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := p.jobRepo.ReleaseStale(time.Now().Add(-2 * p.jobTimeout))
+			if err != nil {
+				log.Printf("Failed to release stale jobs: %v", err)
+			} else if released > 0 {
+				log.Printf("Released %d stale jobs", released)
+			}
+		}
+	}
+}
+
+func isPermanentJobError(err error) bool {
+	var permanent *permanentJobError
+	return errors.As(err, &permanent)
+}
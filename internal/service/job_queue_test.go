@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// jobRepository와 같은 조건으로 작업을 꺼내고 끝내는 메모리 저장소. 행은 복사해서 주고받는다
+type memoryJobs struct {
+	mu   sync.Mutex
+	jobs map[uint]models.Job
+}
+
+func newMemoryJobs() *memoryJobs {
+	return &memoryJobs{jobs: make(map[uint]models.Job)}
+}
+
+func (r *memoryJobs) Create(job *models.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = uint(len(r.jobs) + 1)
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memoryJobs) Claim(workerID string, types []string, now time.Time) (*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []models.Job
+	for _, job := range r.jobs {
+		if job.Status == models.JobStatusPending && !job.RunAt.After(now) && containsString(types, job.Type) {
+			due = append(due, job)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].RunAt.Before(due[j].RunAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+
+	job := due[0]
+	job.Status = models.JobStatusRunning
+	job.Attempts++
+	job.LockedBy = workerID
+	job.LockedAt = &now
+	r.jobs[job.ID] = job
+	return &job, nil
+}
+
+func (r *memoryJobs) finish(job *models.Job, update func(stored *models.Job)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Status != models.JobStatusRunning || stored.LockedBy != job.LockedBy {
+		return repository.ErrJobLockLost
+	}
+	update(&stored)
+	stored.LockedBy = ""
+	stored.LockedAt = nil
+	r.jobs[job.ID] = stored
+	*job = stored
+	return nil
+}
+
+func (r *memoryJobs) MarkCompleted(job *models.Job, now time.Time) error {
+	return r.finish(job, func(stored *models.Job) {
+		stored.Status = models.JobStatusCompleted
+		stored.LastError = ""
+		stored.CompletedAt = &now
+	})
+}
+
+func (r *memoryJobs) MarkRetry(job *models.Job, lastError string, runAt time.Time) error {
+	return r.finish(job, func(stored *models.Job) {
+		stored.Status = models.JobStatusPending
+		stored.LastError = lastError
+		stored.RunAt = runAt
+	})
+}
+
+func (r *memoryJobs) MarkDead(job *models.Job, lastError string, now time.Time) error {
+	return r.finish(job, func(stored *models.Job) {
+		stored.Status = models.JobStatusDead
+		stored.LastError = lastError
+		stored.CompletedAt = &now
+	})
+}
+
+func (r *memoryJobs) ReleaseStale(lockedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var released int64
+	for id, job := range r.jobs {
+		if job.Status == models.JobStatusRunning && job.LockedAt.Before(lockedBefore) {
+			job.Status = models.JobStatusPending
+			job.LockedBy = ""
+			job.LockedAt = nil
+			job.LastError = "worker lock expired"
+			r.jobs[id] = job
+			released++
+		}
+	}
+	return released, nil
+}
+
+func (r *memoryJobs) FindByID(id uint) (*models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &job, nil
+}
+
+func (r *memoryJobs) FindAll(filter repository.JobFilter, limit, offset int) ([]models.Job, int64, error) {
+	return nil, 0, errors.New("not used")
+}
+
+func (r *memoryJobs) CountByStatus() (map[string]int64, error) {
+	return nil, errors.New("not used")
+}
+
+func (r *memoryJobs) Retry(id uint, now time.Time) (*models.Job, error) {
+	return nil, errors.New("not used")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var testJobConfig = &config.Config{
+	JobMaxAttempts:    3,
+	JobTimeoutSeconds: 5,
+	JobBackoffSeconds: 10,
+}
+
+// 워커 하나가 now에 실행할 작업을 꺼내 처리한다. 꺼낼 작업이 없으면 nil
+func runNextJob(t *testing.T, pool *WorkerPool, jobs *memoryJobs, workerID string, now time.Time) *models.Job {
+	t.Helper()
+	job, err := jobs.Claim(workerID, []string{"test", JobTypeCreateRecord}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		return nil
+	}
+	pool.process(job)
+	stored, _ := jobs.FindByID(job.ID)
+	return stored
+}
+
+func TestWorkerPoolRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	jobs := newMemoryJobs()
+	pool := NewWorkerPool(jobs, testJobConfig)
+	calls := 0
+	pool.Register("test", func(ctx context.Context, job *models.Job) error {
+		calls++
+		return errors.New("upstream unavailable")
+	})
+	if _, err := NewJobQueue(jobs, testJobConfig).Enqueue("test", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 실패할 때마다 처리한 시각에서 10초, 20초 뒤로 미뤄지고, 그 전에는 다시 꺼내지 않는다
+	now := time.Now()
+	for attempt, wantDelay := range []time.Duration{10 * time.Second, 20 * time.Second} {
+		processedAt := time.Now()
+		job := runNextJob(t, pool, jobs, "worker-a", now)
+		if job == nil {
+			t.Fatalf("attempt %d: no job claimed", attempt+1)
+		}
+		if job.Status != models.JobStatusPending || job.Attempts != attempt+1 || job.LastError != "upstream unavailable" || job.LockedBy != "" {
+			t.Fatalf("attempt %d: job = %+v", attempt+1, job)
+		}
+		if delay := job.RunAt.Sub(processedAt); delay < wantDelay || delay > wantDelay+time.Second {
+			t.Errorf("attempt %d: retry delay %v, want about %v", attempt+1, delay, wantDelay)
+		}
+		if early := runNextJob(t, pool, jobs, "worker-a", job.RunAt.Add(-time.Millisecond)); early != nil {
+			t.Fatalf("attempt %d: job claimed before run_at", attempt+1)
+		}
+		now = job.RunAt
+	}
+
+	job := runNextJob(t, pool, jobs, "worker-a", now)
+	if job.Status != models.JobStatusDead || job.Attempts != 3 || job.CompletedAt == nil {
+		t.Fatalf("after max attempts: job = %+v", job)
+	}
+	if calls != 3 {
+		t.Errorf("handler calls = %d, want 3", calls)
+	}
+	if runNextJob(t, pool, jobs, "worker-a", now.Add(time.Hour)) != nil {
+		t.Error("dead job was claimed again")
+	}
+}
+
+func TestWorkerPoolPermanentErrorsSkipRetries(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name    string
+		payload interface{}
+		handler JobHandler
+		wantErr string
+	}{
+		{
+			name:    "invalid payload",
+			payload: map[string]int{"name": 1},
+			handler: TypedJobHandler(func(ctx context.Context, job *models.Job, p payload) error { return nil }),
+			wantErr: "invalid payload",
+		},
+		{
+			name:    "permanent handler error",
+			payload: payload{Name: "a"},
+			handler: func(ctx context.Context, job *models.Job) error { return PermanentJobError(errors.New("rejected")) },
+			wantErr: "rejected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := newMemoryJobs()
+			pool := NewWorkerPool(jobs, testJobConfig)
+			pool.Register("test", tt.handler)
+			NewJobQueue(jobs, testJobConfig).Enqueue("test", tt.payload)
+
+			job := runNextJob(t, pool, jobs, "worker-a", time.Now())
+			if job.Status != models.JobStatusDead || job.Attempts != 1 {
+				t.Fatalf("job = %+v, want dead after one attempt", job)
+			}
+			if !strings.HasPrefix(job.LastError, tt.wantErr) {
+				t.Errorf("last error %q, want prefix %q", job.LastError, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolRecoversPanicsAsRetries(t *testing.T) {
+	jobs := newMemoryJobs()
+	pool := NewWorkerPool(jobs, testJobConfig)
+	pool.Register("test", func(ctx context.Context, job *models.Job) error {
+		panic("boom")
+	})
+	NewJobQueue(jobs, testJobConfig).Enqueue("test", nil)
+
+	job := runNextJob(t, pool, jobs, "worker-a", time.Now())
+	if job.Status != models.JobStatusPending || job.LastError != "panic: boom" {
+		t.Errorf("job = %+v, want a retry after the panic", job)
+	}
+}
+
+func TestWorkerPoolDoesNotOverwriteJobClaimedByAnotherWorker(t *testing.T) {
+	jobs := newMemoryJobs()
+	pool := NewWorkerPool(jobs, testJobConfig)
+	var slow *models.Job
+	pool.Register("test", func(ctx context.Context, job *models.Job) error {
+		if job.LockedBy == "worker-a" {
+			slow = job
+			return nil
+		}
+		return errors.New("still failing")
+	})
+	NewJobQueue(jobs, testJobConfig).Enqueue("test", nil)
+
+	// worker-a가 잠금이 만료될 만큼 오래 걸리는 동안 worker-b가 다시 가져간다
+	now := time.Now()
+	claimed, _ := jobs.Claim("worker-a", []string{"test"}, now)
+	if released, _ := jobs.ReleaseStale(now.Add(time.Second)); released != 1 {
+		t.Fatalf("released %d stale jobs, want 1", released)
+	}
+	retaken, _ := jobs.Claim("worker-b", []string{"test"}, now.Add(time.Second))
+	if retaken == nil {
+		t.Fatal("released job was not claimed again")
+	}
+
+	pool.process(claimed)
+	if slow == nil {
+		t.Fatal("worker-a handler did not run")
+	}
+	if job, _ := jobs.FindByID(claimed.ID); job.Status != models.JobStatusRunning || job.LockedBy != "worker-b" {
+		t.Fatalf("late result from worker-a overwrote worker-b's claim: %+v", job)
+	}
+
+	pool.process(retaken)
+	if job, _ := jobs.FindByID(claimed.ID); job.Status != models.JobStatusPending || job.LastError != "still failing" || job.Attempts != 2 {
+		t.Errorf("worker-b result not saved: %+v", job)
+	}
+}
+
+func TestCreateRecordJobSavesOneRecordAcrossRetries(t *testing.T) {
+	jobs := newMemoryJobs()
+	records := &jobRecordStore{}
+	pool := NewWorkerPool(jobs, testJobConfig)
+	RegisterJobHandlers(pool, records, nil)
+	enqueueRecord(NewJobQueue(jobs, testJobConfig), &models.FortuneRecord{UserID: 7, Type: "fortune", Content: "오늘의 운세"})
+
+	// 기록을 저장한 뒤 완료 표시 전에 워커가 죽어 잠금이 만료된 경우
+	now := time.Now()
+	claimed, _ := jobs.Claim("worker-a", []string{JobTypeCreateRecord}, now)
+	if err := pool.run(context.Background(), claimed); err != nil {
+		t.Fatal(err)
+	}
+	jobs.ReleaseStale(now.Add(time.Second))
+
+	job := runNextJob(t, pool, jobs, "worker-b", now.Add(time.Second))
+	if job == nil || job.Status != models.JobStatusCompleted || job.Attempts != 2 {
+		t.Fatalf("retried job = %+v, want completed on the second attempt", job)
+	}
+	if len(records.records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records.records))
+	}
+	if record := records.records[0]; record.UserID != 7 || record.Content != "오늘의 운세" || *record.JobID != job.ID {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
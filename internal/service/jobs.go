@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+// 작업 타입
+const (
+	JobTypeCreateRecord = "record.create"
+	JobTypeSpouseImage  = "spouse_image.generate"
+)
+
+type CreateRecordPayload struct {
+	UserID   uint   `json:"user_id"`
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	ImageURL string `json:"image_url,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+}
+
+type SpouseImagePayload struct {
+	UserID           uint `json:"user_id"`
+	SpouseImageJobID uint `json:"spouse_image_job_id"`
+}
+
+// 워커 풀에 작업 타입별 처리기를 등록한다
+func RegisterJobHandlers(pool *WorkerPool, recordRepo repository.RecordRepository, spouseImageService SpouseImageService) {
+	pool.Register(JobTypeCreateRecord, TypedJobHandler(func(ctx context.Context, job *models.Job, payload CreateRecordPayload) error {
+		// 저장한 뒤 완료 표시 전에 워커가 죽어 다시 실행돼도 기록이 두 번 생기지 않는다
+		return recordRepo.CreateForJob(&models.FortuneRecord{
+			UserID:   payload.UserID,
+			Type:     payload.Type,
+			Content:  payload.Content,
+			ImageURL: payload.ImageURL,
+			Metadata: payload.Metadata,
+			JobID:    &job.ID,
+		})
+	}))
+	pool.Register(JobTypeSpouseImage, TypedJobHandler(func(ctx context.Context, job *models.Job, payload SpouseImagePayload) error {
+		return spouseImageService.ProcessSpouseImageJob(ctx, job.ID, payload.UserID, payload.SpouseImageJobID, job.Attempts >= job.MaxAttempts)
+	}))
+}
+
+// 기록 저장을 작업 큐에 넘겨 실패해도 재시도되게 한다. 큐에 넣지도 못하면 로그만 남긴다 (응답은 이미 계산된 결과로 충분하다)
+func enqueueRecord(queue JobQueue, record *models.FortuneRecord) {
+	_, err := queue.Enqueue(JobTypeCreateRecord, CreateRecordPayload{
+		UserID:   record.UserID,
+		Type:     record.Type,
+		Content:  record.Content,
+		ImageURL: record.ImageURL,
+		Metadata: record.Metadata,
+	})
+	if err != nil {
+		log.Printf("Failed to enqueue %s record for user %d: %v", record.Type, record.UserID, err)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	defaultGeminiModel   = "gemini-2.5-flash"
+)
+
+type geminiProvider struct {
+	baseURL string
+	apiKey  string
+	params  LLMParams
+	client  *http.Client
+}
+
+func newGeminiProvider(baseURL, apiKey string, params LLMParams, client *http.Client) LLMProvider {
+	if baseURL == "" {
+		baseURL = defaultGeminiBaseURL
+	}
+	if params.Model == "" {
+		params.Model = defaultGeminiModel
+	}
+	return &geminiProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		params:  params,
+		client:  client,
+	}
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      *float64    `json:"temperature,omitempty"`
+	MaxOutputTokens  int         `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string      `json:"responseMimeType,omitempty"`
+	ResponseSchema   *JSONSchema `json:"responseSchema,omitempty"`
+}
+
+type GeminiRequest struct {
+	Contents         []geminiContent         `json:"contents"`
+	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+type GeminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+func (p *geminiProvider) Name() string {
+	return LLMProviderGemini
+}
+
+func (p *geminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.generate(ctx, prompt, nil)
+}
+
+func (p *geminiProvider) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	return p.generate(ctx, prompt, &schema)
+}
+
+// streamGenerateContent를 SSE(alt=sse)로 받아 후보 텍스트 조각을 이어 붙인다
+func (p *geminiProvider) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, "streamGenerateContent?alt=sse", prompt, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newLLMHTTPError("Gemini", resp)
+	}
+
+	var full strings.Builder
+	err = readSSEData(resp.Body, func(data string) error {
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+		// 조각마다 지금까지의 누적 토큰 수가 온다
+		if chunk.UsageMetadata != nil {
+			reportTokenUsage(ctx, chunk.UsageMetadata.PromptTokenCount, chunk.UsageMetadata.CandidatesTokenCount)
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			full.WriteString(part.Text)
+			if err := onDelta(part.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if full.Len() == 0 {
+		return "", errors.New("no text in response")
+	}
+
+	return full.String(), nil
+}
+
+func (p *geminiProvider) generate(ctx context.Context, prompt string, schema *ResponseSchema) (string, error) {
+	req, err := p.newRequest(ctx, "generateContent", prompt, schema)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newLLMHTTPError("Gemini", resp)
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+		return "", err
+	}
+	if geminiResp.UsageMetadata != nil {
+		reportTokenUsage(ctx, geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+	}
+
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return "", errors.New("no text in response")
+	}
+
+	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+}
+
+func (p *geminiProvider) newRequest(ctx context.Context, method, prompt string, schema *ResponseSchema) (*http.Request, error) {
+	if p.apiKey == "" {
+		return nil, errors.New("Gemini API key not configured")
+	}
+
+	reqBody := GeminiRequest{
+		Contents: []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: prompt}}},
+		},
+	}
+	generationConfig := &geminiGenerationConfig{MaxOutputTokens: p.params.MaxTokens}
+	if p.params.Temperature > 0 {
+		temperature := p.params.Temperature
+		generationConfig.Temperature = &temperature
+	}
+	if schema != nil {
+		generationConfig.ResponseMimeType = "application/json"
+		generationConfig.ResponseSchema = schema.Schema
+	}
+	reqBody.GenerationConfig = generationConfig
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// API 키는 URL 대신 헤더로 보낸다 (로그에 남지 않도록)
+	url := fmt.Sprintf("%s/models/%s:%s", p.baseURL, p.params.Model, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.apiKey)
+	return req, nil
+}
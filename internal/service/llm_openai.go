@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAI 호환 chat completions 백엔드 (vLLM, Ollama, LM Studio 등 로컬 서버도 LLM_BASE_URL로 지정할 수 있다)
+type openAIProvider struct {
+	baseURL string
+	apiKey  string
+	params  LLMParams
+	client  *http.Client
+}
+
+func newOpenAIProvider(baseURL, apiKey string, params LLMParams, client *http.Client) LLMProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if params.Model == "" {
+		params.Model = defaultOpenAIModel
+	}
+	return &openAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		params:  params,
+		client:  client,
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIJSONSchema struct {
+	Name   string      `json:"name"`
+	Schema *JSONSchema `json:"schema"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type OpenAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+type OpenAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+}
+
+type OpenAIChatStreamChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+}
+
+func (p *openAIProvider) Name() string {
+	return LLMProviderOpenAI
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.generate(ctx, prompt, nil)
+}
+
+func (p *openAIProvider) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	return p.generate(ctx, prompt, &schema)
+}
+
+func (p *openAIProvider) generate(ctx context.Context, prompt string, schema *ResponseSchema) (string, error) {
+	req, err := p.newRequest(ctx, prompt, schema, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newLLMHTTPError("OpenAI", resp)
+	}
+
+	var chatResp OpenAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", err
+	}
+	if chatResp.Usage != nil {
+		reportTokenUsage(ctx, chatResp.Usage.PromptTokens, chatResp.Usage.CompletionTokens)
+	}
+
+	if len(chatResp.Choices) == 0 || chatResp.Choices[0].Message.Content == "" {
+		return "", errors.New("no text in response")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// stream: true로 받은 SSE의 delta.content를 이어 붙인다 ([DONE]에서 끝난다)
+func (p *openAIProvider) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	req, err := p.newRequest(ctx, prompt, nil, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newLLMHTTPError("OpenAI", resp)
+	}
+
+	var full strings.Builder
+	err = readSSEData(resp.Body, func(data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk OpenAIChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+		// include_usage를 켜면 [DONE] 직전에 choices 없이 사용량만 담은 조각이 온다
+		if chunk.Usage != nil {
+			reportTokenUsage(ctx, chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		full.WriteString(chunk.Choices[0].Delta.Content)
+		return onDelta(chunk.Choices[0].Delta.Content)
+	})
+	if err != nil {
+		return "", err
+	}
+	if full.Len() == 0 {
+		return "", errors.New("no text in response")
+	}
+
+	return full.String(), nil
+}
+
+func (p *openAIProvider) newRequest(ctx context.Context, prompt string, schema *ResponseSchema, stream bool) (*http.Request, error) {
+	reqBody := OpenAIChatRequest{
+		Model:     p.params.Model,
+		Messages:  []openAIMessage{{Role: "user", Content: prompt}},
+		MaxTokens: p.params.MaxTokens,
+		Stream:    stream,
+	}
+	if stream {
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if p.params.Temperature > 0 {
+		temperature := p.params.Temperature
+		reqBody.Temperature = &temperature
+	}
+	if schema != nil {
+		reqBody.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: schema.Name, Schema: schema.Schema},
+		}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 로컬 서버는 키 없이 동작하는 경우가 많다
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return req, nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dothefortune_server/internal/config"
+)
+
+// 텍스트 생성 모델 백엔드
+type LLMProvider interface {
+	Name() string
+	Generate(ctx context.Context, prompt string) (string, error)
+	// 응답 스키마에 맞는 JSON 문자열을 생성한다 (형식 검증은 호출하는 쪽에서 한다)
+	GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error)
+	// 생성되는 대로 조각을 onDelta로 넘기고 전체 문장을 반환한다. ctx가 취소되면 업스트림 요청도 끊는다
+	GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error)
+}
+
+// 구조화 응답에 쓰는 JSON 스키마 (Gemini responseSchema와 OpenAI json_schema가 함께 이해하는 부분만)
+type JSONSchema struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+type ResponseSchema struct {
+	Name   string
+	Schema *JSONSchema
+}
+
+// 공통 생성 파라미터 (0이면 백엔드 기본값)
+type LLMParams struct {
+	Model       string
+	Temperature float64
+	MaxTokens   int
+}
+
+const (
+	LLMProviderGemini   = "gemini"
+	LLMProviderOpenAI   = "openai"
+	LLMProviderTemplate = "template"
+)
+
+// 설정의 LLM_PROVIDER에 맞는 백엔드를 만들고 제한 시간, 재시도, 회로 차단기, 동시 호출 제한을 씌운다.
+// 실제 호출(재시도 포함)은 하나하나 usage에 남긴다
+func NewLLMProvider(usage AIUsageService, cfg *config.Config) (LLMProvider, error) {
+	params := LLMParams{
+		Model:       cfg.LLMModel,
+		Temperature: cfg.LLMTemperature,
+		MaxTokens:   cfg.LLMMaxTokens,
+	}
+
+	timeout := time.Duration(cfg.LLMTimeoutSeconds) * time.Second
+	// 전체 요청 시간은 호출마다 컨텍스트로 제한하고, 클라이언트는 연결 수와 응답 헤더 대기만 제한한다
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			MaxConnsPerHost:       cfg.LLMMaxConcurrency,
+			MaxIdleConnsPerHost:   cfg.LLMMaxConcurrency,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+		},
+	}
+
+	var provider LLMProvider
+	model := params.Model
+	switch strings.ToLower(cfg.LLMProvider) {
+	case LLMProviderGemini:
+		apiKey := cfg.LLMAPIKey
+		if apiKey == "" {
+			apiKey = cfg.GeminiAPIKey
+		}
+		provider = newGeminiProvider(cfg.LLMBaseURL, apiKey, params, client)
+		if model == "" {
+			model = defaultGeminiModel
+		}
+	case LLMProviderOpenAI:
+		provider = newOpenAIProvider(cfg.LLMBaseURL, cfg.LLMAPIKey, params, client)
+		if model == "" {
+			model = defaultOpenAIModel
+		}
+	case LLMProviderTemplate:
+		provider = newTemplateProvider()
+		model = LLMProviderTemplate
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.LLMProvider)
+	}
+
+	return newResilientProvider(newMeteredProvider(provider, model, usage), ResilienceOptions{
+		Timeout:          timeout,
+		StreamTimeout:    time.Duration(cfg.LLMStreamTimeoutSeconds) * time.Second,
+		MaxRetries:       cfg.LLMMaxRetries,
+		BaseBackoff:      500 * time.Millisecond,
+		MaxConcurrency:   cfg.LLMMaxConcurrency,
+		FailureThreshold: cfg.LLMBreakerThreshold,
+		Cooldown:         time.Duration(cfg.LLMBreakerCooldownSeconds) * time.Second,
+	}), nil
+}
+
+const templateStreamChunkRunes = 4
+
+// 외부 호출 없이 프롬프트 해시로 문장을 고르는 개발용 백엔드. 같은 프롬프트에는 항상 같은 문장을 준다
+type templateProvider struct {
+	sentences []string
+}
+
+func newTemplateProvider() LLMProvider {
+	return &templateProvider{
+		sentences: []string{
+			"오늘은 차분하게 한 걸음씩 나아가면 좋은 결과가 따라와요.",
+			"주변 사람들과 마음을 나누면 뜻밖의 도움을 받을 수 있어요.",
+			"작은 일에도 감사하는 마음을 가지면 하루가 한결 가벼워져요.",
+			"새로운 시도를 하기 좋은 날이에요. 망설였던 일을 시작해보세요.",
+			"잠시 쉬어가는 것도 좋아요. 충분히 쉬면 다시 힘이 날 거예요.",
+			"꾸준히 해온 노력이 조금씩 빛을 보기 시작하는 하루예요.",
+		},
+	}
+}
+
+func (p *templateProvider) Name() string {
+	return LLMProviderTemplate
+}
+
+func (p *templateProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.pick(prompt), nil
+}
+
+// 스키마의 문자열 필드마다 필드 이름을 섞은 해시로 문장을 골라 채운다
+func (p *templateProvider) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	result := make(map[string]string)
+	if schema.Schema != nil {
+		for name, property := range schema.Schema.Properties {
+			if property.Type == "string" {
+				result[name] = p.pick(prompt + "/" + name)
+			}
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// 글자 몇 개씩 끊어 스트리밍을 흉내 낸다
+func (p *templateProvider) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	text := p.pick(prompt)
+	runes := []rune(text)
+	for start := 0; start < len(runes); start += templateStreamChunkRunes {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		end := start + templateStreamChunkRunes
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if err := onDelta(string(runes[start:end])); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+func (p *templateProvider) pick(seed string) string {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	return p.sentences[h.Sum32()%uint32(len(p.sentences))]
+}
+
+// text/event-stream 응답에서 data 줄을 하나씩 넘긴다
+func readSSEData(body io.Reader, onData func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		if err := onData(strings.TrimSpace(strings.TrimPrefix(line, "data:"))); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// 업스트림이 200이 아닌 응답을 준 경우. 429와 5xx는 재시도 대상이다
+type LLMHTTPError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *LLMHTTPError) Error() string {
+	return fmt.Sprintf("%s API error: %s", e.Provider, e.Body)
+}
+
+func newLLMHTTPError(provider string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	httpErr := &LLMHTTPError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		httpErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return httpErr
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+)
+
+// 남긴 사용량만 모아 두는 AIUsageService. quotaErr가 있으면 한도를 넘은 것으로 본다
+type recordedUsage struct {
+	mu       sync.Mutex
+	usages   []models.AIUsage
+	quotaErr error
+}
+
+func (s *recordedUsage) Record(usage *models.AIUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usages = append(s.usages, *usage)
+}
+
+func (s *recordedUsage) CheckQuota(userID uint) error {
+	return s.quotaErr
+}
+
+func (s *recordedUsage) GetReport(from, to string, userID uint) (*AIUsageReport, error) {
+	return nil, errors.New("not used")
+}
+
+// 받은 요청을 남기고 정해진 본문으로 응답하는 업스트림
+type capturedRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newCapturingServer(t *testing.T, contentType, response string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	captured := &capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		captured.path = r.URL.Path
+		captured.query = r.URL.RawQuery
+		captured.header = r.Header.Clone()
+		captured.body = nil
+		json.Unmarshal(data, &captured.body)
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, captured
+}
+
+var testResponseSchema = ResponseSchema{
+	Name: "fortune",
+	Schema: &JSONSchema{
+		Type:       "object",
+		Properties: map[string]*JSONSchema{"summary": {Type: "string"}, "score": {Type: "integer"}},
+		Required:   []string{"summary"},
+	},
+}
+
+func TestGeminiProviderRequest(t *testing.T) {
+	server, captured := newCapturingServer(t, "application/json",
+		`{"candidates":[{"content":{"parts":[{"text":"좋은 하루"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":5}}`)
+	provider := newGeminiProvider(server.URL+"/", "secret", LLMParams{Model: "gemini-test", Temperature: 0.4, MaxTokens: 256}, server.Client())
+
+	text, err := provider.Generate(context.Background(), "오늘의 운세")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "좋은 하루" {
+		t.Errorf("text = %q", text)
+	}
+	// 모델은 설정을 따르고 API 키는 쿼리가 아닌 헤더로 보낸다
+	if captured.path != "/models/gemini-test:generateContent" || captured.query != "" {
+		t.Errorf("request URL %s?%s", captured.path, captured.query)
+	}
+	if got := captured.header.Get("x-goog-api-key"); got != "secret" {
+		t.Errorf("api key header = %q", got)
+	}
+	generationConfig, _ := captured.body["generationConfig"].(map[string]interface{})
+	if generationConfig["temperature"] != 0.4 || generationConfig["maxOutputTokens"] != 256.0 {
+		t.Errorf("generationConfig = %v", generationConfig)
+	}
+	if _, ok := generationConfig["responseSchema"]; ok {
+		t.Error("plain generation should not send a response schema")
+	}
+
+	if _, err := provider.GenerateStructured(context.Background(), "오늘의 운세", testResponseSchema); err != nil {
+		t.Fatal(err)
+	}
+	generationConfig, _ = captured.body["generationConfig"].(map[string]interface{})
+	schema, _ := generationConfig["responseSchema"].(map[string]interface{})
+	if generationConfig["responseMimeType"] != "application/json" || schema["type"] != "object" {
+		t.Errorf("structured generationConfig = %v", generationConfig)
+	}
+
+	if _, err := newGeminiProvider(server.URL, "", LLMParams{}, server.Client()).Generate(context.Background(), "x"); err == nil {
+		t.Error("missing API key should fail before calling the API")
+	}
+}
+
+func TestGeminiProviderStream(t *testing.T) {
+	server, captured := newCapturingServer(t, "text/event-stream",
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"좋은 \"}]}}]}\n\n"+
+			"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"하루\"}]}}],\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":2}}\n\n")
+	provider := newGeminiProvider(server.URL, "secret", LLMParams{}, server.Client())
+
+	var deltas []string
+	text, err := provider.GenerateStream(context.Background(), "오늘의 운세", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "좋은 하루" || strings.Join(deltas, "|") != "좋은 |하루" {
+		t.Errorf("text %q, deltas %q", text, deltas)
+	}
+	if captured.path != "/models/"+defaultGeminiModel+":streamGenerateContent" || captured.query != "alt=sse" {
+		t.Errorf("stream URL %s?%s", captured.path, captured.query)
+	}
+}
+
+func TestOpenAIProviderRequest(t *testing.T) {
+	server, captured := newCapturingServer(t, "application/json",
+		`{"choices":[{"message":{"role":"assistant","content":"좋은 하루"}}],"usage":{"prompt_tokens":12,"completion_tokens":5}}`)
+
+	provider := newOpenAIProvider(server.URL, "sk-test", LLMParams{Model: "local-model", Temperature: 0.7, MaxTokens: 128}, server.Client())
+	text, err := provider.GenerateStructured(context.Background(), "오늘의 운세", testResponseSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "좋은 하루" || captured.path != "/chat/completions" {
+		t.Errorf("text %q from %s", text, captured.path)
+	}
+	if got := captured.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if captured.body["model"] != "local-model" || captured.body["temperature"] != 0.7 || captured.body["max_tokens"] != 128.0 {
+		t.Errorf("request body = %v", captured.body)
+	}
+	format, _ := captured.body["response_format"].(map[string]interface{})
+	jsonSchema, _ := format["json_schema"].(map[string]interface{})
+	if format["type"] != "json_schema" || jsonSchema["name"] != "fortune" {
+		t.Errorf("response_format = %v", format)
+	}
+
+	// 키 없는 로컬 서버에는 Authorization을 보내지 않고, 0인 파라미터는 서버 기본값에 맡긴다
+	local := newOpenAIProvider(server.URL, "", LLMParams{}, server.Client())
+	if _, err := local.Generate(context.Background(), "오늘의 운세"); err != nil {
+		t.Fatal(err)
+	}
+	if got := captured.header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+	for _, field := range []string{"temperature", "max_tokens", "response_format", "stream"} {
+		if _, ok := captured.body[field]; ok {
+			t.Errorf("unexpected %s in %v", field, captured.body)
+		}
+	}
+	if captured.body["model"] != defaultOpenAIModel {
+		t.Errorf("model = %v, want %s", captured.body["model"], defaultOpenAIModel)
+	}
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	server, captured := newCapturingServer(t, "text/event-stream",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"좋은 \"}}]}\n\n"+
+			"data: {\"choices\":[{\"delta\":{\"content\":\"하루\"}}]}\n\n"+
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2}}\n\n"+
+			"data: [DONE]\n\n")
+	provider := newOpenAIProvider(server.URL, "", LLMParams{}, server.Client())
+
+	var deltas []string
+	text, err := provider.GenerateStream(context.Background(), "오늘의 운세", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "좋은 하루" || strings.Join(deltas, "|") != "좋은 |하루" {
+		t.Errorf("text %q, deltas %q", text, deltas)
+	}
+	options, _ := captured.body["stream_options"].(map[string]interface{})
+	if captured.body["stream"] != true || options["include_usage"] != true {
+		t.Errorf("stream request body = %v", captured.body)
+	}
+}
+
+func TestTemplateProviderIsDeterministic(t *testing.T) {
+	provider := newTemplateProvider()
+	ctx := context.Background()
+
+	first, _ := provider.Generate(ctx, "오늘의 운세")
+	second, _ := provider.Generate(ctx, "오늘의 운세")
+	if first == "" || first != second {
+		t.Errorf("same prompt gave %q and %q", first, second)
+	}
+
+	var deltas []string
+	streamed, err := provider.GenerateStream(ctx, "오늘의 운세", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || streamed != first || strings.Join(deltas, "") != first || len(deltas) < 2 {
+		t.Errorf("stream = %q in %d deltas (err %v), want %q", streamed, len(deltas), err, first)
+	}
+
+	// 문자열 필드만 채운다
+	structured, err := provider.GenerateStructured(ctx, "오늘의 운세", testResponseSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(structured), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields["summary"] == "" {
+		t.Errorf("structured = %s", structured)
+	}
+}
+
+// 한 번에 하나씩, 재시도 없이 호출하는 보호 설정
+func testLLMConfig(provider string) *config.Config {
+	return &config.Config{
+		LLMProvider:             provider,
+		LLMTimeoutSeconds:       5,
+		LLMStreamTimeoutSeconds: 5,
+		LLMMaxConcurrency:       1,
+		LLMBreakerThreshold:     5,
+	}
+}
+
+func TestNewLLMProviderSelectsBackend(t *testing.T) {
+	server, captured := newCapturingServer(t, "application/json",
+		`{"candidates":[{"content":{"parts":[{"text":"좋은 하루"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":5}}`)
+
+	tests := []struct {
+		provider  string
+		wantName  string
+		wantModel string
+	}{
+		{"gemini", LLMProviderGemini, defaultGeminiModel},
+		{"OpenAI", LLMProviderOpenAI, defaultOpenAIModel},
+		{"template", LLMProviderTemplate, LLMProviderTemplate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			usage := &recordedUsage{}
+			provider, err := NewLLMProvider(usage, testLLMConfig(tt.provider))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if provider.Name() != tt.wantName {
+				t.Errorf("Name() = %q, want %q", provider.Name(), tt.wantName)
+			}
+			if tt.provider != "template" {
+				return
+			}
+			if _, err := provider.Generate(context.Background(), "오늘의 운세"); err != nil {
+				t.Fatal(err)
+			}
+			if len(usage.usages) != 1 || usage.usages[0].Model != tt.wantModel || usage.usages[0].Provider != tt.wantName {
+				t.Errorf("usages = %+v", usage.usages)
+			}
+		})
+	}
+
+	if _, err := NewLLMProvider(&recordedUsage{}, testLLMConfig("claude")); err == nil {
+		t.Error("unknown provider should fail")
+	}
+
+	// LLM_API_KEY가 없으면 기존 GEMINI_API_KEY를 쓰고, 모델과 파라미터는 설정을 따른다
+	usage := &recordedUsage{}
+	cfg := testLLMConfig(LLMProviderGemini)
+	cfg.LLMBaseURL = server.URL
+	cfg.LLMModel = "gemini-configured"
+	cfg.LLMMaxTokens = 64
+	cfg.GeminiAPIKey = "legacy-key"
+	provider, err := NewLLMProvider(usage, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Generate(context.Background(), "오늘의 운세"); err != nil {
+		t.Fatal(err)
+	}
+	generationConfig, _ := captured.body["generationConfig"].(map[string]interface{})
+	if captured.header.Get("x-goog-api-key") != "legacy-key" || captured.path != "/models/gemini-configured:generateContent" || generationConfig["maxOutputTokens"] != 64.0 {
+		t.Errorf("request %s with key %q, config %v", captured.path, captured.header.Get("x-goog-api-key"), generationConfig)
+	}
+	if len(usage.usages) != 1 || usage.usages[0].Model != "gemini-configured" || usage.usages[0].PromptTokens != 12 || usage.usages[0].Estimated {
+		t.Errorf("usages = %+v", usage.usages)
+	}
+}
+
+func TestOpenAIStreamCancelsUpstreamOnDisconnect(t *testing.T) {
+	upstreamDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"좋은 \"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// 클라이언트가 끊을 때까지 다음 조각을 보내지 않는다
+		<-r.Context().Done()
+		close(upstreamDone)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := newOpenAIProvider(server.URL, "", LLMParams{}, server.Client())
+	_, err := provider.GenerateStream(ctx, "오늘의 운세", func(delta string) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+
+	select {
+	case <-upstreamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+}
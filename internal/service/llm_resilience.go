@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLLMUnavailable   = errors.New("LLM provider unavailable")
+	ErrLLMPoolExhausted = errors.New("LLM concurrency limit reached")
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+const maxLLMBackoff = 8 * time.Second
+
+// 백엔드 상태 (/health에 노출)
+type LLMHealth struct {
+	Provider            string     `json:"provider"`
+	Circuit             string     `json:"circuit"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	InFlight            int        `json:"in_flight"`
+	MaxConcurrency      int        `json:"max_concurrency"`
+}
+
+type LLMHealthReporter interface {
+	Health() LLMHealth
+}
+
+type ResilienceOptions struct {
+	Timeout          time.Duration // 일반 호출 한 번의 제한 시간
+	StreamTimeout    time.Duration // 스트리밍 호출 한 번의 제한 시간
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxConcurrency   int
+	FailureThreshold int           // 연속 실패가 이만큼 쌓이면 회로를 연다
+	Cooldown         time.Duration // 회로를 연 뒤 다시 시험해볼 때까지 기다리는 시간
+}
+
+// 제한 시간, 429/5xx·네트워크 오류 재시도, 회로 차단기, 동시 호출 제한을 씌운 백엔드.
+// 회로가 열려 있으면 바로 ErrLLMUnavailable을 반환해 호출하는 쪽이 규칙 기반 문장으로 대체하게 한다
+type resilientProvider struct {
+	inner   LLMProvider
+	options ResilienceOptions
+	slots   chan struct{}
+	breaker *circuitBreaker
+}
+
+func newResilientProvider(inner LLMProvider, options ResilienceOptions) LLMProvider {
+	if options.MaxConcurrency <= 0 {
+		options.MaxConcurrency = 1
+	}
+	return &resilientProvider{
+		inner:   inner,
+		options: options,
+		slots:   make(chan struct{}, options.MaxConcurrency),
+		breaker: &circuitBreaker{
+			threshold: options.FailureThreshold,
+			cooldown:  options.Cooldown,
+			state:     CircuitClosed,
+		},
+	}
+}
+
+func (p *resilientProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *resilientProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	var text string
+	err := p.call(ctx, p.options.Timeout, nil, func(callCtx context.Context) error {
+		var err error
+		text, err = p.inner.Generate(callCtx, prompt)
+		return err
+	})
+	return text, err
+}
+
+func (p *resilientProvider) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	var text string
+	err := p.call(ctx, p.options.Timeout, nil, func(callCtx context.Context) error {
+		var err error
+		text, err = p.inner.GenerateStructured(callCtx, prompt, schema)
+		return err
+	})
+	return text, err
+}
+
+// 이미 조각을 내보낸 뒤에는 중복 출력이 되므로 재시도하지 않는다
+func (p *resilientProvider) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	emitted := false
+	var text string
+	err := p.call(ctx, p.options.StreamTimeout, func() bool { return !emitted }, func(callCtx context.Context) error {
+		var err error
+		text, err = p.inner.GenerateStream(callCtx, prompt, func(delta string) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		return err
+	})
+	return text, err
+}
+
+func (p *resilientProvider) Health() LLMHealth {
+	health := p.breaker.snapshot()
+	health.Provider = p.inner.Name()
+	health.InFlight = len(p.slots)
+	health.MaxConcurrency = cap(p.slots)
+	return health
+}
+
+func (p *resilientProvider) call(ctx context.Context, timeout time.Duration, canRetry func() bool, attempt func(context.Context) error) error {
+	if err := p.acquire(ctx, timeout); err != nil {
+		return err
+	}
+	if !p.breaker.allow() {
+		p.release()
+		return ErrLLMUnavailable
+	}
+
+	for try := 0; ; try++ {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := attempt(callCtx)
+		cancel()
+		// 재시도를 기다리는 동안에는 자리를 비워 다른 요청이 쓰게 한다
+		p.release()
+
+		if err == nil {
+			p.breaker.success()
+			return nil
+		}
+		// 호출한 쪽이 끊은 건 업스트림 장애가 아니다
+		if ctx.Err() != nil {
+			p.breaker.abort()
+			return ctx.Err()
+		}
+		retryable := isRetryableLLMError(err)
+		if try >= p.options.MaxRetries || !retryable || (canRetry != nil && !canRetry()) {
+			// 4xx 같은 요청 쪽 오류는 업스트림이 살아 있다는 뜻이므로 실패로 세지 않는다
+			if retryable {
+				p.breaker.failure()
+			} else {
+				p.breaker.abort()
+			}
+			return err
+		}
+
+		select {
+		case <-time.After(p.backoff(try, err)):
+		case <-ctx.Done():
+			p.breaker.abort()
+			return ctx.Err()
+		}
+		if err := p.acquire(ctx, timeout); err != nil {
+			p.breaker.abort()
+			return err
+		}
+	}
+}
+
+// 자리가 날 때까지 기다리되 제한 시간을 넘기면 포기한다
+func (p *resilientProvider) acquire(ctx context.Context, timeout time.Duration) error {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-waitCtx.Done():
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrLLMPoolExhausted
+	}
+}
+
+func (p *resilientProvider) release() {
+	<-p.slots
+}
+
+// 기본 대기 시간 × 2^try에 최대 50% 무작위 지연을 더한다. Retry-After가 더 길면 그만큼 기다린다
+func (p *resilientProvider) backoff(try int, err error) time.Duration {
+	wait := p.options.BaseBackoff << uint(try)
+	if wait > 0 {
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	}
+
+	var httpErr *LLMHTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > wait {
+		wait = httpErr.RetryAfter
+	}
+	if wait > maxLLMBackoff {
+		wait = maxLLMBackoff
+	}
+	return wait
+}
+
+// 429/5xx, 제한 시간 초과, 연결 오류를 일시적인 장애로 본다
+func isRetryableLLMError(err error) bool {
+	var httpErr *LLMHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == 429 || httpErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+// 연속 실패 횟수 기반 회로 차단기. 열린 뒤 cooldown이 지나면 요청 하나만 시험 삼아 보낸다
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == CircuitHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// 결과를 모른 채 끝난 시험 요청은 다음 요청이 다시 시험하게 한다
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) snapshot() LLMHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := LLMHealth{
+		Circuit:             b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == CircuitOpen {
+		retryAt := b.openedAt.Add(b.cooldown)
+		health.RetryAt = &retryAt
+	}
+	return health
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// OpenAI 호환 서버 흉내. respond가 돌려준 상태 코드로 응답하고 200이면 프롬프트를 그대로 돌려준다
+type scriptedLLMServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	calls   map[string]int
+	respond func(prompt string, call int) (status int, retryAfter string)
+}
+
+func newScriptedLLMServer(t *testing.T, respond func(prompt string, call int) (int, string)) *scriptedLLMServer {
+	t.Helper()
+	server := &scriptedLLMServer{calls: make(map[string]int), respond: respond}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[0].Content
+
+		server.mu.Lock()
+		server.calls[prompt]++
+		call := server.calls[prompt]
+		server.mu.Unlock()
+
+		status, retryAfter := server.respond(prompt, call)
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": prompt}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *scriptedLLMServer) callCount(prompt string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[prompt]
+}
+
+func (s *scriptedLLMServer) provider(options ResilienceOptions) *resilientProvider {
+	return newResilientProvider(newOpenAIProvider(s.URL, "test-key", LLMParams{}, s.Client()), options).(*resilientProvider)
+}
+
+func TestResilientProviderReleasesSlotDuringBackoff(t *testing.T) {
+	server := newScriptedLLMServer(t, func(prompt string, call int) (int, string) {
+		if prompt == "flaky" && call == 1 {
+			return http.StatusServiceUnavailable, ""
+		}
+		return http.StatusOK, ""
+	})
+	// 자리 기다리는 시간(200ms)보다 재시도 대기(300ms 이상)가 길다. 대기 중에 자리를 쥐고 있으면 healthy는 자리를 얻지 못한다
+	provider := server.provider(ResilienceOptions{
+		Timeout:          200 * time.Millisecond,
+		MaxRetries:       1,
+		BaseBackoff:      300 * time.Millisecond,
+		MaxConcurrency:   1,
+		FailureThreshold: 5,
+		Cooldown:         time.Minute,
+	})
+
+	flakyDone := make(chan error, 1)
+	go func() {
+		_, err := provider.Generate(context.Background(), "flaky")
+		flakyDone <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for server.callCount("flaky") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("flaky call never reached the server")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 503을 받고 재시도를 기다리는 중
+	time.Sleep(50 * time.Millisecond)
+	if inFlight := provider.Health().InFlight; inFlight != 0 {
+		t.Errorf("in flight while backing off = %d, want 0", inFlight)
+	}
+
+	if text, err := provider.Generate(context.Background(), "healthy"); err != nil || text != "healthy" {
+		t.Fatalf("healthy call during backoff: (%q, %v)", text, err)
+	}
+	select {
+	case err := <-flakyDone:
+		t.Fatalf("flaky call finished before the healthy one: %v", err)
+	default:
+	}
+	if err := <-flakyDone; err != nil {
+		t.Fatalf("flaky call after retry: %v", err)
+	}
+	if calls := server.callCount("flaky"); calls != 2 {
+		t.Errorf("flaky calls = %d, want 2", calls)
+	}
+	if inFlight := provider.Health().InFlight; inFlight != 0 {
+		t.Errorf("in flight after both calls = %d, want 0", inFlight)
+	}
+}
+
+func TestResilientProviderCircuitOverHTTP(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusBadRequest
+	server := newScriptedLLMServer(t, func(prompt string, call int) (int, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		return status, ""
+	})
+	setStatus := func(code int) {
+		mu.Lock()
+		status = code
+		mu.Unlock()
+	}
+	provider := server.provider(ResilienceOptions{
+		Timeout:          time.Second,
+		MaxRetries:       1,
+		MaxConcurrency:   2,
+		FailureThreshold: 2,
+		Cooldown:         50 * time.Millisecond,
+	})
+
+	// 요청이 잘못된 것이라 재시도하지 않고, 업스트림 장애로 세지도 않는다
+	for i := 0; i < 3; i++ {
+		var httpErr *LLMHTTPError
+		if _, err := provider.Generate(context.Background(), "bad"); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("bad request: got %v", err)
+		}
+	}
+	if calls, health := server.callCount("bad"), provider.Health(); calls != 3 || health.Circuit != CircuitClosed || health.ConsecutiveFailures != 0 {
+		t.Fatalf("after 400s: calls %d, health %+v", calls, health)
+	}
+
+	// 503은 한 번 재시도한 뒤 실패로 센다. 두 번 쌓이면 회로가 열려 세 번째는 보내지 않는다
+	setStatus(http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		provider.Generate(context.Background(), "down")
+	}
+	if calls, health := server.callCount("down"), provider.Health(); calls != 4 || health.Circuit != CircuitOpen || health.RetryAt == nil {
+		t.Fatalf("after 503s: calls %d, health %+v", calls, health)
+	}
+	if _, err := provider.Generate(context.Background(), "down"); !errors.Is(err, ErrLLMUnavailable) {
+		t.Fatalf("open circuit: got %v, want ErrLLMUnavailable", err)
+	}
+
+	// cooldown이 지나면 시험 요청 하나가 나가고, 성공하면 닫힌다
+	setStatus(http.StatusOK)
+	time.Sleep(60 * time.Millisecond)
+	if text, err := provider.Generate(context.Background(), "probe"); err != nil || text != "probe" {
+		t.Fatalf("probe: (%q, %v)", text, err)
+	}
+	if health := provider.Health(); health.Circuit != CircuitClosed || health.ConsecutiveFailures != 0 {
+		t.Errorf("after probe: %+v", health)
+	}
+}
+
+func TestResilientProviderHonorsRetryAfter(t *testing.T) {
+	server := newScriptedLLMServer(t, func(prompt string, call int) (int, string) {
+		if call == 1 {
+			return http.StatusTooManyRequests, "1"
+		}
+		return http.StatusOK, ""
+	})
+	provider := server.provider(ResilienceOptions{
+		Timeout:          time.Second,
+		MaxRetries:       1,
+		BaseBackoff:      10 * time.Millisecond,
+		MaxConcurrency:   1,
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	})
+
+	start := time.Now()
+	text, err := provider.Generate(context.Background(), "limited")
+	if err != nil || text != "limited" {
+		t.Fatalf("got (%q, %v)", text, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestResilientProviderDoesNotRetryStartedStream(t *testing.T) {
+	calls := 0
+	inner := streamFunc(func(onDelta func(string) error) (string, error) {
+		calls++
+		onDelta("오늘은 ")
+		return "", &LLMHTTPError{StatusCode: http.StatusBadGateway}
+	})
+	provider := newResilientProvider(inner, ResilienceOptions{
+		StreamTimeout:    time.Second,
+		MaxRetries:       2,
+		MaxConcurrency:   1,
+		FailureThreshold: 5,
+		Cooldown:         time.Minute,
+	})
+
+	var sent strings.Builder
+	_, err := provider.GenerateStream(context.Background(), "prompt", func(delta string) error {
+		sent.WriteString(delta)
+		return nil
+	})
+	if err == nil || calls != 1 || sent.String() != "오늘은 " {
+		t.Errorf("got err %v after %d calls, sent %q; want one call and no duplicated output", err, calls, sent.String())
+	}
+}
+
+// 스트리밍만 쓰는 백엔드
+type streamFunc func(onDelta func(string) error) (string, error)
+
+func (f streamFunc) Name() string { return "stream" }
+
+func (f streamFunc) Generate(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("not used")
+}
+
+func (f streamFunc) GenerateStructured(ctx context.Context, prompt string, schema ResponseSchema) (string, error) {
+	return "", errors.New("not used")
+}
+
+func (f streamFunc) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string) error) (string, error) {
+	return f(onDelta)
+}
+
+func TestResilientProviderCountsConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	provider := newResilientProvider(newOpenAIProvider(url, "test-key", LLMParams{}, http.DefaultClient), ResilienceOptions{
+		Timeout:          time.Second,
+		MaxRetries:       1,
+		BaseBackoff:      time.Millisecond,
+		MaxConcurrency:   1,
+		FailureThreshold: 5,
+		Cooldown:         time.Minute,
+	}).(*resilientProvider)
+
+	if _, err := provider.Generate(context.Background(), "prompt"); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	// 연결 거부는 업스트림 장애이므로 재시도한 뒤 실패로 센다
+	if failures := provider.Health().ConsecutiveFailures; failures != 1 {
+		t.Errorf("consecutive failures = %d, want 1", failures)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+// 요청마다 저장된 매칭 선호를 덮어쓰는 조건. 값이 없는 항목은 저장된 선호를 따른다
+type MatchQuery struct {
+	Genders      []string // nil이면 저장된 선호, 빈 슬라이스면 성별 제한 없음
+	MinAge       *int     // 0이면 제한 없음
+	MaxAge       *int
+	RelationType string
+}
+
+type MatchPreferenceInput struct {
+	TargetGenders []string
+	MinAge        int
+	MaxAge        int
+	Intent        string
+}
+
+// 저장된 선호 위에 요청 조건을 덮어써 후보 조건과 관계 유형을 정한다
+func resolveMatchQuery(preference *models.MatchPreference, query MatchQuery) (MatchFilter, string) {
+	var filter MatchFilter
+	relationType := utils.RelationRomantic
+
+	if preference != nil {
+		filter.Genders = preference.TargetGenders
+		filter.MinAge = preference.MinAge
+		filter.MaxAge = preference.MaxAge
+		if utils.IsValidRelationType(preference.Intent) {
+			relationType = preference.Intent
+		}
+	}
+
+	if query.Genders != nil {
+		filter.Genders = query.Genders
+	}
+	if query.MinAge != nil {
+		filter.MinAge = *query.MinAge
+	}
+	if query.MaxAge != nil {
+		filter.MaxAge = *query.MaxAge
+	}
+	if query.RelationType != "" {
+		relationType = query.RelationType
+	}
+
+	return filter, relationType
+}
+
+func loadMatchFilter(matchPreferenceRepo repository.MatchPreferenceRepository, userID uint, query MatchQuery) (MatchFilter, string, error) {
+	if query.RelationType != "" && !utils.IsValidRelationType(query.RelationType) {
+		return MatchFilter{}, "", errors.New("invalid relation type")
+	}
+
+	preference, err := matchPreferenceRepo.FindByUserID(userID)
+	if err != nil {
+		return MatchFilter{}, "", err
+	}
+
+	filter, relationType := resolveMatchQuery(preference, query)
+	return filter, relationType, nil
+}
+
+// 저장된 선호가 없으면 기본값(제한 없음, romantic)을 반환한다
+func (s *compatibilityService) GetMatchPreference(userID uint) (*models.MatchPreference, error) {
+	preference, err := s.matchPreferenceRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if preference == nil {
+		preference = &models.MatchPreference{
+			UserID:        userID,
+			TargetGenders: []string{},
+			Intent:        utils.RelationRomantic,
+		}
+	}
+	return preference, nil
+}
+
+func (s *compatibilityService) UpdateMatchPreference(userID uint, input MatchPreferenceInput) (*models.MatchPreference, error) {
+	for _, gender := range input.TargetGenders {
+		if gender != "M" && gender != "F" {
+			return nil, errors.New("invalid gender")
+		}
+	}
+	if input.MinAge < 0 || input.MaxAge < 0 {
+		return nil, errors.New("invalid age range")
+	}
+	if input.MinAge > 0 && input.MaxAge > 0 && input.MinAge > input.MaxAge {
+		return nil, errors.New("min_age must not exceed max_age")
+	}
+	if input.Intent == "" {
+		input.Intent = utils.RelationRomantic
+	}
+	if !utils.IsValidRelationType(input.Intent) {
+		return nil, errors.New("invalid relation type")
+	}
+
+	preference, err := s.matchPreferenceRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if preference == nil {
+		preference = &models.MatchPreference{UserID: userID}
+	}
+
+	preference.TargetGenders = input.TargetGenders
+	if preference.TargetGenders == nil {
+		preference.TargetGenders = []string{}
+	}
+	preference.MinAge = input.MinAge
+	preference.MaxAge = input.MaxAge
+	preference.Intent = input.Intent
+
+	if err := s.matchPreferenceRepo.Save(preference); err != nil {
+		return nil, err
+	}
+	return preference, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestResolveMatchQuery(t *testing.T) {
+	stored := &models.MatchPreference{TargetGenders: []string{"F"}, MinAge: 25, MaxAge: 35, Intent: utils.RelationFriend}
+
+	tests := []struct {
+		name         string
+		preference   *models.MatchPreference
+		query        MatchQuery
+		wantFilter   MatchFilter
+		wantRelation string
+	}{
+		{"no preference", nil, MatchQuery{}, MatchFilter{}, utils.RelationRomantic},
+		{"stored preference", stored, MatchQuery{}, MatchFilter{Genders: []string{"F"}, MinAge: 25, MaxAge: 35}, utils.RelationFriend},
+		{"query overrides", stored, MatchQuery{Genders: []string{"M"}, MaxAge: intPtr(40), RelationType: utils.RelationBusiness}, MatchFilter{Genders: []string{"M"}, MinAge: 25, MaxAge: 40}, utils.RelationBusiness},
+		// 빈 목록과 0은 저장된 조건을 지운다
+		{"query clears", stored, MatchQuery{Genders: []string{}, MinAge: intPtr(0)}, MatchFilter{Genders: []string{}, MaxAge: 35}, utils.RelationFriend},
+		{"unknown stored intent", &models.MatchPreference{Intent: "coworker"}, MatchQuery{}, MatchFilter{}, utils.RelationRomantic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, relationType := resolveMatchQuery(tt.preference, tt.query)
+			if !reflect.DeepEqual(filter, tt.wantFilter) || relationType != tt.wantRelation {
+				t.Errorf("got (%+v, %s), want (%+v, %s)", filter, relationType, tt.wantFilter, tt.wantRelation)
+			}
+		})
+	}
+}
+
+func TestMatchFilterCandidateFilter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	got := MatchFilter{Genders: []string{"F"}, MinAge: 25, MaxAge: 35}.candidateFilter(now)
+	want := repository.MatchCandidateFilter{Genders: []string{"F"}, MinBirthYear: 1989, MaxBirthYear: 1999}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := (MatchFilter{}).candidateFilter(now); got.MinBirthYear != 0 || got.MaxBirthYear != 0 {
+		t.Errorf("no age range should not limit birth years: %+v", got)
+	}
+}
+
+func TestUpdateMatchPreference(t *testing.T) {
+	preferences := &memoryPreferences{preferences: map[uint]models.MatchPreference{}}
+	service := NewCompatibilityService(nil, nil, nil, nil, nil, preferences, nil, nil, nil, &config.Config{})
+
+	invalid := []struct {
+		input MatchPreferenceInput
+		want  string
+	}{
+		{MatchPreferenceInput{TargetGenders: []string{"X"}}, "invalid gender"},
+		{MatchPreferenceInput{MinAge: -1}, "invalid age range"},
+		{MatchPreferenceInput{MinAge: 40, MaxAge: 30}, "min_age must not exceed max_age"},
+		{MatchPreferenceInput{Intent: "coworker"}, "invalid relation type"},
+	}
+	for _, tt := range invalid {
+		if _, err := service.UpdateMatchPreference(1, tt.input); err == nil || err.Error() != tt.want {
+			t.Errorf("UpdateMatchPreference(%+v) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+	if len(preferences.preferences) != 0 {
+		t.Fatalf("invalid input was saved: %+v", preferences.preferences)
+	}
+
+	defaults, _ := service.GetMatchPreference(1)
+	if defaults.Intent != utils.RelationRomantic || defaults.TargetGenders == nil {
+		t.Errorf("default preference = %+v", defaults)
+	}
+
+	saved, err := service.UpdateMatchPreference(1, MatchPreferenceInput{MinAge: 20, MaxAge: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Intent != utils.RelationRomantic || saved.TargetGenders == nil || len(saved.TargetGenders) != 0 {
+		t.Errorf("saved preference = %+v, want romantic with no gender limit", saved)
+	}
+	if stored := preferences.preferences[1]; stored.MinAge != 20 || stored.MaxAge != 30 {
+		t.Errorf("stored preference = %+v", stored)
+	}
+}
+
+func TestSimilarUserMatchesApplyPreferences(t *testing.T) {
+	f := newCompatibilityFixture()
+	me := f.addUser("M", 1990, 5, 15, 14)
+	var candidates []models.User
+	for _, birth := range [][4]int{{1992, 11, 3, 8}, {1995, 8, 21, 18}, {1988, 2, 20, 3}} {
+		id := f.addUser("F", birth[0], birth[1], birth[2], birth[3])
+		user := f.users.users[id]
+		user.FortuneInfo, _ = f.fortunes.FindByUserID(id)
+		candidates = append(candidates, user)
+	}
+	fortunes := &rankedFortunes{chartStore: f.fortunes, candidates: candidates}
+	preferences := &memoryPreferences{preferences: map[uint]models.MatchPreference{
+		me: {UserID: me, TargetGenders: []string{"F"}, MinAge: 20, MaxAge: 40, Intent: utils.RelationFriend},
+	}}
+	service := NewFortuneService(fortunes, f.users, nil, nil, preferences, nil, nil, nil, &config.Config{})
+
+	_, best, worst, err := service.GetSimilarUserMatches(me, MatchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	year := time.Now().Year()
+	if want := (repository.MatchCandidateFilter{Genders: []string{"F"}, MinBirthYear: year - 40, MaxBirthYear: year - 20}); !reflect.DeepEqual(fortunes.lastFilter, want) {
+		t.Errorf("candidate filter = %+v, want %+v", fortunes.lastFilter, want)
+	}
+
+	// 잘 맞는 상대는 저장된 관계 유형(친구)의 점수로 고른다
+	myChart, _ := f.fortunes.FindByUserID(me)
+	var wantBest uint
+	bestScore := -1.0
+	for _, candidate := range candidates {
+		score := utils.CalculateRelationCompatibilityScore(fortuneInfoToMap(myChart), fortuneInfoToMap(candidate.FortuneInfo), utils.RelationFriend, "M", "F").Score
+		if score > bestScore {
+			wantBest, bestScore = candidate.ID, score
+		}
+	}
+	if best == nil || best.User.ID != wantBest || best.Score != bestScore {
+		t.Errorf("best match = %+v, want user %d with %v", best, wantBest, bestScore)
+	}
+	if worst == nil || worst.Type != "worst_match" {
+		t.Errorf("worst match = %+v", worst)
+	}
+
+	// 요청 조건이 저장된 선호를 덮어쓴다
+	service.GetSimilarUserMatches(me, MatchQuery{Genders: []string{}, MaxAge: intPtr(0)})
+	if want := (repository.MatchCandidateFilter{Genders: []string{}, MinBirthYear: 0, MaxBirthYear: year - 20}); !reflect.DeepEqual(fortunes.lastFilter, want) {
+		t.Errorf("overridden filter = %+v, want %+v", fortunes.lastFilter, want)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// 프롬프트 용도. 파일은 {PROMPTS_DIR}/{용도}/{로케일}/{버전}.tmpl에 둔다
+const (
+	PromptFortuneCategory        = "fortune_category"
+	PromptDailyFortune           = "daily_fortune"
+	PromptDailyFortuneStream     = "daily_fortune_stream"
+	PromptCompatibilityNarrative = "compatibility_narrative"
+	PromptCompatibilityAnalysis  = "compatibility_analysis"
+	PromptChatCounselor          = "chat_counselor"
+	PromptChatSummary            = "chat_summary"
+	PromptSpouseImage            = "spouse_image"
+)
+
+// manifest.json에 용도별 사용할 버전을 적는다. 적지 않은 용도는 버전 번호가 가장 큰 버전을 쓴다 (v9 < v10)
+const promptManifestFile = "manifest.json"
+
+type PromptStore interface {
+	// 템플릿을 채운 프롬프트와 사용한 버전("용도/로케일/버전")을 반환한다. 로케일에 템플릿이 없으면 기본 로케일을 쓴다
+	Render(useCase, locale string, data interface{}) (string, string, error)
+	ActiveVersion(useCase, locale string) string
+	Reload() error
+	Catalog() []PromptInfo
+}
+
+type PromptInfo struct {
+	UseCase  string   `json:"use_case"`
+	Locale   string   `json:"locale"`
+	Active   string   `json:"active"`
+	Versions []string `json:"versions"`
+}
+
+type promptSet struct {
+	templates map[string]map[string]map[string]*template.Template // 용도 → 로케일 → 버전
+	active    map[string]string
+}
+
+type promptStore struct {
+	dir           string
+	defaultLocale string
+
+	mu  sync.RWMutex
+	set *promptSet
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+func NewPromptStore(dir, defaultLocale string) (PromptStore, error) {
+	store := &promptStore{dir: dir, defaultLocale: defaultLocale}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// 전부 읽고 파싱에 성공했을 때만 바꾼다 (하나라도 실패하면 기존 템플릿을 유지한다)
+func (s *promptStore) Reload() error {
+	set := &promptSet{
+		templates: make(map[string]map[string]map[string]*template.Template),
+		active:    make(map[string]string),
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*", "*", "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no prompt templates found in %s", s.dir)
+	}
+
+	for _, file := range files {
+		rel, err := filepath.Rel(s.dir, file)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		useCase, locale, version := parts[0], parts[1], strings.TrimSuffix(parts[2], ".tmpl")
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(rel).Funcs(promptFuncs).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("invalid prompt template %s: %w", rel, err)
+		}
+
+		if set.templates[useCase] == nil {
+			set.templates[useCase] = make(map[string]map[string]*template.Template)
+		}
+		if set.templates[useCase][locale] == nil {
+			set.templates[useCase][locale] = make(map[string]*template.Template)
+		}
+		set.templates[useCase][locale][version] = tmpl
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(s.dir, promptManifestFile))
+	if err == nil {
+		if err := json.Unmarshal(manifest, &set.active); err != nil {
+			return fmt.Errorf("invalid prompt manifest: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	s.mu.Lock()
+	s.set = set
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *promptStore) Render(useCase, locale string, data interface{}) (string, string, error) {
+	s.mu.RLock()
+	set := s.set
+	s.mu.RUnlock()
+
+	locale, version, tmpl := s.resolve(set, useCase, locale)
+	if tmpl == nil {
+		return "", "", fmt.Errorf("prompt template not found: %s/%s", useCase, locale)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(buf.String()), promptVersionName(useCase, locale, version), nil
+}
+
+func (s *promptStore) ActiveVersion(useCase, locale string) string {
+	s.mu.RLock()
+	set := s.set
+	s.mu.RUnlock()
+
+	locale, version, tmpl := s.resolve(set, useCase, locale)
+	if tmpl == nil {
+		return ""
+	}
+	return promptVersionName(useCase, locale, version)
+}
+
+func (s *promptStore) Catalog() []PromptInfo {
+	s.mu.RLock()
+	set := s.set
+	s.mu.RUnlock()
+
+	var catalog []PromptInfo
+	for useCase, locales := range set.templates {
+		for locale, versions := range locales {
+			info := PromptInfo{UseCase: useCase, Locale: locale}
+			for version := range versions {
+				info.Versions = append(info.Versions, version)
+			}
+			sortPromptVersions(info.Versions)
+			_, info.Active, _ = s.resolve(set, useCase, locale)
+			catalog = append(catalog, info)
+		}
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].UseCase != catalog[j].UseCase {
+			return catalog[i].UseCase < catalog[j].UseCase
+		}
+		return catalog[i].Locale < catalog[j].Locale
+	})
+	return catalog
+}
+
+// 요청 로케일 → 기본 로케일 순으로 찾고, manifest의 버전이 없으면 가장 마지막 버전을 쓴다
+func (s *promptStore) resolve(set *promptSet, useCase, locale string) (string, string, *template.Template) {
+	locales := set.templates[useCase]
+	versions, ok := locales[locale]
+	if !ok {
+		locale = s.defaultLocale
+		versions = locales[locale]
+	}
+	if len(versions) == 0 {
+		return locale, "", nil
+	}
+
+	if version, ok := set.active[useCase]; ok {
+		if tmpl, ok := versions[version]; ok {
+			return locale, version, tmpl
+		}
+	}
+
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sortPromptVersions(names)
+	latest := names[len(names)-1]
+	return locale, latest, versions[latest]
+}
+
+// 끝의 숫자를 수로 비교해 v9가 v10보다 앞에 오게 한다. 숫자 앞부분이 다르면 그 문자열 순서를 따른다
+func sortPromptVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		prefixI, numberI := splitPromptVersion(versions[i])
+		prefixJ, numberJ := splitPromptVersion(versions[j])
+		if prefixI != prefixJ {
+			return prefixI < prefixJ
+		}
+		if numberI != numberJ {
+			return numberI < numberJ
+		}
+		return versions[i] < versions[j]
+	})
+}
+
+// "v10" → ("v", 10). 끝에 숫자가 없으면 -1
+func splitPromptVersion(version string) (string, int) {
+	end := len(version)
+	for end > 0 && version[end-1] >= '0' && version[end-1] <= '9' {
+		end--
+	}
+	number, err := strconv.Atoi(version[end:])
+	if err != nil {
+		return version, -1
+	}
+	return version[:end], number
+}
+
+func promptVersionName(useCase, locale, version string) string {
+	return useCase + "/" + locale + "/" + version
+}
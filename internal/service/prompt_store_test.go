@@ -0,0 +1,138 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePromptFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPromptStoreResolvesVersion(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFiles(t, dir, map[string]string{
+		"daily_fortune/ko/v2.tmpl":  "two",
+		"daily_fortune/ko/v9.tmpl":  "nine",
+		"daily_fortune/ko/v10.tmpl": "ten {{.Name}}",
+		"daily_fortune/en/v1.tmpl":  "english {{.Name}}",
+		"chat_summary/ko/v1.tmpl":   "one",
+		"chat_summary/ko/v2.tmpl":   "two",
+		promptManifestFile:          `{"chat_summary": "v1"}`,
+	})
+	store, err := NewPromptStore(dir, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		useCase     string
+		locale      string
+		wantText    string
+		wantVersion string
+	}{
+		// 문자열 순서로는 v9가 마지막이다
+		{"latest by number", PromptDailyFortune, "ko", "ten 갑목", "daily_fortune/ko/v10"},
+		{"locale template", PromptDailyFortune, "en", "english 갑목", "daily_fortune/en/v1"},
+		{"default locale fallback", PromptDailyFortune, "ja", "ten 갑목", "daily_fortune/ko/v10"},
+		{"manifest pin", PromptChatSummary, "ko", "one", "chat_summary/ko/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, version, err := store.Render(tt.useCase, tt.locale, map[string]string{"Name": "갑목"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.wantText || version != tt.wantVersion {
+				t.Errorf("got (%q, %q), want (%q, %q)", text, version, tt.wantText, tt.wantVersion)
+			}
+			if active := store.ActiveVersion(tt.useCase, tt.locale); active != tt.wantVersion {
+				t.Errorf("ActiveVersion = %q, want %q", active, tt.wantVersion)
+			}
+		})
+	}
+
+	if _, _, err := store.Render(PromptDailyFortune, "ko", map[string]string{}); err == nil {
+		t.Error("missing template field should fail instead of rendering <no value>")
+	}
+	if _, _, err := store.Render(PromptSpouseImage, "ko", nil); err == nil {
+		t.Error("unknown use case should fail")
+	}
+
+	for _, info := range store.Catalog() {
+		if info.UseCase == PromptDailyFortune && info.Locale == "ko" {
+			if got := strings.Join(info.Versions, ","); got != "v2,v9,v10" || info.Active != "v10" {
+				t.Errorf("catalog versions %s, active %s", got, info.Active)
+			}
+		}
+	}
+}
+
+func TestPromptStoreReloadKeepsTemplatesOnError(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFiles(t, dir, map[string]string{"chat_summary/ko/v1.tmpl": "one"})
+	store, err := NewPromptStore(dir, "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 새 버전 하나라도 파싱에 실패하면 전부 이전 템플릿을 쓴다
+	writePromptFiles(t, dir, map[string]string{
+		"chat_summary/ko/v2.tmpl": "two",
+		"chat_summary/en/v1.tmpl": "{{.Broken",
+	})
+	if err := store.Reload(); err == nil {
+		t.Fatal("reload with a broken template should fail")
+	}
+	if text, version, _ := store.Render(PromptChatSummary, "ko", nil); text != "one" || version != "chat_summary/ko/v1" {
+		t.Errorf("after failed reload: (%q, %q)", text, version)
+	}
+
+	writePromptFiles(t, dir, map[string]string{"chat_summary/en/v1.tmpl": "fixed"})
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if text, version, _ := store.Render(PromptChatSummary, "ko", nil); text != "two" || version != "chat_summary/ko/v2" {
+		t.Errorf("after reload: (%q, %q)", text, version)
+	}
+}
+
+func TestShippedFortunePromptsRender(t *testing.T) {
+	store, err := NewPromptStore(filepath.Join("..", "..", "prompts"), "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart := map[string]string{
+		"year_stem": "庚", "year_branch": "午",
+		"month_stem": "辛", "month_branch": "巳",
+		"day_stem": "甲", "day_branch": "子",
+		"hour_stem": "丙", "hour_branch": "寅",
+	}
+
+	for _, useCase := range []string{PromptFortuneCategory, PromptDailyFortune, PromptDailyFortuneStream} {
+		for _, locale := range []string{"ko", "en", "ja"} {
+			t.Run(useCase+"/"+locale, func(t *testing.T) {
+				// 템플릿이 fortunePromptData에 없는 필드를 쓰면 실행이 실패한다
+				text, version, err := store.Render(useCase, locale, newFortunePromptData(chart, "丙", "午", "love", locale))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(version, useCase+"/"+locale+"/") || !strings.Contains(text, "甲") {
+					t.Errorf("version %q, prompt %q", version, text)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"dothefortune_server/internal/config"
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+	"dothefortune_server/internal/utils"
+)
+
+type SpouseImageService interface {
+	// 이미 진행 중인 작업이 있으면 그 작업을 돌려주고, 없으면 AI 사용량 한도를 확인한 뒤 새 작업을 만들어 작업 큐에 넣는다
+	RequestSpouseImage(userID uint, locale string) (*models.SpouseImageJob, error)
+	GetSpouseImageJob(userID, jobID uint) (*models.SpouseImageJob, error)
+	// 작업 큐 워커가 호출한다. lastAttempt가 아니면 실패해도 pending으로 두어 재시도를 기다린다. queueJobID는 ai_spouse 기록을 한 번만 남기는 데 쓴다
+	ProcessSpouseImageJob(ctx context.Context, queueJobID, userID, jobID uint, lastAttempt bool) error
+}
+
+const (
+	SpouseImageJobPending   = "pending"
+	SpouseImageJobRunning   = "running"
+	SpouseImageJobCompleted = "completed"
+	SpouseImageJobFailed    = "failed"
+)
+
+// 이미지 모델은 영어 프롬프트를 가장 잘 이해하므로 사용자 로케일과 관계없이 영어 템플릿을 쓴다
+const spouseImagePromptLocale = "en"
+
+type spouseImageService struct {
+	jobRepo       repository.SpouseImageJobRepository
+	userRepo      repository.UserRepository
+	fortuneRepo   repository.FortuneRepository
+	recordRepo    repository.RecordRepository
+	promptStore   PromptStore
+	imageProvider ImageProvider
+	storage       FileStorage
+	jobQueue      JobQueue
+	aiUsage       AIUsageService
+	imageTimeout  time.Duration
+}
+
+func NewSpouseImageService(jobRepo repository.SpouseImageJobRepository, userRepo repository.UserRepository, fortuneRepo repository.FortuneRepository, recordRepo repository.RecordRepository, promptStore PromptStore, imageProvider ImageProvider, storage FileStorage, jobQueue JobQueue, aiUsage AIUsageService, cfg *config.Config) SpouseImageService {
+	return &spouseImageService{
+		jobRepo:       jobRepo,
+		userRepo:      userRepo,
+		fortuneRepo:   fortuneRepo,
+		recordRepo:    recordRepo,
+		promptStore:   promptStore,
+		imageProvider: imageProvider,
+		storage:       storage,
+		jobQueue:      jobQueue,
+		aiUsage:       aiUsage,
+		imageTimeout:  time.Duration(cfg.ImageTimeoutSeconds) * time.Second,
+	}
+}
+
+func (s *spouseImageService) RequestSpouseImage(userID uint, locale string) (*models.SpouseImageJob, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.FortuneInfo == nil {
+		return nil, errors.New("fortune info not found")
+	}
+
+	active, err := s.jobRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, nil
+	}
+	if err := s.aiUsage.CheckQuota(userID); err != nil {
+		return nil, err
+	}
+
+	traits := DeriveSpouseTraits(fortuneInfoToMap(user.FortuneInfo), user.Gender, locale)
+	traitsJSON, err := json.Marshal(traits)
+	if err != nil {
+		return nil, err
+	}
+	prompt, version, err := s.promptStore.Render(PromptSpouseImage, spouseImagePromptLocale, newSpouseImagePromptData(traits))
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.SpouseImageJob{
+		UserID:        userID,
+		Status:        SpouseImageJobPending,
+		Traits:        string(traitsJSON),
+		Prompt:        prompt,
+		PromptVersion: version,
+		Provider:      s.imageProvider.Name(),
+	}
+	if err := s.jobRepo.Create(job); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.jobQueue.Enqueue(JobTypeSpouseImage, SpouseImagePayload{UserID: userID, SpouseImageJobID: job.ID}); err != nil {
+		s.finish(job, SpouseImageJobFailed, "image generation failed")
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *spouseImageService) GetSpouseImageJob(userID, jobID uint) (*models.SpouseImageJob, error) {
+	job, err := s.jobRepo.FindByID(userID, jobID)
+	if err != nil {
+		return nil, errors.New("spouse image job not found")
+	}
+	return job, nil
+}
+
+func (s *spouseImageService) ProcessSpouseImageJob(ctx context.Context, queueJobID, userID, jobID uint, lastAttempt bool) error {
+	job, err := s.jobRepo.FindByID(userID, jobID)
+	if err != nil {
+		return PermanentJobError(err)
+	}
+	// failed 작업은 관리자가 큐 작업을 다시 실행한 경우이므로 다시 생성한다
+	if job.Status == SpouseImageJobCompleted {
+		return nil
+	}
+	job.Error = ""
+
+	job.Status = SpouseImageJobRunning
+	if err := s.jobRepo.Update(job); err != nil {
+		return err
+	}
+
+	var traits SpouseTraits
+	if err := json.Unmarshal([]byte(job.Traits), &traits); err != nil {
+		s.finish(job, SpouseImageJobFailed, "image generation failed")
+		return PermanentJobError(err)
+	}
+
+	// 실패 원인은 작업 큐의 last_error와 로그에만 자세히 남긴다
+	if err := s.generate(WithAIUsage(ctx, userID, AIFeatureSpouseImage), queueJobID, job, traits); err != nil {
+		if lastAttempt || isPermanentJobError(err) {
+			s.finish(job, SpouseImageJobFailed, "image generation failed")
+		} else {
+			s.finish(job, SpouseImageJobPending, "")
+		}
+		return err
+	}
+
+	s.finish(job, SpouseImageJobCompleted, "")
+	return nil
+}
+
+func (s *spouseImageService) finish(job *models.SpouseImageJob, status, errMessage string) {
+	job.Status = status
+	job.Error = errMessage
+	if status == SpouseImageJobCompleted || status == SpouseImageJobFailed {
+		now := time.Now()
+		job.CompletedAt = &now
+	}
+	if err := s.jobRepo.Update(job); err != nil {
+		log.Printf("Failed to update spouse image job %d: %v", job.ID, err)
+	}
+}
+
+// 이미지를 생성해 저장하고 사주 정보의 URL과 ai_spouse 기록을 남긴다
+func (s *spouseImageService) generate(ctx context.Context, queueJobID uint, job *models.SpouseImageJob, traits SpouseTraits) error {
+	imageCtx, cancel := context.WithTimeout(ctx, s.imageTimeout)
+	defer cancel()
+
+	image, err := s.imageProvider.GenerateImage(imageCtx, job.Prompt)
+	if err != nil {
+		// 429와 5xx 말고는 (정책 위반 등) 다시 보내도 같은 결과다
+		var httpErr *LLMHTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode != http.StatusTooManyRequests && httpErr.StatusCode < 500 {
+			return PermanentJobError(err)
+		}
+		return err
+	}
+
+	key, err := newSpouseImageKey()
+	if err != nil {
+		return err
+	}
+	imageURL, err := s.storage.Save(ctx, key, image.Data, image.ContentType)
+	if err != nil {
+		return err
+	}
+	if err := s.fortuneRepo.UpdateSpouseImageURL(job.UserID, imageURL); err != nil {
+		return err
+	}
+
+	record := &models.FortuneRecord{
+		UserID:   job.UserID,
+		Type:     "ai_spouse",
+		Content:  traits.Description,
+		ImageURL: imageURL,
+		Metadata: fmt.Sprintf(`{"job_id": %d, "traits": %s, "prompt_version": "%s", "provider": "%s"}`,
+			job.ID, job.Traits, job.PromptVersion, job.Provider),
+		JobID: &queueJobID,
+	}
+	if err := s.recordRepo.CreateForJob(record); err != nil {
+		return err
+	}
+	// 기록을 남긴 뒤 재시도된 작업이다. 새 기록 대신 이전 기록을 방금 저장한 이미지로 바꾼다
+	if record.ID == 0 {
+		existing, err := s.recordRepo.FindByJobID(queueJobID)
+		if err != nil {
+			return err
+		}
+		existing.Content = record.Content
+		existing.ImageURL = record.ImageURL
+		existing.Metadata = record.Metadata
+		if err := s.recordRepo.Update(existing); err != nil {
+			return err
+		}
+		record = existing
+	}
+
+	job.ImageURL = imageURL
+	job.RecordID = record.ID
+	return nil
+}
+
+// 사주에서 뽑은 배우자 특징. 배우자궁(일지)은 외모와 분위기를, 배우자성(남: 재성, 여: 관성)은 성격을 정한다
+type SpouseTraits struct {
+	SpouseGender  string   `json:"spouse_gender" example:"F"`
+	PalaceBranch  string   `json:"palace_branch" example:"午"`
+	PalaceElement string   `json:"palace_element" example:"火"`
+	StarName      string   `json:"star_name" example:"재성"`
+	StarElement   string   `json:"star_element" example:"土"`
+	StarCount     int      `json:"star_count" example:"2"`
+	Keywords      []string `json:"keywords"`
+	Description   string   `json:"description"`
+}
+
+// 이미지 프롬프트용 영어 묘사. 응답에 나가는 특징 문구는 메시지 카탈로그의 spouse.appearance.*, spouse.personality.*에 있다
+type elementImage struct {
+	AppearanceEn  string
+	PersonalityEn string
+	ColorsEn      string
+}
+
+var spouseElementImages = map[string]elementImage{
+	"木": {"tall and slender with gentle eyes", "warm, nurturing and growth-minded", "fresh greens and soft teal"},
+	"火": {"a bright expressive face with clear, defined features", "passionate, cheerful and expressive", "warm coral and sunset orange"},
+	"土": {"a soft rounded face with a calm, reassuring look", "dependable, steady and caring", "earthy beige and golden ochre"},
+	"金": {"clean, well-defined features and a neat style", "principled, composed and sincere", "pearl white and silver"},
+	"水": {"clear deep eyes and a soft, graceful impression", "wise, thoughtful and adaptable", "deep navy and misty blue"},
+}
+
+// 특징 문구(키워드, 배우자성 이름, 설명)는 locale로 쓴다
+func DeriveSpouseTraits(fortuneMap map[string]string, gender, locale string) SpouseTraits {
+	dayElement := utils.GetElement(fortuneMap["day_stem"])
+	traits := SpouseTraits{
+		PalaceBranch:  fortuneMap["day_branch"],
+		PalaceElement: utils.GetElement(fortuneMap["day_branch"]),
+	}
+
+	switch gender {
+	case "M":
+		traits.SpouseGender = "F"
+		traits.StarName = i18n.T(locale, "spouse.star.wealth")
+		traits.StarElement = utils.GetWealthElement(dayElement)
+	case "F":
+		traits.SpouseGender = "M"
+		traits.StarName = i18n.T(locale, "spouse.star.officer")
+		traits.StarElement = utils.GetOfficerElement(dayElement)
+	}
+	if traits.StarElement != "" {
+		traits.StarCount = utils.GetFiveElements(fortuneMap)[traits.StarElement]
+	}
+
+	// 배우자성이 사주에 드러나지 않으면 배우자궁 오행으로 성격까지 본다
+	personalityElement := traits.StarElement
+	if traits.StarCount == 0 {
+		personalityElement = traits.PalaceElement
+	}
+	traits.Keywords = nonEmpty(spouseKeyword(locale, "spouse.appearance.", traits.PalaceElement), spouseKeyword(locale, "spouse.personality.", personalityElement))
+
+	label := i18n.T(locale, "spouse.person")
+	if traits.SpouseGender != "" {
+		label = i18n.T(locale, "gender."+traits.SpouseGender)
+	}
+	basis := []string{i18n.T(locale, "spouse.basis.palace", traits.PalaceBranch, traits.PalaceElement)}
+	if traits.StarElement != "" {
+		basis = append(basis, i18n.T(locale, "spouse.basis.star", traits.StarName, traits.StarElement))
+	}
+	traits.Description = i18n.T(locale, "spouse.description",
+		strings.Join(basis, " · "), strings.Join(traits.Keywords, i18n.T(locale, "spouse.keyword_separator")), label)
+	return traits
+}
+
+// 오행을 모르면 빈 문자열
+func spouseKeyword(locale, prefix, element string) string {
+	if element == "" {
+		return ""
+	}
+	message, ok := i18n.Lookup(locale, prefix+element)
+	if !ok {
+		return ""
+	}
+	return message
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+type spouseImagePromptData struct {
+	SpouseGender string
+	Appearance   string
+	Personality  string
+	Colors       string
+}
+
+func newSpouseImagePromptData(traits SpouseTraits) spouseImagePromptData {
+	genders := map[string]string{"M": "man", "F": "woman"}
+	gender := genders[traits.SpouseGender]
+	if gender == "" {
+		gender = "person"
+	}
+
+	appearance := spouseElementImages[traits.PalaceElement]
+	personality := appearance
+	if traits.StarCount > 0 {
+		personality = spouseElementImages[traits.StarElement]
+	}
+	data := spouseImagePromptData{
+		SpouseGender: gender,
+		Appearance:   appearance.AppearanceEn,
+		Personality:  personality.PersonalityEn,
+		Colors:       appearance.ColorsEn,
+	}
+	// 일지를 알 수 없는 경우에도 템플릿이 비지 않게 한다
+	if data.Appearance == "" {
+		data.Appearance = "a kind, approachable face"
+		data.Personality = "warm and sincere"
+		data.Colors = "soft pastel tones"
+	}
+	return data
+}
+
+// 공개 URL로 서빙되므로 사용자 ID나 작업 ID처럼 순서대로 매길 수 있는 값 대신 추측할 수 없는 이름을 쓴다
+func newSpouseImageKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "spouse/" + hex.EncodeToString(buf) + ".png", nil
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"dothefortune_server/internal/i18n"
+	"dothefortune_server/internal/models"
+	"dothefortune_server/internal/repository"
+)
+
+type spouseJobStore struct {
+	jobs map[uint]*models.SpouseImageJob
+}
+
+func (r *spouseJobStore) Create(job *models.SpouseImageJob) error {
+	job.ID = uint(len(r.jobs) + 1)
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *spouseJobStore) Update(job *models.SpouseImageJob) error {
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *spouseJobStore) FindByID(userID, id uint) (*models.SpouseImageJob, error) {
+	job, ok := r.jobs[id]
+	if !ok || job.UserID != userID {
+		return nil, errors.New("record not found")
+	}
+	return job, nil
+}
+
+func (r *spouseJobStore) FindActiveByUserID(userID uint) (*models.SpouseImageJob, error) {
+	return nil, nil
+}
+
+// job_id 유니크 인덱스처럼 같은 작업의 두 번째 기록은 무시한다
+type jobRecordStore struct {
+	records []*models.FortuneRecord
+}
+
+func (r *jobRecordStore) Create(record *models.FortuneRecord) error {
+	record.ID = uint(len(r.records) + 1)
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *jobRecordStore) CreateForJob(record *models.FortuneRecord) error {
+	if _, err := r.FindByJobID(*record.JobID); err == nil {
+		return nil
+	}
+	return r.Create(record)
+}
+
+func (r *jobRecordStore) FindByJobID(jobID uint) (*models.FortuneRecord, error) {
+	for _, record := range r.records {
+		if record.JobID != nil && *record.JobID == jobID {
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *jobRecordStore) Update(record *models.FortuneRecord) error {
+	r.records[record.ID-1] = record
+	return nil
+}
+
+func (r *jobRecordStore) FindByID(userID, recordID uint) (*models.FortuneRecord, error) {
+	for _, record := range r.records {
+		if record.ID == recordID && record.UserID == userID {
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *jobRecordStore) FindByUserID(userID uint, limit int) ([]models.FortuneRecord, error) {
+	var records []models.FortuneRecord
+	for i := len(r.records) - 1; i >= 0 && len(records) < limit; i-- {
+		if r.records[i].UserID == userID {
+			records = append(records, *r.records[i])
+		}
+	}
+	return records, nil
+}
+
+func (r *jobRecordStore) FindByUserIDAndType(userID uint, recordType string, limit int) ([]models.FortuneRecord, error) {
+	return nil, errors.New("not used")
+}
+
+type spouseURLRecorder struct {
+	repository.FortuneRepository
+	urls []string
+}
+
+func (r *spouseURLRecorder) UpdateSpouseImageURL(userID uint, imageURL string) error {
+	r.urls = append(r.urls, imageURL)
+	return nil
+}
+
+type rejectingImageProvider struct{}
+
+func (rejectingImageProvider) Name() string { return "rejecting" }
+
+func (rejectingImageProvider) GenerateImage(ctx context.Context, prompt string) (*GeneratedImage, error) {
+	return nil, &LLMHTTPError{StatusCode: 400, Body: "content policy violation"}
+}
+
+func newSpouseImageTestService(t *testing.T, provider ImageProvider) (*spouseImageService, *spouseJobStore, *jobRecordStore, *spouseURLRecorder, string) {
+	t.Helper()
+	dir := t.TempDir()
+	storage, err := newLocalStorage(dir, "/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := &spouseJobStore{jobs: make(map[uint]*models.SpouseImageJob)}
+	jobs.Create(&models.SpouseImageJob{
+		UserID: 5,
+		Status: SpouseImageJobPending,
+		Traits: `{"spouse_gender": "F", "description": "배우자"}`,
+		Prompt: "portrait",
+	})
+	records := &jobRecordStore{}
+	fortunes := &spouseURLRecorder{}
+	service := &spouseImageService{
+		jobRepo:       jobs,
+		fortuneRepo:   fortunes,
+		recordRepo:    records,
+		imageProvider: provider,
+		storage:       storage,
+		imageTimeout:  time.Second,
+	}
+	return service, jobs, records, fortunes, dir
+}
+
+func TestProcessSpouseImageJobStoresUnguessableImage(t *testing.T) {
+	service, jobs, records, fortunes, dir := newSpouseImageTestService(t, newFakeImageProvider())
+
+	if err := service.ProcessSpouseImageJob(context.Background(), 40, 5, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	job := jobs.jobs[1]
+	if job.Status != SpouseImageJobCompleted || job.CompletedAt == nil {
+		t.Fatalf("job not completed: %+v", job)
+	}
+	if !regexp.MustCompile(`^/media/spouse/[0-9a-f]{32}\.png$`).MatchString(job.ImageURL) {
+		t.Errorf("image URL %q is not a random key", job.ImageURL)
+	}
+	if _, err := os.Stat(filepath.Join(dir, strings.TrimPrefix(job.ImageURL, "/media/"))); err != nil {
+		t.Errorf("image file not saved: %v", err)
+	}
+	if len(fortunes.urls) != 1 || fortunes.urls[0] != job.ImageURL {
+		t.Errorf("profile image URL updates = %v", fortunes.urls)
+	}
+	if len(records.records) != 1 || job.RecordID != records.records[0].ID {
+		t.Fatalf("records = %d, job.RecordID = %d", len(records.records), job.RecordID)
+	}
+	if record := records.records[0]; record.Type != "ai_spouse" || record.ImageURL != job.ImageURL || *record.JobID != 40 {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestProcessSpouseImageJobRetryKeepsOneRecord(t *testing.T) {
+	service, jobs, records, _, _ := newSpouseImageTestService(t, newFakeImageProvider())
+
+	if err := service.ProcessSpouseImageJob(context.Background(), 40, 5, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	firstURL := jobs.jobs[1].ImageURL
+
+	// 기록을 남긴 뒤 완료 표시가 저장되지 않아 큐가 같은 작업을 다시 실행한 경우
+	jobs.jobs[1].Status = SpouseImageJobPending
+	if err := service.ProcessSpouseImageJob(context.Background(), 40, 5, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	job := jobs.jobs[1]
+	if len(records.records) != 1 {
+		t.Fatalf("retry created %d records, want 1", len(records.records))
+	}
+	if job.ImageURL == firstURL {
+		t.Errorf("retry reused the image key %q", firstURL)
+	}
+	if record := records.records[0]; record.ImageURL != job.ImageURL || job.RecordID != record.ID {
+		t.Errorf("record %+v does not point at the retried image %q", record, job.ImageURL)
+	}
+}
+
+func TestProcessSpouseImageJobRejectedPromptFailsWithoutRetry(t *testing.T) {
+	service, jobs, records, fortunes, _ := newSpouseImageTestService(t, rejectingImageProvider{})
+
+	err := service.ProcessSpouseImageJob(context.Background(), 40, 5, 1, false)
+	if !isPermanentJobError(err) {
+		t.Fatalf("got %v, want a permanent job error", err)
+	}
+	if job := jobs.jobs[1]; job.Status != SpouseImageJobFailed || job.Error != "image generation failed" {
+		t.Errorf("job = %+v, want failed", job)
+	}
+	if len(records.records) != 0 || len(fortunes.urls) != 0 {
+		t.Errorf("rejected image left records %d, URL updates %v", len(records.records), fortunes.urls)
+	}
+}
+
+func TestDeriveSpouseTraitsUsesLocale(t *testing.T) {
+	// 丙午일주 남성: 배우자궁 午(火), 재성 金은 庚·申·辛 세 개
+	chart := map[string]string{
+		"year_stem": "庚", "year_branch": "申",
+		"month_stem": "辛", "month_branch": "巳",
+		"day_stem": "丙", "day_branch": "午",
+		"hour_stem": "甲", "hour_branch": "午",
+	}
+	hangul := regexp.MustCompile(`\p{Hangul}`)
+
+	for _, locale := range []string{"ko", "en", "ja"} {
+		t.Run(locale, func(t *testing.T) {
+			traits := DeriveSpouseTraits(chart, "M", locale)
+			if traits.SpouseGender != "F" || traits.PalaceElement != "火" || traits.StarElement != "金" || traits.StarCount != 3 {
+				t.Fatalf("unexpected chart reading %+v", traits)
+			}
+			if !strings.Contains(traits.Description, i18n.T(locale, "gender.F")) || !strings.Contains(traits.Description, i18n.T(locale, "spouse.star.wealth")) {
+				t.Errorf("description %q is missing the localized gender or star", traits.Description)
+			}
+			for _, keyword := range traits.Keywords {
+				if !strings.Contains(traits.Description, keyword) {
+					t.Errorf("description %q is missing keyword %q", traits.Description, keyword)
+				}
+			}
+			if locale != "ko" && hangul.MatchString(traits.Description+traits.StarName+strings.Join(traits.Keywords, "")) {
+				t.Errorf("%s traits contain Korean text: %+v", locale, traits)
+			}
+		})
+	}
+}
+
+func TestDeriveSpouseTraitsFollowsGender(t *testing.T) {
+	// 丙午일주: 여성의 배우자성은 관성 水인데 사주에 없다
+	chart := map[string]string{
+		"year_stem": "庚", "year_branch": "申",
+		"month_stem": "辛", "month_branch": "巳",
+		"day_stem": "丙", "day_branch": "午",
+		"hour_stem": "甲", "hour_branch": "午",
+	}
+
+	female := DeriveSpouseTraits(chart, "F", "ko")
+	if female.SpouseGender != "M" || female.StarName != "관성" || female.StarElement != "水" || female.StarCount != 0 {
+		t.Fatalf("female traits %+v", female)
+	}
+	// 배우자성이 드러나지 않으면 성격도 배우자궁 오행으로 본다
+	want := []string{spouseKeyword("ko", "spouse.appearance.", "火"), spouseKeyword("ko", "spouse.personality.", "火")}
+	if len(female.Keywords) != 2 || female.Keywords[0] != want[0] || female.Keywords[1] != want[1] {
+		t.Errorf("keywords %q, want %q", female.Keywords, want)
+	}
+
+	// 성별을 모르면 배우자성 없이 배우자궁만 쓴다
+	unknown := DeriveSpouseTraits(chart, "", "ko")
+	if unknown.SpouseGender != "" || unknown.StarElement != "" || !strings.Contains(unknown.Description, i18n.T("ko", "spouse.person")) {
+		t.Errorf("unknown gender traits %+v", unknown)
+	}
+}
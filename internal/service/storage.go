@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"dothefortune_server/internal/config"
+)
+
+// 생성한 파일을 저장하고 클라이언트가 받을 수 있는 URL을 돌려준다
+type FileStorage interface {
+	Save(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+const StorageBackendLocal = "local"
+
+func NewFileStorage(cfg *config.Config) (FileStorage, error) {
+	switch cfg.StorageBackend {
+	case StorageBackendLocal:
+		return newLocalStorage(cfg.StorageDir, cfg.StoragePublicURL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// 서버 디스크에 저장하고 라우터가 publicURL 아래로 그대로 서빙한다
+type localStorage struct {
+	dir       string
+	publicURL string
+}
+
+func newLocalStorage(dir, publicURL string) (FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStorage{
+		dir:       dir,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}, nil
+}
+
+func (s *localStorage) Save(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return "", err
+	}
+
+	// 임시 파일에 쓴 뒤 옮겨서 읽는 쪽이 반쯤 쓰인 파일을 보지 않게 한다
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return "", err
+	}
+
+	return s.publicURL + "/" + key, nil
+}
+
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	filePath, err := s.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// 키에 ..나 절대 경로가 섞여 저장 디렉터리 밖으로 나가지 않게 막는다
+func (s *localStorage) filePath(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid storage key: %s", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
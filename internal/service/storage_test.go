@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorageSaveAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := newLocalStorage(dir, "https://cdn.example.com/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	url, err := storage.Save(ctx, "spouse/3/a1b2.png", []byte("png"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://cdn.example.com/files/spouse/3/a1b2.png" {
+		t.Errorf("url = %s", url)
+	}
+	filePath := filepath.Join(dir, "spouse", "3", "a1b2.png")
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "png" {
+		t.Fatalf("saved file %q, err %v", data, err)
+	}
+	// 임시 파일은 남지 않는다
+	if entries, _ := os.ReadDir(filepath.Dir(filePath)); len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+
+	if err := storage.Delete(ctx, "spouse/3/a1b2.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("file still exists: %v", err)
+	}
+	if err := storage.Delete(ctx, "spouse/3/a1b2.png"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestLocalStorageRejectsKeysOutsideDir(t *testing.T) {
+	storage, err := newLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../escape.png", "spouse/../../escape.png", "/abs.png", "", "spouse//a.png"} {
+		if _, err := storage.Save(context.Background(), key, []byte("png"), "image/png"); err == nil {
+			t.Errorf("Save(%q) succeeded", key)
+		}
+	}
+}
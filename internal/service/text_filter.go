@@ -0,0 +1,293 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"dothefortune_server/internal/i18n"
+)
+
+// 생성 문장 검사 기준 (용도마다 길이가 다르다)
+type TextPolicy struct {
+	Name     string // 로그에 남기는 용도 이름
+	MinRunes int
+	MaxRunes int
+}
+
+var (
+	fortuneTextPolicy   = TextPolicy{Name: "fortune", MinRunes: 10, MaxRunes: 200} // 1~2문장
+	analysisTextPolicy  = TextPolicy{Name: "compatibility_analysis", MinRunes: 20, MaxRunes: 320}
+	narrativeTextPolicy = TextPolicy{Name: "compatibility_narrative", MinRunes: 30, MaxRunes: 600}
+	chatTextPolicy      = TextPolicy{Name: "chat", MinRunes: 2, MaxRunes: 1200}
+	// 스트리밍 오늘의 운세는 네 항목을 한 글로 받는다 (항목별 길이는 끝난 뒤 fortuneTextPolicy로 본다)
+	dailyFortuneStreamPolicy = TextPolicy{Name: "daily_fortune_stream", MinRunes: 10, MaxRunes: 1000}
+)
+
+// 검사를 통과하지 못한 이유
+type TextRejection struct {
+	Policy string
+	Reason string
+}
+
+func (e *TextRejection) Error() string {
+	return fmt.Sprintf("generated %s text rejected: %s", e.Policy, e.Reason)
+}
+
+type bannedPattern struct {
+	pattern *regexp.Regexp
+	reason  string
+}
+
+// 보여주면 안 되는 표현. 겁을 주는 예언, 의료/재정 지시, 도박 권유 (생성 언어와 관계없이 모두 검사한다)
+var bannedPatterns = []bannedPattern{
+	{regexp.MustCompile(`죽(음|는다|게 될|을 수)|사망|자살|목숨`), "frightening prediction"},
+	{regexp.MustCompile(`큰 ?사고가|불치병|재앙|저주|파멸|끔찍한`), "frightening prediction"},
+	{regexp.MustCompile(`약(을|은)? ?(끊|중단)|병원에? ?가지 ?마|치료(를|는)? ?(중단|거부|받지 ?마)|수술(을|은)? ?(받지|하지) ?마`), "medical directive"},
+	{regexp.MustCompile(`(주식|코인|비트코인|부동산|펀드)(을|를)? ?(사세요|사야|매수하|매도하|파세요|팔아야)`), "financial directive"},
+	{regexp.MustCompile(`대출(을|를)? ?받(으세요|아야|아서)|전 ?재산|몰빵|올인|100 ?% ?(수익|확실)|무조건 (오르|수익)`), "financial directive"},
+	{regexp.MustCompile(`도박|로또 ?번호`), "gambling"},
+	{regexp.MustCompile(`(?i)\b(you will die|death awaits|suicide|terrible accident|incurable|curse[sd]?|doom(ed)?)\b`), "frightening prediction"},
+	{regexp.MustCompile(`(?i)\b(stop (taking )?your (medication|medicine|treatment)|don'?t (go to|see) (a|the|your) doctor|refuse (the )?(treatment|surgery))\b`), "medical directive"},
+	{regexp.MustCompile(`(?i)\b((buy|sell) (stocks?|crypto|bitcoin|shares)|take out a loan|all your savings|go all[- ]in|guaranteed (profit|returns?))\b`), "financial directive"},
+	{regexp.MustCompile(`(?i)\b(gambl(e|ing)|lottery numbers?)\b`), "gambling"},
+	{regexp.MustCompile(`死ぬ|死亡|自殺|大事故|不治の病|呪い|破滅`), "frightening prediction"},
+	{regexp.MustCompile(`薬を?(やめ|中止)|病院に行かな|治療を?(やめ|拒否|中止)|手術を?受けな`), "medical directive"},
+	{regexp.MustCompile(`(株|仮想通貨|ビットコイン|不動産)を?(買うべき|買いましょう|売るべき|売りましょう)|借金をして|全財産|必ず儲か`), "financial directive"},
+	{regexp.MustCompile(`ギャンブル|賭博|宝くじの番号`), "gambling"},
+}
+
+type disclaimerTopic struct {
+	pattern    *regexp.Regexp
+	disclaimer string // i18n 메시지 ID
+}
+
+// 건강이나 돈 이야기가 나오면 끝에 붙이는 주의 문구 (생성 언어의 문구를 붙인다)
+var disclaimerTopics = []disclaimerTopic{
+	{regexp.MustCompile(`병원|질병|질환|통증|수술|치료|진료|복용|건강검진|(?i)\b(hospital|illness|disease|pain|surgery|treatment|medication|check-?up)\b|病院|病気|疾患|痛み|手術|治療|診察|服用|健康診断`), "filter.disclaimer.health"},
+	{regexp.MustCompile(`투자|주식|코인|대출|부동산|재테크|펀드|매수|매도|(?i)\b(invest(ing|ment)?|stocks?|crypto|loans?|real estate|funds?)\b|投資|株|仮想通貨|ローン|借金|不動産|資産運用`), "filter.disclaimer.investment"},
+}
+
+var sentencePattern = regexp.MustCompile(`[^.!?。…]+[.!?。…]*`)
+
+// 경어체 문장 끝 (~해요, ~입니다, ~습니까)
+var politeEndings = []string{"요", "니다", "니까"}
+
+// 길이, 언어, 경어체, 금지 표현을 검사하고 통과하면 필요한 주의 문구를 붙여 반환한다. 거절하면 이유를 로그로 남긴다.
+// 언어와 경어체는 locale 기준으로 본다 (경어체 어미 검사는 한국어만)
+func filterGeneratedText(text string, policy TextPolicy, locale string) (string, error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if reason := checkGeneratedText(text, policy, locale); reason != "" {
+		log.Printf("Rejected generated %s text (%s): %q", policy.Name, reason, truncateRunes(text, 200))
+		return "", &TextRejection{Policy: policy.Name, Reason: reason}
+	}
+
+	for _, topic := range disclaimerTopics {
+		disclaimer := i18n.T(locale, topic.disclaimer)
+		if topic.pattern.MatchString(text) && !strings.Contains(text, disclaimer) {
+			text += " " + disclaimer
+		}
+	}
+	return text, nil
+}
+
+func checkGeneratedText(text string, policy TextPolicy, locale string) string {
+	if text == "" {
+		return "empty"
+	}
+
+	for _, banned := range bannedPatterns {
+		if match := banned.pattern.FindString(text); match != "" {
+			return fmt.Sprintf("%s (%s)", banned.reason, match)
+		}
+	}
+
+	if reason := checkTextLanguage(text, locale); reason != "" {
+		return reason
+	}
+
+	runes := len([]rune(text))
+	if runes < policy.MinRunes {
+		return fmt.Sprintf("too short (%d runes)", runes)
+	}
+	if runes > policy.MaxRunes {
+		return fmt.Sprintf("too long (%d runes)", runes)
+	}
+
+	if locale != i18n.LocaleKorean {
+		return ""
+	}
+	for _, sentence := range sentencePattern.FindAllString(text, -1) {
+		if !isPoliteSentence(sentence) {
+			return fmt.Sprintf("impolite ending (%s)", strings.TrimSpace(sentence))
+		}
+	}
+	return ""
+}
+
+// 요청 로케일의 문자가 주로 쓰였는지 본다. 일본어는 가나가 있어야 하고 한자는 일본어 쪽으로 센다
+func checkTextLanguage(text, locale string) string {
+	hangul, latin, kana, han := 0, 0, 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		}
+	}
+
+	switch locale {
+	case i18n.LocaleEnglish:
+		if latin == 0 || hangul+kana > latin {
+			return "not english"
+		}
+	case i18n.LocaleJapanese:
+		if kana == 0 || hangul > 0 || latin > kana+han {
+			return "not japanese"
+		}
+	default:
+		if hangul == 0 || latin > hangul {
+			return "not korean"
+		}
+	}
+	return ""
+}
+
+// 문장 끝의 문장부호, 이모지, 따옴표를 떼고 경어체 어미로 끝나는지 본다. 한글이 없는 조각(이모지 등)은 통과시킨다
+func isPoliteSentence(sentence string) bool {
+	trimmed := strings.TrimRightFunc(sentence, func(r rune) bool {
+		return !unicode.Is(unicode.Hangul, r)
+	})
+	if trimmed == "" {
+		return true
+	}
+	for _, ending := range politeEndings {
+		if strings.HasSuffix(trimmed, ending) {
+			return true
+		}
+	}
+	return false
+}
+
+// 짧은 앞부분은 숫자나 기호뿐일 수 있어 이만큼 모인 뒤에 언어를 검사한다
+const streamLanguageCheckRunes = 20
+
+// 스트리밍 응답을 문장 단위로 모아 검사를 통과한 문장만 onDelta로 보낸다.
+// 거절된 문장이 나오면 그 뒤로는 아무것도 보내지 않으므로 사용자는 검사를 통과한 글만 본다
+type filteredStream struct {
+	policy   TextPolicy
+	locale   string
+	onDelta  func(delta string) error
+	pending  string
+	sent     strings.Builder
+	rejected *TextRejection
+}
+
+func newFilteredStream(policy TextPolicy, locale string, onDelta func(delta string) error) *filteredStream {
+	return &filteredStream{policy: policy, locale: locale, onDelta: onDelta}
+}
+
+// GenerateStream에 넘기는 콜백
+func (f *filteredStream) write(delta string) error {
+	if f.rejected != nil {
+		return nil
+	}
+	f.pending += delta
+	end := strings.LastIndexAny(f.pending, ".!?。…\n")
+	if end < 0 {
+		return nil
+	}
+	_, size := utf8.DecodeRuneInString(f.pending[end:])
+	chunk := f.pending[:end+size]
+	f.pending = f.pending[end+size:]
+	return f.emit(chunk)
+}
+
+// 생성이 끝나면 남은 조각을 검사해 보내고, 필요한 주의 문구를 붙인다.
+// 보낸 글 전체를 반환하며 도중에 거절되었으면 거절 이유도 함께 반환한다 (보낸 글은 검사를 통과한 앞부분이다)
+func (f *filteredStream) finish() (string, error) {
+	if f.rejected == nil && strings.TrimSpace(f.pending) != "" {
+		if err := f.emit(f.pending); err != nil {
+			return "", err
+		}
+	}
+	f.pending = ""
+
+	text := strings.Join(strings.Fields(f.sent.String()), " ")
+	if text == "" {
+		if f.rejected != nil {
+			return "", f.rejected
+		}
+		return "", &TextRejection{Policy: f.policy.Name, Reason: "empty"}
+	}
+	for _, topic := range disclaimerTopics {
+		disclaimer := i18n.T(f.locale, topic.disclaimer)
+		if topic.pattern.MatchString(text) && !strings.Contains(text, disclaimer) {
+			if err := f.onDelta(" " + disclaimer); err != nil {
+				return "", err
+			}
+			text += " " + disclaimer
+		}
+	}
+	if f.rejected != nil {
+		return text, f.rejected
+	}
+	return text, nil
+}
+
+// 보낸 글 원문 (줄바꿈을 그대로 둔다)
+func (f *filteredStream) sentText() string {
+	return f.sent.String()
+}
+
+func (f *filteredStream) emit(chunk string) error {
+	if reason := f.checkChunk(chunk); reason != "" {
+		log.Printf("Rejected streamed %s text (%s): %q", f.policy.Name, reason, truncateRunes(chunk, 200))
+		f.rejected = &TextRejection{Policy: f.policy.Name, Reason: reason}
+		return nil
+	}
+	f.sent.WriteString(chunk)
+	return f.onDelta(chunk)
+}
+
+// 금지 표현과 길이, 언어는 지금까지 보낸 글과 합쳐서 보고, 경어체는 새 조각의 문장만 본다
+func (f *filteredStream) checkChunk(chunk string) string {
+	text := strings.Join(strings.Fields(f.sent.String()+chunk), " ")
+	for _, banned := range bannedPatterns {
+		if match := banned.pattern.FindString(text); match != "" {
+			return fmt.Sprintf("%s (%s)", banned.reason, match)
+		}
+	}
+
+	runes := len([]rune(text))
+	if runes > f.policy.MaxRunes {
+		return fmt.Sprintf("too long (%d runes)", runes)
+	}
+	if runes >= streamLanguageCheckRunes {
+		if reason := checkTextLanguage(text, f.locale); reason != "" {
+			return reason
+		}
+	}
+
+	if f.locale != i18n.LocaleKorean {
+		return ""
+	}
+	for _, sentence := range sentencePattern.FindAllString(chunk, -1) {
+		// 라벨만 있는 줄("총운:")은 문장으로 보지 않는다
+		if strings.TrimSpace(sentence) == "" || strings.HasSuffix(strings.TrimSpace(sentence), ":") {
+			continue
+		}
+		if !isPoliteSentence(sentence) {
+			return fmt.Sprintf("impolite ending (%s)", strings.TrimSpace(sentence))
+		}
+	}
+	return ""
+}
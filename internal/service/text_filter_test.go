@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dothefortune_server/internal/i18n"
+)
+
+func TestFilterGeneratedText(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		policy     TextPolicy
+		locale     string
+		want       string
+		wantReason string
+	}{
+		{
+			name:   "polite korean passes",
+			text:   "오늘은 좋은 일이 생길 거예요.  작은 친절이 큰 행운으로 돌아와요.",
+			policy: fortuneTextPolicy,
+			locale: i18n.LocaleKorean,
+			want:   "오늘은 좋은 일이 생길 거예요. 작은 친절이 큰 행운으로 돌아와요.",
+		},
+		{
+			name:       "impolite ending",
+			text:       "오늘은 좋은 일이 생길 것이다. 기대해도 좋아요.",
+			policy:     fortuneTextPolicy,
+			locale:     i18n.LocaleKorean,
+			wantReason: "impolite ending",
+		},
+		{
+			name:       "financial directive",
+			text:       "지금이 기회예요. 주식을 사세요 그러면 좋아질 거예요.",
+			policy:     fortuneTextPolicy,
+			locale:     i18n.LocaleKorean,
+			wantReason: "financial directive",
+		},
+		{
+			name:       "too short",
+			text:       "좋아요.",
+			policy:     fortuneTextPolicy,
+			locale:     i18n.LocaleKorean,
+			wantReason: "too short",
+		},
+		{
+			name:       "too long",
+			text:       strings.Repeat("오늘은 마음이 편안한 하루가 될 거예요. ", 20),
+			policy:     fortuneTextPolicy,
+			locale:     i18n.LocaleKorean,
+			wantReason: "too long",
+		},
+		{
+			name:       "wrong language",
+			text:       "Today brings a calm and steady mood for you.",
+			policy:     fortuneTextPolicy,
+			locale:     i18n.LocaleKorean,
+			wantReason: "not korean",
+		},
+		{
+			name:   "english passes without polite check",
+			text:   "Today brings a calm and steady mood for you.",
+			policy: fortuneTextPolicy,
+			locale: i18n.LocaleEnglish,
+			want:   "Today brings a calm and steady mood for you.",
+		},
+		{
+			name:   "health topic gets disclaimer",
+			text:   "건강검진을 받아 보면 마음이 편해질 거예요.",
+			policy: fortuneTextPolicy,
+			locale: i18n.LocaleKorean,
+			want:   "건강검진을 받아 보면 마음이 편해질 거예요. " + i18n.T(i18n.LocaleKorean, "filter.disclaimer.health"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterGeneratedText(tt.text, tt.policy, tt.locale)
+			if tt.wantReason != "" {
+				var rejection *TextRejection
+				if !errors.As(err, &rejection) || !strings.Contains(rejection.Reason, tt.wantReason) {
+					t.Fatalf("got (%q, %v), want rejection %q", got, err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilteredStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		locale     string
+		deltas     []string
+		wantSent   string // onDelta로 나간 글 전체
+		wantText   string // finish가 반환한 글
+		wantReason string
+	}{
+		{
+			name:     "sentences split across deltas",
+			locale:   i18n.LocaleKorean,
+			deltas:   []string{"오늘은 좋은 ", "일이 생길 거예요. 작은 친절이 ", "큰 행운으로 돌아와요."},
+			wantSent: "오늘은 좋은 일이 생길 거예요. 작은 친절이 큰 행운으로 돌아와요.",
+			wantText: "오늘은 좋은 일이 생길 거예요. 작은 친절이 큰 행운으로 돌아와요.",
+		},
+		{
+			name:     "tail without punctuation is flushed on finish",
+			locale:   i18n.LocaleKorean,
+			deltas:   []string{"오늘은 좋은 일이 생길 거예요.", " 작은 친절이 큰 행운으로 돌아와요"},
+			wantSent: "오늘은 좋은 일이 생길 거예요. 작은 친절이 큰 행운으로 돌아와요",
+			wantText: "오늘은 좋은 일이 생길 거예요. 작은 친절이 큰 행운으로 돌아와요",
+		},
+		{
+			name:       "rejected sentence is never sent",
+			locale:     i18n.LocaleKorean,
+			deltas:     []string{"오늘은 좋은 일이 생길 거예요.", " 하지만 큰 사고가 날 수도 있어요.", " 조심하세요."},
+			wantSent:   "오늘은 좋은 일이 생길 거예요.",
+			wantText:   "오늘은 좋은 일이 생길 거예요.",
+			wantReason: "frightening prediction",
+		},
+		{
+			name:       "impolite sentence stops the stream",
+			locale:     i18n.LocaleKorean,
+			deltas:     []string{"오늘은 좋은 일이 생길 거예요.", " 내일은 더 좋다.", " 기대해 보세요."},
+			wantSent:   "오늘은 좋은 일이 생길 거예요.",
+			wantText:   "오늘은 좋은 일이 생길 거예요.",
+			wantReason: "impolite ending",
+		},
+		{
+			name:     "label lines are not sentences",
+			locale:   i18n.LocaleKorean,
+			deltas:   []string{"총운:\n", "오늘은 좋은 일이 생길 거예요.\n"},
+			wantSent: "총운:\n오늘은 좋은 일이 생길 거예요.\n",
+			wantText: "총운: 오늘은 좋은 일이 생길 거예요.",
+		},
+		{
+			name:       "nothing passes",
+			locale:     i18n.LocaleKorean,
+			deltas:     []string{"You will die young and alone."},
+			wantSent:   "",
+			wantReason: "frightening prediction",
+		},
+		{
+			name:     "disclaimer is streamed after the text",
+			locale:   i18n.LocaleKorean,
+			deltas:   []string{"투자 이야기는 천천히 ", "생각해 보세요."},
+			wantSent: "투자 이야기는 천천히 생각해 보세요. " + i18n.T(i18n.LocaleKorean, "filter.disclaimer.investment"),
+			wantText: "투자 이야기는 천천히 생각해 보세요. " + i18n.T(i18n.LocaleKorean, "filter.disclaimer.investment"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent strings.Builder
+			stream := newFilteredStream(dailyFortuneStreamPolicy, tt.locale, func(delta string) error {
+				sent.WriteString(delta)
+				return nil
+			})
+			for _, delta := range tt.deltas {
+				if err := stream.write(delta); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			text, err := stream.finish()
+
+			if sent.String() != tt.wantSent {
+				t.Errorf("sent %q, want %q", sent.String(), tt.wantSent)
+			}
+			if text != tt.wantText {
+				t.Errorf("finish returned %q, want %q", text, tt.wantText)
+			}
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var rejection *TextRejection
+			if !errors.As(err, &rejection) || !strings.Contains(rejection.Reason, tt.wantReason) {
+				t.Errorf("got error %v, want rejection %q", err, tt.wantReason)
+			}
+		})
+	}
+}
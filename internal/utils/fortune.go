@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math"
+	"sort"
 	"time"
+
+	"dothefortune_server/internal/i18n"
 )
 
 var heavenlyStems = []string{"甲", "乙", "丙", "丁", "戊", "己", "庚", "辛", "壬", "癸"}
@@ -104,8 +109,6 @@ var earthlyBranchPunishment = map[string][]string{
 var flyingHorseBranches = map[string][]string{
 	"寅": {"申"}, "申": {"寅"},
 	"亥": {"巳"}, "巳": {"亥"},
-	"巳": {"亥"}, "亥": {"巳"},
-	"申": {"寅"}, "寅": {"申"},
 }
 
 // 공망(空亡)
@@ -154,8 +157,10 @@ type FortuneResult struct {
 type CompatibilityDetail struct {
 	Score              float64                    `json:"score"`
 	ElementDistribution map[string]int            `json:"element_distribution"`
+	PartnerElementDistribution map[string]int     `json:"partner_element_distribution"`
 	Categories         map[string]CategoryScore  `json:"categories"`
 	Details            string                    `json:"details"`
+	Rules              []string                  `json:"-"` // 점수에 반영된 관계 유형별 규칙
 }
 
 type CategoryScore struct {
@@ -176,6 +181,16 @@ type LuckyItems struct {
 	Numbers []int  `json:"numbers"`
 }
 
+// 일진과 일간/일지의 관계 (표시 문구는 i18n 카탈로그의 daily.relation.{값})
+const (
+	DailyRelationPair       = "pair"
+	DailyRelationClash      = "clash"
+	DailyRelationSixPair    = "six_pair"
+	DailyRelationThreePair  = "three_pair"
+	DailyRelationPunishment = "punishment"
+	DailyRelationNeutral    = "neutral"
+)
+
 type DailyAnalysis struct {
 	GodOfUse      string
 	TenStar       string
@@ -189,7 +204,21 @@ type DailyAnalysis struct {
 type SimilarityResultItem struct {
 	Score  float64
 	Rank   int
-	UserID string
+	UserID uint
+}
+
+// 기둥별 유사도 설명
+type PillarSimilarity struct {
+	Pillar      string  `json:"pillar" example:"day"` // year, month, day
+	PillarName  string  `json:"pillar_name" example:"일주"`
+	StemMatch   string  `json:"stem_match" example:"exact"`     // exact, element, none
+	BranchMatch string  `json:"branch_match" example:"element"` // exact, element, none
+	Points      float64 `json:"points" example:"37.5"`          // 가중치를 적용한 기여 점수
+}
+
+type SimilarityBreakdown struct {
+	Pillars []PillarSimilarity `json:"pillars"`
+	Reasons []string           `json:"reasons" example:"같은 일주 甲子,같은 월간 오행 火"`
 }
 
 func CalculateFortunePillars(year, month, day, hour int) (yearStem, yearBranch, monthStem, monthBranch, dayStem, dayBranch, hourStem, hourBranch string) {
@@ -200,6 +229,23 @@ func CalculateFortunePillars(year, month, day, hour int) (yearStem, yearBranch,
 	return
 }
 
+var pillarKeys = []string{
+	"year_stem", "year_branch",
+	"month_stem", "month_branch",
+	"day_stem", "day_branch",
+	"hour_stem", "hour_branch",
+}
+
+// 사주 팔자로 만든 지문. 출생 정보가 바뀌어 팔자가 달라지면 값도 달라진다
+func ChartFingerprint(fortune map[string]string) string {
+	h := sha256.New()
+	for _, key := range pillarKeys {
+		h.Write([]byte(fortune[key]))
+		h.Write([]byte{'|'})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 func calculateYearPillar(year int) (string, string) {
 	idx := (year - 4) % 60
 	return heavenlyStems[idx%10], earthlyBranches[idx%12]
@@ -282,11 +328,12 @@ func CalculateCompatibilityScore(fortune1, fortune2 map[string]string) Compatibi
 		fortune2["hour_stem"], fortune2["hour_branch"],
 	) * 0.1
 
-	detail.Score = dayScore + monthScore + yearScore
+	detail.Score = dayScore + monthScore + yearScore + hourScore
 
 	// 오행 분포
 	elem1 := GetFiveElements(fortune1)
 	detail.ElementDistribution = elem1
+	detail.PartnerElementDistribution = GetFiveElements(fortune2)
 
 	// 4대 카테고리
 	detail.Categories = CalculateCategories(fortune1, fortune2, elem1)
@@ -294,6 +341,15 @@ func CalculateCompatibilityScore(fortune1, fortune2 map[string]string) Compatibi
 	return detail
 }
 
+// 오행 상생: 木→火→土→金→水→木
+var elementGenerating = map[string]string{
+	"木": "火", "火": "土", "土": "金", "金": "水", "水": "木",
+}
+
+func isElementGenerating(from, to string) bool {
+	return from != "" && elementGenerating[from] == to
+}
+
 func calculatePillarCompatibility(stem1, branch1, stem2, branch2 string) float64 {
 	score := 50.0
 
@@ -340,28 +396,28 @@ func CalculateCategories(fortune1, fortune2 map[string]string, elem1 map[string]
 	if IsHeavenlyStemPair(fortune1["day_stem"], fortune2["day_stem"]) {
 		comScore += 20
 	}
-	categories["대화"] = CategoryScore{Name: "대화", Score: math.Min(100, comScore)}
+	categories[CategoryTalk] = CategoryScore{Name: CategoryTalk, Score: math.Min(100, comScore)}
 
 	// 감정
 	emotScore := 50.0
 	if IsEarthlyBranchSixPair(fortune1["month_branch"], fortune2["month_branch"]) {
 		emotScore += 20
 	}
-	categories["감정"] = CategoryScore{Name: "감정", Score: math.Min(100, emotScore)}
+	categories[CategoryEmotion] = CategoryScore{Name: CategoryEmotion, Score: math.Min(100, emotScore)}
 
 	// 재물
 	wealthScore := 50.0
 	if elem1["木"] > 0 {
 		wealthScore += 15
 	}
-	categories["재물"] = CategoryScore{Name: "재물", Score: math.Min(100, wealthScore)}
+	categories[CategoryWealth] = CategoryScore{Name: CategoryWealth, Score: math.Min(100, wealthScore)}
 
 	// 건강
 	healthScore := 50.0
 	if IsEarthlyBranchThreePair(fortune1["day_branch"], fortune2["day_branch"]) {
 		healthScore += 15
 	}
-	categories["건강"] = CategoryScore{Name: "건강", Score: math.Min(100, healthScore)}
+	categories[CategoryHealth] = CategoryScore{Name: CategoryHealth, Score: math.Min(100, healthScore)}
 
 	return categories
 }
@@ -395,24 +451,24 @@ func AnalyzeDailyPillar(fortune map[string]string, todayStem, todayBranch string
 
 	// User 천간 vs Today 천간
 	if IsHeavenlyStemPair(userDayStem, todayStem) {
-		analysis.StemRelation = "합"
+		analysis.StemRelation = DailyRelationPair
 	} else if IsHeavenlyStemClash(userDayStem, todayStem) {
-		analysis.StemRelation = "충"
+		analysis.StemRelation = DailyRelationClash
 	} else {
-		analysis.StemRelation = "중립"
+		analysis.StemRelation = DailyRelationNeutral
 	}
 
 	// Today 지지 vs User 일지
 	if IsEarthlyBranchSixPair(userDayBranch, todayBranch) {
-		analysis.BranchRelation = "육합"
+		analysis.BranchRelation = DailyRelationSixPair
 	} else if IsEarthlyBranchThreePair(userDayBranch, todayBranch) {
-		analysis.BranchRelation = "삼합"
+		analysis.BranchRelation = DailyRelationThreePair
 	} else if IsEarthlyBranchClash(userDayBranch, todayBranch) {
-		analysis.BranchRelation = "충"
+		analysis.BranchRelation = DailyRelationClash
 	} else if IsEarthlyBranchPunishment(userDayBranch, todayBranch) {
-		analysis.BranchRelation = "형"
+		analysis.BranchRelation = DailyRelationPunishment
 	} else {
-		analysis.BranchRelation = "중립"
+		analysis.BranchRelation = DailyRelationNeutral
 	}
 
 	// 천을귀인
@@ -445,8 +501,12 @@ func IsNobleInfluence(stem, branch string) bool {
 }
 
 func HasFlyingHorse(userBranch, todayBranch string) bool {
-	if clash, ok := flyingHorseBranches[userBranch]; ok {
-		return clash == todayBranch
+	if horses, ok := flyingHorseBranches[userBranch]; ok {
+		for _, horse := range horses {
+			if horse == todayBranch {
+				return true
+			}
+		}
 	}
 	return false
 }
@@ -462,10 +522,13 @@ func HasEmptyTrunk(userBranch, todayBranch string) bool {
 	return false
 }
 
-func CalculateTodayFortune(fortune map[string]string) FortunePrediction {
-	now := time.Now()
-	todayStem, todayBranch := calculateDayPillar(now.Year(), int(now.Month()), now.Day())
+func CalculateTodayFortune(fortune map[string]string, locale string) FortunePrediction {
+	todayStem, todayBranch := CalculateTodayPillar()
+	return CalculateFortuneForPillar(fortune, todayStem, todayBranch, locale)
+}
 
+// 주어진 일진 기준 운세 점수와 키워드 (시간대에 맞춘 날짜의 일진을 넘길 때 사용). 키워드 문구는 locale로 쓴다
+func CalculateFortuneForPillar(fortune map[string]string, todayStem, todayBranch, locale string) FortunePrediction {
 	prediction := FortunePrediction{
 		Score:    70.0,
 		Keywords: make(map[string]string),
@@ -503,69 +566,36 @@ func CalculateTodayFortune(fortune map[string]string) FortunePrediction {
 	prediction.Score = math.Min(100, math.Max(0, prediction.Score))
 
 	// 4대 운세 키워드
-	prediction.Keywords["재물"] = getWealthFortune(GetElement(todayStem))
-	prediction.Keywords["애정"] = getEmotionFortune(todayBranch)
-	prediction.Keywords["건강"] = getHealthFortune(userDayStem)
+	prediction.Keywords["재물"] = getWealthFortune(GetElement(todayStem), locale)
+	prediction.Keywords["애정"] = getEmotionFortune(todayBranch, locale)
+	prediction.Keywords["건강"] = getHealthFortune(userDayStem, locale)
 	prediction.Keywords["총운"] = fmt.Sprintf("%.0f", prediction.Score)
 
 	return prediction
 }
 
-func getWealthFortune(element string) string {
-	fortuneMap := map[string]string{
-		"木": "진행 중인 프로젝트에서 성과가 기대됩니다.",
-		"火": "창의적인 아이디어가 수익으로 이어질 수 있습니다.",
-		"土": "안정적인 재정 관리 시기입니다.",
-		"金": "투자나 거래에서 신중함이 필요합니다.",
-		"水": "유동성 있는 기회가 찾아올 수 있습니다.",
-	}
-	if fortune, ok := fortuneMap[element]; ok {
-		return fortune
-	}
-	return "재물운이 평온합니다."
+// 문구는 i18n 카탈로그의 fortune.wealth.{오행}, fortune.emotion.{지지}, fortune.health.{천간}
+func getWealthFortune(element, locale string) string {
+	return localizedOrDefault(locale, "fortune.wealth.", element)
 }
 
-func getEmotionFortune(branch string) string {
-	fortuneMap := map[string]string{
-		"子": "차분한 감정 상태입니다.",
-		"丑": "인내심이 필요한 시기입니다.",
-		"寅": "활기찬 감정이 넘칩니다.",
-		"卯": "섬세한 감정 표현이 좋습니다.",
-		"辰": "신중한 판단이 필요합니다.",
-		"巳": "열정적인 기운이 강합니다.",
-		"午": "감정 표현이 적극적입니다.",
-		"未": "부드러운 에너지가 흐릅니다.",
-		"申": "이성적인 판단이 우선입니다.",
-		"酉": "말조심이 필요한 시기입니다.",
-		"戌": "충실한 기운이 흐릅니다.",
-		"亥": "휴식과 성찰이 필요합니다.",
-	}
-	if fortune, ok := fortuneMap[branch]; ok {
-		return fortune
-	}
-	return "감정이 평온합니다."
+func getEmotionFortune(branch, locale string) string {
+	return localizedOrDefault(locale, "fortune.emotion.", branch)
 }
 
-func getHealthFortune(stem string) string {
-	fortuneMap := map[string]string{
-		"甲": "신체 활동이 좋은 시기입니다.",
-		"乙": "휴식을 충분히 취하세요.",
-		"丙": "에너지가 넘치는 시기입니다.",
-		"丁": "스트레스 관리가 중요합니다.",
-		"戊": "소화기 건강을 챙기세요.",
-		"己": "면역력 강화가 필요합니다.",
-		"庚": "호흡기 건강에 주의하세요.",
-		"辛": "피부 관리를 신경 쓰세요.",
-		"壬": "수분 섭취에 신경 쓰세요.",
-		"癸": "신장 건강을 돌보세요.",
-	}
-	if fortune, ok := fortuneMap[stem]; ok {
-		return fortune
+func getHealthFortune(stem, locale string) string {
+	return localizedOrDefault(locale, "fortune.health.", stem)
+}
+
+// prefix+key 문구가 없으면 prefix+"default" 문구
+func localizedOrDefault(locale, prefix, key string) string {
+	if message, ok := i18n.Lookup(locale, prefix+key); ok {
+		return message
 	}
-	return "건강한 하루입니다."
+	return i18n.T(locale, prefix+"default")
 }
 //행운 아이템 로직
-func CalculateLuckyItem(fortune map[string]string) LuckyItems {
+func CalculateLuckyItem(fortune map[string]string, locale string) LuckyItems {
 	elements := GetFiveElements(fortune)
 	
 	// 억부: 가장 부족한 오행
@@ -580,7 +610,6 @@ func CalculateLuckyItem(fortune map[string]string) LuckyItems {
 	}
 
 	// 월지 가충지 우선 로직
-	monthBranch := fortune["month_branch"]
 	nowMonth := time.Now().Month()
 	nourishing := monthNourishingBranches[int(nowMonth)]
 	
@@ -606,7 +635,7 @@ func CalculateLuckyItem(fortune map[string]string) LuckyItems {
 		luckyElement = findTransitionElement(fortune)
 	}
 
-	colorName, colorHex := GetLuckyColor(luckyElement)
+	colorName, colorHex := GetLuckyColor(luckyElement, locale)
 	luckyNumbers := GetLuckyNumbers(luckyElement)
 
 	return LuckyItems{
@@ -660,21 +689,22 @@ func findTransitionElement(fortune map[string]string) string {
 
 
 //유사도 동점자 처리
-func HandleSimilarityTie(results []SimilarityResultItem) []SimilarityResultItem {
+// 같은 점수는 같은 순위(dense rank), 순서는 UserID 오름차순. 페이지 단위로 처리할 때는 첫 항목의 순위를 firstRank로 넘긴다
+func HandleSimilarityTie(results []SimilarityResultItem, firstRank int) []SimilarityResultItem {
 	// 점수 내림차순 정렬
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		if results[i].Score != results[j].Score {
 			return results[i].Score > results[j].Score
 		}
-		// 동점이면 UserID 알파벳순
+		// 동점이면 UserID 오름차순
 		return results[i].UserID < results[j].UserID
 	})
 
 	// 순위 지정
-	rank := 1
+	rank := firstRank
 	for i := 0; i < len(results); i++ {
 		if i > 0 && results[i].Score != results[i-1].Score {
-			rank = i + 1
+			rank++
 		}
 		results[i].Rank = rank
 	}
@@ -797,8 +827,9 @@ func CalculateLuckyElement(fortuneInfo map[string]string, todayStem, todayBranch
 	minCount := 999
 	luckyElement := "土"
 
-	for element, count := range allElements {
-		if count < minCount {
+	// 같은 개수면 木火土金水 순으로 앞선 오행 (맵 순회 순서에 따라 결과가 바뀌지 않게)
+	for _, element := range ElementOrder {
+		if count := allElements[element]; count < minCount {
 			minCount = count
 			luckyElement = element
 		}
@@ -807,22 +838,20 @@ func CalculateLuckyElement(fortuneInfo map[string]string, todayStem, todayBranch
 	return luckyElement
 }
 
-func GetLuckyColor(element string) (string, string) {
-	colorMap := map[string]struct {
-		name string
-		hex  string
-	}{
-		"木": {"초록", "#4CAF50"},
-		"火": {"레드", "#F44336"},
-		"土": {"옐로우", "#FFC107"},
-		"金": {"화이트", "#FFFFFF"},
-		"水": {"블루", "#2196F3"},
-	}
+var luckyColorHex = map[string]string{
+	"木": "#4CAF50",
+	"火": "#F44336",
+	"土": "#FFC107",
+	"金": "#FFFFFF",
+	"水": "#2196F3",
+}
 
-	if color, ok := colorMap[element]; ok {
-		return color.name, color.hex
+// 오행의 행운 색 이름(locale)과 HEX
+func GetLuckyColor(element, locale string) (string, string) {
+	if hex, ok := luckyColorHex[element]; ok {
+		return i18n.T(locale, "lucky_color."+element), hex
 	}
-	return "베이지", "#795548"
+	return i18n.T(locale, "lucky_color.default"), "#795548"
 }
 
 func GetLuckyNumbers(element string) []int {
@@ -855,35 +884,18 @@ func IntSliceToJSON(nums []int) string {
 	return result
 }
 
-//오늘의 운세 프롬프트 기준
-func GetTodayFortune(fortuneInfo map[string]string) string {
-	dayStem := fortuneInfo["day_stem"]
-	dayBranch := fortuneInfo["day_branch"]
-	
-	fortuneMap := map[string]string{
-		"甲子": "오늘은 새로운 시작에 좋은 날입니다. 자신감을 가지고 도전해보세요.",
-		"乙丑": "인내심이 필요한 하루입니다. 서두르지 말고 차근차근 진행하세요.",
-		"丙寅": "활동적인 하루가 예상됩니다. 에너지를 잘 활용하세요.",
-		"丁卯": "창의적인 아이디어가 떠오를 수 있는 날입니다.",
-		"戊辰": "안정적인 하루입니다. 기존 일을 마무리하는 데 좋습니다.",
-		"己巳": "변화를 준비하는 날입니다. 새로운 기회를 주시하세요.",
-		"庚午": "의사소통이 중요한 하루입니다. 타인과의 협력이 도움이 됩니다.",
-		"辛未": "세심한 주의가 필요한 날입니다. 작은 실수를 조심하세요.",
-		"壬申": "유연성이 필요한 하루입니다. 상황에 맞게 대응하세요.",
-		"癸酉": "깊이 있는 사고가 필요한 날입니다. 중요한 결정은 신중하게 하세요.",
-	}
-	
-	key := dayStem + dayBranch
-	if fortune, ok := fortuneMap[key]; ok {
-		return fortune
-	}
-	
-	return "오늘은 평범한 하루입니다. 긍정적인 마음가짐으로 하루를 보내세요."
+//오늘의 운세 프롬프트 기준 (문구는 i18n 카탈로그의 fortune.today.{일주})
+func GetTodayFortune(fortuneInfo map[string]string, locale string) string {
+	return localizedOrDefault(locale, "fortune.today.", fortuneInfo["day_stem"]+fortuneInfo["day_branch"])
 }
 
 func CalculateTodayPillar() (string, string) {
-	now := time.Now()
-	return calculateDayPillar(now.Year(), int(now.Month()), now.Day())
+	return CalculateDayPillarAt(time.Now())
+}
+
+// t가 속한 시간대의 날짜 기준 일진
+func CalculateDayPillarAt(t time.Time) (string, string) {
+	return calculateDayPillar(t.Year(), int(t.Month()), t.Day())
 }
 
 func CalculateSimilarityScore(fortune1, fortune2 map[string]string) float64 {
@@ -905,19 +917,93 @@ func CalculateSimilarityScore(fortune1, fortune2 map[string]string) float64 {
 	return dayScore*0.5 + monthScore*0.3 + yearScore*0.2
 }
 
+// 유사도 점수가 어디서 나왔는지 기둥별로 설명한다 (CalculateSimilarityScore와 같은 가중치). 기둥 이름과 이유는 locale로 쓴다
+func ExplainSimilarity(fortune1, fortune2 map[string]string, locale string) SimilarityBreakdown {
+	pillars := []struct {
+		key    string
+		weight float64
+	}{
+		{"day", 0.5},
+		{"month", 0.3},
+		{"year", 0.2},
+	}
+
+	breakdown := SimilarityBreakdown{
+		Pillars: []PillarSimilarity{},
+		Reasons: []string{},
+	}
+
+	for _, pillar := range pillars {
+		stem1, stem2 := fortune1[pillar.key+"_stem"], fortune2[pillar.key+"_stem"]
+		branch1, branch2 := fortune1[pillar.key+"_branch"], fortune2[pillar.key+"_branch"]
+
+		name := i18n.T(locale, "pillar."+pillar.key)
+		stemName := i18n.T(locale, "pillar."+pillar.key+"_stem")
+		branchName := i18n.T(locale, "pillar."+pillar.key+"_branch")
+
+		stemMatch := similarityMatch(stem1, stem2)
+		branchMatch := similarityMatch(branch1, branch2)
+
+		breakdown.Pillars = append(breakdown.Pillars, PillarSimilarity{
+			Pillar:      pillar.key,
+			PillarName:  name,
+			StemMatch:   stemMatch,
+			BranchMatch: branchMatch,
+			Points:      calculatePillarSimilarity(stem1, branch1, stem2, branch2) * pillar.weight,
+		})
+
+		if stemMatch == "exact" && branchMatch == "exact" {
+			breakdown.Reasons = append(breakdown.Reasons, i18n.T(locale, "similarity.same", name, stem2+branch2))
+			continue
+		}
+		switch stemMatch {
+		case "exact":
+			breakdown.Reasons = append(breakdown.Reasons, i18n.T(locale, "similarity.same", stemName, stem2))
+		case "element":
+			breakdown.Reasons = append(breakdown.Reasons, i18n.T(locale, "similarity.same_element", stemName, GetElement(stem2)))
+		}
+		switch branchMatch {
+		case "exact":
+			breakdown.Reasons = append(breakdown.Reasons, i18n.T(locale, "similarity.same", branchName, branch2))
+		case "element":
+			breakdown.Reasons = append(breakdown.Reasons, i18n.T(locale, "similarity.same_element", branchName, GetElement(branch2)))
+		}
+	}
+
+	return breakdown
+}
+
+func similarityMatch(char1, char2 string) string {
+	if char1 == "" || char2 == "" {
+		return "none"
+	}
+	if char1 == char2 {
+		return "exact"
+	}
+	if GetElement(char1) == GetElement(char2) {
+		return "element"
+	}
+	return "none"
+}
+
+// 모르는 글자는 점수를 받지 않는다 (SQL 순위와 같은 기준)
 func calculatePillarSimilarity(stem1, branch1, stem2, branch2 string) float64 {
 	score := 0.0
 
-	if stem1 == stem2 {
-		score += 50
-	} else if GetElement(stem1) == GetElement(stem2) {
-		score += 25
+	if element := GetElement(stem1); element != "" {
+		if stem1 == stem2 {
+			score += 50
+		} else if element == GetElement(stem2) {
+			score += 25
+		}
 	}
 
-	if branch1 == branch2 {
-		score += 50
-	} else if GetElement(branch1) == GetElement(branch2) {
-		score += 25
+	if element := GetElement(branch1); element != "" {
+		if branch1 == branch2 {
+			score += 50
+		} else if element == GetElement(branch2) {
+			score += 25
+		}
 	}
 
 	return score
@@ -946,3 +1032,75 @@ func CalculateConflictScore(fortune1, fortune2 map[string]string) float64 {
 	return math.Min(100, math.Max(0, score))
 }
 
+
+// 오행 순서 (특징 인코딩의 오행 개수 배열 순서)
+var ElementOrder = []string{"木", "火", "土", "金", "水"}
+
+// 유사도 검색용 사주 특징. 천간 0-9, 지지 0-11 인덱스와 오행 개수
+type ChartFeatures struct {
+	YearStem    int
+	YearBranch  int
+	MonthStem   int
+	MonthBranch int
+	DayStem     int
+	DayBranch   int
+	HourStem    int
+	HourBranch  int
+	Elements    [5]int
+}
+
+func StemIndex(stem string) int {
+	for i, v := range heavenlyStems {
+		if v == stem {
+			return i
+		}
+	}
+	return -1
+}
+
+func BranchIndex(branch string) int {
+	for i, v := range earthlyBranches {
+		if v == branch {
+			return i
+		}
+	}
+	return -1
+}
+
+func ElementIndex(element string) int {
+	for i, v := range ElementOrder {
+		if v == element {
+			return i
+		}
+	}
+	return -1
+}
+
+// 지지 인덱스 순서대로 오행 인덱스 (SQL 배열 조회용)
+func BranchElementIndexes() []int {
+	indexes := make([]int, len(earthlyBranches))
+	for i, branch := range earthlyBranches {
+		indexes[i] = ElementIndex(GetElement(branch))
+	}
+	return indexes
+}
+
+func EncodeChartFeatures(fortune map[string]string) ChartFeatures {
+	features := ChartFeatures{
+		YearStem:    StemIndex(fortune["year_stem"]),
+		YearBranch:  BranchIndex(fortune["year_branch"]),
+		MonthStem:   StemIndex(fortune["month_stem"]),
+		MonthBranch: BranchIndex(fortune["month_branch"]),
+		DayStem:     StemIndex(fortune["day_stem"]),
+		DayBranch:   BranchIndex(fortune["day_branch"]),
+		HourStem:    StemIndex(fortune["hour_stem"]),
+		HourBranch:  BranchIndex(fortune["hour_branch"]),
+	}
+
+	elements := GetFiveElements(fortune)
+	for i, element := range ElementOrder {
+		features.Elements[i] = elements[element]
+	}
+
+	return features
+}
@@ -0,0 +1,132 @@
+package utils
+
+import "testing"
+
+func TestCalculateSimilarityScoreIgnoresUnknownCharacters(t *testing.T) {
+	chart := map[string]string{
+		"year_stem": "甲", "year_branch": "子",
+		"month_stem": "丙", "month_branch": "寅",
+		"day_stem": "戊", "day_branch": "午",
+	}
+	missingDay := map[string]string{
+		"year_stem": "甲", "year_branch": "子",
+		"month_stem": "丙", "month_branch": "寅",
+	}
+
+	tests := []struct {
+		name   string
+		first  map[string]string
+		second map[string]string
+		want   float64
+	}{
+		{"identical charts", chart, chart, 100},
+		// 일주를 모르면 일주 50%는 한 점도 받지 않는다 (연 20% + 월 30%)
+		{"unknown day pillar on one side", chart, missingDay, 50},
+		{"unknown day pillar on both sides", missingDay, missingDay, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculateSimilarityScore(tt.first, tt.second); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeChartFeatures(t *testing.T) {
+	features := EncodeChartFeatures(map[string]string{
+		"year_stem": "甲", "year_branch": "子",
+		"month_stem": "丙", "month_branch": "寅",
+		"day_stem": "戊", "day_branch": "午",
+		"hour_stem": "癸", "hour_branch": "亥",
+	})
+
+	want := ChartFeatures{
+		YearStem: 0, YearBranch: 0,
+		MonthStem: 2, MonthBranch: 2,
+		DayStem: 4, DayBranch: 6,
+		HourStem: 9, HourBranch: 11,
+		// 木 甲寅, 火 丙午, 土 戊, 金 없음, 水 子癸亥
+		Elements: [5]int{2, 2, 1, 0, 3},
+	}
+	if features != want {
+		t.Errorf("got %+v, want %+v", features, want)
+	}
+
+	if unknown := EncodeChartFeatures(map[string]string{}); unknown.DayStem != -1 || unknown.DayBranch != -1 {
+		t.Errorf("missing characters should encode as -1, got %+v", unknown)
+	}
+}
+
+func TestHandleSimilarityTie(t *testing.T) {
+	results := HandleSimilarityTie([]SimilarityResultItem{
+		{Score: 72.5, UserID: 9},
+		{Score: 90, UserID: 4},
+		{Score: 72.5, UserID: 3},
+		{Score: 90, UserID: 7},
+		{Score: 60, UserID: 1},
+	}, 3)
+
+	// 같은 점수는 같은 순위, 순서는 사용자 ID 순. 다음 점수는 순위를 건너뛰지 않는다
+	want := []SimilarityResultItem{
+		{Score: 90, Rank: 3, UserID: 4},
+		{Score: 90, Rank: 3, UserID: 7},
+		{Score: 72.5, Rank: 4, UserID: 3},
+		{Score: 72.5, Rank: 4, UserID: 9},
+		{Score: 60, Rank: 5, UserID: 1},
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+}
+
+func TestExplainSimilarity(t *testing.T) {
+	mine := map[string]string{"year_stem": "庚", "year_branch": "午", "month_stem": "丙", "month_branch": "寅", "day_stem": "甲", "day_branch": "子"}
+	theirs := map[string]string{"year_stem": "壬", "year_branch": "申", "month_stem": "丁", "month_branch": "卯", "day_stem": "甲", "day_branch": "子"}
+
+	breakdown := ExplainSimilarity(mine, theirs, "ko")
+	wantReasons := []string{"같은 일주 甲子", "같은 월간 오행 火", "같은 월지 오행 木"}
+	if len(breakdown.Reasons) != len(wantReasons) {
+		t.Fatalf("reasons = %v, want %v", breakdown.Reasons, wantReasons)
+	}
+	for i, reason := range wantReasons {
+		if breakdown.Reasons[i] != reason {
+			t.Errorf("reason %d = %q, want %q", i, breakdown.Reasons[i], reason)
+		}
+	}
+
+	matches := map[string][2]string{"day": {"exact", "exact"}, "month": {"element", "element"}, "year": {"none", "none"}}
+	total := 0.0
+	for _, pillar := range breakdown.Pillars {
+		if got := [2]string{pillar.StemMatch, pillar.BranchMatch}; got != matches[pillar.Pillar] {
+			t.Errorf("%s matches = %v, want %v", pillar.Pillar, got, matches[pillar.Pillar])
+		}
+		total += pillar.Points
+	}
+	// 기둥별 점수를 더하면 유사도 점수다
+	if score := CalculateSimilarityScore(mine, theirs); total != score {
+		t.Errorf("points sum to %v, want the similarity score %v", total, score)
+	}
+
+	if en := ExplainSimilarity(mine, theirs, "en"); en.Reasons[0] == breakdown.Reasons[0] || en.Pillars[0].PillarName == breakdown.Pillars[0].PillarName {
+		t.Errorf("English breakdown was not localized: %+v", en)
+	}
+}
+
+func TestCalculateLuckyElementBreaksTiesInElementOrder(t *testing.T) {
+	// 木 2, 火 3, 土 0, 金 2, 水 1에 丁丑 일진이 더해지면 土와 水가 1개로 같다
+	chart := map[string]string{
+		"year_stem": "庚", "year_branch": "午",
+		"month_stem": "辛", "month_branch": "巳",
+		"day_stem": "甲", "day_branch": "子",
+		"hour_stem": "丙", "hour_branch": "寅",
+	}
+	for i := 0; i < 20; i++ {
+		if got := CalculateLuckyElement(chart, "丁", "丑"); got != "土" {
+			t.Fatalf("CalculateLuckyElement = %s, want 土", got)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+// 상대 사주 특징. 매칭 후보를 DB에서 점수 순으로 정렬할 때 fortune_infos와 users 컬럼으로 바꿔 조회한다
+const (
+	PartnerYearStem     = "year_stem"
+	PartnerYearBranch   = "year_branch"
+	PartnerMonthStem    = "month_stem"
+	PartnerMonthBranch  = "month_branch"
+	PartnerDayStem      = "day_stem"
+	PartnerDayBranch    = "day_branch"
+	PartnerHourStem     = "hour_stem"
+	PartnerHourBranch   = "hour_branch"
+	PartnerGender       = "gender"        // 0: 알 수 없음, 1: M, 2: F
+	PartnerWealthCount  = "wealth_count"  // 상대 일간이 극하는 오행(재성)의 개수
+	PartnerOfficerCount = "officer_count" // 상대 일간을 극하는 오행(관성)의 개수
+)
+
+var partnerGenders = []string{"", "M", "F"}
+
+// 여덟 글자의 오행을 세므로 한 오행은 0~8개
+const maxElementCount = 8
+
+// 상대 특징 값 조합으로 점수 조각을 찾는 표. Values는 Features 순서대로 행 우선으로 펼친다.
+// 조회표들의 값을 모두 더해 0~100으로 자른 값이 점수다
+type ScoreLookup struct {
+	Features []string
+	Values   []float64
+}
+
+// 특징 값의 개수. 천간/지지 인덱스는 없음(-1)이 0이 되도록 1을 더해 센다
+func PartnerFeatureSize(feature string) int {
+	switch feature {
+	case PartnerYearStem, PartnerMonthStem, PartnerDayStem, PartnerHourStem:
+		return len(heavenlyStems) + 1
+	case PartnerYearBranch, PartnerMonthBranch, PartnerDayBranch, PartnerHourBranch:
+		return len(earthlyBranches) + 1
+	case PartnerGender:
+		return len(partnerGenders)
+	}
+	return maxElementCount + 1
+}
+
+// 천간 인덱스에 1을 더한 순서로 재성 오행 인덱스 (없으면 -1, SQL 배열 조회용)
+func StemWealthElementIndexes() []int {
+	return stemElementIndexes(GetWealthElement)
+}
+
+// 천간 인덱스에 1을 더한 순서로 관성 오행 인덱스 (없으면 -1, SQL 배열 조회용)
+func StemOfficerElementIndexes() []int {
+	return stemElementIndexes(GetOfficerElement)
+}
+
+func stemElementIndexes(related func(dayElement string) string) []int {
+	indexes := make([]int, len(heavenlyStems)+1)
+	for i := range indexes {
+		indexes[i] = ElementIndex(related(GetElement(stemAt(i))))
+	}
+	return indexes
+}
+
+// 조회표 내용이 바뀌도록 점수 규칙을 고치면 올린다
+const scoreLookupVersion = "v1"
+
+// RelationScoreLookups 결과를 저장하는 키. 같은 사주, 관계, 성별이면 같은 표다
+func RelationScoreKey(fortune1 map[string]string, relationType, gender1 string) string {
+	if _, ok := relationWeights[relationType]; !ok {
+		relationType = RelationRomantic
+	}
+	return scoreLookupVersion + ":" + relationType + ":" + gender1 + ":" + ChartFingerprint(fortune1)
+}
+
+// 내 사주로 정해지는 관계 궁합 점수를 상대 특징별 조회표로 펼친다.
+// 조회한 값을 더해 0~100으로 자르면 CalculateRelationCompatibilityScore(...).Score와 같다
+func RelationScoreLookups(fortune1 map[string]string, relationType, gender1 string) []ScoreLookup {
+	weights, ok := relationWeights[relationType]
+	if !ok {
+		relationType = RelationRomantic
+		weights = relationWeights[RelationRomantic]
+	}
+
+	pillar := func(stemFeature, branchFeature string, weight float64) ScoreLookup {
+		return newScoreLookup([]string{stemFeature, branchFeature}, func(keys []int) float64 {
+			return weight * calculatePillarCompatibility(fortune1[stemFeature], fortune1[branchFeature], stemAt(keys[0]), branchAt(keys[1]))
+		})
+	}
+	lookups := []ScoreLookup{
+		pillar(PartnerDayStem, PartnerDayBranch, weights.Day),
+		pillar(PartnerMonthStem, PartnerMonthBranch, weights.Month),
+		pillar(PartnerYearStem, PartnerYearBranch, weights.Year),
+		pillar(PartnerHourStem, PartnerHourBranch, weights.Hour),
+	}
+
+	// 가산점 함수가 읽는 상대 특징만 키로 쓴다
+	switch relationType {
+	case RelationRomantic:
+		lookups = append(lookups, newScoreLookup([]string{PartnerDayStem, PartnerDayBranch, PartnerGender}, func(keys []int) float64 {
+			bonus, _ := romanticBonus(fortune1, map[string]string{"day_stem": stemAt(keys[0]), "day_branch": branchAt(keys[1])}, gender1, partnerGenders[keys[2]])
+			return bonus
+		}))
+	case RelationFriend:
+		lookups = append(lookups, newScoreLookup([]string{PartnerDayStem, PartnerYearBranch}, func(keys []int) float64 {
+			bonus, _ := friendBonus(fortune1, map[string]string{"day_stem": stemAt(keys[0]), "year_branch": branchAt(keys[1])})
+			return bonus
+		}))
+	case RelationBusiness:
+		element1 := GetElement(fortune1["day_stem"])
+		elem1 := GetFiveElements(fortune1)
+		wealth1 := elem1[GetWealthElement(element1)]
+		officer1 := elem1[GetOfficerElement(element1)]
+		lookups = append(lookups, newScoreLookup([]string{PartnerDayStem, PartnerWealthCount, PartnerOfficerCount}, func(keys []int) float64 {
+			bonus, _ := businessRoleBonus(element1, GetElement(stemAt(keys[0])), wealth1, officer1, keys[1], keys[2])
+			return bonus
+		}))
+	case RelationFamily:
+		lookups = append(lookups, newScoreLookup([]string{PartnerDayStem, PartnerYearBranch}, func(keys []int) float64 {
+			bonus, _ := familyBonus(fortune1, map[string]string{"day_stem": stemAt(keys[0]), "year_branch": branchAt(keys[1])})
+			return bonus
+		}))
+	}
+	return lookups
+}
+
+// 특징 값의 모든 조합에 대해 value를 불러 표를 채운다 (마지막 특징이 가장 빨리 바뀐다)
+func newScoreLookup(features []string, value func(keys []int) float64) ScoreLookup {
+	total := 1
+	for _, feature := range features {
+		total *= PartnerFeatureSize(feature)
+	}
+
+	lookup := ScoreLookup{Features: features, Values: make([]float64, total)}
+	keys := make([]int, len(features))
+	for i := range lookup.Values {
+		rest := i
+		for j := len(features) - 1; j >= 0; j-- {
+			size := PartnerFeatureSize(features[j])
+			keys[j] = rest % size
+			rest /= size
+		}
+		lookup.Values[i] = value(keys)
+	}
+	return lookup
+}
+
+// 조회표 키(인덱스 + 1)를 글자로. 0은 없음
+func stemAt(key int) string {
+	if key <= 0 || key > len(heavenlyStems) {
+		return ""
+	}
+	return heavenlyStems[key-1]
+}
+
+func branchAt(key int) string {
+	if key <= 0 || key > len(earthlyBranches) {
+		return ""
+	}
+	return earthlyBranches[key-1]
+}
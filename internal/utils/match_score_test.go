@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomChart(rng *rand.Rand) map[string]string {
+	chart := make(map[string]string)
+	for _, pillar := range []string{"year", "month", "day", "hour"} {
+		chart[pillar+"_stem"] = heavenlyStems[rng.Intn(len(heavenlyStems))]
+		chart[pillar+"_branch"] = earthlyBranches[rng.Intn(len(earthlyBranches))]
+	}
+	return chart
+}
+
+// DB가 하는 일(특징을 키로 바꿔 조회하고 더한 뒤 자르기)을 그대로 흉내 낸다
+func lookupScore(lookups []ScoreLookup, chart map[string]string, gender string) float64 {
+	features := EncodeChartFeatures(chart)
+	counts := features.Elements[:]
+	countAt := func(element int) int {
+		if element < 0 {
+			return 0
+		}
+		return counts[element]
+	}
+
+	value := func(feature string) int {
+		switch feature {
+		case PartnerGender:
+			for i, g := range partnerGenders {
+				if g == gender {
+					return i
+				}
+			}
+			return 0
+		case PartnerWealthCount:
+			return countAt(StemWealthElementIndexes()[features.DayStem+1])
+		case PartnerOfficerCount:
+			return countAt(StemOfficerElementIndexes()[features.DayStem+1])
+		}
+		switch feature {
+		case PartnerYearStem:
+			return features.YearStem + 1
+		case PartnerYearBranch:
+			return features.YearBranch + 1
+		case PartnerMonthStem:
+			return features.MonthStem + 1
+		case PartnerMonthBranch:
+			return features.MonthBranch + 1
+		case PartnerDayStem:
+			return features.DayStem + 1
+		case PartnerDayBranch:
+			return features.DayBranch + 1
+		case PartnerHourStem:
+			return features.HourStem + 1
+		}
+		return features.HourBranch + 1
+	}
+
+	total := 0.0
+	for _, lookup := range lookups {
+		index := 0
+		for _, feature := range lookup.Features {
+			index = index*PartnerFeatureSize(feature) + value(feature)
+		}
+		total += lookup.Values[index]
+	}
+	return math.Min(100, math.Max(0, total))
+}
+
+func TestRelationScoreLookupsMatchCalculatedScore(t *testing.T) {
+	tests := []struct {
+		relationType string
+		gender1      string
+		gender2      string
+	}{
+		{RelationRomantic, "M", "F"},
+		{RelationRomantic, "F", "M"},
+		{RelationRomantic, "", "F"},
+		{RelationFriend, "M", "M"},
+		{RelationBusiness, "F", "M"},
+		{RelationFamily, "M", "F"},
+		{"unknown", "M", "F"},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		t.Run(tt.relationType+"/"+tt.gender1+tt.gender2, func(t *testing.T) {
+			for i := 0; i < 200; i++ {
+				mine, theirs := randomChart(rng), randomChart(rng)
+				want := CalculateRelationCompatibilityScore(mine, theirs, tt.relationType, tt.gender1, tt.gender2).Score
+				got := lookupScore(RelationScoreLookups(mine, tt.relationType, tt.gender1), theirs, tt.gender2)
+				if math.Abs(got-want) > 1e-9 {
+					t.Fatalf("score mismatch for %v vs %v: lookups %v, calculated %v", mine, theirs, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPartnerFeatureSize(t *testing.T) {
+	tests := []struct {
+		feature string
+		want    int
+	}{
+		{PartnerDayStem, 11},
+		{PartnerHourBranch, 13},
+		{PartnerGender, 3},
+		{PartnerWealthCount, 9},
+	}
+	for _, tt := range tests {
+		if got := PartnerFeatureSize(tt.feature); got != tt.want {
+			t.Errorf("PartnerFeatureSize(%q) = %d, want %d", tt.feature, got, tt.want)
+		}
+	}
+}
+
+func TestRelationScoreKey(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	mine, other := randomChart(rng), randomChart(rng)
+	key := RelationScoreKey(mine, RelationRomantic, "M")
+
+	// 같은 표를 만드는 입력은 같은 키여야 저장된 표를 다시 쓴다
+	if got := RelationScoreKey(mine, "unknown", "M"); got != key {
+		t.Errorf("unknown relation falls back to romantic lookups but got key %q, want %q", got, key)
+	}
+	for name, got := range map[string]string{
+		"chart":    RelationScoreKey(other, RelationRomantic, "M"),
+		"relation": RelationScoreKey(mine, RelationFriend, "M"),
+		"gender":   RelationScoreKey(mine, RelationRomantic, "F"),
+	} {
+		if got == key {
+			t.Errorf("different %s produced the same key %q", name, key)
+		}
+	}
+	if len(key) > 64 {
+		t.Errorf("key %q does not fit the 64-character column", key)
+	}
+}
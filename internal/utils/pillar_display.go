@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"strings"
+
+	"dothefortune_server/internal/i18n"
+)
+
+// 원국 표시 형식 (?format=). compact는 읽기와 오행만, verbose는 로마자, 음양, 띠, 오행 색까지 붙인다
+const (
+	ChartFormatCompact = "compact"
+	ChartFormatVerbose = "verbose"
+)
+
+func IsValidChartFormat(format string) bool {
+	return format == ChartFormatCompact || format == ChartFormatVerbose
+}
+
+var stemReadings = map[string]string{
+	"甲": "갑", "乙": "을", "丙": "병", "丁": "정", "戊": "무",
+	"己": "기", "庚": "경", "辛": "신", "壬": "임", "癸": "계",
+}
+
+var branchReadings = map[string]string{
+	"子": "자", "丑": "축", "寅": "인", "卯": "묘", "辰": "진", "巳": "사",
+	"午": "오", "未": "미", "申": "신", "酉": "유", "戌": "술", "亥": "해",
+}
+
+// 국어의 로마자 표기법 기준
+var stemRomanizations = map[string]string{
+	"甲": "gap", "乙": "eul", "丙": "byeong", "丁": "jeong", "戊": "mu",
+	"己": "gi", "庚": "gyeong", "辛": "sin", "壬": "im", "癸": "gye",
+}
+
+var branchRomanizations = map[string]string{
+	"子": "ja", "丑": "chuk", "寅": "in", "卯": "myo", "辰": "jin", "巳": "sa",
+	"午": "o", "未": "mi", "申": "sin", "酉": "yu", "戌": "sul", "亥": "hae",
+}
+
+// 지지 순서대로의 띠 (메시지 ID animal.{코드})
+var branchAnimals = []string{"rat", "ox", "tiger", "rabbit", "dragon", "snake", "horse", "goat", "monkey", "rooster", "dog", "pig"}
+
+// 천간/지지 한 글자의 읽기와 속성. compact면 hanja, hangul, element만 채운다
+type PillarCharacter struct {
+	Hanja        string `json:"hanja" example:"庚"`
+	Hangul       string `json:"hangul" example:"경" description:"한글 읽기"`
+	Element      string `json:"element" example:"金" description:"오행"`
+	Romanization string `json:"romanization,omitempty" example:"gyeong"`
+	ElementName  string `json:"element_name,omitempty" example:"금" description:"오행 이름 (요청 언어)"`
+	Polarity     string `json:"polarity,omitempty" example:"yang" description:"음양 (yang, yin)"`
+	PolarityName string `json:"polarity_name,omitempty" example:"양" description:"음양 이름 (요청 언어)"`
+	Animal       string `json:"animal,omitempty" example:"rat" description:"띠 (지지만)"`
+	AnimalName   string `json:"animal_name,omitempty" example:"쥐" description:"띠 이름 (요청 언어, 지지만)"`
+	Color        string `json:"color,omitempty" example:"#FFFFFF" description:"오행 색 HEX"`
+}
+
+// 한 기둥 (천간 + 지지)
+type PillarDisplay struct {
+	Hanja        string          `json:"hanja" example:"庚子"`
+	Hangul       string          `json:"hangul" example:"경자"`
+	Romanization string          `json:"romanization" example:"gyeong-ja"`
+	Stem         PillarCharacter `json:"stem"`
+	Branch       PillarCharacter `json:"branch"`
+}
+
+// 원국 네 기둥. 시주를 모르면 hour는 비어 있다
+type ChartDisplay struct {
+	Year  *PillarDisplay `json:"year"`
+	Month *PillarDisplay `json:"month"`
+	Day   *PillarDisplay `json:"day"`
+	Hour  *PillarDisplay `json:"hour,omitempty"`
+}
+
+// fortune은 year_stem, year_branch ... hour_branch 키를 가진 사주 맵
+func DescribeChart(fortune map[string]string, format, locale string) *ChartDisplay {
+	return &ChartDisplay{
+		Year:  DescribePillar(fortune["year_stem"], fortune["year_branch"], format, locale),
+		Month: DescribePillar(fortune["month_stem"], fortune["month_branch"], format, locale),
+		Day:   DescribePillar(fortune["day_stem"], fortune["day_branch"], format, locale),
+		Hour:  DescribePillar(fortune["hour_stem"], fortune["hour_branch"], format, locale),
+	}
+}
+
+// 천간이나 지지가 비어 있으면 nil
+func DescribePillar(stem, branch, format, locale string) *PillarDisplay {
+	if stem == "" || branch == "" {
+		return nil
+	}
+	stemInfo := describeStem(stem, format, locale)
+	branchInfo := describeBranch(branch, format, locale)
+	return &PillarDisplay{
+		Hanja:        stem + branch,
+		Hangul:       stemReadings[stem] + branchReadings[branch],
+		Romanization: strings.Trim(stemRomanizations[stem]+"-"+branchRomanizations[branch], "-"),
+		Stem:         stemInfo,
+		Branch:       branchInfo,
+	}
+}
+
+func describeStem(stem, format, locale string) PillarCharacter {
+	character := PillarCharacter{
+		Hanja:   stem,
+		Hangul:  stemReadings[stem],
+		Element: stemToElement[stem],
+	}
+	if format == ChartFormatVerbose {
+		character.Romanization = stemRomanizations[stem]
+		describeVerbose(&character, characterIndex(heavenlyStems, stem), locale)
+	}
+	return character
+}
+
+func describeBranch(branch, format, locale string) PillarCharacter {
+	character := PillarCharacter{
+		Hanja:   branch,
+		Hangul:  branchReadings[branch],
+		Element: branchToElement[branch],
+	}
+	if format == ChartFormatVerbose {
+		character.Romanization = branchRomanizations[branch]
+		index := characterIndex(earthlyBranches, branch)
+		describeVerbose(&character, index, locale)
+		if index >= 0 {
+			character.Animal = branchAnimals[index]
+			character.AnimalName = i18n.T(locale, "animal."+character.Animal)
+		}
+	}
+	return character
+}
+
+// indexOf와 달리 모르는 글자면 -1
+func characterIndex(characters []string, character string) int {
+	for i, c := range characters {
+		if c == character {
+			return i
+		}
+	}
+	return -1
+}
+
+// 천간과 지지 모두 짝수 번째(甲, 子부터)가 양이다
+func describeVerbose(character *PillarCharacter, index int, locale string) {
+	if character.Element != "" {
+		character.ElementName = i18n.T(locale, "element."+character.Element)
+		character.Color = luckyColorHex[character.Element]
+	}
+	if index < 0 {
+		return
+	}
+	character.Polarity = "yang"
+	if index%2 == 1 {
+		character.Polarity = "yin"
+	}
+	character.PolarityName = i18n.T(locale, "polarity."+character.Polarity)
+}
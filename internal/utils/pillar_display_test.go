@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestEveryCharacterHasAReading(t *testing.T) {
+	for i, stem := range heavenlyStems {
+		verbose := describeStem(stem, ChartFormatVerbose, "ko")
+		if verbose.Hangul == "" || verbose.Romanization == "" || verbose.Element == "" || verbose.Color == "" {
+			t.Errorf("%s = %+v", stem, verbose)
+		}
+		if want := []string{"yang", "yin"}[i%2]; verbose.Polarity != want {
+			t.Errorf("%s polarity %s, want %s", stem, verbose.Polarity, want)
+		}
+	}
+	for i, branch := range earthlyBranches {
+		verbose := describeBranch(branch, ChartFormatVerbose, "ko")
+		if verbose.Hangul == "" || verbose.Romanization == "" || verbose.Element == "" || verbose.Animal != branchAnimals[i] || verbose.AnimalName == "" {
+			t.Errorf("%s = %+v", branch, verbose)
+		}
+	}
+}
+
+func TestDescribePillar(t *testing.T) {
+	compact := DescribePillar("庚", "子", ChartFormatCompact, "ko")
+	if compact.Hanja != "庚子" || compact.Hangul != "경자" || compact.Romanization != "gyeong-ja" {
+		t.Errorf("compact = %+v", compact)
+	}
+	// compact는 읽기와 오행만 채운다
+	if want := (PillarCharacter{Hanja: "庚", Hangul: "경", Element: "金"}); compact.Stem != want {
+		t.Errorf("compact stem = %+v", compact.Stem)
+	}
+
+	verbose := DescribePillar("乙", "丑", ChartFormatVerbose, "en")
+	want := PillarCharacter{
+		Hanja: "丑", Hangul: "축", Element: "土", Romanization: "chuk", ElementName: "Earth",
+		Polarity: "yin", PolarityName: "Yin", Animal: "ox", AnimalName: "Ox", Color: "#FFC107",
+	}
+	if verbose.Hangul != "을축" || verbose.Branch != want {
+		t.Errorf("verbose = %+v", verbose)
+	}
+	if verbose.Stem.Animal != "" || verbose.Stem.ElementName != "Wood" {
+		t.Errorf("verbose stem = %+v", verbose.Stem)
+	}
+
+	if DescribePillar("", "子", ChartFormatVerbose, "ko") != nil {
+		t.Error("pillar without a stem should be nil")
+	}
+}
+
+func TestDescribeChartWithoutHour(t *testing.T) {
+	chart := DescribeChart(map[string]string{
+		"year_stem": "庚", "year_branch": "午", "month_stem": "辛", "month_branch": "巳",
+		"day_stem": "甲", "day_branch": "子",
+	}, ChartFormatCompact, "ko")
+	if chart.Year.Hangul != "경오" || chart.Month.Hangul != "신사" || chart.Day.Hangul != "갑자" || chart.Hour != nil {
+		t.Errorf("chart = %+v", chart)
+	}
+}
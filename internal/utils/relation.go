@@ -0,0 +1,372 @@
+package utils
+
+import "math"
+
+// 궁합 관계 유형
+const (
+	RelationRomantic = "romantic"
+	RelationFriend   = "friend"
+	RelationBusiness = "business"
+	RelationFamily   = "family"
+)
+
+var RelationTypes = []string{RelationRomantic, RelationFriend, RelationBusiness, RelationFamily}
+
+// 관계 유형별 기둥 가중치
+type RelationWeights struct {
+	Day   float64
+	Month float64
+	Year  float64
+	Hour  float64
+}
+
+// 연인은 배우자궁(일주), 사업은 사회궁(월주), 가족은 조상궁(연주)에 무게를 둔다
+var relationWeights = map[string]RelationWeights{
+	RelationRomantic: {Day: 0.5, Month: 0.2, Year: 0.2, Hour: 0.1},
+	RelationFriend:   {Day: 0.35, Month: 0.35, Year: 0.2, Hour: 0.1},
+	RelationBusiness: {Day: 0.3, Month: 0.45, Year: 0.15, Hour: 0.1},
+	RelationFamily:   {Day: 0.3, Month: 0.2, Year: 0.4, Hour: 0.1},
+}
+
+// 궁합 카테고리 코드 (표시 이름은 i18n 카탈로그의 category.{코드})
+const (
+	CategoryTalk          = "talk"          // 대화
+	CategoryCommunication = "communication" // 소통
+	CategoryEmotion       = "emotion"       // 감정
+	CategoryAffection     = "affection"     // 정서
+	CategoryWealth        = "wealth"        // 재물
+	CategoryHealth        = "health"        // 건강
+	CategoryTaste         = "taste"         // 취향
+	CategoryTrust         = "trust"         // 신뢰
+	CategoryConflict      = "conflict"      // 갈등
+	CategoryRole          = "role"          // 역할
+	CategorySupport       = "support"       // 지지
+)
+
+// 관계 유형별 4대 카테고리
+var relationCategoryNames = map[string][]string{
+	RelationRomantic: {CategoryTalk, CategoryEmotion, CategoryWealth, CategoryHealth},
+	RelationFriend:   {CategoryTalk, CategoryTaste, CategoryTrust, CategoryConflict},
+	RelationBusiness: {CategoryCommunication, CategoryWealth, CategoryRole, CategoryTrust},
+	RelationFamily:   {CategoryTalk, CategoryAffection, CategorySupport, CategoryConflict},
+}
+
+// 오행 상극: 木→土→水→火→金→木 (내가 극하는 오행 = 재성, 나를 극하는 오행 = 관성)
+var elementControlling = map[string]string{
+	"木": "土", "土": "水", "水": "火", "火": "金", "金": "木",
+}
+
+func IsValidRelationType(relationType string) bool {
+	_, ok := relationWeights[relationType]
+	return ok
+}
+
+func GetRelationCategoryNames(relationType string) []string {
+	return relationCategoryNames[relationType]
+}
+
+// 재성: 일간이 극하는 오행
+func GetWealthElement(dayElement string) string {
+	return elementControlling[dayElement]
+}
+
+// 관성: 일간을 극하는 오행
+func GetOfficerElement(dayElement string) string {
+	for element, controlled := range elementControlling {
+		if controlled == dayElement {
+			return element
+		}
+	}
+	return ""
+}
+
+// 관계 유형에 따라 기둥 가중치, 유형별 가산점, 카테고리를 달리 적용한 궁합 점수. 규칙은 i18n 메시지 ID로 돌려준다
+func CalculateRelationCompatibilityScore(fortune1, fortune2 map[string]string, relationType, gender1, gender2 string) CompatibilityDetail {
+	weights, ok := relationWeights[relationType]
+	if !ok {
+		relationType = RelationRomantic
+		weights = relationWeights[RelationRomantic]
+	}
+
+	dayScore := calculatePillarCompatibility(
+		fortune1["day_stem"], fortune1["day_branch"],
+		fortune2["day_stem"], fortune2["day_branch"],
+	)
+	monthScore := calculatePillarCompatibility(
+		fortune1["month_stem"], fortune1["month_branch"],
+		fortune2["month_stem"], fortune2["month_branch"],
+	)
+	yearScore := calculatePillarCompatibility(
+		fortune1["year_stem"], fortune1["year_branch"],
+		fortune2["year_stem"], fortune2["year_branch"],
+	)
+	hourScore := calculatePillarCompatibility(
+		fortune1["hour_stem"], fortune1["hour_branch"],
+		fortune2["hour_stem"], fortune2["hour_branch"],
+	)
+
+	score := dayScore*weights.Day + monthScore*weights.Month + yearScore*weights.Year + hourScore*weights.Hour
+
+	var bonus float64
+	var rules []string
+	switch relationType {
+	case RelationRomantic:
+		bonus, rules = romanticBonus(fortune1, fortune2, gender1, gender2)
+	case RelationFriend:
+		bonus, rules = friendBonus(fortune1, fortune2)
+	case RelationBusiness:
+		bonus, rules = businessBonus(fortune1, fortune2)
+	case RelationFamily:
+		bonus, rules = familyBonus(fortune1, fortune2)
+	}
+	score += bonus
+
+	elem1 := GetFiveElements(fortune1)
+
+	return CompatibilityDetail{
+		Score:                      math.Min(100, math.Max(0, score)),
+		ElementDistribution:        elem1,
+		PartnerElementDistribution: GetFiveElements(fortune2),
+		Categories:                 CalculateRelationCategories(relationType, fortune1, fortune2, elem1),
+		Rules:                      rules,
+	}
+}
+
+// 연인: 배우자궁(일지)의 합충과 성별에 따른 재성(남)/관성(여) 배우자성
+func romanticBonus(fortune1, fortune2 map[string]string, gender1, gender2 string) (float64, []string) {
+	bonus := 0.0
+	var rules []string
+
+	if IsEarthlyBranchSixPair(fortune1["day_branch"], fortune2["day_branch"]) {
+		bonus += 10
+		rules = append(rules, "rule.spouse_palace_six_pair")
+	}
+	if IsEarthlyBranchClash(fortune1["day_branch"], fortune2["day_branch"]) {
+		bonus -= 10
+		rules = append(rules, "rule.spouse_palace_clash")
+	}
+
+	if hasSpouseStar(fortune1["day_stem"], fortune2["day_stem"], gender1) {
+		bonus += 5
+		rules = append(rules, "rule.partner_is_spouse_star")
+	}
+	if hasSpouseStar(fortune2["day_stem"], fortune1["day_stem"], gender2) {
+		bonus += 5
+		rules = append(rules, "rule.self_is_spouse_star")
+	}
+
+	return bonus, rules
+}
+
+// 상대 일간이 내 배우자성(남: 재성, 여: 관성)인지
+func hasSpouseStar(myStem, partnerStem, gender string) bool {
+	myElement := GetElement(myStem)
+	partnerElement := GetElement(partnerStem)
+	if myElement == "" || partnerElement == "" {
+		return false
+	}
+
+	switch gender {
+	case "M":
+		return GetWealthElement(myElement) == partnerElement
+	case "F":
+		return GetOfficerElement(myElement) == partnerElement
+	}
+	return false
+}
+
+// 친구: 비견(같은 오행 일간)과 연지의 합
+func friendBonus(fortune1, fortune2 map[string]string) (float64, []string) {
+	bonus := 0.0
+	var rules []string
+
+	if GetElement(fortune1["day_stem"]) == GetElement(fortune2["day_stem"]) {
+		bonus += 10
+		rules = append(rules, "rule.same_day_element_friend")
+	}
+	if IsEarthlyBranchSixPair(fortune1["year_branch"], fortune2["year_branch"]) ||
+		IsEarthlyBranchThreePair(fortune1["year_branch"], fortune2["year_branch"]) {
+		bonus += 5
+		rules = append(rules, "rule.year_branch_pair")
+	}
+
+	return bonus, rules
+}
+
+// 사업: 재성과 관성의 상호작용 (한쪽이 재물을 만들고 다른 쪽이 관리하는 구조)
+func businessBonus(fortune1, fortune2 map[string]string) (float64, []string) {
+	element1 := GetElement(fortune1["day_stem"])
+	element2 := GetElement(fortune2["day_stem"])
+	elem1 := GetFiveElements(fortune1)
+	elem2 := GetFiveElements(fortune2)
+	return businessRoleBonus(element1, element2,
+		elem1[GetWealthElement(element1)], elem1[GetOfficerElement(element1)],
+		elem2[GetWealthElement(element2)], elem2[GetOfficerElement(element2)])
+}
+
+// 두 일간의 오행과 각자의 재성/관성 개수로 사업 가산점을 매긴다 (매칭 정렬용 조회표도 이 함수로 만든다)
+func businessRoleBonus(element1, element2 string, wealth1, officer1, wealth2, officer2 int) (float64, []string) {
+	bonus := 0.0
+	var rules []string
+
+	if GetWealthElement(element1) == element2 || GetWealthElement(element2) == element1 {
+		bonus += 8
+		rules = append(rules, "rule.day_stem_wealth_star")
+	}
+
+	// 재성이 강한 쪽과 관성이 강한 쪽이 만나면 역할 분담이 잘 된다
+	if (wealth1 >= 2 && officer2 >= 2) || (wealth2 >= 2 && officer1 >= 2) {
+		bonus += 7
+		rules = append(rules, "rule.wealth_officer_roles")
+	}
+	// 둘 다 재성이 없으면 수익 구조가 약하다
+	if wealth1 == 0 && wealth2 == 0 {
+		bonus -= 10
+		rules = append(rules, "rule.no_wealth_star")
+	}
+
+	return bonus, rules
+}
+
+// 가족: 인성(서로 생해주는 일간)과 연주의 합충
+func familyBonus(fortune1, fortune2 map[string]string) (float64, []string) {
+	bonus := 0.0
+	var rules []string
+
+	element1 := GetElement(fortune1["day_stem"])
+	element2 := GetElement(fortune2["day_stem"])
+	if isElementGenerating(element1, element2) || isElementGenerating(element2, element1) {
+		bonus += 10
+		rules = append(rules, "rule.day_stem_generating")
+	}
+
+	if IsEarthlyBranchSixPair(fortune1["year_branch"], fortune2["year_branch"]) ||
+		IsEarthlyBranchThreePair(fortune1["year_branch"], fortune2["year_branch"]) {
+		bonus += 5
+		rules = append(rules, "rule.year_branch_pair")
+	}
+	if IsEarthlyBranchClash(fortune1["year_branch"], fortune2["year_branch"]) {
+		bonus -= 10
+		rules = append(rules, "rule.year_branch_clash")
+	}
+
+	return bonus, rules
+}
+
+// 관계 유형별 카테고리 점수
+func CalculateRelationCategories(relationType string, fortune1, fortune2 map[string]string, elem1 map[string]int) map[string]CategoryScore {
+	switch relationType {
+	case RelationFriend:
+		return calculateFriendCategories(fortune1, fortune2)
+	case RelationBusiness:
+		return calculateBusinessCategories(fortune1, fortune2)
+	case RelationFamily:
+		return calculateFamilyCategories(fortune1, fortune2)
+	default:
+		return CalculateCategories(fortune1, fortune2, elem1)
+	}
+}
+
+func calculateFriendCategories(fortune1, fortune2 map[string]string) map[string]CategoryScore {
+	talkScore := 50.0
+	if IsHeavenlyStemPair(fortune1["day_stem"], fortune2["day_stem"]) {
+		talkScore += 20
+	}
+	if GetElement(fortune1["day_stem"]) == GetElement(fortune2["day_stem"]) {
+		talkScore += 15
+	}
+
+	tasteScore := 50.0
+	if IsEarthlyBranchThreePair(fortune1["month_branch"], fortune2["month_branch"]) {
+		tasteScore += 20
+	}
+
+	trustScore := 50.0
+	if IsEarthlyBranchSixPair(fortune1["day_branch"], fortune2["day_branch"]) {
+		trustScore += 20
+	}
+
+	// 갈등 점수는 높을수록 갈등이 적다
+	conflictScore := 70.0
+	if IsEarthlyBranchClash(fortune1["day_branch"], fortune2["day_branch"]) {
+		conflictScore -= 25
+	}
+	if IsEarthlyBranchResentment(fortune1["day_branch"], fortune2["day_branch"]) {
+		conflictScore -= 15
+	}
+
+	return buildCategories(RelationFriend, talkScore, tasteScore, trustScore, conflictScore)
+}
+
+func calculateBusinessCategories(fortune1, fortune2 map[string]string) map[string]CategoryScore {
+	element1 := GetElement(fortune1["day_stem"])
+	element2 := GetElement(fortune2["day_stem"])
+	elem1 := GetFiveElements(fortune1)
+	elem2 := GetFiveElements(fortune2)
+
+	commScore := 50.0
+	if IsHeavenlyStemPair(fortune1["month_stem"], fortune2["month_stem"]) {
+		commScore += 20
+	}
+
+	wealthScore := 40.0 + float64(elem1[GetWealthElement(element1)]+elem2[GetWealthElement(element2)])*8
+
+	roleScore := 50.0
+	if GetWealthElement(element1) == element2 || GetWealthElement(element2) == element1 {
+		roleScore += 20
+	}
+	if element1 == element2 {
+		roleScore -= 10
+	}
+
+	trustScore := 50.0
+	if IsEarthlyBranchSixPair(fortune1["month_branch"], fortune2["month_branch"]) {
+		trustScore += 20
+	}
+	if IsEarthlyBranchClash(fortune1["month_branch"], fortune2["month_branch"]) {
+		trustScore -= 15
+	}
+
+	return buildCategories(RelationBusiness, commScore, wealthScore, roleScore, trustScore)
+}
+
+func calculateFamilyCategories(fortune1, fortune2 map[string]string) map[string]CategoryScore {
+	element1 := GetElement(fortune1["day_stem"])
+	element2 := GetElement(fortune2["day_stem"])
+
+	talkScore := 50.0
+	if IsHeavenlyStemPair(fortune1["day_stem"], fortune2["day_stem"]) {
+		talkScore += 20
+	}
+
+	emotionScore := 50.0
+	if IsEarthlyBranchSixPair(fortune1["year_branch"], fortune2["year_branch"]) {
+		emotionScore += 20
+	}
+
+	supportScore := 50.0
+	if isElementGenerating(element1, element2) || isElementGenerating(element2, element1) {
+		supportScore += 25
+	}
+
+	conflictScore := 70.0
+	if IsEarthlyBranchClash(fortune1["year_branch"], fortune2["year_branch"]) {
+		conflictScore -= 20
+	}
+	if IsEarthlyBranchClash(fortune1["day_branch"], fortune2["day_branch"]) {
+		conflictScore -= 15
+	}
+
+	return buildCategories(RelationFamily, talkScore, emotionScore, supportScore, conflictScore)
+}
+
+func buildCategories(relationType string, scores ...float64) map[string]CategoryScore {
+	categories := make(map[string]CategoryScore)
+	for i, name := range relationCategoryNames[relationType] {
+		if i >= len(scores) {
+			break
+		}
+		categories[name] = CategoryScore{Name: name, Score: math.Min(100, math.Max(0, scores[i]))}
+	}
+	return categories
+}
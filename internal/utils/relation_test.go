@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestRelationWeightsSumToOne(t *testing.T) {
+	for _, relationType := range RelationTypes {
+		w := relationWeights[relationType]
+		if sum := w.Day + w.Month + w.Year + w.Hour; math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%s weights sum to %v", relationType, sum)
+		}
+	}
+}
+
+func TestRelationCategoriesFollowType(t *testing.T) {
+	mine := map[string]string{"year_stem": "庚", "year_branch": "午", "month_stem": "辛", "month_branch": "巳", "day_stem": "甲", "day_branch": "子", "hour_stem": "丙", "hour_branch": "寅"}
+	theirs := map[string]string{"year_stem": "壬", "year_branch": "申", "month_stem": "癸", "month_branch": "丑", "day_stem": "戊", "day_branch": "午", "hour_stem": "丁", "hour_branch": "巳"}
+
+	for _, relationType := range RelationTypes {
+		t.Run(relationType, func(t *testing.T) {
+			detail := CalculateRelationCompatibilityScore(mine, theirs, relationType, "M", "F")
+			var codes []string
+			for code, category := range detail.Categories {
+				codes = append(codes, code)
+				if category.Score < 0 || category.Score > 100 {
+					t.Errorf("%s score %v out of range", code, category.Score)
+				}
+			}
+			want := slices.Clone(GetRelationCategoryNames(relationType))
+			sort.Strings(codes)
+			sort.Strings(want)
+			if !slices.Equal(codes, want) {
+				t.Errorf("categories %v, want %v", codes, want)
+			}
+		})
+	}
+
+	// 알 수 없는 유형은 연인 궁합으로 계산한다
+	unknown := CalculateRelationCompatibilityScore(mine, theirs, "coworker", "M", "F")
+	romantic := CalculateRelationCompatibilityScore(mine, theirs, RelationRomantic, "M", "F")
+	if unknown.Score != romantic.Score || len(unknown.Categories) != len(romantic.Categories) {
+		t.Errorf("unknown relation = %v, want the romantic score %v", unknown.Score, romantic.Score)
+	}
+}
+
+func TestRomanticBonusSpouseStar(t *testing.T) {
+	// 甲(木) 일간 남성에게 戊(土)는 재성, 戊 일간 여성에게 甲은 관성
+	mine := map[string]string{"day_stem": "甲", "day_branch": "寅"}
+	theirs := map[string]string{"day_stem": "戊", "day_branch": "辰"}
+
+	tests := []struct {
+		gender1, gender2 string
+		wantBonus        float64
+		wantRules        []string
+	}{
+		{"M", "F", 10, []string{"rule.partner_is_spouse_star", "rule.self_is_spouse_star"}},
+		{"F", "M", 0, nil},
+		{"M", "", 5, []string{"rule.partner_is_spouse_star"}},
+	}
+	for _, tt := range tests {
+		bonus, rules := romanticBonus(mine, theirs, tt.gender1, tt.gender2)
+		if bonus != tt.wantBonus || !slices.Equal(rules, tt.wantRules) {
+			t.Errorf("%s/%s: got (%v, %v), want (%v, %v)", tt.gender1, tt.gender2, bonus, rules, tt.wantBonus, tt.wantRules)
+		}
+	}
+
+	// 배우자궁 子午충은 감점
+	clash, rules := romanticBonus(map[string]string{"day_branch": "子"}, map[string]string{"day_branch": "午"}, "", "")
+	if clash != -10 || !slices.Equal(rules, []string{"rule.spouse_palace_clash"}) {
+		t.Errorf("spouse palace clash: (%v, %v)", clash, rules)
+	}
+}
+
+func TestBusinessRoleBonus(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		element1, element2                   string
+		wealth1, officer1, wealth2, officer2 int
+		want                                 float64
+		wantRules                            []string
+	}{
+		{"wealth star day stems", "木", "土", 1, 0, 1, 0, 8, []string{"rule.day_stem_wealth_star"}},
+		{"wealth meets officer", "木", "木", 2, 0, 0, 2, 7, []string{"rule.wealth_officer_roles"}},
+		{"nobody has wealth", "木", "木", 0, 3, 0, 3, -10, []string{"rule.no_wealth_star"}},
+		{"neutral", "木", "火", 1, 1, 1, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rules := businessRoleBonus(tt.element1, tt.element2, tt.wealth1, tt.officer1, tt.wealth2, tt.officer2)
+			if got != tt.want || !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("got (%v, %v), want (%v, %v)", got, rules, tt.want, tt.wantRules)
+			}
+		})
+	}
+}